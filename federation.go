@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/collector"
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+	"github.com/cosanet/cosanet/internal/federation"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runFederation serves the merged metrics of the cosanet pods of the service
+// on /metrics, until the process exits. It doesn't collect any netns, the
+// collector flags being ignored but the naming ones.
+func runFederation(opts *CliOpts) {
+	if opts.ListenAddr == "" {
+		slog.Error("The federation mode needs -listen")
+		os.Exit(1)
+	}
+	namespace, service, found := strings.Cut(opts.Federation.Service, "/")
+	if !found {
+		namespace, service = cmp.Or(os.Getenv("POD_NAMESPACE"), "default"), opts.Federation.Service
+	}
+	lister, err := controller_resolver.NewEndpointsLister(namespace, service, opts.Federation.Port)
+	if err != nil {
+		slog.Error("Failed to create the endpoints lister", slog.Any("err", err))
+		os.Exit(1)
+	}
+	opts.Federation.MetricPrefix = opts.CollectorOptions.MetricPrefix
+	// The labels are named from the naming flags the DaemonSet runs with
+	opts.Federation.LabelName = collector.LabelNamer(opts.CollectorOptions)
+	federator := federation.MustNewFederator(opts.Federation, lister)
+
+	extraLabels, err := parseExtraLabels(opts.ExtraLabels)
+	if err != nil {
+		slog.Error("Invalid extra labels", slog.Any("err", err))
+		os.Exit(1)
+	}
+	prometheus.WrapRegistererWith(extraLabels, prometheus.DefaultRegisterer).MustRegister(federator)
+
+	compressions, err := parseCompressions(opts.MetricsCompression)
+	if err != nil {
+		slog.Error("Invalid metrics compression", slog.Any("err", err))
+		os.Exit(1)
+	}
+	opts.MetricsHandler.OfferedCompressions = compressions
+	opts.MetricsHandler.DisableCompression = len(compressions) == 0
+	// A series two pods both expose mustn't fail the whole scrape
+	opts.MetricsHandler.ErrorHandling = promhttp.ContinueOnError
+	// The federator first, for the state of its scrapes to be the current one
+	gatherers := prometheus.Gatherers{federator, prometheus.DefaultGatherer}
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(gatherers, opts.MetricsHandler),
+	))
+	http.HandleFunc("/", indexHandler)
+
+	slog.Info(
+		"Federation running",
+		slog.String("address", opts.ListenAddr+"/metrics"),
+		slog.String("service", namespace+"/"+service),
+		slog.String("aggregation", opts.Federation.Aggregation),
+	)
+	if err := http.ListenAndServe(opts.ListenAddr, nil); err != nil {
+		slog.Error("Federation failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,151 @@
+// Package bpf_counters holds the building blocks of the eBPF programs counting
+// kernel events per network namespace: a per-CPU counters map, the
+// instructions to update it and the offsets of the kernel structs from BTF.
+// The programs are assembled at runtime, without any compiled object.
+package bpf_counters
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+// Size of the counters maps, the events of the netns and kinds beyond it
+// aren't counted
+const maxCounters = 16384
+
+// Key of the counters map, what Kind counts depending on the program (eg: a
+// drop reason)
+type Key struct {
+	Netns uint32
+	Kind  uint32
+}
+
+// Map counts the events per netns inode and kind.
+type Map struct {
+	m *ebpf.Map
+}
+
+// NewMap creates the counters map, to be closed.
+func NewMap(name string) (*Map, error) {
+	// The memlock limit accounts the eBPF memory before the kernel 5.11
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, err
+	}
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       name,
+		Type:       ebpf.PerCPUHash,
+		KeySize:    8,
+		ValueSize:  8,
+		MaxEntries: maxCounters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s map: %w", name, err)
+	}
+	return &Map{m: m}, nil
+}
+
+// Counts returns the events counted so far per netns inode and kind.
+func (m *Map) Counts() (map[uint32]map[uint32]uint64, error) {
+	counts := make(map[uint32]map[uint32]uint64)
+	var key Key
+	var perCPU []uint64
+	entries := m.m.Iterate()
+	for entries.Next(&key, &perCPU) {
+		var total uint64
+		for _, count := range perCPU {
+			total += count
+		}
+		kinds, ok := counts[key.Netns]
+		if !ok {
+			kinds = make(map[uint32]uint64)
+			counts[key.Netns] = kinds
+		}
+		kinds[key.Kind] = total
+	}
+	return counts, entries.Err()
+}
+
+// Forget deletes the counters of the netns, eg: once gone.
+func (m *Map) Forget(forget func(netns uint32) bool) error {
+	var keys []Key
+	var key Key
+	var perCPU []uint64
+	entries := m.m.Iterate()
+	for entries.Next(&key, &perCPU) {
+		if forget(key.Netns) {
+			keys = append(keys, key)
+		}
+	}
+	if err := entries.Err(); err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range keys {
+		if err := m.m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FD is the file descriptor of the map, for the programs to update it.
+func (m *Map) FD() int {
+	return m.m.FD()
+}
+
+func (m *Map) Close() error {
+	return m.m.Close()
+}
+
+// The programs keep the key at fp-8 (netns inode, kind), fp-16 being free for
+// their reads and fp-24 holding the initial value of a new counter
+const (
+	KeyNetns = -8
+	KeyKind  = -4
+	Scratch  = -16
+	initial  = -24
+)
+
+// ProbeRead reads size bytes at src+offset to the stack at dst, clobbering
+// R1 to R5.
+func ProbeRead(dst int32, size int32, src asm.Register, offset int32) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, dst),
+		asm.Mov.Imm(asm.R2, size),
+		asm.Mov.Reg(asm.R3, src),
+		asm.Add.Imm(asm.R3, offset),
+		asm.FnProbeReadKernel.Call(),
+	}
+}
+
+// Increment increments the counter of the key in the map of the fd, from the
+// "count" symbol, and returns from the "exit" symbol.
+func Increment(fd int) asm.Instructions {
+	return asm.Instructions{
+		asm.LoadMapPtr(asm.R1, fd).WithSymbol("count"),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, KeyNetns),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "insert"),
+		asm.LoadMem(asm.R1, asm.R0, 0, asm.DWord),
+		asm.Add.Imm(asm.R1, 1),
+		asm.StoreMem(asm.R0, 0, asm.R1, asm.DWord),
+		asm.Ja.Label("exit"),
+		asm.Mov.Imm(asm.R1, 1).WithSymbol("insert"),
+		asm.StoreMem(asm.RFP, initial, asm.R1, asm.DWord),
+		asm.LoadMapPtr(asm.R1, fd),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, KeyNetns),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, initial),
+		asm.Mov.Imm(asm.R4, int32(ebpf.UpdateAny)),
+		asm.FnMapUpdateElem.Call(),
+		asm.Mov.Imm(asm.R0, 0).WithSymbol("exit"),
+		asm.Return(),
+	}
+}
@@ -0,0 +1,103 @@
+package bpf_counters
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+// MemberOffset returns the offset in bytes of a member of the struct, through
+// the nested members of path (eg: "sock", "__sk_common", "skc_net", "net").
+func MemberOffset(spec *btf.Spec, typeName string, path ...string) (int32, error) {
+	var typ *btf.Struct
+	if err := spec.TypeByName(typeName, &typ); err != nil {
+		return 0, fmt.Errorf("struct %s: %w", typeName, err)
+	}
+	var offset btf.Bits
+	var current btf.Type = typ
+	for _, name := range path {
+		member, memberOffset, ok := findMember(current, name)
+		if !ok {
+			return 0, fmt.Errorf("struct %s has no member %s", typeName, strings.Join(path, "."))
+		}
+		offset += memberOffset
+		current = btf.UnderlyingType(member.Type)
+	}
+	return int32(offset / 8), nil
+}
+
+// MemberOffsets fills the offsets of the members, see MemberOffset.
+func MemberOffsets(spec *btf.Spec, members ...Member) error {
+	for _, member := range members {
+		offset, err := MemberOffset(spec, member.Type, member.Path...)
+		if err != nil {
+			return err
+		}
+		*member.Offset = offset
+	}
+	return nil
+}
+
+// Member is a struct member whose offset is looked up by MemberOffsets
+type Member struct {
+	Offset *int32
+	Type   string
+	Path   []string
+}
+
+// KprobeArgs returns the offsets in the pt_regs of a kprobe of the first n
+// arguments of the function, per the calling convention of the architecture.
+func KprobeArgs(spec *btf.Spec, n int) ([]int16, error) {
+	args := make([]int16, n)
+	for i := range args {
+		var offset int32
+		var err error
+		switch runtime.GOARCH {
+		case "amd64":
+			if i >= 6 {
+				return nil, fmt.Errorf("argument %d not passed in a register", i)
+			}
+			offset, err = MemberOffset(spec, "pt_regs", []string{"di", "si", "dx", "cx", "r8", "r9"}[i])
+		case "arm64":
+			if i >= 8 {
+				return nil, fmt.Errorf("argument %d not passed in a register", i)
+			}
+			offset, err = MemberOffset(spec, "pt_regs", "regs")
+			offset += int32(i) * 8
+		default:
+			return nil, fmt.Errorf("kprobe arguments unknown on %s", runtime.GOARCH)
+		}
+		if err != nil {
+			return nil, err
+		}
+		args[i] = int16(offset)
+	}
+	return args, nil
+}
+
+// findMember looks up a member by name, in the anonymous structs and unions
+// too (eg: sk_buff.dev)
+func findMember(typ btf.Type, name string) (btf.Member, btf.Bits, bool) {
+	var members []btf.Member
+	switch typ := typ.(type) {
+	case *btf.Struct:
+		members = typ.Members
+	case *btf.Union:
+		members = typ.Members
+	default:
+		return btf.Member{}, 0, false
+	}
+	for _, member := range members {
+		if member.Name == name {
+			return member, member.Offset, true
+		}
+		if member.Name == "" {
+			if nested, offset, ok := findMember(btf.UnderlyingType(member.Type), name); ok {
+				return nested, member.Offset + offset, true
+			}
+		}
+	}
+	return btf.Member{}, 0, false
+}
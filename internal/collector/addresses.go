@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Address scopes, see RT_SCOPE_* in include/uapi/linux/rtnetlink.h. The
+// global, link and host ones are always emitted, a lost address counting 0
+// rather than vanishing.
+var addrScopes = map[int]string{
+	unix.RT_SCOPE_UNIVERSE: "global",
+	unix.RT_SCOPE_SITE:     "site",
+	unix.RT_SCOPE_LINK:     "link",
+	unix.RT_SCOPE_HOST:     "host",
+	unix.RT_SCOPE_NOWHERE:  "nowhere",
+}
+
+var defaultAddrScopes = []string{"global", "link", "host"}
+
+func addrScopeName(scope int) string {
+	if name, found := addrScopes[scope]; found {
+		return name
+	}
+	return fmt.Sprintf("%d", scope)
+}
+
+// countAddrs counts the addresses per scope
+func countAddrs(addrs []netlink.Addr) map[string]int {
+	counts := make(map[string]int, len(defaultAddrScopes))
+	for _, scope := range defaultAddrScopes {
+		counts[scope] = 0
+	}
+	for _, addr := range addrs {
+		counts[addrScopeName(addr.Scope)]++
+	}
+	return counts
+}
+
+func (c *CosanetCollector) collectAndEmitAddresses(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+	var errs []error
+	links, err := dumpLinks()
+	if err == nil {
+		ch <- c.interfacesDesc.mustNewConstMetric(prometheus.GaugeValue, float64(len(links)), labelValues...)
+	} else {
+		errs = append(errs, fmt.Errorf("links: %w", err))
+	}
+	for _, family := range neighFamilies {
+		addrs, err := netlink.AddrList(nil, int(family.family))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family.name, err))
+			continue
+		}
+		for scope, count := range countAddrs(addrs) {
+			ch <- c.ipAddressesDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{family.name, scope}, labelValues...)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
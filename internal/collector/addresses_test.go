@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestCountAddrs(t *testing.T) {
+	counts := countAddrs([]netlink.Addr{
+		{Scope: unix.RT_SCOPE_LINK},
+		{Scope: unix.RT_SCOPE_HOST},
+		{Scope: unix.RT_SCOPE_LINK},
+		{Scope: unix.RT_SCOPE_SITE},
+		{Scope: 42},
+	})
+	// The lost global address counts 0
+	assert.Equal(t, map[string]int{"global": 0, "link": 2, "host": 1, "site": 1, "42": 1}, counts)
+}
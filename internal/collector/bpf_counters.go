@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// errTracerUnavailable is returned in every netns by the eBPF collectors
+// whose tracer failed to start, logged once at startup
+var errTracerUnavailable = errors.New("eBPF tracer unavailable")
+
+// bpfTracer counts kernel events per netns inode and kind with eBPF
+type bpfTracer interface {
+	Counts() (map[uint32]map[uint32]uint64, error)
+	Forget(forget func(netns uint32) bool) error
+}
+
+// bpfCounters attributes the events counted by an eBPF tracer to the netns
+// collected. Only used from the main collection thread.
+type bpfCounters struct {
+	name   string
+	tracer bpfTracer
+	// kindName names the kinds of the tracer, as label values
+	kindName func(kind uint32) string
+	// Counts of the current collection per netns inode and kind
+	counts map[uint32]map[uint32]uint64
+	used   map[uint32]bool
+	// Failure starting the tracer, the collector being disabled
+	err error
+}
+
+func newBPFCounters(name string, tracer bpfTracer, kindName func(uint32) string) *bpfCounters {
+	return &bpfCounters{name: name, tracer: tracer, kindName: kindName, used: make(map[uint32]bool)}
+}
+
+// unavailableBPFCounters disables the collector whose tracer failed to start
+// (no kernel BTF, missing capabilities...), rather than stopping the exporter
+func unavailableBPFCounters(name string, err error) *bpfCounters {
+	slog.Error(
+		"failed to start the eBPF tracer, the collector is disabled",
+		slog.String("tracer", name),
+		slog.Any("err", err),
+	)
+	return &bpfCounters{name: name, err: fmt.Errorf("%w: %w", errTracerUnavailable, err)}
+}
+
+// snapshot reads the counts once for the netns of the collection
+func (b *bpfCounters) snapshot() {
+	counts, err := b.tracer.Counts()
+	if err != nil {
+		slog.Error("failed to read the eBPF counters", slog.String("tracer", b.name), slog.Any("err", err))
+	}
+	b.counts = counts
+}
+
+// netnsCounts returns the counts of the current netns per kind
+func (b *bpfCounters) netnsCounts() (map[uint32]uint64, error) {
+	// The thread is in the netns of the pod
+	inode, err := netnsInode("/proc/thread-self/ns/net")
+	if err != nil {
+		return nil, err
+	}
+	b.used[uint32(inode)] = true
+	return b.counts[uint32(inode)], nil
+}
+
+// sweep forgets the counters of the netns not seen since the last sweep, a
+// netns collected again counting from zero
+func (b *bpfCounters) sweep() {
+	if err := b.tracer.Forget(func(inode uint32) bool { return !b.used[inode] }); err != nil {
+		slog.Error("failed to forget the eBPF counters of vanished netns", slog.String("tracer", b.name), slog.Any("err", err))
+	}
+	clear(b.used)
+}
+
+// bpfCounters returns the eBPF counters enabled whose tracer started
+func (c *CosanetCollector) bpfCounters() []*bpfCounters {
+	var counters []*bpfCounters
+	for _, b := range []*bpfCounters{c.packetDrops, c.dnsQueries} {
+		if b != nil && b.err == nil {
+			counters = append(counters, b)
+		}
+	}
+	return counters
+}
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestUnavailableBPFCounters(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+	}
+	c.collectorSuccessDesc = c.newCollectorSuccessDesc()
+	c.packetDropsDesc = c.newPacketDropsDesc()
+	c.packetDrops = unavailableBPFCounters(subCollectorPacketDrops, errors.New("no BTF"))
+	assert.Empty(t, c.bpfCounters(), "neither snapshot nor swept")
+
+	ch := make(chan prometheus.Metric, 10)
+	err := c.collectAndEmitPacketDrops(PodInfo{Name: "web", Namespace: "default"}, ch)
+	close(ch)
+	assert.ErrorIs(t, err, errTracerUnavailable)
+	require.Len(t, ch, 1)
+	m := <-ch
+	var out dto.Metric
+	require.NoError(t, m.Write(&out))
+	assert.Equal(t, subCollectorPacketDrops, metricLabels(t, m)["cosanet_collector"])
+	assert.Equal(t, 0.0, out.GetGauge().GetValue())
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+type bridgeFDBKey struct {
+	bridge    string
+	entryType string
+}
+
+// bridgeFDBEntryType tells the learned entries from the static and local
+// (addresses of the bridge ports) ones
+func bridgeFDBEntryType(state int) string {
+	if state&(netlink.NUD_PERMANENT|netlink.NUD_NOARP) != 0 {
+		return "static"
+	}
+	return "learned"
+}
+
+// countBridgeFDB counts the forwarding database entries of the bridges,
+// skipping the entries of the devices own FDB
+func countBridgeFDB(neighs []netlink.Neigh, bridges map[int]string) map[bridgeFDBKey]int {
+	counts := make(map[bridgeFDBKey]int)
+	for _, neigh := range neighs {
+		bridge, found := bridges[neigh.MasterIndex]
+		if !found {
+			continue
+		}
+		counts[bridgeFDBKey{bridge, bridgeFDBEntryType(neigh.State)}]++
+	}
+	return counts
+}
+
+// countBridgeVlans counts the distinct VLANs configured on the bridges and
+// their ports
+func countBridgeVlans(vlans map[int32][]*nl.BridgeVlanInfo, bridges map[int]string, masters map[int]int) map[string]int {
+	vids := make(map[string]map[uint16]struct{}, len(bridges))
+	for index, infos := range vlans {
+		bridge, found := bridges[int(index)]
+		if !found {
+			bridge, found = bridges[masters[int(index)]]
+		}
+		if !found {
+			continue
+		}
+		if vids[bridge] == nil {
+			vids[bridge] = make(map[uint16]struct{})
+		}
+		for _, info := range infos {
+			vids[bridge][info.Vid] = struct{}{}
+		}
+	}
+	counts := make(map[string]int, len(bridges))
+	for _, bridge := range bridges {
+		counts[bridge] = len(vids[bridge])
+	}
+	return counts
+}
+
+func (c *CosanetCollector) collectAndEmitBridges(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+	bridges := make(map[int]string)
+	masters := make(map[int]int, len(links))
+	for _, link := range links {
+		attrs := link.Attrs()
+		masters[attrs.Index] = attrs.MasterIndex
+		if link.Type() == "bridge" && c.netdevFilter.Match(attrs.Name) {
+			bridges[attrs.Index] = attrs.Name
+		}
+	}
+	if len(bridges) == 0 {
+		return nil
+	}
+
+	labelValues := c.netnsLabelValues(info)
+	var errs []error
+	neighs, err := netlink.NeighList(0, unix.AF_BRIDGE)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		for key, count := range countBridgeFDB(neighs, bridges) {
+			ch <- c.bridgeFDBEntriesDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{key.bridge, key.entryType}, labelValues...)...,
+			)
+		}
+	}
+
+	vlans, err := netlink.BridgeVlanList()
+	// An interrupted dump is still worth publishing, the next one will be consistent
+	if err != nil && !errors.Is(err, netlink.ErrDumpInterrupted) {
+		errs = append(errs, err)
+	} else {
+		for bridge, count := range countBridgeVlans(vlans, bridges, masters) {
+			ch <- c.bridgeVlansDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{bridge}, labelValues...)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
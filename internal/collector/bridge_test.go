@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+func TestCountBridgeFDB(t *testing.T) {
+	bridges := map[int]string{3: "cni0"}
+	counts := countBridgeFDB([]netlink.Neigh{
+		{MasterIndex: 3, State: netlink.NUD_REACHABLE},
+		{MasterIndex: 3, State: netlink.NUD_STALE},
+		{MasterIndex: 3, State: netlink.NUD_PERMANENT},
+		// Own FDB of a port
+		{LinkIndex: 5, State: netlink.NUD_PERMANENT},
+	}, bridges)
+	assert.Equal(t, map[bridgeFDBKey]int{
+		{"cni0", "learned"}: 2,
+		{"cni0", "static"}:  1,
+	}, counts)
+}
+
+func TestCountBridgeVlans(t *testing.T) {
+	bridges := map[int]string{3: "cni0", 4: "br1"}
+	masters := map[int]int{5: 3, 6: 3, 7: 0}
+	counts := countBridgeVlans(map[int32][]*nl.BridgeVlanInfo{
+		3: {{Vid: 1}},
+		5: {{Vid: 1}, {Vid: 100}},
+		6: {{Vid: 200}},
+		7: {{Vid: 300}},
+	}, bridges, masters)
+	assert.Equal(t, map[string]int{"cni0": 3, "br1": 0}, counts)
+}
@@ -0,0 +1,50 @@
+package collector
+
+import "time"
+
+// circuitBreaker opens after threshold consecutive failures and stays open
+// for cooldown, after which a single attempt is let through (half-open).
+// It is only used from the main collection thread, hence not locked.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// isOpen tells if calls are currently being short-circuited
+func (b *circuitBreaker) isOpen() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// Allow tells if a call may be attempted
+func (b *circuitBreaker) Allow() bool {
+	if !b.isOpen() {
+		return true
+	}
+	return b.now().Sub(b.openedAt) >= b.cooldown
+}
+
+// Success closes the breaker
+func (b *circuitBreaker) Success() {
+	b.failures = 0
+}
+
+// Failure records a failed call and returns true if it (re)opened the breaker
+func (b *circuitBreaker) Failure() bool {
+	b.failures++
+	if b.isOpen() {
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
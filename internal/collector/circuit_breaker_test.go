@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newCircuitBreaker(2, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.Allow())
+	assert.False(t, b.Failure())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Failure())
+	assert.False(t, b.Allow())
+
+	// Half-open once the cooldown has elapsed
+	now = now.Add(10 * time.Second)
+	assert.True(t, b.Allow())
+
+	// A failed probe re-opens for another cooldown
+	assert.True(t, b.Failure())
+	assert.False(t, b.Allow())
+
+	now = now.Add(10 * time.Second)
+	b.Success()
+	assert.True(t, b.Allow())
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Minute)
+	for range 5 {
+		assert.False(t, b.Failure())
+	}
+	assert.True(t, b.Allow())
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cniNetnsLabels are appended to netnsLabels with CNILabels
+var cniNetnsLabels = []string{"cosanet_cni", "cosanet_netns_id"}
+
+// Normalized netns path conventions, the cosanet_cni values
+const (
+	cniConventionHost   = "host"
+	cniConventionCNI    = "cni"
+	cniConventionCRIO   = "crio"
+	cniConventionDocker = "docker"
+	cniConventionPodman = "podman"
+	cniConventionProc   = "proc"
+	cniConventionOther  = "other"
+)
+
+var (
+	uuidPattern     = `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+	namedNetnsDirs  = regexp.MustCompile(`^(/var)?/run/netns$`)
+	cniNetnsName    = regexp.MustCompile(`^cni-` + uuidPattern + `$`)
+	crioNetnsName   = regexp.MustCompile(`^` + uuidPattern + `$`)
+	podmanNetnsName = regexp.MustCompile(`^netns-` + uuidPattern + `$`)
+	dockerNetnsDirs = regexp.MustCompile(`^(/var)?/run/docker/netns$`)
+	userNetnsDirs   = regexp.MustCompile(`^/run/user/[0-9]+/netns$`)
+	procNetnsPath   = regexp.MustCompile(`/proc/[0-9]+/ns/net$`)
+)
+
+// cniConvention tells the convention of the netns path: the cni-<uuid>
+// names of containerd under /var/run/netns, the bare uuids of CRI-O, the
+// docker and podman ones, or a process netns. Paths looked up in the host
+// mount namespace (under /proc/1/root) are recognized too. Empty when the
+// path is unknown.
+func cniConvention(netNSPath string) string {
+	switch {
+	case netNSPath == "":
+		return ""
+	case netNSPath == "HOST":
+		return cniConventionHost
+	case procNetnsPath.MatchString(netNSPath):
+		return cniConventionProc
+	}
+	dir, name := filepath.Dir(netNSPath), filepath.Base(netNSPath)
+	if _, hostPath, found := strings.Cut(dir, "/root/"); found {
+		dir = "/" + hostPath
+	}
+	switch {
+	case namedNetnsDirs.MatchString(dir) && cniNetnsName.MatchString(name):
+		return cniConventionCNI
+	case namedNetnsDirs.MatchString(dir) && crioNetnsName.MatchString(name):
+		return cniConventionCRIO
+	case namedNetnsDirs.MatchString(dir) && podmanNetnsName.MatchString(name),
+		userNetnsDirs.MatchString(dir):
+		return cniConventionPodman
+	case dockerNetnsDirs.MatchString(dir):
+		return cniConventionDocker
+	}
+	return cniConventionOther
+}
+
+// stableNetnsID identifies the netns by the pod owning it, unlike its path
+// changing with every sandbox. The netns without a pod fall back to their
+// name.
+func stableNetnsID(info PodInfo) string {
+	if info.netNSPath == "HOST" {
+		return cniConventionHost
+	}
+	key := info.netNSName
+	if info.Name != "" && info.Namespace != "" {
+		key = info.Namespace + "/" + info.Name
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
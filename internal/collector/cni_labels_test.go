@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestCNIConvention(t *testing.T) {
+	for path, convention := range map[string]string{
+		"":     "",
+		"HOST": "host",
+		"/var/run/netns/cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71":             "cni",
+		"/run/netns/cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71":                 "cni",
+		"/proc/1/root/var/run/netns/cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71": "cni",
+		"/var/run/netns/0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71":                 "crio",
+		"/run/netns/netns-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71":               "podman",
+		"/run/user/1000/netns/netns-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71":     "podman",
+		"/var/run/docker/netns/3f2b1c9d8e7a":                                  "docker",
+		"/proc/4242/ns/net":                                                   "proc",
+		"/var/run/netns/blue":                                                 "other",
+	} {
+		assert.Equal(t, convention, cniConvention(path), path)
+	}
+}
+
+func TestStableNetnsID(t *testing.T) {
+	web := PodInfo{Name: "web-0", Namespace: "default", netNSName: "cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71"}
+	recreated := web
+	recreated.netNSName = "cni-7c1e0f3a-9b2d-4e5f-8a6b-1c2d3e4f5a6b"
+	assert.Len(t, stableNetnsID(web), 12)
+	assert.Equal(t, stableNetnsID(web), stableNetnsID(recreated))
+	assert.NotEqual(t, stableNetnsID(web), stableNetnsID(PodInfo{Name: "web-1", Namespace: "default"}))
+	assert.Equal(t, "host", stableNetnsID(PodInfo{netNSPath: "HOST"}))
+	// Named netns without a pod
+	assert.NotEqual(t, stableNetnsID(PodInfo{netNSName: "blue"}), stableNetnsID(PodInfo{netNSName: "red"}))
+}
+
+func TestCNILabels(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		podLabelKeys:        []string{"team"},
+		netnsLabels:         append(buildNetnsLabels([]string{"team"}, nil, false, false), cniNetnsLabels...),
+	}
+	c.options.CNILabels = true
+	c.conntrackCurrDesc = c.newConntrackCurrDesc()
+
+	info := PodInfo{
+		Name:      "web-0",
+		Namespace: "default",
+		Labels:    map[string]string{"team": "shop"},
+		netNSPath: "/var/run/netns/cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71",
+		netNSName: "cni-0b5a44d5-2c2e-8d7a-41e7-8c0f9a7e8a71",
+	}
+	labels := metricLabels(t, c.conntrackCurrDesc.mustNewConstMetric(prometheus.GaugeValue, 1, c.netnsLabelValues(info)...))
+	assert.Equal(t, "cni", labels["cosanet_cni"])
+	assert.Equal(t, stableNetnsID(info), labels["cosanet_netns_id"])
+	assert.Equal(t, "shop", labels["cosanet_pod_label_team"])
+}
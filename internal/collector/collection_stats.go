@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectionBuckets are the upper bounds of the collection duration histograms
+var collectionBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Phases of cosanet_collection_phase_duration_seconds, the collectors having
+// their own family
+const (
+	phaseListSandboxes = "list_sandboxes"
+	phaseNetnsSwitch   = "netns_switch"
+)
+
+var collectionPhases = []string{phaseListSandboxes, phaseNetnsSwitch}
+
+// phaseTimer splits the time of a collection between its phases and
+// collectors, summed over every netns. Only used from the main thread.
+type phaseTimer struct {
+	current   string
+	since     time.Time
+	durations map[string]time.Duration
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{durations: make(map[string]time.Duration)}
+}
+
+// reset forgets the previous collection, returning the start of the new one
+func (t *phaseTimer) reset() time.Time {
+	t.current = ""
+	clear(t.durations)
+	return time.Now()
+}
+
+// begin ends the current phase, if any, and starts the given one
+func (t *phaseTimer) begin(phase string) {
+	now := time.Now()
+	if t.current != "" {
+		t.durations[t.current] += now.Sub(t.since)
+	}
+	t.current, t.since = phase, now
+}
+
+// stop ends the current phase, if any
+func (t *phaseTimer) stop() {
+	t.begin("")
+}
+
+type collectionDurations struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func (d *collectionDurations) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	d.count++
+	d.sum += seconds
+	for _, bound := range collectionBuckets {
+		if seconds <= bound {
+			d.buckets[bound]++
+		}
+	}
+}
+
+// collectionStats accumulates the durations of the collections, of their
+// phases and of their collectors. Only used from the main thread.
+type collectionStats struct {
+	total      *collectionDurations
+	phases     map[string]*collectionDurations
+	collectors map[string]*collectionDurations
+}
+
+func newCollectionDurations() *collectionDurations {
+	return &collectionDurations{buckets: make(map[float64]uint64, len(collectionBuckets))}
+}
+
+func newCollectionStats() *collectionStats {
+	return &collectionStats{
+		total:      newCollectionDurations(),
+		phases:     make(map[string]*collectionDurations, len(collectionPhases)),
+		collectors: make(map[string]*collectionDurations),
+	}
+}
+
+// observe records a collection. The phases are always observed, to keep
+// their series stable, the collectors only when they ran.
+func (s *collectionStats) observe(total time.Duration, durations map[string]time.Duration) {
+	s.total.observe(total)
+	for _, phase := range collectionPhases {
+		if _, ok := s.phases[phase]; !ok {
+			s.phases[phase] = newCollectionDurations()
+		}
+		s.phases[phase].observe(durations[phase])
+	}
+	for collector, duration := range durations {
+		if slices.Contains(collectionPhases, collector) {
+			continue
+		}
+		if _, ok := s.collectors[collector]; !ok {
+			s.collectors[collector] = newCollectionDurations()
+		}
+		s.collectors[collector].observe(duration)
+	}
+}
+
+// emitCollectionStats records the collection, which took total, then
+// publishes the collection latencies
+func (c *CosanetCollector) emitCollectionStats(total time.Duration, ch chan<- prometheus.Metric) {
+	c.phaseTimer.stop()
+	stats := c.collectionStats
+	stats.observe(total, c.phaseTimer.durations)
+	ch <- c.collectionDurationDesc.mustNewConstHistogram(
+		stats.total.count,
+		stats.total.sum,
+		stats.total.buckets,
+		c.nodename,
+	)
+	for phase, durations := range stats.phases {
+		ch <- c.collectionPhaseDurationDesc.mustNewConstHistogram(
+			durations.count,
+			durations.sum,
+			durations.buckets,
+			phase,
+			c.nodename,
+		)
+	}
+	for collector, durations := range stats.collectors {
+		ch <- c.collectorDurationDesc.mustNewConstHistogram(
+			durations.count,
+			durations.sum,
+			durations.buckets,
+			collector,
+			c.nodename,
+		)
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	timer := newPhaseTimer()
+	timer.reset()
+	timer.begin(phaseNetnsSwitch)
+	time.Sleep(time.Millisecond)
+	timer.begin(subCollectorSnmp)
+	timer.begin(phaseNetnsSwitch)
+	time.Sleep(time.Millisecond)
+	timer.stop()
+	// Not timed outside of a phase
+	time.Sleep(time.Millisecond)
+	timer.stop()
+
+	assert.GreaterOrEqual(t, timer.durations[phaseNetnsSwitch], 2*time.Millisecond)
+	assert.Contains(t, timer.durations, subCollectorSnmp)
+
+	timer.reset()
+	assert.Empty(t, timer.durations)
+}
+
+func TestCollectionStats(t *testing.T) {
+	stats := newCollectionStats()
+	stats.observe(300*time.Millisecond, map[string]time.Duration{
+		phaseListSandboxes: 20 * time.Millisecond,
+		subCollectorSnmp:   200 * time.Millisecond,
+	})
+	stats.observe(2*time.Second, map[string]time.Duration{
+		phaseListSandboxes: 40 * time.Millisecond,
+	})
+
+	assert.Equal(t, uint64(2), stats.total.count)
+	assert.InDelta(t, 2.3, stats.total.sum, 1e-9)
+	assert.Equal(t, uint64(1), stats.total.buckets[0.5])
+	assert.Equal(t, uint64(2), stats.total.buckets[2.5])
+	assert.Equal(t, uint64(2), stats.phases[phaseListSandboxes].buckets[0.05])
+	// Phases are observed even when empty, not the collectors
+	assert.Equal(t, uint64(2), stats.phases[phaseNetnsSwitch].buckets[0.005])
+	assert.Equal(t, uint64(1), stats.collectors[subCollectorSnmp].count)
+	assert.NotContains(t, stats.collectors, phaseListSandboxes)
+}
@@ -1,70 +1,584 @@
 package collector
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cosanet/cosanet/internal/controller_resolver"
-	"github.com/cosanet/cosanet/internal/netstat"
-	"github.com/cosanet/cosanet/internal/procnet_2l_parser"
-	"github.com/cosanet/cosanet/internal/procnet_v6_parser"
+	"github.com/cosanet/cosanet/internal/log_dedup"
+	"github.com/cosanet/cosanet/internal/procnet_xfrm_parser"
+	"github.com/cosanet/cosanet/pkg/netstat"
+	"github.com/cosanet/cosanet/pkg/procnet_2l_parser"
+	"github.com/cosanet/cosanet/pkg/procnet_v6_parser"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/ti-mo/conntrack"
 	"github.com/vishvananda/netns"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type PodInfo struct {
-	PID       int
-	UID       string
-	Name      string
-	Namespace string
-	netNSPath string
-	netNSName string
+	PID         int
+	UID         string
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	HostNetwork bool
+	netNSPath   string
+	netNSName   string
+	// Unique id (device and inode) of the network namespace
+	netNSID string
+	// Running containers, only listed with the containers info metric
+	Containers []ContainerInfo
+	// Runtime the sandbox was listed from, see multipleCRISockets
+	Runtime string
+	// Why the sandbox can't be collected, empty when it can
+	skipReason string
+	// IP families the netns lacks (ipv4, ipv6), detected before collecting it
+	missingIPFamilies []string
 }
 
 type CosanetCollector struct {
-	nodename            string
-	chanToFeed          chan CollectRequest
-	options             CosanetCollectorOptions
-	podFilter           regexp.Regexp
-	snmpMetricFilter    regexp.Regexp
-	netstatMetricFilter regexp.Regexp
-	controller_resolver controller_resolver.PodControllerResolver
+	nodename              string
+	chanToFeed            chan CollectRequest
+	options               CosanetCollectorOptions
+	podFilter             podNameFilter
+	podAnnotationFilter   podAnnotationFilter
+	podLabelSelector      labels.Selector
+	podAnnotationSelector labels.Selector
+	snmpMetricFilter      *metricFilter
+	netstatMetricFilter   *metricFilter
+	ethtoolStatFilter     regexp.Regexp
+	xfrmMetricFilter      *metricFilter
+	shard                 podShard
+	controller_resolver   controller_resolver.PodControllerResolver
+	// Sandboxes of the last collection, served by /debug/pods
+	lastPods               lastPods
+	cri                    *criClient
+	kubelet                *kubeletClient
+	conntrackPool          *conntrackPool
+	conntrackEventWatchers *conntrackEventWatchers
+	conntrackSaturation    *saturationWatchdog
+	pendingCollectors      *pendingCollectors
+	phaseTimer             *phaseTimer
+	watchdog               *watchdog
+	// Set by the first collection listing the sandboxes successfully
+	ready             atomic.Bool
+	collectionStats   *collectionStats
+	podLabelKeys      []string
+	podAnnotationKeys []string
+	netnsLabels       []string
+	// Adds cosanet_runtime to netnsLabels
+	runtimeLabel bool
+
+	// New label names per label, see CosanetCollectorOptions.LabelNames
+	renamedLabels         map[string]string
+	relabelRules          []relabelRule
+	namespaceAggregation  *namespaceAggregation
+	controllerAggregation *controllerAggregation
+	sockFamilies          []sockFamily
+	sockPorts             []uint16
+	tcpInfoRTTBuckets     []float64
+	skMemProtos           []string
+	sysctlNames           []string
+	netdevFilter          deviceFilter
+	// Host ifindex to name, refreshed every scrape before entering the pods netns
+	hostDevices                 map[int]string
+	conntrackCurrDesc           *metricDesc
+	hostNetworkPodDesc          *metricDesc
+	conntrackMaxDesc            *metricDesc
+	conntrackStatDescs          [len(conntrackStatNames)]*metricDesc
+	conntrackEntriesDesc        *metricDesc
+	conntrackZoneEntriesDesc    *metricDesc
+	conntrackBytesDesc          *metricDesc
+	conntrackPacketsDesc        *metricDesc
+	conntrackEventsDesc         *metricDesc
+	conntrackSaturationDesc     *metricDesc
+	sockProtoDescs              map[string]*metricDesc
+	sockQueueDescs              map[string]*metricDesc
+	sockQueueMaxDescs           map[string]*metricDesc
+	sockPortDescs               map[string]*metricDesc
+	tcpRTTDesc                  *metricDesc
+	tcpRetransSegmentsDesc      *metricDesc
+	tcpRetransmittingDesc       *metricDesc
+	tcpCAStateDesc              *metricDesc
+	netdevDescs                 []*metricDesc
+	linkUpDesc                  *metricDesc
+	linkCarrierDesc             *metricDesc
+	linkCarrierChangesDesc      *metricDesc
+	linkMTUDesc                 *metricDesc
+	qdiscBytesDesc              *metricDesc
+	qdiscPacketsDesc            *metricDesc
+	qdiscQlenDesc               *metricDesc
+	qdiscBacklogDesc            *metricDesc
+	qdiscDropsDesc              *metricDesc
+	qdiscRequeuesDesc           *metricDesc
+	qdiscOverlimitsDesc         *metricDesc
+	criConnStateDesc            *metricDesc
+	criRequestsDesc             *metricDesc
+	criRequestDurationDesc      *metricDesc
+	collectionDurationDesc      *metricDesc
+	collectionPhaseDurationDesc *metricDesc
+	collectorDurationDesc       *metricDesc
+	skippedSandboxesDesc        *metricDesc
+	collectionStalledDesc       *metricDesc
+	collectionStallsDesc        *metricDesc
+	resolverCacheDescs          resolverCacheDescs
+	containerInfoDesc           *metricDesc
+	softnetProcessedDesc        *metricDesc
+	softnetDroppedDesc          *metricDesc
+	softnetTimeSqueezeDesc      *metricDesc
+	unixSocketsDesc             *metricDesc
+	netlinkSocketsDesc          *metricDesc
+	packetSocketsDesc           *metricDesc
+	packetSocketInfoDesc        *metricDesc
+	multicastGroupsDesc         *metricDesc
+	neighborEntriesDesc         *metricDesc
+	interfacesDesc              *metricDesc
+	ipFamilyAvailableDesc       *metricDesc
+	ipAddressesDesc             *metricDesc
+	neighborGCThreshDesc        *metricDesc
+	sysctlDesc                  *metricDesc
+	ethtoolStatDesc             *metricDesc
+	linkSpeedDesc               *metricDesc
+	bridgeFDBEntriesDesc        *metricDesc
+	bridgeVlansDesc             *metricDesc
+	vethPeerInfoDesc            *metricDesc
+	skMemBytesDesc              *metricDesc
+	skMemDropsDesc              *metricDesc
+	skMemRcvBufUsageDesc        *metricDesc
+	wireguardRxBytesDesc        *metricDesc
+	wireguardTxBytesDesc        *metricDesc
+	wireguardAllowedIPsDesc     *metricDesc
+	wireguardHandshakeDesc      *metricDesc
+	icmpMsgDesc                 *metricDesc
+	logSuppressedDesc           *metricDesc
+	packetDropsDesc             *metricDesc
+	dnsQueriesDesc              *metricDesc
+	dnsResponsesDesc            *metricDesc
+	collectorSuccessDesc        *metricDesc
+	procNetDescs                map[procNetKey]*metricDesc
+	procExtraDescs              map[procNetKey]*metricDesc
+	// Descriptors of the counters missing from the host files at startup
+	lateProcNetDescs   descCache
+	lateProcExtraDescs descCache
+	// Kept between the pods, the collection running on a single thread
+	snmpParser    *procnet_2l_parser.Parser
+	snmp6Parser   *procnet_v6_parser.Parser
+	netstatParser *procnet_2l_parser.Parser
+	xfrmParser    *procnet_xfrm_parser.Parser
+	// Extra procfs files of the config, with their own parser
+	procExtraFiles []procExtraFile
+	// Metrics of the netns of the last collection, served by /api/v1/netns
+	lastNetns lastNetns
+	// eBPF counters, nil unless enabled
+	packetDrops *bpfCounters
+	dnsQueries  *bpfCounters
 }
 
 // Describe implements prometheus.Collector.
-func (c *CosanetCollector) Describe(chan<- *prometheus.Desc) {
+func (c *CosanetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch, describedPerController := c.describeByController(ch)
+	defer describedPerController()
+	ch <- c.hostNetworkPodDesc.desc
+	ch <- c.criConnStateDesc.desc
+	ch <- c.criRequestsDesc.desc
+	ch <- c.criRequestDurationDesc.desc
+	ch <- c.collectionDurationDesc.desc
+	ch <- c.collectionPhaseDurationDesc.desc
+	ch <- c.collectorDurationDesc.desc
+	ch <- c.skippedSandboxesDesc.desc
+	if c.watchdog != nil {
+		ch <- c.collectionStalledDesc.desc
+		ch <- c.collectionStallsDesc.desc
+	}
+	c.resolverCacheDescs.describe(ch)
+	if c.options.LogDedup != nil {
+		ch <- c.logSuppressedDesc.desc
+	}
+	if c.options.Conntrack.Enabled {
+		ch <- c.conntrackCurrDesc.desc
+		ch <- c.conntrackMaxDesc.desc
+		if c.options.Conntrack.Stats {
+			for _, desc := range c.conntrackStatDescs {
+				ch <- desc.desc
+			}
+		}
+		if c.options.Conntrack.Breakdown {
+			ch <- c.conntrackEntriesDesc.desc
+		}
+		if c.options.Conntrack.Zones {
+			ch <- c.conntrackZoneEntriesDesc.desc
+		}
+		if c.options.Conntrack.Accounting.Enabled {
+			ch <- c.conntrackBytesDesc.desc
+			ch <- c.conntrackPacketsDesc.desc
+		}
+		if c.options.Conntrack.Events {
+			ch <- c.conntrackEventsDesc.desc
+		}
+		if c.options.Conntrack.Saturation.Threshold > 0 {
+			ch <- c.conntrackSaturationDesc.desc
+		}
+	}
+	if c.options.SockProto.Enabled {
+		for _, sockproto := range strings.Split(c.options.SockProto.Protos, ",") {
+			if desc, ok := c.sockProtoDescs[sockproto]; ok {
+				ch <- desc.desc
+				ch <- c.sockQueueDescs[sockproto].desc
+				ch <- c.sockQueueMaxDescs[sockproto].desc
+			}
+			if desc, ok := c.sockPortDescs[sockproto]; ok && len(c.sockPorts) > 0 {
+				ch <- desc.desc
+			}
+		}
+	}
+	if c.options.TCPInfo.Enabled {
+		ch <- c.tcpRTTDesc.desc
+		ch <- c.tcpRetransSegmentsDesc.desc
+		ch <- c.tcpRetransmittingDesc.desc
+		ch <- c.tcpCAStateDesc.desc
+	}
+	if c.options.Netdev.Enabled {
+		for _, desc := range c.netdevDescs {
+			ch <- desc.desc
+		}
+	}
+	if c.options.Link.Enabled {
+		ch <- c.linkUpDesc.desc
+		ch <- c.linkCarrierDesc.desc
+		ch <- c.linkCarrierChangesDesc.desc
+		ch <- c.linkMTUDesc.desc
+	}
+	if c.options.Qdisc.Enabled {
+		ch <- c.qdiscBytesDesc.desc
+		ch <- c.qdiscPacketsDesc.desc
+		ch <- c.qdiscQlenDesc.desc
+		ch <- c.qdiscBacklogDesc.desc
+		ch <- c.qdiscDropsDesc.desc
+		ch <- c.qdiscRequeuesDesc.desc
+		ch <- c.qdiscOverlimitsDesc.desc
+	}
+	if c.options.Multicast.Enabled {
+		ch <- c.multicastGroupsDesc.desc
+	}
+	if c.options.Neighbor.Enabled {
+		ch <- c.neighborEntriesDesc.desc
+		ch <- c.neighborGCThreshDesc.desc
+	}
+	if c.options.Addresses.Enabled {
+		ch <- c.interfacesDesc.desc
+		ch <- c.ipAddressesDesc.desc
+	}
+	if c.options.Sysctl.Enabled {
+		ch <- c.sysctlDesc.desc
+	}
+	if c.options.Ethtool.Enabled {
+		ch <- c.ethtoolStatDesc.desc
+		ch <- c.linkSpeedDesc.desc
+	}
+	if c.options.Bridge.Enabled {
+		ch <- c.bridgeFDBEntriesDesc.desc
+		ch <- c.bridgeVlansDesc.desc
+	}
+	if c.options.Containers.Enabled {
+		ch <- c.containerInfoDesc.desc
+	}
+	if c.options.Veth.Enabled {
+		ch <- c.vethPeerInfoDesc.desc
+	}
+	if c.options.SkMem.Enabled {
+		ch <- c.skMemBytesDesc.desc
+		ch <- c.skMemDropsDesc.desc
+		ch <- c.skMemRcvBufUsageDesc.desc
+	}
+	if c.options.Wireguard.Enabled {
+		ch <- c.wireguardRxBytesDesc.desc
+		ch <- c.wireguardTxBytesDesc.desc
+		ch <- c.wireguardAllowedIPsDesc.desc
+		ch <- c.wireguardHandshakeDesc.desc
+	}
+	if c.options.Softnet.Enabled {
+		ch <- c.softnetProcessedDesc.desc
+		ch <- c.softnetDroppedDesc.desc
+		ch <- c.softnetTimeSqueezeDesc.desc
+	}
+	if c.options.Unix.Enabled {
+		ch <- c.unixSocketsDesc.desc
+	}
+	if c.options.NetlinkSockets.Enabled {
+		ch <- c.netlinkSocketsDesc.desc
+	}
+	if c.options.Packet.Enabled {
+		ch <- c.packetSocketsDesc.desc
+		if c.options.Packet.Details {
+			ch <- c.packetSocketInfoDesc.desc
+		}
+	}
+	if c.options.Snmp.Enabled && c.options.Snmp.IcmpMsg {
+		ch <- c.icmpMsgDesc.desc
+	}
+	if c.options.PacketDrops.Enabled {
+		ch <- c.packetDropsDesc.desc
+	}
+	if c.options.DNS.Enabled {
+		ch <- c.dnsQueriesDesc.desc
+		ch <- c.dnsResponsesDesc.desc
+	}
+	if c.options.CollectorTimeout > 0 || c.options.PacketDrops.Enabled || c.options.DNS.Enabled {
+		ch <- c.collectorSuccessDesc.desc
+	}
+	ch <- c.ipFamilyAvailableDesc.desc
+	for _, desc := range c.procNetDescs {
+		ch <- desc.desc
+	}
+	for _, desc := range c.procExtraDescs {
+		ch <- desc.desc
+	}
 }
 
 type CosanetCollectorOptions struct {
-	PodFilter   string
+	// Mount point of the procfs, /proc unless the host's one is mounted elsewhere
+	ProcRoot  string
+	PodFilter PodFilterOptions
+	MaxPods   int
+	Shard     string
+	CRI       CRIOptions
+	Discovery DiscoveryOptions
+	// Collectors is the comma separated list of the enabled collectors,
+	// overriding their Enabled options when not empty
+	Collectors string
+	// Mode is daemonset (every sandbox of the node) or sidecar (the netns of
+	// cosanet only, identified by Sidecar)
+	Mode    string
+	Sidecar SidecarOptions
+	// Comma separated pod labels and annotations to add as metric labels
+	PodLabels      string
+	PodAnnotations string
+	// ContainerLabels names the pod labels after containers (cosanet_container,
+	// cosanet_container_id) for docker/podman hosts outside of Kubernetes
+	ContainerLabels bool
+	// CNILabels adds cosanet_cni, the convention of the netns path, and
+	// cosanet_netns_id, a netns identifier stable across the sandboxes of a pod
+	CNILabels bool
+	// MetricNames is the naming scheme of the metrics and labels, legacy
+	// (kernel counter names as is) or snake_case
+	MetricNames string
+	// MetricPrefix replaces the cosanet namespace of the metric names, and
+	// of the label names with LabelPrefix
+	MetricPrefix string
+	LabelPrefix  bool
+	// LabelNames renames the node, pod, namespace and netnsname labels, as a
+	// comma separated key=name list, eg: node=node,namespace=namespace
+	LabelNames string
+	// HostLabels are the namespace and netnsname values of the host entry,
+	// sentinel (HOST) or empty
+	HostLabels string
+	// Relabel rules applied to every metric family
+	Relabel []RelabelRule
+	// NamespaceAggregation sums the metric families matching Metrics (an
+	// anchored regex of their names before relabeling) per Kubernetes
+	// namespace, dropping the pod labels
+	NamespaceAggregation struct {
+		Metrics string
+	}
+	// ControllerAggregation also sums the metric families matching Metrics
+	// per pod controller, in a <family>_by_controller family without the pod
+	// labels. Requires the controller resolver.
+	ControllerAggregation struct {
+		Metrics string
+	}
+	// LogDedup is the log handler whose suppressed records are counted,
+	// nil when the logs aren't deduplicated
+	LogDedup *log_dedup.Handler
+	// CollectorTimeout bounds the conntrack, sockproto, snmp and netstat
+	// collectors in every netns, 0 for no timeout
+	CollectorTimeout time.Duration
+	// Watchdog abandons the netns of a collection making no progress for
+	// Deadline and exits once stuck for twice the deadline, 0 for no watchdog
+	Watchdog struct {
+		Deadline time.Duration
+	}
 	CollectHost struct {
 		Enabled bool
 	}
+	// API records the metrics of every netns for /api/v1/netns
+	API struct {
+		Enabled bool
+		// GRPCListen is the address of the gRPC API (host:port or
+		// unix:/path), empty to disable it
+		GRPCListen string
+	}
 	Conntrack struct {
 		Enabled bool
+		// Backend is netlink (falling back to procfs on failure) or procfs
+		Backend string
+		// Stats enables the per-CPU statistics (insert_failed, drop...)
+		Stats bool
+		// Breakdown dumps the table to count entries per protocol and TCP state
+		Breakdown bool
+		// Zones dumps the host table to count entries per conntrack zone
+		Zones bool
+		// Accounting dumps the table to sum the flows bytes and packets
+		Accounting struct {
+			Enabled        bool
+			SplitDirection bool
+		}
+		// Events counts the created and destroyed flows from conntrack events
+		Events bool
+		// Saturation signals the tables filled above Threshold (0 to 1, 0
+		// disables), optionally with a Warning Event on the pod
+		Saturation struct {
+			Threshold        float64
+			KubernetesEvents bool
+		}
 	}
+	// The counters of the /proc/net files matched by any MetricInclude regex
+	// (every counter when there is none) and by no MetricExclude one
 	Snmp struct {
 		Enabled       bool
-		MetricInclude string
+		MetricInclude []string
+		MetricExclude []string
+		// IcmpMsg emits the IcmpMsg columns as a single family labeled with
+		// their direction and ICMP type, whatever MetricInclude
+		IcmpMsg bool
 	}
 	Netstat struct {
 		Enabled       bool
-		MetricInclude string
+		MetricInclude []string
+		MetricExclude []string
+	}
+	Xfrm struct {
+		Enabled       bool
+		MetricInclude []string
+		MetricExclude []string
 	}
+	// ProcExtra lists the additional procfs files collected in every netns
+	ProcExtra []ProcExtraFile
 	SockProto struct {
 		Enabled bool
 		Protos  string
+		// Backend is either "netlink" (INET_DIAG, falling back to procfs) or "procfs"
+		Backend string
+		// Families is the comma separated list of IP families to collect
+		// (ipv4, ipv6), empty means both
+		Families string
+		// Ports is the comma separated list of local ports counting their
+		// tcp, udp and udplite sockets per state, eg: 8080,5432
+		Ports string
+	}
+	// TCPInfo dumps tcp_info of established sockets through INET_DIAG
+	TCPInfo struct {
+		Enabled bool
+		// RTTBuckets is the comma separated list of RTT histogram upper
+		// bounds, in seconds
+		RTTBuckets string
+	}
+	// SkMem aggregates the sockets memory counters through INET_DIAG
+	SkMem struct {
+		Enabled bool
+		// Protos is the comma separated list of protocols to dump
+		Protos string
+	}
+	// Netdev device filters also apply to the link and qdisc collectors
+	Netdev struct {
+		Enabled       bool
+		DeviceInclude string
+		DeviceExclude string
+		// NetworkAttachment adds the Multus network attachment of the
+		// interface to the per interface metrics
+		NetworkAttachment bool
+	}
+	// Link exposes the link attributes (operstate, carrier, MTU) via netlink
+	Link struct {
+		Enabled bool
+	}
+	// Qdisc exposes the tc queueing disciplines statistics via netlink
+	Qdisc struct {
+		Enabled bool
+	}
+	// Multicast counts the joined IGMP/MLD multicast groups per interface
+	Multicast struct {
+		Enabled bool
+	}
+	// Ethtool exposes the driver statistics and link speed of the host
+	// interfaces, and optionally of the pods ones
+	Ethtool struct {
+		Enabled bool
+		// Pods also collects the interfaces of the pods netns, their veth end
+		Pods bool
+		// StatInclude filters the driver statistics by name
+		StatInclude string
+	}
+	// Bridge counts the FDB entries and VLANs of the host bridges via netlink
+	Bridge struct {
+		Enabled bool
+	}
+	// Containers exposes an info metric per running container of the pods
+	Containers struct {
+		Enabled bool
+	}
+	// Veth maps the pods veths to their host side peer
+	Veth struct {
+		Enabled bool
+	}
+	// Wireguard exposes the WireGuard peers statistics via generic netlink
+	Wireguard struct {
+		Enabled bool
+	}
+	// Neighbor counts the neighbor table entries per state via netlink and
+	// exposes the host gc_thresh sysctls
+	Neighbor struct {
+		Enabled bool
+	}
+	// Addresses counts the interfaces and the IP addresses per family and
+	// scope via netlink
+	Addresses struct {
+		Enabled bool
+	}
+	// Sysctl exposes the listed net sysctls of every netns
+	Sysctl struct {
+		Enabled bool
+		// Names is the comma separated list of dotted sysctl names
+		Names string
+	}
+	// Softnet exposes the host per CPU /proc/net/softnet_stat counters
+	Softnet struct {
+		Enabled bool
+	}
+	// Unix counts the unix domain sockets from /proc/net/unix
+	Unix struct {
+		Enabled bool
+	}
+	// NetlinkSockets counts the netlink sockets from /proc/net/netlink
+	NetlinkSockets struct {
+		Enabled bool
+	}
+	// Packet counts the AF_PACKET sockets from /proc/net/packet
+	Packet struct {
+		Enabled bool
+		// Details exposes an info metric per socket with its interface index and inode
+		Details bool
+	}
+	// PacketDrops counts the packets dropped by the kernel per reason with
+	// an eBPF program on the skb:kfree_skb tracepoint
+	PacketDrops struct {
+		Enabled bool
+	}
+	// DNS counts the DNS queries and responses over UDP per response code
+	// with eBPF programs on kprobes
+	DNS struct {
+		Enabled bool
 	}
 }
 
@@ -74,293 +588,767 @@ func NewCosanetCollector(
 	options CosanetCollectorOptions,
 	controller_resolver *controller_resolver.PodControllerResolver,
 ) *CosanetCollector {
-	return &CosanetCollector{
-		nodename:            nodename,
-		chanToFeed:          ch,
-		options:             options,
-		podFilter:           *regexp.MustCompile(options.PodFilter),
-		snmpMetricFilter:    *regexp.MustCompile(options.Snmp.MetricInclude),
-		netstatMetricFilter: *regexp.MustCompile(options.Netstat.MetricInclude),
-		controller_resolver: *controller_resolver,
+	if options.ProcRoot == "" {
+		options.ProcRoot = "/proc"
+	}
+	mustApplyCollectors(&options)
+	mustCheckContainerLabels(options)
+	options.Discovery.Mode = mustParseDiscoveryMode(options.Discovery.Mode)
+	options.Mode = mustParseMode(options.Mode)
+	mustCheckSidecarOptions(options)
+	options.MetricNames = mustParseMetricNames(options.MetricNames)
+	options.MetricPrefix = mustParseMetricPrefix(options.MetricPrefix)
+	options.HostLabels = mustParseHostLabels(options.HostLabels)
+	options.Conntrack.Backend = mustParseConntrackBackend(options.Conntrack.Backend)
+	c := &CosanetCollector{
+		nodename:               nodename,
+		chanToFeed:             ch,
+		options:                options,
+		podFilter:              newPodNameFilter(options.PodFilter),
+		podAnnotationFilter:    newPodAnnotationFilter(options.PodFilter),
+		podLabelSelector:       mustParseLabelSelector("label", options.PodFilter.PodLabelSelector),
+		podAnnotationSelector:  mustParseLabelSelector("annotation", options.PodFilter.PodAnnotationSelector),
+		snmpMetricFilter:       mustCompileMetricFilter("snmp", options.Snmp.MetricInclude, options.Snmp.MetricExclude),
+		netstatMetricFilter:    mustCompileMetricFilter("netstat", options.Netstat.MetricInclude, options.Netstat.MetricExclude),
+		xfrmMetricFilter:       mustCompileMetricFilter("xfrm", options.Xfrm.MetricInclude, options.Xfrm.MetricExclude),
+		ethtoolStatFilter:      *regexp.MustCompile(options.Ethtool.StatInclude),
+		controller_resolver:    *controller_resolver,
+		shard:                  mustParseShard(options.Shard),
+		cri:                    newCRIClient(options),
+		kubelet:                mustNewKubeletClient(options),
+		conntrackPool:          newConntrackPool(),
+		conntrackEventWatchers: newConntrackEventWatchers(),
+		conntrackSaturation:    newSaturationWatchdog(options.Conntrack.Saturation.Threshold),
+		pendingCollectors:      newPendingCollectors(),
+		phaseTimer:             newPhaseTimer(),
+		collectionStats:        newCollectionStats(),
+		podLabelKeys:           splitList(options.PodLabels),
+		podAnnotationKeys:      splitList(options.PodAnnotations),
+		renamedLabels:          mustParseLabelNames(options.LabelNames),
+		relabelRules:           mustCompileRelabelRules(options.Relabel),
+		sockFamilies:           mustParseSockFamilies(options.SockProto.Families),
+		sockPorts:              mustParseSockPorts(options.SockProto.Ports),
+		tcpInfoRTTBuckets:      mustParseBuckets(options.TCPInfo.RTTBuckets),
+		skMemProtos:            mustParseSkMemProtos(options.SkMem.Protos),
+		sysctlNames:            mustParseSysctlNames(options.Sysctl.Names),
+		netdevFilter:           newDeviceFilter(options.Netdev.DeviceInclude, options.Netdev.DeviceExclude),
+	}
+	c.snmpParser, c.snmp6Parser = c.newSnmpParsers()
+	c.netstatParser = c.newNetstatParser()
+	c.xfrmParser = procnet_xfrm_parser.NewParser(procNetInclude(c.xfrmMetricFilter))
+	c.procExtraFiles = mustCompileProcExtraFiles(options.ProcExtra)
+	c.runtimeLabel = options.Mode == ModeDaemonSet && options.Discovery.Mode == DiscoveryModeCRI && multipleCRISockets()
+	c.netnsLabels = buildNetnsLabels(c.podLabelKeys, c.podAnnotationKeys, options.ContainerLabels, c.runtimeLabel)
+	if options.CNILabels {
+		c.netnsLabels = append(c.netnsLabels, cniNetnsLabels...)
+	}
+	c.mustCheckNetnsLabels()
+	c.namespaceAggregation = mustNewNamespaceAggregation(
+		options.NamespaceAggregation.Metrics,
+		c.netnsLabels,
+		c.labelNames(c.netnsLabels),
+	)
+	c.controllerAggregation = mustNewControllerAggregation(
+		options.ControllerAggregation.Metrics,
+		c.netnsLabels,
+		c.labelNames(c.netnsLabels),
+	)
+	c.buildDescs()
+	c.watchdog = newWatchdog(options.Watchdog.Deadline)
+	if c.watchdog != nil {
+		go c.runWatchdog()
+	}
+	c.packetDrops = startPacketDrops(options)
+	c.dnsQueries = startDNSQueries(options)
+	return c
+}
+
+// procPath returns the path of a file relative to the procfs root
+func (c *CosanetCollector) procPath(name string) string {
+	return filepath.Join(c.options.ProcRoot, name)
+}
+
+// splitList splits a comma separated list, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
 
 type CollectRequest struct {
 	Done chan bool
 	Feed chan<- prometheus.Metric
+	// Pod restricts the collection to a pod (namespace/name), off the cache
+	Pod string
 }
 
 // The kludge to perform collect from main thread
 func (c *CosanetCollector) Collect(ch chan<- prometheus.Metric) {
-	start := time.Now().UnixNano()
-	doneCh := make(chan bool)
-	defer close(doneCh)
-	c.chanToFeed <- CollectRequest{Done: doneCh, Feed: ch}
-	<-doneCh
-	durationMs := float64(time.Now().UnixNano()-start) / 1e6
-	slog.Info("CosanetCollector.Collect duration", slog.Float64("ms", durationMs))
+	c.emitWatchdog(ch)
+	c.requestMainThread(CollectRequest{}, ch)
+}
+
+// PodCollector returns a collector of the metrics of a single pod
+// (namespace/name), see CollectPodFromMainThread.
+func (c *CosanetCollector) PodCollector(pod string) prometheus.Collector {
+	return podCollector{c: c, pod: pod}
+}
+
+type podCollector struct {
+	c   *CosanetCollector
+	pod string
+}
+
+// Describe sends no descriptor: the collector is unchecked, the pod metrics
+// being a subset of the ones of CosanetCollector.
+func (pc podCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect requests the collection of the pod to the main thread.
+func (pc podCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.c.requestMainThread(CollectRequest{Pod: pc.pod}, ch)
 }
 
 // The kludge to perform collect from main thread
 func (c *CosanetCollector) CollectFromMainThread(ch chan<- prometheus.Metric) {
+	ch, emitAggregated := c.aggregateByNamespace(ch)
+	defer emitAggregated()
+	ch, emitPerController := c.aggregateByController(ch)
+	defer emitPerController()
+	c.watchdog.begin()
+	defer c.watchdog.end()
+	c.collect(ch)
+}
+
+// collect collects every netns at once, the metrics being aggregated by the
+// caller
+func (c *CosanetCollector) collect(ch chan<- prometheus.Metric) {
+	start := c.phaseTimer.reset()
+	defer func() { c.emitCollectionStats(time.Since(start), ch) }()
 
 	// Save the current network namespace
 	origns, _ := netns.Get()
 	defer origns.Close()
 
-	infos, err := listSandboxes()
-	if err != nil {
-		slog.Error("failed to list sandboxes", slog.Any("err", err))
-		os.Exit(1)
+	if c.options.Mode == ModeSidecar {
+		c.collectSidecar(origns, ch)
+		return
 	}
-	for _, info := range infos {
-		composedPodName := fmt.Appendf(nil, "%s/%s", info.Namespace, info.Name)
-		if !c.podFilter.Match(composedPodName) {
-			slog.Debug(
-				"sandbox skipped due to PodFilter",
-				slog.String("name", info.Name),
-				slog.String("namespace", info.Namespace),
-				slog.String("composedpodname", string(composedPodName)),
-				slog.String("filter", c.podFilter.String()),
-			)
-			continue
-		}
-		nsHandle, err := netns.GetFromPid(info.PID)
+
+	round := c.beginRound(origns, ch)
+	for _, info := range round.pods {
+		round.collectPod(c, info, ch)
+	}
+	c.endRound(round, ch)
+}
+
+// collectionRound is a collection between its beginning, listing the
+// sandboxes, and its end, once its pods are collected
+type collectionRound struct {
+	origns  netns.NsHandle
+	report  *podsReport
+	records *netnsRecords
+	skipped map[string]int
+	// Pods to collect, followed by the named netns without a pod
+	pods []PodInfo
+	// Failure listing the sandboxes, only the host being collected
+	listErr error
+}
+
+// beginRound lists the sandboxes to collect and emits the node wide stats
+// of the discovery
+func (c *CosanetCollector) beginRound(origns netns.NsHandle, ch chan<- prometheus.Metric) *collectionRound {
+	if c.options.Veth.Enabled {
+		hostDevices, err := hostDeviceNames()
 		if err != nil {
-			slog.Error(
-				"failed to get network namespace for PID",
-				slog.Int("pid", info.PID),
-				slog.Any("err", err),
-			)
-			continue
+			slog.Error("failed to list host interfaces", slog.Any("err", err))
 		}
+		c.hostDevices = hostDevices
+	}
 
-		if err := netns.Set(nsHandle); err != nil {
-			slog.Error(
-				"failed to switch to network namespace",
-				slog.Int("pid", info.PID),
-				slog.Any("err", err),
-			)
-			nsHandle.Close()
-			continue
+	// On CRI failure, keep going with host metrics only
+	c.phaseTimer.begin(phaseListSandboxes)
+	infos, err := c.listSandboxes(c.shard)
+	c.phaseTimer.stop()
+	if err != nil {
+		slog.Error("failed to list sandboxes", slog.Any("err", err))
+	}
+	round := &collectionRound{
+		origns:  origns,
+		report:  newPodsReport(infos),
+		skipped: make(map[string]int, len(skipReasons)),
+		listErr: err,
+	}
+	for _, counters := range c.bpfCounters() {
+		counters.snapshot()
+	}
+	if c.recordNetns() {
+		round.records = &netnsRecords{collectedAt: round.report.collectedAt}
+	}
+	c.emitCRIConnectionState(ch)
+	c.emitCRICallStats(ch)
+	c.emitResolverStats(ch)
+	c.emitLogSuppressed(ch)
+	round.pods = selectPods(c.filterPods(infos, round.report), c.shard, c.options.MaxPods)
+	// Without the sandboxes, the pods named netns can't be told apart. Like
+	// the host, they are collected by the first shard only.
+	if c.options.Discovery.NamedNetns && err == nil && c.shard.primary() {
+		named, err := c.listUnownedNamedNetns(infos)
+		if err != nil {
+			slog.Error("failed to list the named network namespaces", slog.Any("err", err))
 		}
+		round.pods = append(round.pods, named...)
+	}
+	return round
+}
 
-		c.collectStatsInNETNS(info, ch)
-		if err := netns.Set(origns); err != nil {
-			slog.Error(
-				"failed to switch back to the original network namespace",
-				slog.Any("err", err),
-			)
-			os.Exit(1)
-		}
-		nsHandle.Close()
+// collectPod collects a pod of the round, counting it when skipped
+func (r *collectionRound) collectPod(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) {
+	if reason := c.collectPod(info, r.origns, r.report, r.records, ch); reason != "" {
+		r.skipped[reason]++
 	}
-	if c.options.CollectHost.Enabled {
-		c.collectStatsInNETNS(
+}
+
+// endRound collects the host and publishes the outcome of the round. The
+// host and node wide metrics are left to the first shard.
+func (c *CosanetCollector) endRound(round *collectionRound, ch chan<- prometheus.Metric) {
+	primary := c.shard.primary()
+	if c.options.CollectHost.Enabled && primary {
+		c.collectNetnsStats(
 			PodInfo{
-				Namespace: "HOST",
-				netNSPath: "HOST",
-				netNSName: "HOST",
+				Namespace:   "HOST",
+				HostNetwork: true,
+				netNSPath:   "HOST",
+				netNSName:   "HOST",
+				netNSID:     round.origns.UniqueId(),
 			},
+			round.records,
 			ch,
 		)
 	}
+	if c.options.Softnet.Enabled && primary {
+		if err := c.collectAndEmitSoftnet(ch); err != nil {
+			slog.Error("error while parsing softnet_stat", slog.Any("err", err))
+		}
+	}
+	if c.options.Neighbor.Enabled && primary {
+		if err := c.collectAndEmitNeighborGCThresholds(ch); err != nil {
+			slog.Error("error while reading neighbor gc thresholds", slog.Any("err", err))
+		}
+	}
+	c.conntrackPool.sweep()
+	c.conntrackEventWatchers.sweep()
+	c.conntrackSaturation.sweep()
+	for _, counters := range c.bpfCounters() {
+		counters.sweep()
+	}
+	if round.listErr == nil {
+		c.ready.Store(true)
+	}
+	c.emitSkippedSandboxes(round.skipped, ch)
+	if round.listErr == nil {
+		c.watchdog.endRound(round.pods)
+	}
+	if round.records != nil {
+		c.lastNetns.set(round.records)
+	}
+	c.lastPods.set(round.report)
 }
 
-func (c *CosanetCollector) collectStatsInNETNS(info PodInfo, ch chan<- prometheus.Metric) {
+// collectPod collects the metrics of a sandbox from its netns, returning the
+// reason it was skipped, empty when it wasn't
+func (c *CosanetCollector) collectPod(
+	info PodInfo,
+	origns netns.NsHandle,
+	report *podsReport,
+	records *netnsRecords,
+	ch chan<- prometheus.Metric,
+) string {
+	defer c.phaseTimer.stop()
+	c.watchdog.enter(info)
+	c.emitContainers(info, ch)
+	// hostNetwork pods share the host counters, only emitted once by the host entry
+	if info.HostNetwork {
+		report.decide(info, podDecisionHostNetwork, "")
+		c.emitHostNetworkPod(info, ch)
+		return ""
+	}
+	if c.watchdog.isAbandoned(info) {
+		report.decide(info, podDecisionSkipped, skipReasonStalled)
+		return skipReasonStalled
+	}
+	if info.skipReason != "" {
+		report.decide(info, podDecisionSkipped, info.skipReason)
+		slog.Debug(
+			"sandbox skipped",
+			slog.String("name", info.Name),
+			slog.String("namespace", info.Namespace),
+			slog.String("reason", info.skipReason),
+		)
+		return info.skipReason
+	}
+	c.phaseTimer.begin(phaseNetnsSwitch)
+	nsHandle, err := c.podNetns(info)
+	if err != nil {
+		slog.Error(
+			"failed to get network namespace of the sandbox",
+			slog.Int("pid", info.PID),
+			slog.String("path", info.netNSPath),
+			slog.Any("err", err),
+		)
+		report.decide(info, podDecisionSkipped, skipReasonNetnsUnavailable)
+		return skipReasonNetnsUnavailable
+	}
+	info.netNSID = nsHandle.UniqueId()
+	if nsHandle.Equal(origns) {
+		info.HostNetwork = true
+		report.decide(info, podDecisionHostNetwork, "")
+		c.emitHostNetworkPod(info, ch)
+		nsHandle.Close()
+		return ""
+	}
 
-	if c.options.Conntrack.Enabled {
-		err := c.collectAndEmitConntrackStats(info, ch)
+	if err := netns.Set(nsHandle); err != nil {
+		slog.Error(
+			"failed to switch to network namespace",
+			slog.Int("pid", info.PID),
+			slog.Any("err", err),
+		)
+		report.decide(info, podDecisionSkipped, skipReasonNetnsUnavailable)
+		nsHandle.Close()
+		return skipReasonNetnsUnavailable
+	}
+
+	report.decide(info, podDecisionCollected, "")
+	c.collectNetnsStats(info, records, ch)
+	c.phaseTimer.begin(phaseNetnsSwitch)
+	if err := netns.Set(origns); err != nil {
+		slog.Error(
+			"failed to switch back to the original network namespace",
+			slog.Any("err", err),
+		)
+		os.Exit(1)
+	}
+	nsHandle.Close()
+	return ""
+}
+
+// CollectPodFromMainThread collects the metrics of a single pod (namespace/name)
+// regardless of the pod filters, sharding and max pods, for debugging a
+// workload. The node wide metrics are left out.
+func (c *CosanetCollector) CollectPodFromMainThread(pod string, ch chan<- prometheus.Metric) {
+	namespace, name, _ := strings.Cut(pod, "/")
+	ch, emitAggregated := c.aggregateByNamespace(ch)
+	defer emitAggregated()
+	ch, emitPerController := c.aggregateByController(ch)
+	defer emitPerController()
+	c.watchdog.begin()
+	defer c.watchdog.end()
+
+	// Save the current network namespace
+	origns, _ := netns.Get()
+	defer origns.Close()
+
+	if c.options.Mode == ModeSidecar {
+		info := c.sidecarPodInfo()
+		if info.Namespace == namespace && info.Name == name {
+			info.netNSID = origns.UniqueId()
+			c.collectNetnsStats(info, nil, ch)
+		}
+		return
+	}
+
+	if c.options.Veth.Enabled {
+		hostDevices, err := hostDeviceNames()
 		if err != nil {
-			slog.Error(
-				"error while collecting conntrack stats",
-				slog.String("name", info.Name),
-				slog.String("namespace", info.Namespace),
-				slog.Any("err", err),
-			)
+			slog.Error("failed to list host interfaces", slog.Any("err", err))
 		}
+		c.hostDevices = hostDevices
 	}
 
-	// Socket stats per proto
-	if c.options.SockProto.Enabled {
-		sockprotoToCollect := strings.Split(c.options.SockProto.Protos, ",")
-		for _, sockproto := range []string{"tcp", "udp", "icmp", "udplite", "raw"} {
-			if !slices.Contains(sockprotoToCollect, sockproto) {
-				slog.Debug(
-					"socket proto skipped, not in collect list",
-					slog.String("name", info.Name),
-					slog.String("namespace", info.Namespace),
-					slog.String("sockproto", sockproto),
-					slog.Any("collectlist", sockprotoToCollect),
-				)
-				continue
-			}
-			_, _, err := c.collectAndEmitSockStats(info, sockproto, ch)
-			if err != nil {
-				slog.Error(
-					"socket proto stats fetch failed",
-					slog.String("name", info.Name),
-					slog.String("namespace", info.Namespace),
-					slog.String("sockproto", sockproto),
-					slog.Any("err", err),
-				)
-			}
+	infos, err := c.listSandboxes(podShard{})
+	if err != nil {
+		slog.Error("failed to list sandboxes", slog.Any("err", err))
+		return
+	}
+	for _, counters := range c.bpfCounters() {
+		counters.snapshot()
+	}
+	for _, info := range infos {
+		if info.Namespace == namespace && info.Name == name {
+			// Not part of the /debug/pods report of the last full scrape
+			c.collectPod(info, origns, nil, nil, ch)
 		}
 	}
+}
 
-	if c.options.Snmp.Enabled {
-		snmp_stats, err := procnet_2l_parser.Parse2LFile("/proc/net/snmp")
-		if err == nil {
-			c.publishProcNet("snmp", snmp_stats, info, ch, c.snmpMetricFilter)
-		} else {
-			slog.Error(
-				"error while parsing snmp",
+// collectNetnsStats collects the stats of the current netns, recording its
+// metrics when records isn't nil
+func (c *CosanetCollector) collectNetnsStats(info PodInfo, records *netnsRecords, ch chan<- prometheus.Metric) {
+	c.watchdog.enter(info)
+	if records == nil {
+		c.collectStatsInNETNS(info, ch)
+		return
+	}
+	teeCh, done := records.tee(info, ch)
+	c.collectStatsInNETNS(info, teeCh)
+	done()
+}
+
+// filterPods returns the sandboxes matching the pod filters, recording the
+// filtered ones in the report
+func (c *CosanetCollector) filterPods(infos []PodInfo, report *podsReport) []PodInfo {
+	filtered := make([]PodInfo, 0, len(infos))
+	for _, info := range infos {
+		if reason := c.filterReason(info); reason != "" {
+			slog.Debug(
+				"sandbox filtered out",
 				slog.String("name", info.Name),
 				slog.String("namespace", info.Namespace),
-				slog.Any("err", err),
+				slog.String("reason", reason),
 			)
+			report.decide(info, podDecisionFiltered, reason)
+			continue
 		}
+		filtered = append(filtered, info)
+	}
+	return filtered
+}
+
+// filterReason tells why the sandbox doesn't match the pod filters, empty
+// when it does
+func (c *CosanetCollector) filterReason(info PodInfo) string {
+	if ok, reason := c.podFilter.Match(info.Namespace, info.Name); !ok {
+		return "pod filter: " + reason
+	}
+	podAnnotations := c.podAnnotations(info)
+	if ok, reason := c.podAnnotationFilter.Match(podAnnotations); !ok {
+		return "scrape annotation: " + reason
+	}
+	if !c.podLabelSelector.Empty() && !c.podLabelSelector.Matches(labels.Set(c.podLabels(info))) {
+		return "pod label selector: " + c.podLabelSelector.String()
+	}
+	if !c.podAnnotationSelector.Empty() && !c.podAnnotationSelector.Matches(labels.Set(podAnnotations)) {
+		return "pod annotation selector: " + c.podAnnotationSelector.String()
+	}
+	return ""
+}
+
+// Reasons of cosanet_skipped_sandboxes
+const (
+	// VM runtime sandbox without a host side netns path
+	skipReasonVMNetnsUnknown = "vm_netns_unknown"
+	// Neither the PID nor the path of the netns could be opened
+	skipReasonNetnsUnavailable = "netns_unavailable"
+)
+
+var skipReasons = []string{skipReasonVMNetnsUnknown, skipReasonNetnsUnavailable, skipReasonStalled}
+
+// emitSkippedSandboxes publishes the number of sandboxes not collected by
+// reason, every reason being emitted to keep the series stable
+func (c *CosanetCollector) emitSkippedSandboxes(skipped map[string]int, ch chan<- prometheus.Metric) {
+	for _, reason := range skipReasons {
+		ch <- c.skippedSandboxesDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(skipped[reason]),
+			reason,
+			c.nodename,
+		)
+	}
+}
+
+// emitHostNetworkPod marks a pod living in the host network namespace, its
+// network counters being the ones of the host entry
+func (c *CosanetCollector) emitHostNetworkPod(info PodInfo, ch chan<- prometheus.Metric) {
+	slog.Debug(
+		"sandbox uses the host network, not collected on its own",
+		slog.String("name", info.Name),
+		slog.String("namespace", info.Namespace),
+	)
+	ch <- c.hostNetworkPodDesc.mustNewConstMetric(
+		prometheus.GaugeValue,
+		1,
+		c.netnsLabelValues(info)...,
+	)
+}
+
+// listSandboxes discovers the pods through the configured backend, the CRI
+// one leaving out the sandboxes out of shard (the zero value selects all)
+func (c *CosanetCollector) listSandboxes(shard podShard) ([]PodInfo, error) {
+	if c.kubelet != nil {
+		return c.kubelet.listSandboxes()
+	}
+	return c.cri.listSandboxes(shard)
+}
 
-		snmp6_stats, err := procnet_v6_parser.ParseV6File("/proc/net/snmp6")
+// podNetns opens the network namespace of the pod from its PID. Runtimes
+// reporting pid 0 (or a PID already gone) fall back to the netns path of the
+// runtime spec, looked up in the host mount namespace when not mounted here.
+func (c *CosanetCollector) podNetns(info PodInfo) (netns.NsHandle, error) {
+	var err error
+	if info.PID > 0 {
+		handle, pidErr := netns.GetFromPid(info.PID)
+		if pidErr == nil {
+			return handle, nil
+		}
+		err = pidErr
+	}
+	if info.netNSPath == "" || info.netNSPath == "HOST" {
 		if err == nil {
-			c.publishProcNet("snmp6", snmp6_stats, info, ch, c.snmpMetricFilter)
-		} else {
+			err = errors.New("sandbox has neither a PID nor a network namespace path")
+		}
+		return netns.None(), err
+	}
+	handle, pathErr := netns.GetFromPath(info.netNSPath)
+	if errors.Is(pathErr, fs.ErrNotExist) {
+		handle, pathErr = netns.GetFromPath(c.procPath(filepath.Join("1/root", info.netNSPath)))
+	}
+	if pathErr != nil {
+		return netns.None(), errors.Join(err, pathErr)
+	}
+	return handle, nil
+}
+
+// podLabels returns the pod's Kubernetes labels, from the resolver's informer
+// when available, falling back to the labels propagated to the CRI sandbox.
+func (c *CosanetCollector) podLabels(info PodInfo) map[string]string {
+	if podLabels, found := c.controller_resolver.GetPodLabels(info.Namespace, info.Name); found {
+		return podLabels
+	}
+	return info.Labels
+}
+
+// podAnnotations returns the pod's Kubernetes annotations, from the resolver's
+// informer when available, falling back to the annotations of the CRI sandbox.
+// Only the informer sees the annotations set after the pod creation.
+func (c *CosanetCollector) podAnnotations(info PodInfo) map[string]string {
+	if podAnnotations, found := c.controller_resolver.GetPodAnnotations(info.Namespace, info.Name); found {
+		return podAnnotations
+	}
+	return info.Annotations
+}
+
+func (c *CosanetCollector) collectStatsInNETNS(info PodInfo, ch chan<- prometheus.Metric) {
+	// The collectors time adds up in cosanet_collector_duration_seconds
+	defer c.phaseTimer.stop()
+
+	info.missingIPFamilies = c.detectMissingIPFamilies()
+	c.emitIPFamilies(info, ch)
+	for _, collector := range netnsCollectors {
+		if !collector.enabled(c, info) {
+			continue
+		}
+		c.phaseTimer.begin(collector.name)
+		c.watchdog.advance()
+		// The tracers unavailable were logged at startup
+		if err := collector.collect(c, info, ch); err != nil && !errors.Is(err, errTracerUnavailable) {
 			slog.Error(
-				"error while parsing snmp6",
+				"collector failed",
+				slog.String("collector", collector.name),
 				slog.String("name", info.Name),
 				slog.String("namespace", info.Namespace),
 				slog.Any("err", err),
 			)
 		}
 	}
+}
 
-	if c.options.Netstat.Enabled {
-		netstat_stats, err := procnet_2l_parser.Parse2LFile("/proc/net/netstat")
-		if err == nil {
-			c.publishProcNet("netstat", netstat_stats, info, ch, c.netstatMetricFilter)
-		} else {
+// collectAndEmitSockProtos collects the socket stats of the configured
+// protos, a proto failing doesn't prevent the other ones from being collected
+func (c *CosanetCollector) collectAndEmitSockProtos(info PodInfo, ch chan<- prometheus.Metric) error {
+	sockprotoToCollect := strings.Split(c.options.SockProto.Protos, ",")
+	var errs []error
+	for _, sockproto := range knownSockProtos {
+		if !slices.Contains(sockprotoToCollect, sockproto) {
+			slog.Debug(
+				"socket proto skipped, not in collect list",
+				slog.String("name", info.Name),
+				slog.String("namespace", info.Namespace),
+				slog.String("sockproto", sockproto),
+				slog.Any("collectlist", sockprotoToCollect),
+			)
+			continue
+		}
+		err := c.collectAndEmitSockStats(info, sockproto, ch)
+		if err != nil {
 			slog.Error(
-				"error while parsing netstat",
+				"socket proto stats fetch failed",
 				slog.String("name", info.Name),
 				slog.String("namespace", info.Namespace),
+				slog.String("sockproto", sockproto),
 				slog.Any("err", err),
 			)
+			errs = append(errs, fmt.Errorf("%s: %w", sockproto, err))
 		}
+	}
+	return errors.Join(errs...)
+}
 
+// newSnmpParsers returns the parsers of /proc/net/snmp and /proc/net/snmp6
+func (c *CosanetCollector) newSnmpParsers() (*procnet_2l_parser.Parser, *procnet_v6_parser.Parser) {
+	snmpInclude := procNetInclude(c.snmpMetricFilter)
+	if c.options.Snmp.IcmpMsg {
+		snmpInclude = icmpMsgInclude(snmpInclude)
 	}
+	return procnet_2l_parser.NewParser(snmpInclude), procnet_v6_parser.NewParser(procNetInclude(c.snmpMetricFilter))
+}
 
+// newNetstatParser returns the parser of /proc/net/netstat
+func (c *CosanetCollector) newNetstatParser() *procnet_2l_parser.Parser {
+	return procnet_2l_parser.NewParser(procNetInclude(c.netstatMetricFilter))
 }
 
-func (c *CosanetCollector) collectAndEmitConntrackStats(info PodInfo, ch chan<- prometheus.Metric) error {
-	dynamic_labels := []string{
-		"cosanet_node",
-		"cosanet_pod",
-		"cosanet_namespace",
-		"cosanet_netnsname",
-	}
-	dynamic_values := []string{
-		c.nodename,
-		info.Name,
-		info.Namespace,
-		info.netNSName,
+// collectAndEmitSnmp collects /proc/net/snmp and /proc/net/snmp6 with the
+// given parsers, a file failing doesn't prevent the other one from being
+// collected
+func (c *CosanetCollector) collectAndEmitSnmp(
+	snmpParser *procnet_2l_parser.Parser,
+	snmp6Parser *procnet_v6_parser.Parser,
+	info PodInfo,
+	ch chan<- prometheus.Metric,
+) error {
+	var errs []error
+	if info.hasIPFamily("ipv4") {
+		snmp_stats, err := snmpParser.ParseFile(c.procPath("net/snmp"))
+		if err == nil {
+			c.publishProcNet("snmp", snmp_stats, info, ch)
+			if c.options.Snmp.IcmpMsg {
+				c.publishIcmpMsg(snmp_stats[icmpMsgSection], info, ch)
+			}
+		} else {
+			errs = append(errs, fmt.Errorf("snmp: %w", err))
+		}
 	}
 
-	ctrlref, found := c.controller_resolver.GetControllerForUid(info.UID)
-	if found {
-		dynamic_labels = append(dynamic_labels, "cosanet_pod_controller_kind", "cosanet_pod_controller_name")
-		dynamic_values = append(dynamic_values, ctrlref.Kind, ctrlref.Name)
+	// Missing without IPv6 in the kernel
+	if info.hasIPFamily("ipv6") {
+		snmp6_stats, err := snmp6Parser.ParseFile(c.procPath("net/snmp6"))
+		if err == nil {
+			c.publishProcNet("snmp6", snmp6_stats, info, ch)
+		} else {
+			errs = append(errs, fmt.Errorf("snmp6: %w", err))
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	cntck, err := conntrack.Dial(nil)
-	if err != nil {
-		return err
+// publishProcNet emits the counters of a /proc/net file, the parsers having
+// already dropped the ones excluded by the metric filter
+func (c *CosanetCollector) publishProcNet(source string, stats map[string]map[string]uint64, info PodInfo, ch chan<- prometheus.Metric) {
+	labelValues := c.netnsLabelValues(info)
+
+	for proto, metrics := range stats {
+		if c.icmpMsgFamily(source, proto) {
+			continue
+		}
+		for metric, value := range metrics {
+			ch <- c.procNetDesc(source, proto, metric).mustNewConstMetric(
+				prometheus.UntypedValue,
+				float64(value),
+				labelValues...,
+			)
+		}
 	}
-	defer cntck.Close()
+}
 
-	statsg, err := cntck.StatsGlobal()
-	if err != nil {
-		return err
-	}
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			"cosanet_conntrack_curr",
-			"Number of entries in the conntrack table",
-			dynamic_labels,
-			nil,
-		),
-		prometheus.UntypedValue,
-		float64(statsg.Entries),
-		dynamic_values...,
-	)
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			"cosanet_conntrack_max",
-			"Maximum entries in the conntrack table",
-			dynamic_labels,
-			nil,
-		),
-		prometheus.UntypedValue,
-		float64(statsg.MaxEntries),
-		dynamic_values...,
-	)
-	return nil
+type sockFamily struct {
+	name   string
+	family uint8
+}
+
+// IP families the sockproto collector handles, named after cosanet_ipversion
+var knownSockFamilies = []sockFamily{
+	{"ipv4", unix.AF_INET},
+	{"ipv6", unix.AF_INET6},
 }
 
-func (c *CosanetCollector) publishProcNet(source string, stats map[string]map[string]int, info PodInfo, ch chan<- prometheus.Metric, filter regexp.Regexp) {
-	dynamic_labels := []string{
-		"cosanet_node",
-		"cosanet_pod",
-		"cosanet_namespace",
-		"cosanet_netnsname",
+// mustParseSockFamilies returns the families of the comma separated list,
+// panicking on an unknown one. An empty list selects every family.
+func mustParseSockFamilies(list string) []sockFamily {
+	names := splitList(list)
+	if len(names) == 0 {
+		return knownSockFamilies
 	}
-	dynamic_values := []string{
-		c.nodename,
-		info.Name,
-		info.Namespace,
-		info.netNSName,
+	var families []sockFamily
+	for _, family := range knownSockFamilies {
+		if slices.Contains(names, family.name) {
+			families = append(families, family)
+		}
 	}
-
-	ctrlref, found := c.controller_resolver.GetControllerForUid(info.UID)
-	if found {
-		dynamic_labels = append(dynamic_labels, "cosanet_pod_controller_kind", "cosanet_pod_controller_name")
-		dynamic_values = append(dynamic_values, ctrlref.Kind, ctrlref.Name)
+	for _, name := range names {
+		if !slices.ContainsFunc(knownSockFamilies, func(f sockFamily) bool { return f.name == name }) {
+			panic(fmt.Errorf("unknown socket family %q, expected ipv4 or ipv6", name))
+		}
 	}
+	return families
+}
 
-	for proto, metrics := range stats {
-		for metric, value := range metrics {
-			motif := fmt.Appendf(nil, "%s_%s", proto, metric)
-			if !filter.Match(motif) {
-				slog.Debug(
-					"metric skipped due to filter",
-					slog.String("name", info.Name),
-					slog.String("namespace", info.Namespace),
-					slog.String("proto_metric", string(motif)),
-					slog.String("source", source),
-					slog.Any("filter", filter.String()),
-				)
-				continue
-			}
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					fmt.Sprintf("cosanet_proc_net_%s_%s_%s", source, proto, metric),
-					fmt.Sprintf("/proc/net/%s %s %s entry", source, proto, metric),
-					dynamic_labels,
-					nil,
-				),
-				prometheus.UntypedValue,
-				float64(value),
-				dynamic_values...,
-			)
+// Socket types having local ports, the other ones get no per port counts
+var sockPortProtos = []string{"tcp", "udp", "udplite"}
+
+// mustParseSockPorts returns the sorted ports of the comma separated list,
+// panicking on an invalid one
+func mustParseSockPorts(list string) []uint16 {
+	var ports []uint16
+	for _, item := range splitList(list) {
+		port, err := strconv.ParseUint(item, 10, 16)
+		if err != nil || port == 0 {
+			panic(fmt.Errorf("invalid socket port %q", item))
+		}
+		if !slices.Contains(ports, uint16(port)) {
+			ports = append(ports, uint16(port))
 		}
 	}
+	slices.Sort(ports)
+	return ports
 }
 
 type statscollcouple struct {
-	v4 func() (netstat.SocketStats, error)
-	v6 func() (netstat.SocketStats, error)
+	v4 func(string, ...uint16) (netstat.SocketStats, error)
+	v6 func(string, ...uint16) (netstat.SocketStats, error)
 }
 
-func (c *CosanetCollector) collectAndEmitSockStats(info PodInfo, socktype string, ch chan<- prometheus.Metric) (netstat.SocketStats, netstat.SocketStats, error) {
+const (
+	SockProtoBackendNetlink = "netlink"
+	SockProtoBackendProcfs  = "procfs"
+)
+
+// IP protocols of the socket types INET_DIAG can dump (no ping sockets)
+var sockDiagProtocols = map[string]uint8{
+	"tcp":     unix.IPPROTO_TCP,
+	"udp":     unix.IPPROTO_UDP,
+	"udplite": unix.IPPROTO_UDPLITE,
+	"raw":     unix.IPPROTO_RAW,
+}
+
+// sockStats returns the socket state counts of a family, and of the given
+// local ports, through INET_DIAG when enabled and supported, from the procfs
+// tables otherwise
+func (c *CosanetCollector) sockStats(
+	socktype string,
+	family uint8,
+	fromProcfs func(string, ...uint16) (netstat.SocketStats, error),
+	ports []uint16,
+) (netstat.SocketStats, error) {
+	if protocol, ok := sockDiagProtocols[socktype]; ok && c.options.SockProto.Backend == SockProtoBackendNetlink {
+		stats, err := netstat.DiagStats(family, protocol, ports...)
+		if err == nil {
+			return stats, nil
+		}
+		slog.Debug(
+			"netlink socket dump failed, falling back to procfs",
+			slog.String("socktype", socktype),
+			slog.Int("family", int(family)),
+			slog.Any("err", err),
+		)
+	}
+	return fromProcfs(c.options.ProcRoot, ports...)
+}
+
+func (c *CosanetCollector) collectAndEmitSockStats(info PodInfo, socktype string, ch chan<- prometheus.Metric) error {
 	var callbacks statscollcouple
 	switch socktype {
 	case "tcp":
@@ -393,200 +1381,61 @@ func (c *CosanetCollector) collectAndEmitSockStats(info PodInfo, socktype string
 		}
 
 	default:
-		return nil, nil, fmt.Errorf("unrecognized socket type: %s", socktype)
+		return fmt.Errorf("unrecognized socket type: %s", socktype)
 	}
 
-	statsv4, err := callbacks.v4()
-	if err != nil {
-		slog.Error(
-			"failed to collect IPv4 stats",
-			slog.String("name", info.Name),
-			slog.String("namespace", info.Namespace),
-			slog.String("socktype", socktype),
-			slog.Any("err", err),
-		)
-		return nil, nil, err
+	desc := c.sockProtoDescs[socktype]
+	labelValues := c.netnsLabelValues(info)
+	var ports []uint16
+	if slices.Contains(sockPortProtos, socktype) {
+		ports = c.sockPorts
 	}
 
-	statsv6, err := callbacks.v6()
-	if err != nil {
-		slog.Error(
-			"failed to collect IPv6 stats",
-			slog.String("name", info.Name),
-			slog.String("namespace", info.Namespace),
-			slog.String("socktype", socktype),
-			slog.Any("err", err),
-		)
-		return nil, nil, err
-	}
-
-	dynamic_labels := []string{
-		"cosanet_state",
-		"cosanet_ipversion",
-		"cosanet_node",
-		"cosanet_pod",
-		"cosanet_namespace",
-		"cosanet_netnsname",
-	}
-	dynamic_values := []string{
-		c.nodename,
-		info.Name,
-		info.Namespace,
-		info.netNSName,
-	}
-
-	ctrlref, found := c.controller_resolver.GetControllerForUid(info.UID)
-	if found {
-		dynamic_labels = append(dynamic_labels, "cosanet_pod_controller_kind", "cosanet_pod_controller_name")
-		dynamic_values = append(dynamic_values, ctrlref.Kind, ctrlref.Name)
-	}
-
-	for state, value := range statsv4 {
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("cosanet_proc_net_%s", socktype),
-				fmt.Sprintf("Socket statistics for %s", socktype),
-				dynamic_labels,
-				nil,
-			),
-			prometheus.UntypedValue,
-			float64(value),
-			append([]string{state, "ipv4"}, dynamic_values...)...,
-		)
-	}
-
-	for state, value := range statsv6 {
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				fmt.Sprintf("cosanet_proc_net_%s", socktype),
-				fmt.Sprintf("Socket statistics for %s", socktype),
-				dynamic_labels,
-				nil,
-			),
-			prometheus.UntypedValue,
-			float64(value),
-			append([]string{state, "ipv6"}, dynamic_values...)...,
-		)
-	}
-	return statsv4, statsv6, nil
-}
-
-type podSandboxStatusInfo struct {
-	PID         int `json:"pid"`
-	RuntimeSpec struct {
-		Linux struct {
-			Namespaces []struct {
-				Type string `json:"type"`
-				Path string `json:"path"`
-			}
-		} `json:"linux"`
-	} `json:"runtimeSpec"`
-}
-
-func (p *podSandboxStatusInfo) getNetworkNamespaceName() string {
-	path := p.getNetworkNamespacePath()
-	idx := strings.LastIndex(path, "/")
-	if idx == -1 {
-		return path
-	}
-	return path[idx+1:]
-}
-
-func (p *podSandboxStatusInfo) getNetworkNamespacePath() string {
-	for _, ns := range p.RuntimeSpec.Linux.Namespaces {
-		if ns.Type == "network" {
-			return ns.Path
+	// A family failing doesn't prevent the other one from being collected
+	var errs []error
+	for _, family := range c.sockFamilies {
+		if !info.hasIPFamily(family.name) {
+			continue
 		}
-	}
-	return "HOST"
-}
-
-func listSandboxes() ([]PodInfo, error) {
-	// List of possible containerd socket paths
-	socketPath, err := getCRISocketPath()
-	if err != nil {
-		return nil, err
-	}
-	conn, err := grpc.NewClient(
-		"unix://"+socketPath,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		slog.Error("Failed to create gRPC client", slog.Any("err", err))
-		return nil, err
-	}
-	defer conn.Close()
-
-	client := criruntime.NewRuntimeServiceClient(conn)
-	filter := &criruntime.PodSandboxFilter{
-		State: &criruntime.PodSandboxStateValue{
-			State: criruntime.PodSandboxState_SANDBOX_READY,
-		},
-	}
-	req := &criruntime.ListPodSandboxRequest{Filter: filter}
-	resp, err := client.ListPodSandbox(context.Background(), req)
-	if err != nil {
-		slog.Error("Failed to list pod sandboxes", slog.Any("err", err))
-		return nil, err
-	}
-
-	sandboxes := resp.Items
-	var podInfos []PodInfo
-
-	for _, sb := range sandboxes {
-		statusReq := &criruntime.PodSandboxStatusRequest{
-			PodSandboxId: sb.Id,
-			Verbose:      true,
+		fromProcfs := callbacks.v4
+		if family.family == unix.AF_INET6 {
+			fromProcfs = callbacks.v6
 		}
-		statusResp, err := client.PodSandboxStatus(context.Background(), statusReq)
+		stats, err := c.sockStats(socktype, family.family, fromProcfs, ports)
 		if err != nil {
-			slog.Error("Failed to get pod sandbox status", slog.Any("err", err))
+			errs = append(errs, fmt.Errorf("%s: %w", family.name, err))
 			continue
 		}
-
-		jsonpayload_as_text := statusResp.Info["info"]
-
-		var podInfo podSandboxStatusInfo
-		err = json.Unmarshal([]byte(jsonpayload_as_text), &podInfo)
-		if err != nil {
-			slog.Warn("unable to unmarshal CRI's podInfo", slog.Any("err", err))
+		for state, value := range stats.States {
+			ch <- desc.mustNewConstMetric(
+				prometheus.UntypedValue,
+				float64(value),
+				append([]string{state, family.name}, labelValues...)...,
+			)
 		}
-
-		podInfos = append(podInfos, PodInfo{
-			PID:       podInfo.PID,
-			netNSPath: podInfo.getNetworkNamespacePath(),
-			netNSName: podInfo.getNetworkNamespaceName(),
-			UID:       statusResp.Status.Metadata.Uid,
-			Name:      statusResp.Status.Metadata.Name,
-			Namespace: statusResp.Status.Metadata.Namespace,
-		})
-	}
-
-	return podInfos, nil
-}
-
-func getCRISocketPath() (string, error) {
-	socketPaths := []string{
-		"/run/k3s/containerd/containerd.sock",
-		"/var/run/containerd/containerd.sock",
-		"/run/containerd/containerd.sock",
-		"/var/run/dockershim.sock",
-		"/run/crio/crio.sock",
-	}
-
-	if crisocket := os.Getenv("CRI_SOCKET"); crisocket != "" {
-		slog.Info("searching for cri socket: using provided path", slog.String("path", crisocket))
-		socketPaths = []string{crisocket}
-	}
-
-	for _, path := range socketPaths {
-		if stat, err := os.Stat(path); err == nil {
-			if stat.Mode()&os.ModeSocket != 0 {
-				slog.Info("Found containerd socket", slog.String("path", path))
-				return path, nil
+		queues := map[string]netstat.QueueStats{"rx": stats.RxQueue, "tx": stats.TxQueue}
+		for queue, queueStats := range queues {
+			queueLabelValues := append([]string{queue, family.name}, labelValues...)
+			ch <- c.sockQueueDescs[socktype].mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(queueStats.Total),
+				queueLabelValues...,
+			)
+			ch <- c.sockQueueMaxDescs[socktype].mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(queueStats.Max),
+				queueLabelValues...,
+			)
+		}
+		for port, states := range stats.Ports {
+			for state, value := range states {
+				ch <- c.sockPortDescs[socktype].mustNewConstMetric(
+					prometheus.GaugeValue,
+					float64(value),
+					append([]string{strconv.Itoa(int(port)), state, family.name}, labelValues...)...,
+				)
 			}
 		}
 	}
-
-	return "", fmt.Errorf("no containerd socket file found in usual places or provided path %v", socketPaths)
+	return errors.Join(errs...)
 }
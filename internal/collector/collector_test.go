@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestMustParseSockFamilies(t *testing.T) {
+	assert.Equal(t, knownSockFamilies, mustParseSockFamilies(""))
+	assert.Equal(t, knownSockFamilies, mustParseSockFamilies("ipv6, ipv4"))
+	assert.Equal(t, []sockFamily{{"ipv6", unix.AF_INET6}}, mustParseSockFamilies("ipv6"))
+	assert.Panics(t, func() { mustParseSockFamilies("ipv5") })
+}
+
+func TestMustParseBuckets(t *testing.T) {
+	assert.Equal(t, []float64{0.001, 0.5, 1}, mustParseBuckets("1, 0.001,0.5,1"))
+	assert.Nil(t, mustParseBuckets(""))
+	assert.Panics(t, func() { mustParseBuckets("0.1,fast") })
+}
+
+func TestMustParseSkMemProtos(t *testing.T) {
+	assert.Equal(t, []string{"tcp", "udp"}, mustParseSkMemProtos("udp, tcp,udp"))
+	assert.Nil(t, mustParseSkMemProtos(""))
+	assert.Panics(t, func() { mustParseSkMemProtos("icmp") })
+}
+
+func TestMustParseSockPorts(t *testing.T) {
+	assert.Equal(t, []uint16{5432, 8080}, mustParseSockPorts("8080, 5432,8080"))
+	assert.Nil(t, mustParseSockPorts(""))
+	assert.Panics(t, func() { mustParseSockPorts("http") })
+	assert.Panics(t, func() { mustParseSockPorts("0") })
+	assert.Panics(t, func() { mustParseSockPorts("65536") })
+}
+
+func TestProcNetMetricName(t *testing.T) {
+	assert.Equal(t, "cosanet_proc_net_snmp6_UdpLite6_InErrors", procNetMetricName("snmp6", "UdpLite6", "InErrors"))
+	// Unknown snmp6 section
+	assert.Equal(t, "cosanet_proc_net_snmp6_Foo6InBars", procNetMetricName("snmp6", "", "Foo6InBars"))
+}
+
+func TestProcNetInclude(t *testing.T) {
+	include := procNetInclude(regexp.MustCompile("^(Tcp_|Foo6InBars$)"))
+	assert.True(t, include("Tcp", "ActiveOpens"))
+	assert.False(t, include("Udp", "InDatagrams"))
+	// Unknown snmp6 section
+	assert.True(t, include("", "Foo6InBars"))
+}
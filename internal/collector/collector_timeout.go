@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netns"
+)
+
+// Sub-collectors run under CosanetCollectorOptions.CollectorTimeout, the
+// values of cosanet_collector_success
+const (
+	subCollectorConntrack = "conntrack"
+	subCollectorSockProto = "sockproto"
+	subCollectorSnmp      = "snmp"
+	subCollectorNetstat   = "netstat"
+)
+
+// eBPF collectors, always reporting cosanet_collector_success as their
+// tracer may fail to start
+const (
+	subCollectorPacketDrops = "packet_drops"
+	subCollectorDNS         = "dns"
+)
+
+var (
+	errCollectorTimeout = errors.New("collector timed out")
+	errCollectorPending = errors.New("collector still running in the netns since its last timeout")
+)
+
+// pendingRun is a sub-collector run in a netns
+type pendingRun struct {
+	collector string
+	netns     string
+}
+
+// pendingCollectors are the sub-collector runs left running past their
+// timeout, per netns. A sub-collector isn't run again in the netns until it
+// returns, the other netns being collected meanwhile: the abandoned run keeps
+// its parsers, replaced for the next runs, and its conntrack connection is
+// discarded.
+type pendingCollectors struct {
+	mu      sync.Mutex
+	pending map[pendingRun]bool
+}
+
+func newPendingCollectors() *pendingCollectors {
+	return &pendingCollectors{pending: make(map[pendingRun]bool)}
+}
+
+// start marks the collector as running in the netns, false when it already is
+func (p *pendingCollectors) start(run pendingRun) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending[run] {
+		return false
+	}
+	p.pending[run] = true
+	return true
+}
+
+func (p *pendingCollectors) done(run pendingRun) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, run)
+}
+
+// collectWithTimeout runs the sub-collector, abandoning it past the collector
+// timeout with the metrics emitted so far, and reports whether it succeeded
+// with cosanet_collector_success. It is skipped while a previous run in the
+// netns is still pending. Without timeout it runs as is.
+func (c *CosanetCollector) collectWithTimeout(
+	collector string,
+	info PodInfo,
+	ch chan<- prometheus.Metric,
+	collect func(ch chan<- prometheus.Metric) error,
+) error {
+	if c.options.CollectorTimeout <= 0 {
+		return collect(ch)
+	}
+	err := errCollectorPending
+	run := pendingRun{collector: collector, netns: info.netNSID}
+	if c.pendingCollectors.start(run) {
+		err = runWithTimeout(c.options.CollectorTimeout, ch, collect, func() {
+			c.pendingCollectors.done(run)
+		})
+	}
+	c.emitCollectorSuccess(collector, info, err, ch)
+	return err
+}
+
+// emitCollectorSuccess publishes whether the sub-collector succeeded in the
+// netns
+func (c *CosanetCollector) emitCollectorSuccess(collector string, info PodInfo, err error, ch chan<- prometheus.Metric) {
+	var success float64
+	if err == nil {
+		success = 1
+	}
+	ch <- c.collectorSuccessDesc.mustNewConstMetric(
+		prometheus.GaugeValue,
+		success,
+		append([]string{collector}, c.netnsLabelValues(info)...)...,
+	)
+}
+
+// runWithTimeout runs collect on its own thread, in the netns of the calling
+// one, forwarding its metrics to ch until the timeout. Past it, collect is
+// left running: its late metrics are dropped and its thread, never unlocked,
+// exits with it instead of going back to the scheduler in the netns. finished
+// is called once collect returned, timed out or not.
+func runWithTimeout(
+	timeout time.Duration,
+	ch chan<- prometheus.Metric,
+	collect func(ch chan<- prometheus.Metric) error,
+	finished func(),
+) error {
+	handle, err := netns.Get()
+	if err != nil {
+		finished()
+		return err
+	}
+	collectCh := make(chan prometheus.Metric)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(collectCh)
+		defer finished()
+		runtime.LockOSThread()
+		err := setThreadNetns(handle)
+		handle.Close()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- collect(collectCh)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case m, ok := <-collectCh:
+			if !ok {
+				return <-errCh
+			}
+			ch <- m
+		case <-timer.C:
+			go func() {
+				for range collectCh {
+				}
+			}()
+			return fmt.Errorf("%w after %s", errCollectorTimeout, timeout)
+		}
+	}
+}
+
+// setThreadNetns moves the current thread to the netns unless already in it
+func setThreadNetns(handle netns.NsHandle) error {
+	current, err := netns.Get()
+	if err != nil {
+		return err
+	}
+	defer current.Close()
+	if current.Equal(handle) {
+		return nil
+	}
+	return netns.Set(handle)
+}
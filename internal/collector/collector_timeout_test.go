@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestCollectWithTimeout(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+		pendingCollectors:   newPendingCollectors(),
+	}
+	c.options.CollectorTimeout = 50 * time.Millisecond
+	c.collectorSuccessDesc = c.newCollectorSuccessDesc()
+	c.conntrackCurrDesc = c.newConntrackCurrDesc()
+	info := PodInfo{Name: "web", Namespace: "default", netNSID: "NS(4:4026532001)"}
+
+	collect := func(collector string, fn func(ch chan<- prometheus.Metric) error) ([]prometheus.Metric, error) {
+		ch := make(chan prometheus.Metric, 10)
+		err := c.collectWithTimeout(collector, info, ch, fn)
+		close(ch)
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		return metrics, err
+	}
+	success := func(m prometheus.Metric) float64 {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		assert.Equal(t, "web", metricLabels(t, m)["cosanet_pod"])
+		return out.GetGauge().GetValue()
+	}
+	emit := func(ch chan<- prometheus.Metric) {
+		ch <- c.conntrackCurrDesc.mustNewConstMetric(prometheus.UntypedValue, 1, c.netnsLabelValues(info)...)
+	}
+
+	metrics, err := collect(subCollectorSnmp, func(ch chan<- prometheus.Metric) error {
+		emit(ch)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, 1.0, success(metrics[1]))
+
+	failure := errors.New("unreadable")
+	metrics, err = collect(subCollectorSnmp, func(ch chan<- prometheus.Metric) error { return failure })
+	assert.ErrorIs(t, err, failure)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 0.0, success(metrics[0]))
+
+	// The metrics emitted before the timeout are kept
+	unblock := make(chan struct{})
+	returned := make(chan struct{})
+	metrics, err = collect(subCollectorConntrack, func(ch chan<- prometheus.Metric) error {
+		defer close(returned)
+		emit(ch)
+		<-unblock
+		emit(ch)
+		return nil
+	})
+	assert.ErrorIs(t, err, errCollectorTimeout)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, 0.0, success(metrics[1]))
+
+	// Not run again while pending, the other collectors are
+	metrics, err = collect(subCollectorConntrack, func(ch chan<- prometheus.Metric) error {
+		t.Error("pending collector run again")
+		return nil
+	})
+	assert.ErrorIs(t, err, errCollectorPending)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 0.0, success(metrics[0]))
+	_, err = collect(subCollectorNetstat, func(ch chan<- prometheus.Metric) error { return nil })
+	assert.NoError(t, err)
+	// Nor in the other netns
+	other := info
+	other.netNSID = "NS(4:4026532002)"
+	otherCh := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.collectWithTimeout(subCollectorConntrack, other, otherCh, func(ch chan<- prometheus.Metric) error {
+		return nil
+	}))
+
+	// Its late metrics are dropped, it runs again once it returned
+	close(unblock)
+	<-returned
+	assert.Eventually(t, func() bool {
+		_, err := collect(subCollectorConntrack, func(ch chan<- prometheus.Metric) error { return nil })
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCollectWithTimeout_Disabled(t *testing.T) {
+	c := &CosanetCollector{}
+	ch := make(chan prometheus.Metric, 1)
+	failure := errors.New("unreadable")
+	err := c.collectWithTimeout(subCollectorSnmp, PodInfo{}, ch, func(ch chan<- prometheus.Metric) error { return failure })
+	assert.ErrorIs(t, err, failure)
+	assert.Empty(t, ch, "no success metric without timeout")
+}
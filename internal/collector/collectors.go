@@ -0,0 +1,220 @@
+package collector
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// collectorEntry is a collector enabled through -collectors or its own
+// enable flag. Adding one here registers both.
+type collectorEntry struct {
+	name string
+	help string
+	// flag is the enable flag, collector.<name>.enabled when empty
+	flag           string
+	defaultEnabled bool
+	option         func(options *CosanetCollectorOptions) *bool
+}
+
+func (e collectorEntry) flagName() string {
+	if e.flag != "" {
+		return e.flag
+	}
+	return "collector." + e.name + ".enabled"
+}
+
+// collectorEntries are named after the collectors of netnsCollectors, as in
+// cosanet_collector_duration_seconds
+var collectorEntries = []collectorEntry{
+	{
+		name:           subCollectorConntrack,
+		help:           "conntrack table stats (curr and max)",
+		flag:           "collector.connstrack.enabled",
+		defaultEnabled: true,
+		option:         func(o *CosanetCollectorOptions) *bool { return &o.Conntrack.Enabled },
+	},
+	{
+		name:           subCollectorSnmp,
+		help:           "/proc/net/snmp and snmp6 counters",
+		defaultEnabled: true,
+		option:         func(o *CosanetCollectorOptions) *bool { return &o.Snmp.Enabled },
+	},
+	{
+		name:           subCollectorNetstat,
+		help:           "/proc/net/netstat counters",
+		defaultEnabled: true,
+		option:         func(o *CosanetCollectorOptions) *bool { return &o.Netstat.Enabled },
+	},
+	{
+		name:   "xfrm",
+		help:   "/proc/net/xfrm_stat (IPsec) counters, requires CONFIG_XFRM_STATISTICS",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Xfrm.Enabled },
+	},
+	{
+		name:   subCollectorSockProto,
+		help:   "per socket protocol states stats (/proc/net/{tcp,udp,icmp,udplite,raw}{,6})",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.SockProto.Enabled },
+	},
+	{
+		name:   "unix",
+		help:   "unix domain sockets count per type and state (/proc/net/unix)",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Unix.Enabled },
+	},
+	{
+		name:   "netlink_sockets",
+		help:   "netlink sockets count per protocol (/proc/net/netlink)",
+		flag:   "collector.netlink-sockets.enabled",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.NetlinkSockets.Enabled },
+	},
+	{
+		name:   "packet",
+		help:   "AF_PACKET sockets count per type and protocol (/proc/net/packet)",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Packet.Enabled },
+	},
+	{
+		name:   "tcpinfo",
+		help:   "tcp_info of the established TCP sockets (INET_DIAG): RTT, retransmits and congestion states",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.TCPInfo.Enabled },
+	},
+	{
+		name:   "skmem",
+		help:   "sockets memory counters (INET_DIAG): rmem_alloc, wmem_alloc and backlog summed per protocol",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.SkMem.Enabled },
+	},
+	{
+		name:   "netdev",
+		help:   "per interface stats (/proc/net/dev)",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Netdev.Enabled },
+	},
+	{
+		name:   "link",
+		help:   "per interface link attributes (operstate, carrier, carrier changes and MTU) through netlink, using the netdev device filters",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Link.Enabled },
+	},
+	{
+		name:   "qdisc",
+		help:   "per interface tc qdisc stats (backlog, drops, overlimits, requeues) through netlink, using the netdev device filters",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Qdisc.Enabled },
+	},
+	{
+		name:   "ethtool",
+		help:   "host interfaces driver stats and link speed through ethtool, using the netdev device filters",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Ethtool.Enabled },
+	},
+	{
+		name:   "bridge",
+		help:   "host bridges FDB entries and VLANs count through netlink, using the netdev device filters",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Bridge.Enabled },
+	},
+	{
+		name:   "veth",
+		help:   "info metric mapping the pods veths to their host side peer",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Veth.Enabled },
+	},
+	{
+		name:   "multicast",
+		help:   "joined multicast groups count per interface (/proc/net/igmp and /proc/net/igmp6), using the netdev device filters",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Multicast.Enabled },
+	},
+	{
+		name:   "wireguard",
+		help:   "per peer WireGuard stats (bytes, last handshake age, allowed IPs) through generic netlink",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Wireguard.Enabled },
+	},
+	{
+		name:   "neighbor",
+		help:   "neighbor (ARP/NDP) table entries count per state through netlink, and the host gc_thresh sysctls",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Neighbor.Enabled },
+	},
+	{
+		name:   "addresses",
+		help:   "interfaces count and IP addresses count per family and scope through netlink",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Addresses.Enabled },
+	},
+	{
+		name:   "sysctl",
+		help:   "net sysctls values per netns",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Sysctl.Enabled },
+	},
+	{
+		name:   "softnet",
+		help:   "host per CPU packet processing stats (/proc/net/softnet_stat)",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Softnet.Enabled },
+	},
+	{
+		name:   "containers",
+		help:   "info metric per running container of the pods, listed through CRI or the kubelet",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.Containers.Enabled },
+	},
+	{
+		name:   "packet_drops",
+		help:   "dropped packets per reason with eBPF (skb:kfree_skb tracepoint)",
+		flag:   "collector.packet-drops.enabled",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.PacketDrops.Enabled },
+	},
+	{
+		name:   "dns",
+		help:   "DNS queries and responses per response code over UDP with eBPF (kprobes)",
+		option: func(o *CosanetCollectorOptions) *bool { return &o.DNS.Enabled },
+	},
+}
+
+// RegisterCollectorFlags registers the enable flag of every collector, the
+// ones -collectors overrides
+func RegisterCollectorFlags(fs *flag.FlagSet, options *CosanetCollectorOptions) {
+	for _, entry := range collectorEntries {
+		fs.BoolVar(
+			entry.option(options),
+			entry.flagName(),
+			entry.defaultEnabled,
+			fmt.Sprintf("enable the %s collector: %s (see -collectors)", entry.name, entry.help),
+		)
+	}
+}
+
+// mustApplyCollectors enables the collectors of options.Collectors and
+// disables the others, leaving the enable flags as is when empty
+func mustApplyCollectors(options *CosanetCollectorOptions) {
+	if err := applyCollectors(options); err != nil {
+		panic(err)
+	}
+}
+
+func applyCollectors(options *CosanetCollectorOptions) error {
+	if strings.TrimSpace(options.Collectors) == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(options.Collectors, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(collectorEntries, func(e collectorEntry) bool { return e.name == name }) {
+			return fmt.Errorf("unknown collector %q in -collectors, see -list-collectors", name)
+		}
+		names = append(names, name)
+	}
+	for _, entry := range collectorEntries {
+		*entry.option(options) = slices.Contains(names, entry.name)
+	}
+	return nil
+}
+
+// ListCollectors writes the collectors with whether they are enabled by the
+// options, their default and description
+func ListCollectors(w io.Writer, options CosanetCollectorOptions) error {
+	if err := applyCollectors(&options); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tENABLED\tDEFAULT\tDESCRIPTION")
+	for _, entry := range collectorEntries {
+		fmt.Fprintf(tw, "%s\t%t\t%t\t%s\n", entry.name, *entry.option(&options), entry.defaultEnabled, entry.help)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCollectorFlags(t *testing.T) {
+	var options CosanetCollectorOptions
+	fs := flag.NewFlagSet("cosanet", flag.ContinueOnError)
+	RegisterCollectorFlags(fs, &options)
+	require.NoError(t, fs.Parse([]string{"-collector.snmp.enabled=false", "-collector.netlink-sockets.enabled"}))
+	assert.True(t, options.Conntrack.Enabled, "enabled by default")
+	assert.False(t, options.Snmp.Enabled)
+	assert.True(t, options.NetlinkSockets.Enabled)
+	assert.NotNil(t, fs.Lookup("collector.connstrack.enabled"), "the historical name is kept")
+}
+
+func TestApplyCollectors(t *testing.T) {
+	var options CosanetCollectorOptions
+	options.Conntrack.Enabled = true
+	options.Collectors = " netdev, addresses,,packet_drops "
+	mustApplyCollectors(&options)
+	assert.False(t, options.Conntrack.Enabled, "disabled when not listed")
+	assert.True(t, options.Netdev.Enabled)
+	assert.True(t, options.Addresses.Enabled)
+	assert.True(t, options.PacketDrops.Enabled)
+
+	// Empty keeps the enable flags
+	options = CosanetCollectorOptions{}
+	options.Snmp.Enabled = true
+	mustApplyCollectors(&options)
+	assert.True(t, options.Snmp.Enabled)
+
+	options.Collectors = "netdev,conntrak"
+	assert.Panics(t, func() { mustApplyCollectors(&options) })
+}
+
+func TestCollectorEntries(t *testing.T) {
+	names := make(map[string]bool)
+	for _, entry := range collectorEntries {
+		assert.False(t, names[entry.name], "duplicate %s", entry.name)
+		names[entry.name] = true
+	}
+	// The netns collectors share the names of their enable list entry, but
+	// procextra, configured by its file
+	for _, collector := range netnsCollectors {
+		if collector.name != "procextra" {
+			assert.True(t, names[collector.name], collector.name)
+		}
+	}
+}
+
+func TestListCollectors(t *testing.T) {
+	var out bytes.Buffer
+	var options CosanetCollectorOptions
+	options.Collectors = "dns"
+	require.NoError(t, ListCollectors(&out, options))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, len(collectorEntries)+1)
+	assert.Equal(t, []string{"conntrack", "false", "true"}, strings.Fields(lines[1])[:3])
+	assert.Equal(t, []string{"dns", "true", "false"}, strings.Fields(lines[len(lines)-1])[:3])
+
+	options.Collectors = "nope"
+	assert.Error(t, ListCollectors(&out, options))
+}
@@ -0,0 +1,321 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ti-mo/conntrack"
+)
+
+// Names of the conntrack per-CPU statistics, in conntrackStatValues order
+var conntrackStatNames = [...]string{
+	"found",
+	"invalid",
+	"ignore",
+	"insert",
+	"insert_failed",
+	"drop",
+	"early_drop",
+	"error",
+	"search_restart",
+}
+
+func conntrackStatValues(s conntrack.Stats) [len(conntrackStatNames)]uint32 {
+	return [...]uint32{
+		s.Found,
+		s.Invalid,
+		s.Ignore,
+		s.Insert,
+		s.InsertFailed,
+		s.Drop,
+		s.EarlyDrop,
+		s.Error,
+		s.SearchRestart,
+	}
+}
+
+// Names of the l4 protocols commonly tracked, others are exposed by number
+var conntrackProtoNames = map[uint8]string{
+	1:   "icmp",
+	6:   "tcp",
+	17:  "udp",
+	33:  "dccp",
+	47:  "gre",
+	58:  "icmpv6",
+	132: "sctp",
+	136: "udplite",
+}
+
+// TCP conntrack states, see enum tcp_conntrack in the kernel
+var conntrackTCPStates = []string{
+	"NONE",
+	"SYN_SENT",
+	"SYN_RECV",
+	"ESTABLISHED",
+	"FIN_WAIT",
+	"CLOSE_WAIT",
+	"LAST_ACK",
+	"TIME_WAIT",
+	"CLOSE",
+	"SYN_SENT2",
+}
+
+func conntrackProtoName(proto uint8) string {
+	if name, found := conntrackProtoNames[proto]; found {
+		return name
+	}
+	return strconv.Itoa(int(proto))
+}
+
+func conntrackTCPStateName(state uint8) string {
+	if int(state) < len(conntrackTCPStates) {
+		return conntrackTCPStates[state]
+	}
+	return strconv.Itoa(int(state))
+}
+
+type conntrackEntryKey struct {
+	proto    string
+	tcpState string
+}
+
+// Flow directions of the accounting counters
+const (
+	conntrackDirOrig  = 0
+	conntrackDirReply = 1
+)
+
+var conntrackDirNames = [...]string{"orig", "reply"}
+
+// conntrackDumpSummary is what is kept from a dump of the conntrack table
+type conntrackDumpSummary struct {
+	entries map[conntrackEntryKey]int
+	// Entries per conntrack zone, 0 being the default zone
+	zones map[uint16]int
+	// Accounting counters, only filled when nf_conntrack_acct is enabled
+	bytes   [2]uint64
+	packets [2]uint64
+}
+
+// summarizeConntrackFlows groups the flows by l4 protocol, and by conntrack
+// state for TCP, and sums their accounting counters per direction
+func summarizeConntrackFlows(flows []conntrack.Flow) conntrackDumpSummary {
+	summary := conntrackDumpSummary{
+		entries: make(map[conntrackEntryKey]int),
+		zones:   make(map[uint16]int),
+	}
+	for _, flow := range flows {
+		key := conntrackEntryKey{proto: conntrackProtoName(flow.TupleOrig.Proto.Protocol)}
+		if flow.ProtoInfo.TCP != nil {
+			key.tcpState = conntrackTCPStateName(flow.ProtoInfo.TCP.State)
+		}
+		summary.entries[key]++
+		summary.zones[flow.Zone]++
+		summary.bytes[conntrackDirOrig] += flow.CountersOrig.Bytes
+		summary.packets[conntrackDirOrig] += flow.CountersOrig.Packets
+		summary.bytes[conntrackDirReply] += flow.CountersReply.Bytes
+		summary.packets[conntrackDirReply] += flow.CountersReply.Packets
+	}
+	return summary
+}
+
+func (c *CosanetCollector) collectAndEmitConntrackStats(info PodInfo, ch chan<- prometheus.Metric) error {
+	if c.options.Conntrack.Backend == ConntrackBackendProcfs {
+		return c.collectAndEmitConntrackProcfs(info, ch, nil)
+	}
+	cntck, err := c.conntrackPool.get(info.netNSID)
+	if err != nil {
+		return c.collectAndEmitConntrackProcfs(info, ch, err)
+	}
+
+	statsg, err := cntck.StatsGlobal()
+	if err != nil {
+		c.conntrackPool.discard(info.netNSID)
+		return c.collectAndEmitConntrackProcfs(info, ch, err)
+	}
+	labelValues := c.netnsLabelValues(info)
+	ch <- c.conntrackCurrDesc.mustNewConstMetric(
+		prometheus.UntypedValue,
+		float64(statsg.Entries),
+		labelValues...,
+	)
+	ch <- c.conntrackMaxDesc.mustNewConstMetric(
+		prometheus.UntypedValue,
+		float64(statsg.MaxEntries),
+		labelValues...,
+	)
+
+	if c.options.Conntrack.Saturation.Threshold > 0 {
+		c.checkConntrackSaturation(info, statsg, labelValues, ch)
+	}
+
+	if c.options.Conntrack.Stats {
+		perCPU, err := cntck.Stats()
+		if err != nil {
+			c.conntrackPool.discard(info.netNSID)
+			return err
+		}
+		var total [len(conntrackStatNames)]float64
+		for _, cpu := range perCPU {
+			for i, value := range conntrackStatValues(cpu) {
+				total[i] += float64(value)
+			}
+		}
+		for i, desc := range c.conntrackStatDescs {
+			ch <- desc.mustNewConstMetric(prometheus.CounterValue, total[i], labelValues...)
+		}
+	}
+
+	// Zones are set up by the CNIs on the host side, pods netns are not dumped for them
+	zones := c.options.Conntrack.Zones && info.netNSPath == "HOST"
+	if c.options.Conntrack.Breakdown || c.options.Conntrack.Accounting.Enabled || zones {
+		flows, err := cntck.Dump(nil)
+		if err != nil {
+			c.conntrackPool.discard(info.netNSID)
+			return err
+		}
+		summary := summarizeConntrackFlows(flows)
+		if c.options.Conntrack.Breakdown {
+			for key, count := range summary.entries {
+				ch <- c.conntrackEntriesDesc.mustNewConstMetric(
+					prometheus.GaugeValue,
+					float64(count),
+					append([]string{key.proto, key.tcpState}, labelValues...)...,
+				)
+			}
+		}
+		if c.options.Conntrack.Accounting.Enabled {
+			c.emitConntrackAccounting(summary, labelValues, ch)
+		}
+		if zones {
+			for zone, count := range summary.zones {
+				ch <- c.conntrackZoneEntriesDesc.mustNewConstMetric(
+					prometheus.GaugeValue,
+					float64(count),
+					append([]string{strconv.Itoa(int(zone))}, labelValues...)...,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// emitConntrackAccounting publishes the accounting counters of the live flows,
+// split per direction if requested
+func (c *CosanetCollector) emitConntrackAccounting(summary conntrackDumpSummary, labelValues []string, ch chan<- prometheus.Metric) {
+	if !c.options.Conntrack.Accounting.SplitDirection {
+		ch <- c.conntrackBytesDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(summary.bytes[conntrackDirOrig]+summary.bytes[conntrackDirReply]),
+			labelValues...,
+		)
+		ch <- c.conntrackPacketsDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(summary.packets[conntrackDirOrig]+summary.packets[conntrackDirReply]),
+			labelValues...,
+		)
+		return
+	}
+	for dir, name := range conntrackDirNames {
+		dirLabelValues := append([]string{name}, labelValues...)
+		ch <- c.conntrackBytesDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(summary.bytes[dir]),
+			dirLabelValues...,
+		)
+		ch <- c.conntrackPacketsDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(summary.packets[dir]),
+			dirLabelValues...,
+		)
+	}
+}
+
+// saturationWatchdog remembers which namespaces are above the threshold to
+// only signal transitions. Locked for the collections abandoned past the
+// collector timeout.
+type saturationWatchdog struct {
+	mu        sync.Mutex
+	threshold float64
+	saturated map[string]bool
+	used      map[string]bool
+}
+
+func newSaturationWatchdog(threshold float64) *saturationWatchdog {
+	return &saturationWatchdog{
+		threshold: threshold,
+		saturated: make(map[string]bool),
+		used:      make(map[string]bool),
+	}
+}
+
+// check returns the fill ratio of the table, whether it is above the
+// threshold and whether the namespace just crossed it
+func (w *saturationWatchdog) check(nsID string, entries, max uint32) (float64, bool, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.used[nsID] = true
+	if max == 0 {
+		return 0, false, false
+	}
+	ratio := float64(entries) / float64(max)
+	saturated := ratio >= w.threshold
+	crossed := saturated && !w.saturated[nsID]
+	if saturated {
+		w.saturated[nsID] = true
+	} else {
+		delete(w.saturated, nsID)
+	}
+	return ratio, saturated, crossed
+}
+
+// sweep forgets the namespaces not seen since the last sweep
+func (w *saturationWatchdog) sweep() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for nsID := range w.saturated {
+		if !w.used[nsID] {
+			delete(w.saturated, nsID)
+		}
+	}
+	clear(w.used)
+}
+
+func (c *CosanetCollector) checkConntrackSaturation(info PodInfo, statsg conntrack.StatsGlobal, labelValues []string, ch chan<- prometheus.Metric) {
+	ratio, saturated, crossed := c.conntrackSaturation.check(info.netNSID, statsg.Entries, statsg.MaxEntries)
+	var value float64
+	if saturated {
+		value = 1
+	}
+	ch <- c.conntrackSaturationDesc.mustNewConstMetric(prometheus.GaugeValue, value, labelValues...)
+	if !crossed {
+		return
+	}
+
+	slog.Warn(
+		"conntrack table saturating",
+		slog.String("name", info.Name),
+		slog.String("namespace", info.Namespace),
+		slog.String("netns", info.netNSName),
+		slog.Uint64("entries", uint64(statsg.Entries)),
+		slog.Uint64("max", uint64(statsg.MaxEntries)),
+		slog.Float64("threshold", c.conntrackSaturation.threshold),
+	)
+	if c.options.Conntrack.Saturation.KubernetesEvents && info.UID != "" && !info.HostNetwork {
+		c.controller_resolver.RecordPodWarning(
+			info.Namespace,
+			info.Name,
+			info.UID,
+			"ConntrackSaturation",
+			fmt.Sprintf(
+				"conntrack table is %.0f%% full (%d/%d entries)",
+				ratio*100,
+				statsg.Entries,
+				statsg.MaxEntries,
+			),
+		)
+	}
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ti-mo/conntrack"
+	"github.com/ti-mo/netfilter"
+)
+
+// Size of the buffer between the netlink listener and the counting goroutine
+const conntrackEventBuffer = 1024
+
+// conntrackEventWatcher counts the flows created and destroyed in a network
+// namespace from the conntrack multicast events
+type conntrackEventWatcher struct {
+	conn      *conntrack.Conn
+	done      chan struct{}
+	failed    atomic.Bool
+	newFlows  atomic.Uint64
+	destroyed atomic.Uint64
+}
+
+// listen subscribes to the events, the current thread must be in the
+// namespace to watch
+func (w *conntrackEventWatcher) listen(nsID string) error {
+	conn, err := conntrack.Dial(nil)
+	if err != nil {
+		return err
+	}
+	events := make(chan conntrack.Event, conntrackEventBuffer)
+	errs, err := conn.Listen(events, 1, []netfilter.NetlinkGroup{netfilter.GroupCTNew, netfilter.GroupCTDestroy})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	w.conn = conn
+	w.done = make(chan struct{})
+	w.failed.Store(false)
+
+	go func(done chan struct{}) {
+		// Keep draining until stop, the listener worker must never block
+		// for Close to return
+		for {
+			select {
+			case ev := <-events:
+				switch ev.Type {
+				case conntrack.EventNew:
+					w.newFlows.Add(1)
+				case conntrack.EventDestroy:
+					w.destroyed.Add(1)
+				}
+			case err := <-errs:
+				slog.Warn(
+					"conntrack event listener failed, it will be restarted on next scrape",
+					slog.String("netns", nsID),
+					slog.Any("err", err),
+				)
+				w.failed.Store(true)
+			case <-done:
+				return
+			}
+		}
+	}(w.done)
+	return nil
+}
+
+func (w *conntrackEventWatcher) stop() {
+	w.conn.Close()
+	close(w.done)
+}
+
+// conntrackEventWatchers keeps one watcher per network namespace, keyed by
+// the namespace unique id like conntrackPool. Only used from the main
+// collection thread.
+type conntrackEventWatchers struct {
+	watchers map[string]*conntrackEventWatcher
+	used     map[string]bool
+}
+
+func newConntrackEventWatchers() *conntrackEventWatchers {
+	return &conntrackEventWatchers{
+		watchers: make(map[string]*conntrackEventWatcher),
+		used:     make(map[string]bool),
+	}
+}
+
+// get returns the watcher of the namespace, starting or restarting it from
+// the current namespace if needed. Counters survive restarts.
+func (ws *conntrackEventWatchers) get(nsID string) (*conntrackEventWatcher, error) {
+	ws.used[nsID] = true
+	w, found := ws.watchers[nsID]
+	if found && !w.failed.Load() {
+		return w, nil
+	}
+	if found {
+		w.stop()
+	} else {
+		w = &conntrackEventWatcher{}
+	}
+	if err := w.listen(nsID); err != nil {
+		delete(ws.watchers, nsID)
+		return nil, err
+	}
+	ws.watchers[nsID] = w
+	return w, nil
+}
+
+// sweep stops the watchers of namespaces not seen since the last sweep
+func (ws *conntrackEventWatchers) sweep() {
+	for nsID, w := range ws.watchers {
+		if !ws.used[nsID] {
+			slog.Debug("stopping conntrack event watcher of vanished netns", slog.String("netns", nsID))
+			w.stop()
+			delete(ws.watchers, nsID)
+		}
+	}
+	clear(ws.used)
+}
+
+func (c *CosanetCollector) collectAndEmitConntrackEvents(info PodInfo, ch chan<- prometheus.Metric) error {
+	w, err := c.conntrackEventWatchers.get(info.netNSID)
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	ch <- c.conntrackEventsDesc.mustNewConstMetric(
+		prometheus.CounterValue,
+		float64(w.newFlows.Load()),
+		append([]string{"new"}, labelValues...)...,
+	)
+	ch <- c.conntrackEventsDesc.mustNewConstMetric(
+		prometheus.CounterValue,
+		float64(w.destroyed.Load()),
+		append([]string{"destroy"}, labelValues...)...,
+	)
+	return nil
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/ti-mo/conntrack"
+)
+
+// conntrackPool keeps one conntrack netlink connection per network namespace
+// across scrapes. A netlink socket stays bound to the namespace it was created
+// in, so connections are keyed by the namespace unique id and must be dialed
+// while inside it. Locked for the collections abandoned past the collector
+// timeout.
+type conntrackPool struct {
+	mu    sync.Mutex
+	conns map[string]*conntrack.Conn
+	used  map[string]bool
+}
+
+func newConntrackPool() *conntrackPool {
+	return &conntrackPool{
+		conns: make(map[string]*conntrack.Conn),
+		used:  make(map[string]bool),
+	}
+}
+
+// get returns the connection of the namespace, dialing it from the current
+// namespace if needed
+func (p *conntrackPool) get(nsID string) (*conntrack.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.used[nsID] = true
+	if conn, found := p.conns[nsID]; found {
+		return conn, nil
+	}
+	conn, err := conntrack.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[nsID] = conn
+	return conn, nil
+}
+
+// discard closes a connection which returned an error, it will be dialed
+// again on next use
+func (p *conntrackPool) discard(nsID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close(nsID)
+}
+
+func (p *conntrackPool) close(nsID string) {
+	if conn, found := p.conns[nsID]; found {
+		conn.Close()
+		delete(p.conns, nsID)
+	}
+}
+
+// sweep closes the connections of namespaces not used since the last sweep,
+// typically the ones of deleted pods
+func (p *conntrackPool) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for nsID := range p.conns {
+		if !p.used[nsID] {
+			slog.Debug("closing conntrack connection of vanished netns", slog.String("netns", nsID))
+			p.close(nsID)
+		}
+	}
+	clear(p.used)
+}
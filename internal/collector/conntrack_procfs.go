@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ti-mo/conntrack"
+)
+
+// Conntrack backends
+const (
+	// ConntrackBackendNetlink queries conntrack through netlink, falling back
+	// to procfs when it fails (e.g. without CAP_NET_ADMIN)
+	ConntrackBackendNetlink = "netlink"
+	// ConntrackBackendProcfs reads the counters from procfs only, the table
+	// dumps being unavailable
+	ConntrackBackendProcfs = "procfs"
+)
+
+func mustParseConntrackBackend(backend string) string {
+	switch backend {
+	case "", ConntrackBackendNetlink:
+		return ConntrackBackendNetlink
+	case ConntrackBackendProcfs:
+		return backend
+	}
+	panic(fmt.Errorf("unknown conntrack backend %q, expected netlink or procfs", backend))
+}
+
+// conntrackProcStatColumns maps the /proc/net/stat/nf_conntrack columns to
+// their conntrackStatNames index. error is icmp_error there.
+var conntrackProcStatColumns = map[string]int{
+	"found":          0,
+	"invalid":        1,
+	"ignore":         2,
+	"insert":         3,
+	"insert_failed":  4,
+	"drop":           5,
+	"early_drop":     6,
+	"icmp_error":     7,
+	"search_restart": 8,
+}
+
+// parseConntrackProcStats sums the per-CPU rows of /proc/net/stat/nf_conntrack,
+// hexadecimal values under a header naming the columns. The columns vary
+// with the kernel version, the missing ones count 0.
+func parseConntrackProcStats(filename string) ([len(conntrackStatNames)]float64, error) {
+	var total [len(conntrackStatNames)]float64
+	file, err := os.Open(filename)
+	if err != nil {
+		return total, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return total, err
+		}
+		return total, fmt.Errorf("%s: missing header", filename)
+	}
+	columns := strings.Fields(scanner.Text())
+	for scanner.Scan() {
+		values := strings.Fields(scanner.Text())
+		if len(values) != len(columns) {
+			return total, fmt.Errorf("%s: %d values for %d columns", filename, len(values), len(columns))
+		}
+		for i, column := range columns {
+			index, ok := conntrackProcStatColumns[column]
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseUint(values[i], 16, 32)
+			if err != nil {
+				return total, fmt.Errorf("%s: %s: %w", filename, column, err)
+			}
+			total[index] += float64(value)
+		}
+	}
+	return total, scanner.Err()
+}
+
+func readUintFile(filename string) (uint64, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// collectAndEmitConntrackProcfs emits the conntrack gauges and statistics
+// from procfs. netlinkErr is the netlink failure it replaces, reported for
+// the table dumps procfs can't replace, nil with the procfs backend.
+func (c *CosanetCollector) collectAndEmitConntrackProcfs(info PodInfo, ch chan<- prometheus.Metric, netlinkErr error) error {
+	if netlinkErr != nil {
+		slog.Debug(
+			"conntrack netlink query failed, falling back to procfs",
+			slog.String("name", info.Name),
+			slog.String("namespace", info.Namespace),
+			slog.Any("err", netlinkErr),
+		)
+	}
+	count, err := readUintFile(c.procPath("sys/net/netfilter/nf_conntrack_count"))
+	if err != nil {
+		return errors.Join(netlinkErr, err)
+	}
+	labelValues := c.netnsLabelValues(info)
+	ch <- c.conntrackCurrDesc.mustNewConstMetric(prometheus.UntypedValue, float64(count), labelValues...)
+	var errs []error
+	max, err := readUintFile(c.procPath("sys/net/netfilter/nf_conntrack_max"))
+	if err == nil {
+		ch <- c.conntrackMaxDesc.mustNewConstMetric(prometheus.UntypedValue, float64(max), labelValues...)
+		if c.options.Conntrack.Saturation.Threshold > 0 {
+			statsg := conntrack.StatsGlobal{Entries: uint32(count), MaxEntries: uint32(max)}
+			c.checkConntrackSaturation(info, statsg, labelValues, ch)
+		}
+	} else {
+		errs = append(errs, err)
+	}
+
+	if c.options.Conntrack.Stats {
+		total, err := parseConntrackProcStats(c.procPath("net/stat/nf_conntrack"))
+		if err == nil {
+			for i, desc := range c.conntrackStatDescs {
+				ch <- desc.mustNewConstMetric(prometheus.CounterValue, total[i], labelValues...)
+			}
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	zones := c.options.Conntrack.Zones && info.netNSPath == "HOST"
+	if c.options.Conntrack.Breakdown || c.options.Conntrack.Accounting.Enabled || zones {
+		if netlinkErr == nil {
+			netlinkErr = errors.New("the procfs backend can't dump the table")
+		}
+		errs = append(errs, fmt.Errorf("conntrack table dump: %w", netlinkErr))
+	}
+	return errors.Join(errs...)
+}
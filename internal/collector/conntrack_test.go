@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ti-mo/conntrack"
+)
+
+func TestSummarizeConntrackFlows(t *testing.T) {
+	tcp := func(state uint8) conntrack.Flow {
+		var f conntrack.Flow
+		f.TupleOrig.Proto.Protocol = 6
+		f.ProtoInfo.TCP = &conntrack.ProtoInfoTCP{State: state}
+		return f
+	}
+	udp := conntrack.Flow{
+		Zone:          7,
+		CountersOrig:  conntrack.Counter{Packets: 2, Bytes: 100},
+		CountersReply: conntrack.Counter{Packets: 1, Bytes: 1000, Direction: true},
+	}
+	udp.TupleOrig.Proto.Protocol = 17
+	unknown := conntrack.Flow{}
+	unknown.TupleOrig.Proto.Protocol = 253
+
+	got := summarizeConntrackFlows([]conntrack.Flow{tcp(3), tcp(3), tcp(7), tcp(42), udp, udp, unknown})
+	assert.Equal(t, [2]uint64{200, 2000}, got.bytes)
+	assert.Equal(t, [2]uint64{4, 2}, got.packets)
+	assert.Equal(t, map[conntrackEntryKey]int{
+		{"tcp", "ESTABLISHED"}: 2,
+		{"tcp", "TIME_WAIT"}:   1,
+		{"tcp", "42"}:          1,
+		{"udp", ""}:            2,
+		{"253", ""}:            1,
+	}, got.entries)
+	assert.Equal(t, map[uint16]int{0: 5, 7: 2}, got.zones)
+}
+
+func TestSaturationWatchdog(t *testing.T) {
+	w := newSaturationWatchdog(0.8)
+
+	ratio, saturated, crossed := w.check("ns1", 50, 100)
+	assert.Equal(t, 0.5, ratio)
+	assert.False(t, saturated)
+	assert.False(t, crossed)
+
+	_, saturated, crossed = w.check("ns1", 80, 100)
+	assert.True(t, saturated)
+	assert.True(t, crossed)
+	_, saturated, crossed = w.check("ns1", 90, 100)
+	assert.True(t, saturated)
+	assert.False(t, crossed, "already signaled")
+
+	_, saturated, _ = w.check("ns1", 10, 100)
+	assert.False(t, saturated)
+	_, _, crossed = w.check("ns1", 95, 100)
+	assert.True(t, crossed, "signaled again after recovery")
+
+	_, saturated, _ = w.check("ns2", 0, 0)
+	assert.False(t, saturated)
+
+	w.sweep()
+	w.sweep()
+	assert.Empty(t, w.saturated)
+}
+
+func TestParseConntrackProcStats(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nf_conntrack")
+	content := "entries  clashres found new invalid ignore delete delete_list insert insert_failed drop early_drop icmp_error  expect_new expect_create expect_delete search_restart\n" +
+		"0000001c  00000000 00000000 00000000 0000000a 00000002 00000000 00000000 00000010 00000001 00000000 00000000 00000003  00000000 00000000 00000000 00000004\n" +
+		"0000001c  00000000 00000000 00000000 00000006 00000000 00000000 00000000 00000020 00000000 00000001 00000000 00000000  00000000 00000000 00000000 00000000\n"
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	total, err := parseConntrackProcStats(filename)
+	require.NoError(t, err)
+	assert.Equal(t, [len(conntrackStatNames)]float64{0, 16, 2, 48, 1, 1, 0, 3, 4}, total)
+
+	require.NoError(t, os.WriteFile(filename, []byte("entries found\n00000001\n"), 0o644))
+	_, err = parseConntrackProcStats(filename)
+	assert.Error(t, err)
+}
+
+func TestMustParseConntrackBackend(t *testing.T) {
+	assert.Equal(t, ConntrackBackendNetlink, mustParseConntrackBackend(""))
+	assert.Equal(t, ConntrackBackendProcfs, mustParseConntrackBackend("procfs"))
+	assert.Panics(t, func() { mustParseConntrackBackend("ebpf") })
+}
@@ -0,0 +1,312 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// containerdK8sNamespace holds the containers created through CRI, collected
+// by the cri discovery mode
+const containerdK8sNamespace = "k8s.io"
+
+// containerdNameLabel is the container name set by nerdctl
+const containerdNameLabel = "nerdctl/name"
+
+// containerd services methods, see github.com/containerd/containerd/api
+const (
+	containerdNamespacesList = "/containerd.services.namespaces.v1.Namespaces/List"
+	containerdContainersList = "/containerd.services.containers.v1.Containers/List"
+	containerdTasksList      = "/containerd.services.tasks.v1.Tasks/List"
+)
+
+// containerd.v1.types.Status RUNNING
+const containerdTaskRunning = 2
+
+type ContainerdOptions struct {
+	// Socket of the containerd API
+	Socket string
+	// Comma separated containerd namespaces, empty for all but k8s.io
+	Namespaces string
+}
+
+// rawCodec sends and receives the protobuf messages as encoded bytes, the few
+// containerd messages needed being hand decoded rather than pulling the
+// containerd API module
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = slices.Clone(data)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// protoFields calls fn with every field of the protobuf message b, value
+// being the content of the length delimited fields and number the varints
+func protoFields(b []byte, fn func(num protowire.Number, value []byte, number uint64)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, value, 0)
+			b = b[n:]
+		case protowire.VarintType:
+			number, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, nil, number)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// parseNamespaces decodes a ListNamespacesResponse into the namespace names
+func parseNamespaces(resp []byte) ([]string, error) {
+	var names []string
+	var errs []error
+	errs = append(errs, protoFields(resp, func(num protowire.Number, value []byte, _ uint64) {
+		if num != 1 {
+			return
+		}
+		errs = append(errs, protoFields(value, func(num protowire.Number, value []byte, _ uint64) {
+			if num == 1 {
+				names = append(names, string(value))
+			}
+		}))
+	}))
+	return names, errors.Join(errs...)
+}
+
+// containerdContainer is the subset of the containerd Container message used
+type containerdContainer struct {
+	ID     string
+	Labels map[string]string
+	Image  string
+	// OCI runtime spec JSON
+	Spec []byte
+}
+
+// parseContainers decodes a ListContainersResponse
+func parseContainers(resp []byte) ([]containerdContainer, error) {
+	var containers []containerdContainer
+	var errs []error
+	errs = append(errs, protoFields(resp, func(num protowire.Number, value []byte, _ uint64) {
+		if num != 1 {
+			return
+		}
+		ctr := containerdContainer{Labels: make(map[string]string)}
+		errs = append(errs, protoFields(value, func(num protowire.Number, value []byte, _ uint64) {
+			switch num {
+			case 1:
+				ctr.ID = string(value)
+			case 2:
+				var key, labelValue string
+				errs = append(errs, protoFields(value, func(num protowire.Number, value []byte, _ uint64) {
+					switch num {
+					case 1:
+						key = string(value)
+					case 2:
+						labelValue = string(value)
+					}
+				}))
+				ctr.Labels[key] = labelValue
+			case 3:
+				ctr.Image = string(value)
+			case 5:
+				// google.protobuf.Any, the spec JSON is its value
+				errs = append(errs, protoFields(value, func(num protowire.Number, value []byte, _ uint64) {
+					if num == 2 {
+						ctr.Spec = value
+					}
+				}))
+			}
+		}))
+		containers = append(containers, ctr)
+	}))
+	return containers, errors.Join(errs...)
+}
+
+// parseRunningTasks decodes a ListTasksResponse into the PIDs of the running
+// tasks per container id
+func parseRunningTasks(resp []byte) (map[string]int, error) {
+	pids := make(map[string]int)
+	var errs []error
+	errs = append(errs, protoFields(resp, func(num protowire.Number, value []byte, _ uint64) {
+		if num != 1 {
+			return
+		}
+		var containerID string
+		var pid, status uint64
+		errs = append(errs, protoFields(value, func(num protowire.Number, value []byte, number uint64) {
+			switch num {
+			case 1:
+				containerID = string(value)
+			case 3:
+				pid = number
+			case 4:
+				status = number
+			}
+		}))
+		if status == containerdTaskRunning && pid > 0 {
+			pids[containerID] = int(pid)
+		}
+	}))
+	return pids, errors.Join(errs...)
+}
+
+// podInfo maps a running container to a PodInfo, named after its nerdctl
+// name (else its short id) in its containerd namespace
+func (ctr containerdContainer) podInfo(namespace string, pid int) PodInfo {
+	name := ctr.Labels[containerdNameLabel]
+	if name == "" {
+		name = ctr.ID[:min(len(ctr.ID), 12)]
+	}
+	info := PodInfo{
+		PID:       pid,
+		UID:       ctr.ID,
+		Name:      name,
+		Namespace: namespace,
+		Labels:    ctr.Labels,
+	}
+	var status podSandboxStatusInfo
+	if json.Unmarshal(ctr.Spec, &status.RuntimeSpec) == nil {
+		info.netNSPath = status.getNetworkNamespacePath()
+	}
+	if info.netNSPath == "HOST" {
+		info.HostNetwork = true
+		info.netNSName = "HOST"
+	}
+	return info
+}
+
+// containerdCall invokes a containerd List method in namespace, empty for
+// the non namespaced services. name is the method in the calls stats.
+func (c *criClient) containerdCall(conn *grpc.ClientConn, name, method, namespace string) ([]byte, error) {
+	var resp []byte
+	err := c.withRetry(name, func(ctx context.Context) error {
+		if namespace != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "containerd-namespace", namespace)
+		}
+		// Empty requests: no filter
+		req := []byte{}
+		return conn.Invoke(ctx, method, &req, &resp, grpc.ForceCodec(rawCodec{}))
+	})
+	return resp, err
+}
+
+// containerdNamespaces returns the configured namespaces, else all of them
+// but the CRI one
+func (c *criClient) containerdNamespaces(conn *grpc.ClientConn) ([]string, error) {
+	if namespaces := splitList(c.discovery.Containerd.Namespaces); len(namespaces) > 0 {
+		return namespaces, nil
+	}
+	resp, err := c.containerdCall(conn, "ListNamespaces", containerdNamespacesList, "")
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := parseNamespaces(resp)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(namespaces, func(namespace string) bool {
+		return namespace == containerdK8sNamespace
+	}), nil
+}
+
+// listContainerdSandboxes lists the running containers of the containerd
+// namespaces, one per network namespace
+func (c *criClient) listContainerdSandboxes() ([]PodInfo, error) {
+	if c.containerdConn == nil {
+		conn, err := c.dialRuntime(c.discovery.Containerd.Socket)
+		if err != nil {
+			return nil, err
+		}
+		c.containerdConn = conn
+	}
+	conn := c.containerdConn
+	namespaces, err := c.containerdNamespaces(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var infos []PodInfo
+	for _, namespace := range namespaces {
+		resp, err := c.containerdCall(conn, "ListTasks", containerdTasksList, namespace)
+		if err != nil {
+			return nil, err
+		}
+		pids, err := parseRunningTasks(resp)
+		if err != nil {
+			return nil, err
+		}
+		if len(pids) == 0 {
+			continue
+		}
+		resp, err = c.containerdCall(conn, "ListContainers", containerdContainersList, namespace)
+		if err != nil {
+			return nil, err
+		}
+		containers, err := parseContainers(resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, ctr := range containers {
+			pid, running := pids[ctr.ID]
+			if !running {
+				continue
+			}
+			info := ctr.podInfo(namespace, pid)
+			if !info.HostNetwork {
+				inode, err := netnsInode(filepath.Join(c.procRoot, strconv.Itoa(pid), "ns/net"))
+				if err != nil {
+					slog.Debug("container process gone", slog.String("id", ctr.ID), slog.Any("err", err))
+					continue
+				}
+				info.netNSName = fmt.Sprintf("net:[%d]", inode)
+				// Containers joining another one's netns are collected once
+				if seen[info.netNSName] {
+					continue
+				}
+				seen[info.netNSName] = true
+			}
+			if c.withContainers {
+				info.Containers = []ContainerInfo{{ID: ctr.ID, Name: info.Name, Image: ctr.Image}}
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
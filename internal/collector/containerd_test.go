@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendVarint(b []byte, num protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func encodeContainer(id string, labels map[string]string, spec string) []byte {
+	var ctr []byte
+	ctr = appendString(ctr, 1, id)
+	for key, value := range labels {
+		ctr = appendMessage(ctr, 2, appendString(appendString(nil, 1, key), 2, value))
+	}
+	ctr = appendString(ctr, 3, "docker.io/library/alpine:3")
+	var specAny []byte
+	specAny = appendString(specAny, 1, "types.containerd.io/opencontainers/runtime-spec/1/Spec")
+	specAny = appendString(specAny, 2, spec)
+	ctr = appendMessage(ctr, 5, specAny)
+	// created_at, skipped
+	ctr = appendMessage(ctr, 8, appendVarint(nil, 1, 1700000000))
+	return appendMessage(nil, 1, ctr)
+}
+
+func encodeTask(containerID string, pid, status uint64) []byte {
+	var task []byte
+	task = appendString(task, 1, containerID)
+	task = appendString(task, 2, containerID)
+	task = appendVarint(task, 3, pid)
+	task = appendVarint(task, 4, status)
+	return appendMessage(nil, 1, task)
+}
+
+const netnsSpec = `{"linux": {"namespaces": [{"type": "pid"}, {"type": "network"}]}}`
+
+func TestParseContainerdMessages(t *testing.T) {
+	namespaces, err := parseNamespaces(append(
+		appendMessage(nil, 1, appendString(nil, 1, "default")),
+		appendMessage(nil, 1, appendString(nil, 1, "k8s.io"))...,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "k8s.io"}, namespaces)
+
+	containers, err := parseContainers(encodeContainer("abc", map[string]string{"nerdctl/name": "web"}, netnsSpec))
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "abc", containers[0].ID)
+	assert.Equal(t, map[string]string{"nerdctl/name": "web"}, containers[0].Labels)
+	assert.Equal(t, "docker.io/library/alpine:3", containers[0].Image)
+	assert.JSONEq(t, netnsSpec, string(containers[0].Spec))
+
+	pids, err := parseRunningTasks(append(encodeTask("abc", 42, containerdTaskRunning), encodeTask("def", 43, 3)...))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"abc": 42}, pids)
+
+	_, err = parseContainers([]byte{0x0a, 0x05, 0x01})
+	assert.Error(t, err)
+}
+
+func TestContainerdPodInfo(t *testing.T) {
+	ctr := containerdContainer{
+		ID:     "0123456789abcdef",
+		Labels: map[string]string{"nerdctl/name": "web"},
+		Spec:   []byte(netnsSpec),
+	}
+	info := ctr.podInfo("default", 42)
+	assert.Equal(t, "web", info.Name)
+	assert.Equal(t, "default", info.Namespace)
+	assert.Equal(t, "0123456789abcdef", info.UID)
+	assert.Equal(t, 42, info.PID)
+	assert.False(t, info.HostNetwork)
+
+	// Unnamed container of the host network
+	ctr = containerdContainer{ID: "0123456789abcdef", Spec: []byte(`{"linux": {"namespaces": [{"type": "pid"}]}}`)}
+	info = ctr.podInfo("moby", 42)
+	assert.Equal(t, "0123456789ab", info.Name)
+	assert.True(t, info.HostNetwork)
+	assert.Equal(t, "HOST", info.netNSName)
+}
+
+func TestListContainerdSandboxes(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "containerd.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	pid := uint64(os.Getpid())
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			var req []byte
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method == "/grpc.health.v1.Health/Watch" {
+				// SERVING
+				serving := appendVarint(nil, 1, 1)
+				if err := stream.SendMsg(&serving); err != nil {
+					return err
+				}
+				<-stream.Context().Done()
+				return nil
+			}
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			namespace := md.Get("containerd-namespace")
+			var resp []byte
+			switch {
+			case method == containerdNamespacesList:
+				for _, name := range []string{"default", "k8s.io"} {
+					resp = appendMessage(resp, 1, appendString(nil, 1, name))
+				}
+			case method == containerdTasksList && namespace[0] == "default":
+				resp = append(encodeTask("web", pid, containerdTaskRunning), encodeTask("sidecar", pid, containerdTaskRunning)...)
+			case method == containerdContainersList && namespace[0] == "default":
+				resp = append(
+					encodeContainer("web", map[string]string{"nerdctl/name": "web"}, netnsSpec),
+					encodeContainer("sidecar", nil, netnsSpec)...,
+				)
+			case method == containerdContainersList || method == containerdTasksList:
+				t.Errorf("unexpected namespace %v listed", namespace)
+			}
+			return stream.SendMsg(&resp)
+		}),
+	)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	c := &criClient{
+		options:   CRIOptions{Timeout: time.Second},
+		breakers:  make(map[string]*circuitBreaker),
+		limiter:   newCRIRateLimiter(0, 0),
+		stats:     newCRICallStats(),
+		procRoot:  "/proc",
+		discovery: DiscoveryOptions{Mode: DiscoveryModeContainerd, Containerd: ContainerdOptions{Socket: socketPath}},
+	}
+	t.Cleanup(func() {
+		if c.containerdConn != nil {
+			c.containerdConn.Close()
+		}
+	})
+	infos, err := c.listSandboxes(podShard{})
+	require.NoError(t, err)
+	// Both containers share the test process netns
+	require.Len(t, infos, 1)
+	assert.Equal(t, "web", infos[0].Name)
+	assert.Equal(t, "default", infos[0].Namespace)
+	assert.Regexp(t, `^net:\[\d+\]$`, infos[0].netNSName)
+	assert.Contains(t, c.connectionStates(), socketPath)
+}
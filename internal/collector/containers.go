@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// ContainerInfo is a running container of a sandbox, kept on the PodInfo for
+// the collectors able to attribute their metrics per container
+type ContainerInfo struct {
+	ID   string
+	Name string
+	// Image as written in the pod spec when the runtime knows it, else the
+	// image the runtime resolved
+	Image   string
+	ImageID string
+}
+
+func newContainerInfo(ctr *criruntime.Container) ContainerInfo {
+	image := ctr.GetImage().GetUserSpecifiedImage()
+	if image == "" {
+		image = ctr.GetImage().GetImage()
+	}
+	return ContainerInfo{
+		ID:      ctr.GetId(),
+		Name:    ctr.GetMetadata().GetName(),
+		Image:   image,
+		ImageID: ctr.GetImageRef(),
+	}
+}
+
+// containersBySandbox groups the containers by sandbox id
+func containersBySandbox(containers []*criruntime.Container) map[string][]ContainerInfo {
+	grouped := make(map[string][]ContainerInfo)
+	for _, ctr := range containers {
+		grouped[ctr.GetPodSandboxId()] = append(grouped[ctr.GetPodSandboxId()], newContainerInfo(ctr))
+	}
+	return grouped
+}
+
+// listContainers returns the running containers grouped by sandbox id, with a
+// single ListContainers call for every sandbox
+func (c *criClient) listContainers(client criruntime.RuntimeServiceClient) (map[string][]ContainerInfo, error) {
+	req := &criruntime.ListContainersRequest{
+		Filter: &criruntime.ContainerFilter{
+			State: &criruntime.ContainerStateValue{
+				State: criruntime.ContainerState_CONTAINER_RUNNING,
+			},
+		},
+	}
+	var resp *criruntime.ListContainersResponse
+	err := c.withRetry("ListContainers", func(ctx context.Context) error {
+		var err error
+		resp, err = client.ListContainers(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return containersBySandbox(resp.GetContainers()), nil
+}
+
+// mustCheckContainerLabels rejects the container info metric along with
+// ContainerLabels, both would name a label cosanet_container
+func mustCheckContainerLabels(options CosanetCollectorOptions) {
+	if options.Containers.Enabled && options.ContainerLabels {
+		panic(errors.New("the containers info metric can't be enabled along with the container labels"))
+	}
+}
+
+// emitContainers publishes an info metric per container of the pod
+func (c *CosanetCollector) emitContainers(info PodInfo, ch chan<- prometheus.Metric) {
+	if len(info.Containers) == 0 {
+		return
+	}
+	labelValues := c.netnsLabelValues(info)
+	for _, ctr := range info.Containers {
+		ch <- c.containerInfoDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			1,
+			append([]string{ctr.Name, ctr.ID, ctr.Image, ctr.ImageID}, labelValues...)...,
+		)
+	}
+	slog.Debug(
+		"emitted containers info",
+		slog.String("name", info.Name),
+		slog.String("namespace", info.Namespace),
+		slog.Int("containers", len(info.Containers)),
+	)
+}
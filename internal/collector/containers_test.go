@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func TestContainersBySandbox(t *testing.T) {
+	grouped := containersBySandbox([]*criruntime.Container{
+		{
+			Id:           "c1",
+			PodSandboxId: "sb1",
+			Metadata:     &criruntime.ContainerMetadata{Name: "app"},
+			Image:        &criruntime.ImageSpec{Image: "sha256:aaa", UserSpecifiedImage: "nginx:1.27"},
+			ImageRef:     "docker.io/library/nginx@sha256:bbb",
+		},
+		{
+			Id:           "c2",
+			PodSandboxId: "sb1",
+			Metadata:     &criruntime.ContainerMetadata{Name: "sidecar"},
+			Image:        &criruntime.ImageSpec{Image: "envoy:v1.31"},
+		},
+		{
+			Id:           "c3",
+			PodSandboxId: "sb2",
+			Metadata:     &criruntime.ContainerMetadata{Name: "db"},
+		},
+	})
+	assert.Equal(t, map[string][]ContainerInfo{
+		"sb1": {
+			{ID: "c1", Name: "app", Image: "nginx:1.27", ImageID: "docker.io/library/nginx@sha256:bbb"},
+			{ID: "c2", Name: "sidecar", Image: "envoy:v1.31"},
+		},
+		"sb2": {
+			{ID: "c3", Name: "db"},
+		},
+	}, grouped)
+}
+
+func TestMustCheckContainerLabels(t *testing.T) {
+	var options CosanetCollectorOptions
+	options.Containers.Enabled = true
+	assert.NotPanics(t, func() { mustCheckContainerLabels(options) })
+	options.ContainerLabels = true
+	assert.Panics(t, func() { mustCheckContainerLabels(options) })
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Netns labels kept by the controller aggregation, the other ones identifying
+// the pod
+var controllerAggregationLabels = []string{
+	"cosanet_node",
+	"cosanet_namespace",
+	"cosanet_pod_controller_kind",
+	"cosanet_pod_controller_name",
+}
+
+// controllerAggregation selects the metric families also summed per pod
+// controller (Deployment, StatefulSet...), see
+// CosanetCollectorOptions.ControllerAggregation
+type controllerAggregation struct {
+	metrics *regexp.Regexp
+	// drop_label rules of the pod level netns labels, applied before the
+	// relabel rules
+	rules []relabelRule
+	// Name of the controller kind label once emitted
+	kindLabel string
+	// Per controller descriptor of the described ones, built along with
+	// the late descriptors
+	mu    sync.Mutex
+	descs map[*prometheus.Desc]*prometheus.Desc
+}
+
+// mustNewControllerAggregation builds the aggregation of the metrics, given
+// the netns labels and their names once emitted
+func mustNewControllerAggregation(metrics string, netnsLabels, names []string) *controllerAggregation {
+	regex, err := compileAnchored(metrics)
+	if err != nil {
+		panic(fmt.Errorf("invalid controller aggregation metrics regex: %w", err))
+	}
+	if regex == nil {
+		return nil
+	}
+	a := &controllerAggregation{metrics: regex, descs: make(map[*prometheus.Desc]*prometheus.Desc)}
+	for i, label := range netnsLabels {
+		switch {
+		case label == "cosanet_pod_controller_kind":
+			a.kindLabel = names[i]
+		case !slices.Contains(controllerAggregationLabels, label):
+			a.rules = append(a.rules, relabelRule{action: RelabelDropLabel, label: names[i]})
+		}
+	}
+	return a
+}
+
+// controllerFamilyName names the per controller family, before the _total
+// suffix of the counters
+func controllerFamilyName(name string) string {
+	if base, found := strings.CutSuffix(name, "_total"); found {
+		return base + "_by_controller_total"
+	}
+	return name + "_by_controller"
+}
+
+// addPerController sets the per controller family of d when it is
+// aggregated and labelled with the pod controller
+func (a *controllerAggregation) addPerController(d *metricDesc, rules []relabelRule, name, help string, labels []string) {
+	if a == nil || !a.metrics.MatchString(name) {
+		return
+	}
+	kind := slices.Index(labels, a.kindLabel)
+	if kind == -1 {
+		return
+	}
+	perController := newMetricDesc(append(slices.Clip(a.rules), rules...), controllerFamilyName(name), help+", summed per controller", labels)
+	perController.aggregation = aggregationSum
+	if strings.HasSuffix(name, "_max") {
+		perController.aggregation = aggregationMax
+	}
+	d.perController = perController
+	d.controllerKind = kind
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.descs[d.desc] = perController.desc
+}
+
+// controllerMetric is a metric of a pod with a controller, along with its
+// share of the per controller family combined by aggregateByController. It
+// is the metric of the pod otherwise.
+type controllerMetric struct {
+	prometheus.Metric
+	controller *aggregatedMetric
+}
+
+// withController adds to m its share of the per controller family, for the
+// pods with a controller. build creates it from the per controller
+// descriptor.
+func (d *metricDesc) withController(m prometheus.Metric, labelValues []string, build func(*metricDesc) prometheus.Metric) prometheus.Metric {
+	if d.perController == nil || labelValues[d.controllerKind] == "" {
+		return m
+	}
+	return &controllerMetric{Metric: m, controller: build(d.perController).(*aggregatedMetric)}
+}
+
+// aggregateByController returns the channel passing the metrics sent to it
+// to ch and combining the per controller families, and the func emitting
+// them to ch once the collection is done.
+func (c *CosanetCollector) aggregateByController(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	if c.controllerAggregation == nil {
+		return ch, func() {}
+	}
+	return combineMetrics(ch, func(m prometheus.Metric) (prometheus.Metric, *aggregatedMetric) {
+		if cm, ok := m.(*controllerMetric); ok {
+			return cm.Metric, cm.controller
+		}
+		return m, nil
+	})
+}
+
+// describeByController returns the channel passing the descriptors sent to
+// it to ch, along with the ones of their per controller family, and the func
+// waiting for them to be passed.
+func (c *CosanetCollector) describeByController(ch chan<- *prometheus.Desc) (chan<- *prometheus.Desc, func()) {
+	a := c.controllerAggregation
+	if a == nil {
+		return ch, func() {}
+	}
+	descCh := make(chan *prometheus.Desc)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for desc := range descCh {
+			ch <- desc
+			a.mu.Lock()
+			perController, ok := a.descs[desc]
+			a.mu.Unlock()
+			if ok {
+				ch <- perController
+			}
+		}
+	}()
+	return descCh, func() {
+		close(descCh)
+		<-done
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateByController(t *testing.T) {
+	c := &CosanetCollector{netnsLabels: buildNetnsLabels([]string{"app"}, nil, false, false)}
+	c.controllerAggregation = mustNewControllerAggregation("cosanet_proc_net_tcp", c.netnsLabels, c.netnsLabels)
+	sockets := c.newSockProtoDesc("tcp")
+	udp := c.newSockProtoDesc("udp")
+	require.NotNil(t, sockets.perController)
+	assert.Nil(t, udp.perController)
+	assert.Contains(t, sockets.perController.desc.String(), "cosanet_proc_net_tcp_by_controller")
+	assert.NotContains(t, sockets.perController.desc.String(), "cosanet_pod\"")
+	assert.Contains(t, sockets.perController.desc.String(), "cosanet_pod_controller_name")
+
+	netnsLabels := func(pod, kind, controller string) []string {
+		return []string{"node1", pod, "default", "netns-" + pod, "uid-" + pod, "false", kind, controller, "web"}
+	}
+	ch := make(chan prometheus.Metric, 20)
+	aggCh, emitPerController := c.aggregateByController(ch)
+	for _, pod := range []struct {
+		name, kind, controller string
+		established            float64
+	}{
+		{"web-1", "Deployment", "web", 3},
+		{"web-2", "Deployment", "web", 5},
+		{"db-0", "StatefulSet", "db", 1},
+		{"debug", "", "", 7},
+	} {
+		labels := netnsLabels(pod.name, pod.kind, pod.controller)
+		aggCh <- sockets.mustNewConstMetric(prometheus.GaugeValue, pod.established, append([]string{"ESTABLISHED", "4"}, labels...)...)
+		aggCh <- udp.mustNewConstMetric(prometheus.GaugeValue, 1, append([]string{"CLOSE", "4"}, labels...)...)
+	}
+	emitPerController()
+	close(ch)
+
+	values := map[string]float64{}
+	var perPod int
+	for m := range ch {
+		labels := metricLabels(t, m)
+		if labels["cosanet_pod"] != "" {
+			perPod++
+			continue
+		}
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		assert.Equal(t, "default", labels["cosanet_namespace"])
+		values[labels["cosanet_pod_controller_kind"]+"/"+labels["cosanet_pod_controller_name"]] = out.GetGauge().GetValue()
+	}
+	assert.Equal(t, 8, perPod, "the pod series are kept")
+	assert.Equal(t, map[string]float64{"Deployment/web": 8, "StatefulSet/db": 1}, values)
+
+	descCh := make(chan *prometheus.Desc, 10)
+	describeCh, described := c.describeByController(descCh)
+	describeCh <- sockets.desc
+	describeCh <- udp.desc
+	described()
+	close(descCh)
+	var descs []*prometheus.Desc
+	for desc := range descCh {
+		descs = append(descs, desc)
+	}
+	assert.Equal(t, []*prometheus.Desc{sockets.desc, sockets.perController.desc, udp.desc}, descs)
+}
+
+func TestControllerFamilyName(t *testing.T) {
+	assert.Equal(t, "cosanet_proc_net_tcp_by_controller", controllerFamilyName("cosanet_proc_net_tcp"))
+	assert.Equal(t, "cosanet_conntrack_stat_drop_by_controller_total", controllerFamilyName("cosanet_conntrack_stat_drop_total"))
+}
+
+func TestMustNewControllerAggregation(t *testing.T) {
+	assert.Nil(t, mustNewControllerAggregation("", baseNetnsLabels, baseNetnsLabels))
+	assert.Panics(t, func() { mustNewControllerAggregation("(", baseNetnsLabels, baseNetnsLabels) })
+}
@@ -0,0 +1,592 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // registers the health checking function
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+type podSandboxStatusInfo struct {
+	PID int `json:"pid"`
+	// containerd shim, eg: io.containerd.kata.v2
+	RuntimeType string `json:"runtimeType"`
+	RuntimeSpec struct {
+		Linux struct {
+			Namespaces []struct {
+				Type string `json:"type"`
+				Path string `json:"path"`
+			}
+		} `json:"linux"`
+	} `json:"runtimeSpec"`
+}
+
+func (p *podSandboxStatusInfo) getNetworkNamespacePath() string {
+	for _, ns := range p.RuntimeSpec.Linux.Namespaces {
+		if ns.Type == "network" {
+			return ns.Path
+		}
+	}
+	return "HOST"
+}
+
+// criClient wraps the CRI runtime service calls with per-call timeouts,
+// retries and a circuit breaker per socket, so a hung runtime can't stall
+// scrapes.
+type criClient struct {
+	options CRIOptions
+	// Circuit breakers per socket path, kept across reconnections
+	breakers map[string]*circuitBreaker
+	// Connections per socket path
+	conns   map[string]*criConn
+	limiter *rate.Limiter
+	stats   *criCallStats
+	// withContainers also lists the running containers of the sandboxes
+	withContainers bool
+	discovery      DiscoveryOptions
+	procRoot       string
+	infoPaths      criInfoPaths
+	vmRuntimes     *regexp.Regexp
+	// Connection of the containerd discovery mode
+	containerdConn *grpc.ClientConn
+}
+
+// criConn is the long lived connection to a CRI socket, grpc transparently
+// reconnects it with backoff when the runtime restarts
+type criConn struct {
+	conn    *grpc.ClientConn
+	client  criruntime.RuntimeServiceClient
+	breaker *circuitBreaker
+	// Socket path, naming the runtime in the logs
+	socketPath string
+	// Runtime name detected on connection, see sandboxRuntimeInfo
+	runtimeName string
+}
+
+// criSocket is a CRI socket to list the sandboxes from
+type criSocket struct {
+	// Value of cosanet_runtime, the detected runtime name when empty
+	name string
+	path string
+}
+
+type CRIOptions struct {
+	// Timeout applied to every CRI call
+	Timeout time.Duration
+	// Number of retries of the transient failures on top of the initial attempt
+	Retries int
+	// Consecutive failed listings or sandbox status calls opening the circuit
+	// breaker
+	BreakerThreshold int
+	// Time the breaker stays open before trying the runtime again
+	BreakerCooldown time.Duration
+	// JSONPath expressions overriding the extraction of the PID and netns
+	// path from the sandbox verbose info
+	PIDJSONPath   string
+	NetnsJSONPath string
+	// Maximum calls per second to the runtime (0 for unlimited) and burst
+	RateLimit float64
+	RateBurst int
+	// VMRuntimes matches the runtime handlers and types running the pods in
+	// a VM, eg: kata
+	VMRuntimes string
+}
+
+func newCRIClient(options CosanetCollectorOptions) *criClient {
+	return &criClient{
+		options:        options.CRI,
+		breakers:       make(map[string]*circuitBreaker),
+		conns:          make(map[string]*criConn),
+		limiter:        newCRIRateLimiter(options.CRI.RateLimit, options.CRI.RateBurst),
+		stats:          newCRICallStats(),
+		withContainers: options.Containers.Enabled,
+		discovery:      options.Discovery,
+		procRoot:       options.ProcRoot,
+		infoPaths:      mustParseCRIInfoPaths(options.CRI),
+		vmRuntimes:     compileOptional(options.CRI.VMRuntimes),
+	}
+}
+
+// criBackoffBase is the delay before the first retry, doubled on each attempt
+const criBackoffBase = 100 * time.Millisecond
+
+// criRetryableCodes are the gRPC codes of a runtime transiently unavailable,
+// the other ones (e.g. NotFound for a sandbox removed since listed) failing
+// the same way again
+var criRetryableCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted}
+
+// isRetryable tells whether a failed call may succeed on retry. The calls to
+// the Docker and Podman APIs are retried on server (5xx) and network errors,
+// not when answered with a client error such as 404 for a container gone.
+func isRetryable(err error) bool {
+	if s, ok := status.FromError(err); ok {
+		return slices.Contains(criRetryableCodes, s.Code())
+	}
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// withRetry runs call with a fresh deadline on each attempt and an exponential
+// backoff between the attempts failing transiently, see isRetryable. Attempts
+// wait for the rate limiter, before their deadline starts, and are recorded
+// in the calls stats.
+func (c *criClient) withRetry(name string, call func(ctx context.Context) error) error {
+	var err error
+	backoff := criBackoffBase
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			slog.Debug(
+				"retrying CRI call",
+				slog.String("call", name),
+				slog.Int("attempt", attempt),
+				slog.Duration("backoff", backoff),
+				slog.Any("err", err),
+			)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err := c.limiter.Wait(context.Background()); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
+		start := time.Now()
+		err = call(ctx)
+		c.stats.observe(name, err, time.Since(start))
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == c.options.Retries || !isRetryable(err) {
+			return fmt.Errorf("%s failed after %d attempt(s): %w", name, attempt+1, err)
+		}
+	}
+}
+
+// ErrCRIUnavailable is returned while the circuit breaker is open
+var ErrCRIUnavailable = errors.New("CRI runtime marked unhealthy, circuit breaker is open")
+
+// breakerFor returns the circuit breaker of the runtime socket
+func (c *criClient) breakerFor(socketPath string) *circuitBreaker {
+	breaker, ok := c.breakers[socketPath]
+	if !ok {
+		breaker = newCircuitBreaker(c.options.BreakerThreshold, c.options.BreakerCooldown)
+		c.breakers[socketPath] = breaker
+	}
+	return breaker
+}
+
+// breakerFailure records a failed call to the runtime of the socket and
+// returns true if it opened its breaker
+func (c *criClient) breakerFailure(socketPath string, breaker *circuitBreaker) bool {
+	if !breaker.Failure() {
+		return false
+	}
+	slog.Warn(
+		"CRI circuit breaker opened, the sandboxes of the runtime won't be collected",
+		slog.String("socket", socketPath),
+		slog.Duration("cooldown", c.options.BreakerCooldown),
+	)
+	return true
+}
+
+// throughBreaker runs list unless the breaker of the socket is open, counting
+// its failure. list closes the breaker itself, once the runtime answered.
+func (c *criClient) throughBreaker(socketPath string, list func() ([]PodInfo, error)) ([]PodInfo, error) {
+	breaker := c.breakerFor(socketPath)
+	if !breaker.Allow() {
+		return nil, ErrCRIUnavailable
+	}
+	infos, err := list()
+	// The failed status call opening the breaker was already counted
+	if err != nil && !errors.Is(err, ErrCRIUnavailable) {
+		c.breakerFailure(socketPath, breaker)
+	}
+	return infos, err
+}
+
+// listThroughBreaker is throughBreaker for the listings whose success closes
+// the breaker
+func (c *criClient) listThroughBreaker(socketPath string, list func() ([]PodInfo, error)) ([]PodInfo, error) {
+	return c.throughBreaker(socketPath, func() ([]PodInfo, error) {
+		infos, err := list()
+		if err == nil {
+			c.breakerFor(socketPath).Success()
+		}
+		return infos, err
+	})
+}
+
+// listSandboxes returns the ready sandboxes, going through the circuit
+// breaker of each runtime. The CRI sandboxes out of shard are left out
+// before their status is fetched.
+func (c *criClient) listSandboxes(shard podShard) ([]PodInfo, error) {
+	if c.discovery.Mode == DiscoveryModeContainerd {
+		return c.listThroughBreaker(c.discovery.Containerd.Socket, c.listContainerdSandboxes)
+	}
+	sockets, err := getCRISockets()
+	if err != nil {
+		c.closeConns(nil)
+		// Legacy nodes may run docker without any CRI shim
+		if dockerSocket, ok := getDockerSocketPath(); ok {
+			return c.listThroughBreaker(dockerSocket, func() ([]PodInfo, error) {
+				return c.doListDockerSandboxes(dockerSocket)
+			})
+		}
+		if podmanSocket, ok := getPodmanSocketPath(); ok {
+			return c.listThroughBreaker(podmanSocket, func() ([]PodInfo, error) {
+				return c.doListPodmanSandboxes(podmanSocket)
+			})
+		}
+		// No runtime to protect, the netns are read from the filesystem
+		if c.discovery.Netns {
+			return c.discoverNetns()
+		}
+		return nil, err
+	}
+	c.closeConns(sockets)
+
+	// A failing runtime doesn't prevent collecting the other ones
+	var podInfos []PodInfo
+	var errs []error
+	for _, socket := range sockets {
+		infos, err := c.throughBreaker(socket.path, func() ([]PodInfo, error) {
+			return c.listRuntimeSandboxes(socket, shard)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", socket.path, err))
+			continue
+		}
+		podInfos = append(podInfos, infos...)
+	}
+	if len(errs) == len(sockets) {
+		return nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		slog.Error("failed to list the sandboxes of a runtime", slog.Any("err", errors.Join(errs...)))
+	}
+	return podInfos, nil
+}
+
+func (c *criClient) listRuntimeSandboxes(socket criSocket, shard podShard) ([]PodInfo, error) {
+	conn, err := c.runtimeConn(socket.path)
+	if err != nil {
+		slog.Error("Failed to create gRPC client", slog.Any("err", err))
+		return nil, err
+	}
+	client := conn.client
+	runtime := socket.name
+	if runtime == "" {
+		runtime = conn.runtimeName
+	}
+	filter := &criruntime.PodSandboxFilter{
+		State: &criruntime.PodSandboxStateValue{
+			State: criruntime.PodSandboxState_SANDBOX_READY,
+		},
+	}
+	req := &criruntime.ListPodSandboxRequest{Filter: filter}
+	var resp *criruntime.ListPodSandboxResponse
+	err = c.withRetry("ListPodSandbox", func(ctx context.Context) error {
+		var err error
+		resp, err = client.ListPodSandbox(ctx, req)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to list pod sandboxes", slog.Any("err", err))
+		return nil, err
+	}
+	// The status calls count on their own
+	conn.breaker.Success()
+
+	var containers map[string][]ContainerInfo
+	if c.withContainers {
+		// Sandboxes are still reported without their containers
+		containers, err = c.listContainers(client)
+		if err != nil {
+			slog.Error("Failed to list containers", slog.Any("err", err))
+		}
+	}
+
+	return c.sandboxInfos(conn, runtime, sandboxesInShard(resp.Items, shard), containers)
+}
+
+// sandboxInfos returns the infos of the sandboxes from their status. The
+// failed status calls count towards the circuit breaker, the sandboxes of a
+// runtime answering the listing but not the status calls being given up once
+// it opens.
+func (c *criClient) sandboxInfos(
+	conn *criConn,
+	runtime string,
+	sandboxes []*criruntime.PodSandbox,
+	containers map[string][]ContainerInfo,
+) ([]PodInfo, error) {
+	client := conn.client
+	var podInfos []PodInfo
+	for _, sb := range sandboxes {
+		statusReq := &criruntime.PodSandboxStatusRequest{
+			PodSandboxId: sb.Id,
+			Verbose:      true,
+		}
+		var statusResp *criruntime.PodSandboxStatusResponse
+		err := c.withRetry("PodSandboxStatus", func(ctx context.Context) error {
+			var err error
+			statusResp, err = client.PodSandboxStatus(ctx, statusReq)
+			return err
+		})
+		if status.Code(err) == codes.NotFound {
+			slog.Debug("sandbox removed since listed", slog.String("id", sb.Id))
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to get pod sandbox status", slog.Any("err", err))
+			if c.breakerFailure(conn.socketPath, conn.breaker) {
+				return nil, fmt.Errorf("%w: %w", ErrCRIUnavailable, err)
+			}
+			continue
+		}
+		conn.breaker.Success()
+
+		hostNetwork := statusResp.Status.GetLinux().GetNamespaces().GetOptions().GetNetwork() ==
+			criruntime.NamespaceMode_NODE
+		runtimeInfo := c.sandboxRuntimeInfo(client, conn.runtimeName, statusResp.Status, statusResp.Info)
+		if runtimeInfo.NetNSPath == "" && hostNetwork {
+			runtimeInfo.NetNSPath = "HOST"
+		}
+		var skipReason string
+		if runtimeInfo.VM && !hostNetwork && (runtimeInfo.NetNSPath == "" || runtimeInfo.NetNSPath == "HOST") {
+			skipReason = skipReasonVMNetnsUnknown
+		}
+
+		podInfos = append(podInfos, PodInfo{
+			PID:         runtimeInfo.PID,
+			netNSPath:   runtimeInfo.NetNSPath,
+			netNSName:   runtimeInfo.netNSName(),
+			UID:         sandboxPodUID(statusResp.Status),
+			Name:        statusResp.Status.Metadata.Name,
+			Namespace:   statusResp.Status.Metadata.Namespace,
+			HostNetwork: hostNetwork,
+			// Kubelet propagates the pod's labels and annotations to the sandbox
+			Labels:      sandboxPodLabels(statusResp.Status.Labels),
+			Annotations: statusResp.Status.Annotations,
+			Containers:  containers[sb.Id],
+			Runtime:     runtime,
+			skipReason:  skipReason,
+		})
+	}
+
+	return podInfos, nil
+}
+
+// criKeepalive pings an idle connection to detect a dead runtime. The
+// interval stays above the 5 minutes grpc servers enforce by default, more
+// frequent pings get the connection closed with too_many_pings.
+var criKeepalive = keepalive.ClientParameters{
+	Time:    5 * time.Minute,
+	Timeout: 20 * time.Second,
+}
+
+// criReconnectBackoff bounds the delay between two reconnection attempts
+var criReconnectBackoff = backoff.Config{
+	BaseDelay:  time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   30 * time.Second,
+}
+
+// criServiceConfig enables the grpc.health.v1 client side health checking
+// (only run by round_robin, a single address here). Runtimes not serving the
+// health service are considered healthy.
+const criServiceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"healthCheckConfig": {"serviceName": ""}
+}`
+
+// dialRuntime opens a gRPC connection to the runtime socket, kept alive and
+// re-established in the background
+func (c *criClient) dialRuntime(socketPath string) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(
+		"unix://"+socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(criKeepalive),
+		grpc.WithDefaultServiceConfig(criServiceConfig),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           criReconnectBackoff,
+			MinConnectTimeout: c.options.Timeout,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.Connect()
+	return conn, nil
+}
+
+// runtimeConn returns the persistent connection to socketPath, dialing it
+// on first use or after a shutdown.
+func (c *criClient) runtimeConn(socketPath string) (*criConn, error) {
+	if conn, ok := c.conns[socketPath]; ok {
+		if conn.conn.GetState() != connectivity.Shutdown {
+			return conn, nil
+		}
+		c.closeConn(socketPath)
+	}
+	grpcConn, err := c.dialRuntime(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	conn := &criConn{
+		conn:       grpcConn,
+		client:     criruntime.NewRuntimeServiceClient(grpcConn),
+		breaker:    c.breakerFor(socketPath),
+		socketPath: socketPath,
+	}
+	conn.runtimeName = c.detectRuntime(conn.client)
+	c.conns[socketPath] = conn
+	return conn, nil
+}
+
+func (c *criClient) closeConn(socketPath string) {
+	conn, ok := c.conns[socketPath]
+	if !ok {
+		return
+	}
+	if err := conn.conn.Close(); err != nil {
+		slog.Warn("failed to close CRI connection", slog.String("socket", socketPath), slog.Any("err", err))
+	}
+	delete(c.conns, socketPath)
+}
+
+// closeConns closes the connections to the sockets not in keep
+func (c *criClient) closeConns(keep []criSocket) {
+	for socketPath := range c.conns {
+		if !slices.ContainsFunc(keep, func(socket criSocket) bool { return socket.path == socketPath }) {
+			c.closeConn(socketPath)
+		}
+	}
+}
+
+// connectionStates returns the state of the CRI connections per socket path,
+// empty when no CRI socket was found
+func (c *criClient) connectionStates() map[string]connectivity.State {
+	states := make(map[string]connectivity.State, len(c.conns))
+	for socketPath, conn := range c.conns {
+		states[socketPath] = conn.conn.GetState()
+	}
+	if c.containerdConn != nil {
+		states[c.discovery.Containerd.Socket] = c.containerdConn.GetState()
+	}
+	return states
+}
+
+// parseCRISocketEnv parses the comma separated CRI_SOCKET paths, each
+// optionally prefixed by the name given to its runtime (name=path)
+func parseCRISocketEnv(value string) []criSocket {
+	var sockets []criSocket
+	for _, item := range splitList(value) {
+		name, path, found := strings.Cut(item, "=")
+		if !found {
+			name, path = "", item
+		}
+		sockets = append(sockets, criSocket{name: name, path: path})
+	}
+	return sockets
+}
+
+// multipleCRISockets tells whether several CRI sockets are configured, the
+// sandboxes are then labeled with their runtime
+func multipleCRISockets() bool {
+	return len(parseCRISocketEnv(os.Getenv("CRI_SOCKET"))) > 1
+}
+
+// getCRISockets returns the sockets listed in CRI_SOCKET, or the first one
+// found in the usual places
+func getCRISockets() ([]criSocket, error) {
+	if crisocket := os.Getenv("CRI_SOCKET"); crisocket != "" {
+		slog.Info("searching for cri socket: using provided path", slog.String("path", crisocket))
+		var sockets []criSocket
+		for _, socket := range parseCRISocketEnv(crisocket) {
+			if isSocket(socket.path) {
+				sockets = append(sockets, socket)
+			} else {
+				slog.Warn("CRI socket not found", slog.String("path", socket.path))
+			}
+		}
+		if len(sockets) == 0 {
+			return nil, fmt.Errorf("none of the provided CRI sockets %q found", crisocket)
+		}
+		return sockets, nil
+	}
+
+	socketPaths := []string{
+		"/run/k3s/containerd/containerd.sock",
+		"/var/run/containerd/containerd.sock",
+		"/run/containerd/containerd.sock",
+		"/var/run/dockershim.sock",
+		"/run/crio/crio.sock",
+	}
+	for _, path := range socketPaths {
+		if isSocket(path) {
+			slog.Info("Found containerd socket", slog.String("path", path))
+			return []criSocket{{path: path}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no containerd socket file found in usual places %v", socketPaths)
+}
+
+func isSocket(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode()&os.ModeSocket != 0
+}
+
+// criConnStates are the states reported by cosanet_cri_connection_state
+var criConnStates = []connectivity.State{
+	connectivity.Idle,
+	connectivity.Connecting,
+	connectivity.Ready,
+	connectivity.TransientFailure,
+	connectivity.Shutdown,
+}
+
+// emitCRIConnectionState publishes the state of the CRI connections, nothing
+// when sandboxes were not listed through CRI
+func (c *CosanetCollector) emitCRIConnectionState(ch chan<- prometheus.Metric) {
+	for socketPath, current := range c.cri.connectionStates() {
+		for _, state := range criConnStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- c.criConnStateDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				value,
+				strings.ToLower(state.String()),
+				socketPath,
+				c.nodename,
+			)
+		}
+	}
+}
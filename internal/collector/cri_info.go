@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// Runtime names reported by the CRI Version call
+const (
+	runtimeContainerd = "containerd"
+	runtimeCRIO       = "cri-o"
+	// cri-dockerd, formerly dockershim
+	runtimeDocker = "docker"
+)
+
+// criInfoPaths are the optional JSONPath expressions extracting the PID and
+// netns path from the sandbox verbose info, overriding the runtime layouts
+type criInfoPaths struct {
+	pid   *jsonpath.JSONPath
+	netns *jsonpath.JSONPath
+}
+
+func mustParseInfoJSONPath(name, expr string) *jsonpath.JSONPath {
+	if expr == "" {
+		return nil
+	}
+	jp := jsonpath.New(name)
+	if err := jp.Parse(expr); err != nil {
+		panic(fmt.Errorf("malformed %s JSONPath %q: %w", name, expr, err))
+	}
+	return jp
+}
+
+func mustParseCRIInfoPaths(options CRIOptions) criInfoPaths {
+	return criInfoPaths{
+		pid:   mustParseInfoJSONPath("pid", options.PIDJSONPath),
+		netns: mustParseInfoJSONPath("netns", options.NetnsJSONPath),
+	}
+}
+
+// jsonPathText returns the text of the first match of jp in data, false when
+// it doesn't match
+func jsonPathText(jp *jsonpath.JSONPath, data any) (string, bool) {
+	var buf bytes.Buffer
+	if err := jp.Execute(&buf, data); err != nil {
+		return "", false
+	}
+	text, _, _ := strings.Cut(strings.TrimSpace(buf.String()), " ")
+	return text, text != ""
+}
+
+// sandboxRuntimeInfo is what is needed from the runtime to enter a sandbox
+type sandboxRuntimeInfo struct {
+	PID int
+	// Network namespace path, HOST when the spec has no network namespace,
+	// empty when unknown
+	NetNSPath string
+	// containerd runtime type, empty for the other runtimes
+	RuntimeType string
+	// VM is set for the runtimes running the pod in a VM, whose netns path is
+	// the host side one holding the VM's interfaces
+	VM bool
+}
+
+func (s sandboxRuntimeInfo) netNSName() string {
+	if s.NetNSPath == "HOST" || s.NetNSPath == "" {
+		return s.NetNSPath
+	}
+	return filepath.Base(s.NetNSPath)
+}
+
+// parseSandboxInfo extracts the PID and netns path of the sandbox verbose
+// info. containerd and CRI-O share the same layout: the pid and the OCI
+// runtime spec under the "info" key.
+func (p criInfoPaths) parseSandboxInfo(info map[string]string) (sandboxRuntimeInfo, error) {
+	raw, ok := info["info"]
+	if !ok {
+		return sandboxRuntimeInfo{}, nil
+	}
+	var status podSandboxStatusInfo
+	if err := json.Unmarshal([]byte(raw), &status); err != nil {
+		return sandboxRuntimeInfo{}, err
+	}
+	result := sandboxRuntimeInfo{
+		PID:         status.PID,
+		NetNSPath:   status.getNetworkNamespacePath(),
+		RuntimeType: status.RuntimeType,
+	}
+	if p.pid == nil && p.netns == nil {
+		return result, nil
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return result, err
+	}
+	if p.pid != nil {
+		if text, ok := jsonPathText(p.pid, data); ok {
+			pid, err := strconv.Atoi(text)
+			if err != nil {
+				return result, fmt.Errorf("pid JSONPath matched a non integer %q", text)
+			}
+			result.PID = pid
+		}
+	}
+	if p.netns != nil {
+		if text, ok := jsonPathText(p.netns, data); ok {
+			result.NetNSPath = text
+		}
+	}
+	return result, nil
+}
+
+// detectRuntime returns the name of the runtime serving the CRI socket
+func (c *criClient) detectRuntime(client criruntime.RuntimeServiceClient) string {
+	var resp *criruntime.VersionResponse
+	err := c.withRetry("Version", func(ctx context.Context) error {
+		var err error
+		resp, err = client.Version(ctx, &criruntime.VersionRequest{})
+		return err
+	})
+	if err != nil {
+		slog.Warn("failed to detect the CRI runtime, assuming containerd", slog.Any("err", err))
+		return runtimeContainerd
+	}
+	slog.Info(
+		"detected CRI runtime",
+		slog.String("name", resp.GetRuntimeName()),
+		slog.String("version", resp.GetRuntimeVersion()),
+	)
+	return resp.GetRuntimeName()
+}
+
+// isVMRuntime returns whether the sandbox runs in a VM, from its runtime
+// handler (the RuntimeClass one) or its containerd runtime type
+func (c *criClient) isVMRuntime(runtimeHandler, runtimeType string) bool {
+	if c.vmRuntimes == nil {
+		return false
+	}
+	return (runtimeHandler != "" && c.vmRuntimes.MatchString(runtimeHandler)) ||
+		(runtimeType != "" && c.vmRuntimes.MatchString(runtimeType))
+}
+
+// sandboxRuntimeInfo returns the PID and netns path of a sandbox, completing
+// the verbose info with runtime specific lookups when it lacks them
+func (c *criClient) sandboxRuntimeInfo(
+	client criruntime.RuntimeServiceClient,
+	runtimeName string,
+	status *criruntime.PodSandboxStatus,
+	info map[string]string,
+) sandboxRuntimeInfo {
+	sandboxID := status.GetId()
+	result, err := c.infoPaths.parseSandboxInfo(info)
+	if err != nil {
+		slog.Warn("unable to parse CRI's sandbox verbose info", slog.String("id", sandboxID), slog.Any("err", err))
+	}
+	if c.isVMRuntime(status.GetRuntimeHandler(), result.RuntimeType) {
+		// The reported PID is either the shim's, in the host netns, or a
+		// guest one: the sandbox is only entered through its netns path
+		result.VM = true
+		result.PID = 0
+		return result
+	}
+	if result.PID > 0 {
+		return result
+	}
+	switch runtimeName {
+	case runtimeCRIO:
+		// Without infra container (drop_infra_ctr), the sandbox has no
+		// process, any of its containers is in the pod netns
+		if pid, err := c.containerPID(client, sandboxID); err == nil {
+			result.PID = pid
+		} else {
+			slog.Debug("no running container PID for the sandbox", slog.String("id", sandboxID), slog.Any("err", err))
+		}
+	case runtimeDocker:
+		// cri-dockerd has no verbose info, the sandbox is a docker container
+		// of the same id
+		if len(info) == 0 {
+			if ctr, err := c.inspectDockerSandbox(sandboxID); err == nil {
+				result.PID = ctr.State.Pid
+				result.NetNSPath = ctr.NetworkSettings.SandboxKey
+				if ctr.HostConfig.NetworkMode == "host" {
+					result.NetNSPath = "HOST"
+				}
+			} else {
+				slog.Debug("failed to inspect the docker sandbox", slog.String("id", sandboxID), slog.Any("err", err))
+			}
+		}
+	}
+	return result
+}
+
+// containerPID returns the PID of a running container of the sandbox, from
+// the container verbose info
+func (c *criClient) containerPID(client criruntime.RuntimeServiceClient, sandboxID string) (int, error) {
+	var containers *criruntime.ListContainersResponse
+	err := c.withRetry("ListContainers", func(ctx context.Context) error {
+		var err error
+		containers, err = client.ListContainers(ctx, &criruntime.ListContainersRequest{
+			Filter: &criruntime.ContainerFilter{
+				PodSandboxId: sandboxID,
+				State: &criruntime.ContainerStateValue{
+					State: criruntime.ContainerState_CONTAINER_RUNNING,
+				},
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, ctr := range containers.GetContainers() {
+		var status *criruntime.ContainerStatusResponse
+		err := c.withRetry("ContainerStatus", func(ctx context.Context) error {
+			var err error
+			status, err = client.ContainerStatus(ctx, &criruntime.ContainerStatusRequest{
+				ContainerId: ctr.GetId(),
+				Verbose:     true,
+			})
+			return err
+		})
+		if err != nil {
+			continue
+		}
+		var info struct {
+			PID int `json:"pid"`
+		}
+		if json.Unmarshal([]byte(status.GetInfo()["info"]), &info) == nil && info.PID > 0 {
+			return info.PID, nil
+		}
+	}
+	return 0, fmt.Errorf("none of the %d running containers reported a pid", len(containers.GetContainers()))
+}
+
+// inspectDockerSandbox inspects the sandbox container through the docker
+// socket cri-dockerd drives
+func (c *criClient) inspectDockerSandbox(sandboxID string) (*dockerContainer, error) {
+	socketPath, ok := getDockerSocketPath()
+	if !ok {
+		return nil, fmt.Errorf("no docker socket found")
+	}
+	client := newUnixHTTPClient(socketPath)
+	defer client.CloseIdleConnections()
+	var ctr dockerContainer
+	err := c.withRetry("ContainerInspect", func(ctx context.Context) error {
+		return dockerGet(ctx, client, "/containers/"+url.PathEscape(sandboxID)+"/json", &ctr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ctr, nil
+}
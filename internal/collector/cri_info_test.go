@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const containerdSandboxInfo = `{
+	"pid": 4242,
+	"runtimeSpec": {"linux": {"namespaces": [
+		{"type": "pid"},
+		{"type": "network", "path": "/var/run/netns/cni-1c2d3e4f"}
+	]}}
+}`
+
+// CRI-O with drop_infra_ctr: no sandbox process, pinned namespaces
+const crioSandboxInfo = `{
+	"image": "registry.k8s.io/pause:3.9",
+	"pid": 0,
+	"runtimeSpec": {"linux": {"namespaces": [
+		{"type": "network", "path": "/var/run/netns/8a9b0c1d"}
+	]}}
+}`
+
+func TestParseSandboxInfo(t *testing.T) {
+	var paths criInfoPaths
+
+	info, err := paths.parseSandboxInfo(map[string]string{"info": containerdSandboxInfo})
+	require.NoError(t, err)
+	assert.Equal(t, sandboxRuntimeInfo{PID: 4242, NetNSPath: "/var/run/netns/cni-1c2d3e4f"}, info)
+	assert.Equal(t, "cni-1c2d3e4f", info.netNSName())
+
+	info, err = paths.parseSandboxInfo(map[string]string{"info": crioSandboxInfo})
+	require.NoError(t, err)
+	assert.Equal(t, sandboxRuntimeInfo{PID: 0, NetNSPath: "/var/run/netns/8a9b0c1d"}, info)
+
+	// No network namespace in the spec: the host one
+	info, err = paths.parseSandboxInfo(map[string]string{"info": `{"pid": 7, "runtimeSpec": {}}`})
+	require.NoError(t, err)
+	assert.Equal(t, "HOST", info.netNSName())
+
+	// cri-dockerd: no verbose info at all
+	info, err = paths.parseSandboxInfo(nil)
+	require.NoError(t, err)
+	assert.Equal(t, sandboxRuntimeInfo{}, info)
+	assert.Equal(t, "", info.netNSName())
+
+	_, err = paths.parseSandboxInfo(map[string]string{"info": "{"})
+	assert.Error(t, err)
+}
+
+func TestParseSandboxInfoJSONPath(t *testing.T) {
+	paths := mustParseCRIInfoPaths(CRIOptions{
+		PIDJSONPath:   "{.process.pid}",
+		NetnsJSONPath: `{.spec.namespaces[?(@.kind=="net")].file}`,
+	})
+	info, err := paths.parseSandboxInfo(map[string]string{"info": `{
+		"process": {"pid": 31337},
+		"spec": {"namespaces": [{"kind": "ipc", "file": "/x"}, {"kind": "net", "file": "/run/netns/custom"}]}
+	}`})
+	require.NoError(t, err)
+	assert.Equal(t, sandboxRuntimeInfo{PID: 31337, NetNSPath: "/run/netns/custom"}, info)
+
+	// Unmatched expressions keep the default layout values
+	info, err = paths.parseSandboxInfo(map[string]string{"info": containerdSandboxInfo})
+	require.NoError(t, err)
+	assert.Equal(t, sandboxRuntimeInfo{PID: 4242, NetNSPath: "/var/run/netns/cni-1c2d3e4f"}, info)
+
+	paths = mustParseCRIInfoPaths(CRIOptions{PIDJSONPath: "{.runtimeSpec}"})
+	_, err = paths.parseSandboxInfo(map[string]string{"info": containerdSandboxInfo})
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { mustParseCRIInfoPaths(CRIOptions{PIDJSONPath: "{.pid"}) })
+}
+
+// Kata: the pid is the shim one, the netns the host side one of the VM
+const kataSandboxInfo = `{
+	"pid": 5150,
+	"runtimeType": "io.containerd.kata.v2",
+	"runtimeSpec": {"linux": {"namespaces": [
+		{"type": "network", "path": "/var/run/netns/cni-5e6f7a8b"}
+	]}}
+}`
+
+func TestSandboxRuntimeInfoVM(t *testing.T) {
+	c := &criClient{vmRuntimes: compileOptional("kata|firecracker")}
+
+	// Detected from the containerd runtime type
+	info := c.sandboxRuntimeInfo(nil, runtimeContainerd, &criruntime.PodSandboxStatus{Id: "a"},
+		map[string]string{"info": kataSandboxInfo})
+	assert.Equal(t, sandboxRuntimeInfo{
+		NetNSPath:   "/var/run/netns/cni-5e6f7a8b",
+		RuntimeType: "io.containerd.kata.v2",
+		VM:          true,
+	}, info)
+
+	// Detected from the RuntimeClass handler
+	info = c.sandboxRuntimeInfo(nil, runtimeCRIO, &criruntime.PodSandboxStatus{Id: "b", RuntimeHandler: "kata-qemu"},
+		map[string]string{"info": crioSandboxInfo})
+	assert.True(t, info.VM)
+	assert.Equal(t, "/var/run/netns/8a9b0c1d", info.NetNSPath)
+
+	info = c.sandboxRuntimeInfo(nil, runtimeContainerd, &criruntime.PodSandboxStatus{Id: "c", RuntimeHandler: "runc"},
+		map[string]string{"info": containerdSandboxInfo})
+	assert.False(t, info.VM)
+	assert.Equal(t, 4242, info.PID)
+
+	// Disabled
+	c.vmRuntimes = nil
+	info = c.sandboxRuntimeInfo(nil, runtimeContainerd, &criruntime.PodSandboxStatus{Id: "a"},
+		map[string]string{"info": kataSandboxInfo})
+	assert.False(t, info.VM)
+	assert.Equal(t, 5150, info.PID)
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/status"
+)
+
+// criCallBuckets are the upper bounds of cosanet_cri_request_duration_seconds
+var criCallBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type criCallKey struct {
+	method string
+	code   string
+}
+
+type criCallDurations struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+// criCallStats accumulates the outcome and latency of every runtime call
+// attempt, retries included
+type criCallStats struct {
+	mu        sync.Mutex
+	requests  map[criCallKey]uint64
+	durations map[string]*criCallDurations
+}
+
+func newCRICallStats() *criCallStats {
+	return &criCallStats{
+		requests:  make(map[criCallKey]uint64),
+		durations: make(map[string]*criCallDurations),
+	}
+}
+
+// callCode returns the gRPC code name of the call error, OK on success
+func callCode(err error) string {
+	switch {
+	case err == nil:
+		return "OK"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "DeadlineExceeded"
+	}
+	// Unknown for the non gRPC (docker API) errors
+	return status.Code(err).String()
+}
+
+func (s *criCallStats) observe(method string, err error, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[criCallKey{method: method, code: callCode(err)}]++
+	durations, ok := s.durations[method]
+	if !ok {
+		durations = &criCallDurations{buckets: make(map[float64]uint64, len(criCallBuckets))}
+		s.durations[method] = durations
+	}
+	seconds := duration.Seconds()
+	durations.count++
+	durations.sum += seconds
+	for _, bound := range criCallBuckets {
+		if seconds <= bound {
+			durations.buckets[bound]++
+		}
+	}
+}
+
+// newCRIRateLimiter returns the limiter of the runtime calls, unlimited when
+// ratePerSecond isn't positive
+func newCRIRateLimiter(ratePerSecond float64, burst int) *rate.Limiter {
+	if ratePerSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(ratePerSecond), max(burst, 1))
+}
+
+// emitCRICallStats publishes the runtime calls counters and latencies
+func (c *CosanetCollector) emitCRICallStats(ch chan<- prometheus.Metric) {
+	stats := c.cri.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	for key, count := range stats.requests {
+		ch <- c.criRequestsDesc.mustNewConstMetric(
+			prometheus.CounterValue,
+			float64(count),
+			key.method,
+			key.code,
+			c.nodename,
+		)
+	}
+	for method, durations := range stats.durations {
+		ch <- c.criRequestDurationDesc.mustNewConstHistogram(
+			durations.count,
+			durations.sum,
+			durations.buckets,
+			method,
+			c.nodename,
+		)
+	}
+}
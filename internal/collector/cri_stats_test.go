@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCallCode(t *testing.T) {
+	assert.Equal(t, "OK", callCode(nil))
+	assert.Equal(t, "Unavailable", callCode(status.Error(codes.Unavailable, "connection refused")))
+	assert.Equal(t, "DeadlineExceeded", callCode(fmt.Errorf("inspect: %w", context.DeadlineExceeded)))
+	assert.Equal(t, "Unknown", callCode(errors.New("API call /containers/json: 500 Internal Server Error")))
+}
+
+func TestCRICallStats(t *testing.T) {
+	stats := newCRICallStats()
+	stats.observe("ListPodSandbox", nil, 3*time.Millisecond)
+	stats.observe("ListPodSandbox", status.Error(codes.Unavailable, ""), 2*time.Second)
+	stats.observe("ListPodSandbox", nil, 20*time.Millisecond)
+
+	assert.Equal(t, map[criCallKey]uint64{
+		{method: "ListPodSandbox", code: "OK"}:          2,
+		{method: "ListPodSandbox", code: "Unavailable"}: 1,
+	}, stats.requests)
+	durations := stats.durations["ListPodSandbox"]
+	assert.Equal(t, uint64(3), durations.count)
+	assert.InDelta(t, 2.023, durations.sum, 1e-9)
+	assert.Equal(t, uint64(0), durations.buckets[0.001])
+	assert.Equal(t, uint64(1), durations.buckets[0.005])
+	assert.Equal(t, uint64(2), durations.buckets[0.025])
+	assert.Equal(t, uint64(2), durations.buckets[1])
+	assert.Equal(t, uint64(3), durations.buckets[2.5])
+}
+
+func TestCRIRateLimiter(t *testing.T) {
+	unlimited := newCRIRateLimiter(0, 0)
+	for range 100 {
+		assert.True(t, unlimited.Allow())
+	}
+
+	limited := newCRIRateLimiter(1, 2)
+	assert.True(t, limited.Allow())
+	assert.True(t, limited.Allow())
+	assert.False(t, limited.Allow())
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func TestParseCRISocketEnv(t *testing.T) {
+	assert.Equal(t, []criSocket{{path: "/run/containerd/containerd.sock"}}, parseCRISocketEnv("/run/containerd/containerd.sock"))
+	assert.Equal(t, []criSocket{
+		{path: "/run/containerd/containerd.sock"},
+		{name: "kata", path: "/run/kata-containerd/containerd.sock"},
+	}, parseCRISocketEnv("/run/containerd/containerd.sock, kata=/run/kata-containerd/containerd.sock,"))
+	assert.Nil(t, parseCRISocketEnv(""))
+}
+
+// fakeRuntimeService answers PodSandboxStatus with the errors of the sandboxes
+type fakeRuntimeService struct {
+	criruntime.RuntimeServiceClient
+	errs  map[string]error
+	calls map[string]int
+}
+
+func (f *fakeRuntimeService) PodSandboxStatus(
+	_ context.Context,
+	req *criruntime.PodSandboxStatusRequest,
+	_ ...grpc.CallOption,
+) (*criruntime.PodSandboxStatusResponse, error) {
+	f.calls[req.PodSandboxId]++
+	if err := f.errs[req.PodSandboxId]; err != nil {
+		return nil, err
+	}
+	return &criruntime.PodSandboxStatusResponse{Status: &criruntime.PodSandboxStatus{
+		Id:       req.PodSandboxId,
+		Metadata: &criruntime.PodSandboxMetadata{Name: req.PodSandboxId, Namespace: "default"},
+	}}, nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(status.Error(codes.Unavailable, "connection refused")))
+	assert.True(t, isRetryable(status.Error(codes.DeadlineExceeded, "timeout")))
+	assert.True(t, isRetryable(fmt.Errorf("wrapped: %w", status.Error(codes.ResourceExhausted, "busy"))))
+	assert.False(t, isRetryable(status.Error(codes.NotFound, "sandbox not found")))
+	assert.False(t, isRetryable(status.Error(codes.Unimplemented, "")))
+	assert.True(t, isRetryable(&apiStatusError{path: "/containers/json", status: "500 Internal Server Error", statusCode: 500}))
+	assert.False(t, isRetryable(&apiStatusError{path: "/containers/abc/json", status: "404 Not Found", statusCode: 404}))
+	assert.False(t, isRetryable(&apiStatusError{path: "/containers/json", status: "400 Bad Request", statusCode: 400}))
+	assert.True(t, isRetryable(&url.Error{Op: "Get", URL: "http://docker/containers/json", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}))
+	assert.True(t, isRetryable(fmt.Errorf("decoding: %w", io.ErrUnexpectedEOF)))
+	assert.False(t, isRetryable(errors.New("invalid character 'x' looking for beginning of value")))
+}
+
+func TestSandboxInfos(t *testing.T) {
+	newClient := func(errs map[string]error) (*criClient, *criConn, *fakeRuntimeService) {
+		c := newCRIClient(CosanetCollectorOptions{CRI: CRIOptions{Timeout: time.Second, Retries: 1, BreakerThreshold: 2}})
+		fake := &fakeRuntimeService{errs: errs, calls: make(map[string]int)}
+		return c, &criConn{client: fake, breaker: c.breakerFor("/run/fake.sock"), socketPath: "/run/fake.sock"}, fake
+	}
+	sandboxes := func(ids ...string) []*criruntime.PodSandbox {
+		var list []*criruntime.PodSandbox
+		for _, id := range ids {
+			list = append(list, &criruntime.PodSandbox{Id: id})
+		}
+		return list
+	}
+
+	// A sandbox removed since listed is skipped without retry
+	c, conn, fake := newClient(map[string]error{"gone": status.Error(codes.NotFound, "not found")})
+	infos, err := c.sandboxInfos(conn, "", sandboxes("web", "gone", "db"), nil)
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, 1, fake.calls["gone"])
+	assert.False(t, conn.breaker.isOpen())
+
+	// A runtime hanging on the status calls opens the breaker
+	hang := status.Error(codes.DeadlineExceeded, "timeout")
+	c, conn, fake = newClient(map[string]error{"a": hang, "b": hang, "c": hang})
+	_, err = c.sandboxInfos(conn, "", sandboxes("a", "b", "c"), nil)
+	assert.ErrorIs(t, err, ErrCRIUnavailable)
+	assert.Equal(t, 2, fake.calls["a"], "retried")
+	assert.Zero(t, fake.calls["c"], "given up once the breaker opened")
+	assert.False(t, c.breakerFor("/run/other.sock").isOpen(), "the other runtimes are still called")
+}
+
+func TestThroughBreaker(t *testing.T) {
+	c := newCRIClient(CosanetCollectorOptions{CRI: CRIOptions{BreakerThreshold: 2, BreakerCooldown: time.Hour}})
+
+	// A listing given up by the status call opening the breaker isn't counted twice
+	breaker := c.breakerFor("/run/fake.sock")
+	breaker.Failure()
+	_, err := c.throughBreaker("/run/fake.sock", func() ([]PodInfo, error) {
+		c.breakerFailure("/run/fake.sock", breaker)
+		return nil, fmt.Errorf("%w: %w", ErrCRIUnavailable, errors.New("timeout"))
+	})
+	assert.ErrorIs(t, err, ErrCRIUnavailable)
+	assert.Equal(t, 2, breaker.failures)
+
+	// Not allowed anymore
+	_, err = c.throughBreaker("/run/fake.sock", func() ([]PodInfo, error) {
+		t.Error("listed through an open breaker")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrCRIUnavailable)
+
+	// The other runtimes are still listed
+	infos, err := c.listThroughBreaker("/run/other.sock", func() ([]PodInfo, error) {
+		return []PodInfo{{Name: "web"}}, nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.True(t, breaker.isOpen())
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+// Decisions of the sandboxes served by /debug/pods
+const (
+	podDecisionCollected   = "collected"
+	podDecisionHostNetwork = "host_network"
+	podDecisionFiltered    = "filtered"
+	podDecisionSkipped     = "skipped"
+	// Out of the shard or beyond the max pods
+	podDecisionNotSelected = "not_selected"
+)
+
+// debugPod is a sandbox discovered by the last collection
+type debugPod struct {
+	Name        string                                `json:"name"`
+	Namespace   string                                `json:"namespace"`
+	UID         string                                `json:"uid"`
+	PID         int                                   `json:"pid"`
+	NetNSPath   string                                `json:"netnsPath"`
+	NetNSName   string                                `json:"netnsName"`
+	HostNetwork bool                                  `json:"hostNetwork"`
+	Runtime     string                                `json:"runtime,omitempty"`
+	Decision    string                                `json:"decision"`
+	Reason      string                                `json:"reason,omitempty"`
+	Controller  *controller_resolver.PodControllerRef `json:"controller,omitempty"`
+}
+
+// debugPods is the /debug/pods response
+type debugPods struct {
+	CollectedAt time.Time  `json:"collectedAt"`
+	Pods        []debugPod `json:"pods"`
+}
+
+// podsReport records the decision taken on every sandbox discovered by a
+// collection
+type podsReport struct {
+	collectedAt time.Time
+	pods        []debugPod
+	index       map[string]int
+}
+
+func newPodsReport(infos []PodInfo) *podsReport {
+	r := &podsReport{
+		collectedAt: time.Now(),
+		pods:        make([]debugPod, 0, len(infos)),
+		index:       make(map[string]int, len(infos)),
+	}
+	for _, info := range infos {
+		r.index[podKey(info)] = len(r.pods)
+		r.pods = append(r.pods, debugPod{
+			Name:        info.Name,
+			Namespace:   info.Namespace,
+			UID:         info.UID,
+			PID:         info.PID,
+			NetNSPath:   info.netNSPath,
+			NetNSName:   info.netNSName,
+			HostNetwork: info.HostNetwork,
+			Runtime:     info.Runtime,
+			// Until filtered or collected
+			Decision: podDecisionNotSelected,
+		})
+	}
+	return r
+}
+
+// decide records the decision on the sandbox, nil reports being ignored
+func (r *podsReport) decide(info PodInfo, decision, reason string) {
+	if r == nil {
+		return
+	}
+	if i, ok := r.index[podKey(info)]; ok {
+		r.pods[i].Decision = decision
+		r.pods[i].Reason = reason
+		r.pods[i].HostNetwork = info.HostNetwork
+	}
+}
+
+// lastPods keeps the report of the last collection for /debug/pods
+type lastPods struct {
+	mu     sync.Mutex
+	report *podsReport
+}
+
+func (l *lastPods) set(report *podsReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report = report
+}
+
+func (l *lastPods) get() *podsReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.report
+}
+
+// DebugPodsHandler serves the sandboxes discovered by the last collection as
+// JSON, with the filter decision and the resolved controller of each.
+func (c *CosanetCollector) DebugPodsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := debugPods{Pods: []debugPod{}}
+		if report := c.lastPods.get(); report != nil {
+			resp.CollectedAt = report.collectedAt
+			for _, pod := range report.pods {
+				if ref, found := c.controller_resolver.GetControllerForUid(pod.UID); found {
+					pod.Controller = ref
+				}
+				resp.Pods = append(resp.Pods, pod)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(resp); err != nil {
+			slog.Warn("failed to write the debug pods", slog.Any("err", err))
+		}
+	})
+}
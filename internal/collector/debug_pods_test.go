@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestDebugPodsHandler(t *testing.T) {
+	c := &CosanetCollector{controller_resolver: controller_resolver.NewNoopResolver()}
+
+	// No collection yet
+	rec := httptest.NewRecorder()
+	c.DebugPodsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pods", nil))
+	assert.JSONEq(t, `{"collectedAt": "0001-01-01T00:00:00Z", "pods": []}`, rec.Body.String())
+
+	web := PodInfo{UID: "uid-web", Name: "web", Namespace: "default", PID: 42, netNSPath: "/var/run/netns/cni-1"}
+	debug := PodInfo{UID: "uid-debug", Name: "debug", Namespace: "kube-system"}
+	batch := PodInfo{UID: "uid-batch", Name: "batch", Namespace: "default"}
+	report := newPodsReport([]PodInfo{web, debug, batch})
+	report.decide(web, podDecisionCollected, "")
+	report.decide(debug, podDecisionFiltered, "pod filter: namespace excluded")
+	c.lastPods.set(report)
+
+	rec = httptest.NewRecorder()
+	c.DebugPodsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pods", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var resp debugPods
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Pods, 3)
+	assert.Equal(t, debugPod{
+		Name:      "web",
+		Namespace: "default",
+		UID:       "uid-web",
+		PID:       42,
+		NetNSPath: "/var/run/netns/cni-1",
+		Decision:  podDecisionCollected,
+	}, resp.Pods[0])
+	assert.Equal(t, podDecisionFiltered, resp.Pods[1].Decision)
+	assert.Equal(t, "pod filter: namespace excluded", resp.Pods[1].Reason)
+	// Out of the shard
+	assert.Equal(t, podDecisionNotSelected, resp.Pods[2].Decision)
+}
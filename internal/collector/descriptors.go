@@ -0,0 +1,946 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// All the socket protocols the sockproto collector knows about
+var knownSockProtos = []string{"tcp", "udp", "icmp", "udplite", "raw"}
+
+// Labels shared by every metric emitted from a network namespace.
+// Controller labels are always present (empty when unresolved) so a metric
+// family keeps a single label set and can be described upfront.
+var baseNetnsLabels = []string{
+	"cosanet_node",
+	"cosanet_pod",
+	"cosanet_namespace",
+	"cosanet_netnsname",
+	"cosanet_pod_uid",
+	"cosanet_hostnetwork",
+	"cosanet_pod_controller_kind",
+	"cosanet_pod_controller_name",
+}
+
+// containerNetnsLabels renames the pod labels on hosts running plain
+// docker/podman containers, see CosanetCollectorOptions.ContainerLabels
+var containerNetnsLabels = map[string]string{
+	"cosanet_pod":     "cosanet_container",
+	"cosanet_pod_uid": "cosanet_container_id",
+}
+
+// Prefixes of the labels built from pod labels and annotations
+const (
+	podLabelPrefix      = "cosanet_pod_label_"
+	podAnnotationPrefix = "cosanet_pod_annotation_"
+	nodeLabelPrefix     = "cosanet_node_label_"
+)
+
+// NodeLabelName names the constant label added for a label of the node, as
+// the other labels are named, see labelNames
+func (c *CosanetCollector) NodeLabelName(key string) string {
+	return c.labelNames([]string{nodeLabelPrefix + sanitizeLabelName(key)})[0]
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// sanitizeLabelName turns a Kubernetes label key into a valid Prometheus label name
+func sanitizeLabelName(name string) string {
+	return invalidLabelChars.ReplaceAllString(name, "_")
+}
+
+// buildNetnsLabels returns the label names of every per netns metric, the
+// base ones, the runtime one, followed by the configured pod labels and
+// annotations
+func buildNetnsLabels(podLabels, podAnnotations []string, containers, runtime bool) []string {
+	names := slices.Clone(baseNetnsLabels)
+	if containers {
+		for i, name := range names {
+			if renamed, ok := containerNetnsLabels[name]; ok {
+				names[i] = renamed
+			}
+		}
+	}
+	if runtime {
+		names = append(names, "cosanet_runtime")
+	}
+	for _, key := range podLabels {
+		names = append(names, podLabelPrefix+sanitizeLabelName(key))
+	}
+	for _, key := range podAnnotations {
+		names = append(names, podAnnotationPrefix+sanitizeLabelName(key))
+	}
+	return names
+}
+
+// mustCheckNetnsLabels panics when two netns labels are emitted with the same
+// name, e.g. a repeated pod label key or keys only differing by the
+// characters sanitized or the naming scheme
+func (c *CosanetCollector) mustCheckNetnsLabels() {
+	podLabelsStart := len(c.netnsLabels) - len(c.podLabelKeys) - len(c.podAnnotationKeys)
+	if c.options.CNILabels {
+		podLabelsStart -= len(cniNetnsLabels)
+	}
+	podAnnotationsStart := podLabelsStart + len(c.podLabelKeys)
+	source := func(i int) string {
+		switch {
+		case i >= podLabelsStart && i < podAnnotationsStart:
+			return fmt.Sprintf("pod label %q", c.podLabelKeys[i-podLabelsStart])
+		case i >= podAnnotationsStart && i < podAnnotationsStart+len(c.podAnnotationKeys):
+			return fmt.Sprintf("pod annotation %q", c.podAnnotationKeys[i-podAnnotationsStart])
+		}
+		return "label " + c.netnsLabels[i]
+	}
+	seen := make(map[string]int, len(c.netnsLabels))
+	for i, name := range c.labelNames(c.netnsLabels) {
+		if other, found := seen[name]; found {
+			panic(fmt.Errorf("the %s and %s are both emitted as the %s label", source(other), source(i), name))
+		}
+		seen[name] = i
+	}
+}
+
+// deviceLabels returns the label names of the per interface metrics: the
+// interface, optionally its network attachment, the given extra labels and
+// the netns ones. See deviceLabeler for their values.
+func (c *CosanetCollector) deviceLabels(extra ...string) []string {
+	names := []string{"cosanet_device"}
+	if c.options.Netdev.NetworkAttachment {
+		names = append(names, "cosanet_network")
+	}
+	names = append(names, extra...)
+	return append(names, c.netnsLabels...)
+}
+
+// netnsLabelValues returns the values matching netnsLabels for the given pod
+func (c *CosanetCollector) netnsLabelValues(info PodInfo) []string {
+	var controllerKind, controllerName string
+	// Pods not delivered by the informer yet are resolved for the next scrape
+	if ctrlref, found := c.controller_resolver.ResolveControllerForUid(info.UID, info.Namespace, info.Name); found {
+		controllerKind = ctrlref.Kind
+		controllerName = ctrlref.Name
+	}
+	values := make([]string, 0, len(c.netnsLabels))
+	namespace, netnsName := info.Namespace, info.netNSName
+	if c.options.HostLabels == HostLabelsEmpty {
+		if info.netNSPath == "HOST" && namespace == "HOST" {
+			namespace = ""
+		}
+		if netnsName == "HOST" {
+			netnsName = ""
+		}
+	}
+	values = append(
+		values,
+		c.nodename,
+		info.Name,
+		namespace,
+		netnsName,
+		info.UID,
+		strconv.FormatBool(info.HostNetwork),
+		controllerKind,
+		controllerName,
+	)
+	if c.runtimeLabel {
+		values = append(values, info.Runtime)
+	}
+	if len(c.podLabelKeys) > 0 {
+		podLabels := c.podLabels(info)
+		for _, key := range c.podLabelKeys {
+			values = append(values, podLabels[key])
+		}
+	}
+	if len(c.podAnnotationKeys) > 0 {
+		podAnnotations := c.podAnnotations(info)
+		for _, key := range c.podAnnotationKeys {
+			values = append(values, podAnnotations[key])
+		}
+	}
+	if c.options.CNILabels {
+		values = append(values, cniConvention(info.netNSPath), stableNetnsID(info))
+	}
+	return values
+}
+
+func (c *CosanetCollector) newConntrackCurrDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_curr",
+		"Number of entries in the conntrack table",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newConntrackStatDesc(stat string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_conntrack_stat_%s_total", stat),
+		fmt.Sprintf("Conntrack %s statistic summed over all CPUs", stat),
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newConntrackEntriesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_entries",
+		"Number of conntrack entries per l4 protocol and TCP conntrack state",
+		append([]string{"cosanet_l4proto", "cosanet_tcpstate"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newConntrackZoneEntriesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_zone_entries",
+		"Number of conntrack entries per conntrack zone",
+		append([]string{"cosanet_zone"}, c.netnsLabels...),
+	)
+}
+
+// newConntrackAccountingDesc describes the sum of a counter over the live flows.
+// It is not monotonic as flows vanish from the table, hence a gauge despite the
+// _total suffix.
+func (c *CosanetCollector) newConntrackAccountingDesc(unit string) *metricDesc {
+	labels := c.netnsLabels
+	if c.options.Conntrack.Accounting.SplitDirection {
+		labels = append([]string{"cosanet_direction"}, labels...)
+	}
+	return c.newDesc(
+		fmt.Sprintf("cosanet_conntrack_%s_total", unit),
+		fmt.Sprintf("Sum of the %s accounted to the flows currently in the conntrack table, requires nf_conntrack_acct", unit),
+		labels,
+	)
+}
+
+func (c *CosanetCollector) newConntrackEventsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_events_total",
+		"Number of conntrack flows created or destroyed since the netns is watched",
+		append([]string{"cosanet_event"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newConntrackSaturationDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_saturation",
+		"1 when the conntrack table fill ratio is above the saturation threshold, 0 otherwise",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newConntrackMaxDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_conntrack_max",
+		"Maximum entries in the conntrack table",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newHostNetworkPodDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_hostnetwork_pod_info",
+		"Pod sharing the host network namespace, its counters are the host ones",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newSockProtoDesc(socktype string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_proc_net_%s", socktype),
+		fmt.Sprintf("Socket statistics for %s", socktype),
+		append([]string{"cosanet_state", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSockQueueDesc(socktype string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_proc_net_%s_queue", socktype),
+		fmt.Sprintf("Sum of the %s sockets receive and transmit queues (accept backlog for listening sockets)", socktype),
+		append([]string{"cosanet_queue", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSockQueueMaxDesc(socktype string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_proc_net_%s_queue_max", socktype),
+		fmt.Sprintf("Largest %s socket receive and transmit queue (accept backlog for listening sockets)", socktype),
+		append([]string{"cosanet_queue", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSockPortDesc(socktype string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_proc_net_%s_port", socktype),
+		fmt.Sprintf("Number of %s sockets per state of the selected local ports", socktype),
+		append([]string{"cosanet_port", "cosanet_state", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newTCPRTTDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_tcp_rtt_seconds",
+		"Smoothed RTT of the established TCP sockets",
+		append([]string{"cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newTCPRetransSegmentsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_tcp_retrans_segments",
+		"Sum of the segments retransmitted by the established TCP sockets over their lifetime",
+		append([]string{"cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newTCPRetransmittingDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_tcp_retransmitting_connections",
+		"Number of established TCP sockets with unacknowledged retransmissions",
+		append([]string{"cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newTCPCAStateDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_tcp_ca_state_connections",
+		"Number of established TCP sockets per congestion avoidance state",
+		append([]string{"cosanet_ca_state", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newNetdevDesc(counter string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_netdev_%s_total", counter),
+		fmt.Sprintf("/proc/net/dev %s of the interface", counter),
+		c.deviceLabels(),
+	)
+}
+
+func (c *CosanetCollector) newLinkDesc(name, help string) *metricDesc {
+	return c.newDesc(
+		name,
+		help,
+		c.deviceLabels(),
+	)
+}
+
+func (c *CosanetCollector) newMulticastGroupsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_multicast_groups",
+		"Number of joined multicast groups per interface (/proc/net/igmp and /proc/net/igmp6)",
+		c.deviceLabels("cosanet_ipversion"),
+	)
+}
+
+func (c *CosanetCollector) newNeighborEntriesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_neighbor_entries",
+		"Number of neighbor (ARP/NDP) table entries per state",
+		append([]string{"cosanet_ipversion", "cosanet_state"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newIPFamilyAvailableDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_ip_family_available",
+		"1 when the netns handles the IP family, 0 when the kernel lacks it or it is disabled",
+		append([]string{"cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newInterfacesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_interfaces",
+		"Number of network interfaces, loopback included",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newIPAddressesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_ip_addresses",
+		"Number of IP addresses per scope",
+		append([]string{"cosanet_ipversion", "cosanet_scope"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newNeighborGCThreshDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_neighbor_gc_thresh",
+		"Neighbor table garbage collector threshold of the node",
+		[]string{"cosanet_ipversion", "cosanet_threshold", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newSysctlDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_sysctl",
+		"Value of a net sysctl, multi-valued ones are split by index",
+		append([]string{"cosanet_sysctl", "cosanet_index"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newWireguardDesc(name, help string) *metricDesc {
+	return c.newDesc(
+		name,
+		help,
+		append([]string{"cosanet_device", "cosanet_public_key"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newEthtoolStatDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_ethtool_stat",
+		"Driver statistic of the interface, as shown by ethtool -S",
+		c.deviceLabels("cosanet_stat"),
+	)
+}
+
+func (c *CosanetCollector) newBridgeFDBEntriesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_bridge_fdb_entries",
+		"Number of forwarding database entries of the bridge per type",
+		append([]string{"cosanet_bridge", "cosanet_type"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newBridgeVlansDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_bridge_vlans",
+		"Number of distinct VLANs configured on the bridge and its ports",
+		append([]string{"cosanet_bridge"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newVethPeerInfoDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_veth_peer_info",
+		"Pod side veth with its host side peer, empty when the peer is not in the host netns",
+		append([]string{"cosanet_device", "cosanet_ifindex", "cosanet_peer_ifindex", "cosanet_host_device"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSkMemBytesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_sock_memory_bytes",
+		"Sum over the sockets of the protocol of their memory usage (INET_DIAG skmem)",
+		append([]string{"cosanet_protocol", "cosanet_ipversion", "cosanet_memory"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSkMemDropsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_sock_drops",
+		"Sum over the live sockets of the protocol of their dropped packets",
+		append([]string{"cosanet_protocol", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newSkMemRcvBufUsageDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_sock_rcvbuf_usage_ratio_max",
+		"Highest receive buffer usage (rmem_alloc / rcvbuf) among the sockets of the protocol",
+		append([]string{"cosanet_protocol", "cosanet_ipversion"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newQdiscDesc(name, help string) *metricDesc {
+	return c.newDesc(
+		name,
+		help,
+		c.deviceLabels("cosanet_qdisc", "cosanet_handle", "cosanet_parent"),
+	)
+}
+
+func (c *CosanetCollector) newSoftnetDesc(counter string) *metricDesc {
+	return c.newDesc(
+		fmt.Sprintf("cosanet_softnet_%s_total", counter),
+		fmt.Sprintf("/proc/net/softnet_stat %s counter of the CPU", counter),
+		[]string{"cosanet_cpu", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCRIConnStateDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_cri_connection_state",
+		"State of the gRPC connection to the CRI runtime, 1 for the current one",
+		[]string{"cosanet_state", "cosanet_socket", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCRIRequestsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_cri_requests_total",
+		"Container runtime calls attempts per method and gRPC code",
+		[]string{"cosanet_method", "cosanet_code", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCRIRequestDurationDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_cri_request_duration_seconds",
+		"Container runtime calls attempts latency per method",
+		[]string{"cosanet_method", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCollectionDurationDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collection_duration_seconds",
+		"Duration of the collections of every netns, the cached scrapes left out",
+		[]string{"cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCollectionPhaseDurationDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collection_phase_duration_seconds",
+		"Time spent per collection listing the sandboxes and switching between the netns",
+		[]string{"cosanet_phase", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCollectorDurationDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collector_duration_seconds",
+		"Time spent per collection in each collector, summed over the netns",
+		[]string{"cosanet_collector", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newLogSuppressedDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_log_messages_suppressed_total",
+		"Repeated log records suppressed by the deduplication per level",
+		[]string{"cosanet_level", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newResolverCacheDescs() resolverCacheDescs {
+	labels := []string{"cosanet_cache", "cosanet_node"}
+	return resolverCacheDescs{
+		hits: c.newDesc(
+			"cosanet_resolver_cache_hits_total",
+			"Controller resolver cache lookups finding their entry",
+			labels,
+		),
+		misses: c.newDesc(
+			"cosanet_resolver_cache_misses_total",
+			"Controller resolver cache lookups missing their entry",
+			labels,
+		),
+		evictions: c.newDesc(
+			"cosanet_resolver_cache_evictions_total",
+			"Controller resolver cache entries evicted to make room for new ones",
+			labels,
+		),
+		entries: c.newDesc(
+			"cosanet_resolver_cache_entries",
+			"Current number of entries of the controller resolver cache",
+			labels,
+		),
+		capacity: c.newDesc(
+			"cosanet_resolver_cache_capacity",
+			"Maximum number of entries of the controller resolver cache",
+			labels,
+		),
+		lookupDuration: c.newDesc(
+			"cosanet_resolver_lookup_duration_seconds",
+			"Controller resolver apiserver lookups latency per owner kind",
+			[]string{"cosanet_kind", "cosanet_node"},
+		),
+		capability: c.newDesc(
+			"cosanet_resolver_capability",
+			"Whether the controller resolver can list the resource (1) or degrades without it (0)",
+			[]string{"cosanet_capability", "cosanet_node"},
+		),
+	}
+}
+
+func (c *CosanetCollector) newSkippedSandboxesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_skipped_sandboxes",
+		"Sandboxes selected for collection whose network namespace couldn't be entered, per reason",
+		[]string{"cosanet_reason", "cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCollectionStalledDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collection_stalled",
+		"Whether the running collection is stuck past the watchdog deadline",
+		[]string{"cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newCollectionStallsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collection_stalls_total",
+		"Collections stuck past the watchdog deadline, their netns being abandoned",
+		[]string{"cosanet_node"},
+	)
+}
+
+func (c *CosanetCollector) newContainerInfoDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_container_info",
+		"Running container of the pod with its image",
+		append([]string{"cosanet_container", "cosanet_container_id", "cosanet_image", "cosanet_image_id"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newUnixSocketsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_proc_net_unix",
+		"Number of unix domain sockets per type and state",
+		append([]string{"cosanet_type", "cosanet_state"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newNetlinkSocketsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_proc_net_netlink",
+		"Number of netlink sockets per protocol",
+		append([]string{"cosanet_protocol"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newPacketSocketsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_proc_net_packet",
+		"Number of AF_PACKET sockets per type and protocol",
+		append([]string{"cosanet_type", "cosanet_protocol"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newPacketSocketInfoDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_proc_net_packet_socket_info",
+		"AF_PACKET socket with its bound interface index (0 for all interfaces) and inode",
+		append([]string{"cosanet_type", "cosanet_protocol", "cosanet_ifindex", "cosanet_inode"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newIcmpMsgDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_icmp_messages_total",
+		"ICMP messages per direction and type, from the IcmpMsg section of /proc/net/snmp",
+		append([]string{"cosanet_direction", "cosanet_type"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newPacketDropsDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_packet_drops_total",
+		"Packets dropped by the kernel per drop reason, from the skb:kfree_skb tracepoint",
+		append([]string{"cosanet_reason"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newDNSQueriesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_dns_queries_total",
+		"DNS queries sent over UDP to the port 53, from eBPF",
+		c.netnsLabels,
+	)
+}
+
+func (c *CosanetCollector) newDNSResponsesDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_dns_responses_total",
+		"DNS responses received over UDP from the port 53 per response code, from eBPF",
+		append([]string{"cosanet_rcode"}, c.netnsLabels...),
+	)
+}
+
+func (c *CosanetCollector) newCollectorSuccessDesc() *metricDesc {
+	return c.newDesc(
+		"cosanet_collector_success",
+		"1 when the sub-collector completed in the netns within the collector timeout, 0 on error, timeout or eBPF tracer unavailable",
+		append([]string{"cosanet_collector"}, c.netnsLabels...),
+	)
+}
+
+func procNetMetricName(source, proto, metric string) string {
+	return fmt.Sprintf("cosanet_proc_net_%s_%s", source, procNetCounter(proto, metric))
+}
+
+// procNetKey indexes the /proc/net descriptors, looked up for every counter
+// of every pod without building the metric name
+type procNetKey struct {
+	source, proto, metric string
+}
+
+// descCache memoizes the descriptors of the counters unknown at startup,
+// built on their first sample rather than on each. The collectors left
+// running past their timeout may use it concurrently. The zero value is
+// ready to use.
+type descCache struct {
+	mu    sync.Mutex
+	descs map[procNetKey]*metricDesc
+}
+
+func (d *descCache) get(key procNetKey, build func() *metricDesc) *metricDesc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if desc, ok := d.descs[key]; ok {
+		return desc
+	}
+	if d.descs == nil {
+		d.descs = make(map[procNetKey]*metricDesc)
+	}
+	desc := build()
+	d.descs[key] = desc
+	return desc
+}
+
+// procNetInclude returns the include filter of the /proc/net parsers, the
+// counters not matched by the metric filter being never converted
+func procNetInclude(filter interface{ MatchString(string) bool }) func(section, field string) bool {
+	return func(section, field string) bool {
+		return filter.MatchString(procNetCounter(section, field))
+	}
+}
+
+// procNetCounter joins the section and the counter name, the counters of
+// unknown sections (empty) being named as is
+func procNetCounter(proto, metric string) string {
+	if proto == "" {
+		return metric
+	}
+	return proto + "_" + metric
+}
+
+func (c *CosanetCollector) newProcNetDesc(source, proto, metric string) *metricDesc {
+	return c.newDesc(
+		procNetMetricName(source, proto, metric),
+		fmt.Sprintf("/proc/net/%s %s entry", source, strings.TrimSpace(proto+" "+metric)),
+		c.netnsLabels,
+	)
+}
+
+func procExtraMetricName(name, section, field string) string {
+	return sanitizeLabelName(fmt.Sprintf("cosanet_proc_extra_%s_%s", name, procNetCounter(section, field)))
+}
+
+func (c *CosanetCollector) newProcExtraDesc(file procExtraFile, section, field string) *metricDesc {
+	return c.newDesc(
+		procExtraMetricName(file.name, section, field),
+		fmt.Sprintf("/proc/%s %s entry", file.path, strings.TrimSpace(section+" "+field)),
+		c.netnsLabels,
+	)
+}
+
+// newDesc builds a metric family descriptor, applying the naming scheme then
+// the relabel rules
+func (c *CosanetCollector) newDesc(name, help string, labels []string) *metricDesc {
+	if c.options.MetricNames == MetricNamesSnakeCase {
+		name = snakeCase(name)
+	}
+	name = c.prefixed(name)
+	labels = c.labelNames(labels)
+	d := c.namespaceAggregation.newMetricDesc(c.relabelRules, name, help, labels)
+	c.controllerAggregation.addPerController(d, c.relabelRules, name, help, labels)
+	return d
+}
+
+// buildProcNetDescs reads the host's copy of a /proc/net file to discover the
+// counters exposed by the running kernel. Every netns shares the same kernel,
+// so the fields found here are the ones which will be collected later on.
+func (c *CosanetCollector) buildProcNetDescs(
+	source string,
+	parse func(string) (map[string]map[string]uint64, error),
+) {
+	stats, err := parse(c.procPath("net/" + source))
+	if err != nil {
+		slog.Warn(
+			"unable to discover counters, they won't be described",
+			slog.String("source", source),
+			slog.Any("err", err),
+		)
+		return
+	}
+	for proto, metrics := range stats {
+		if c.icmpMsgFamily(source, proto) {
+			continue
+		}
+		for metric := range metrics {
+			c.procNetDescs[procNetKey{source, proto, metric}] = c.newProcNetDesc(source, proto, metric)
+		}
+	}
+}
+
+// buildProcExtraDescs discovers the counters of an extra procfs file from the
+// host's copy, like buildProcNetDescs
+func (c *CosanetCollector) buildProcExtraDescs(file procExtraFile) {
+	stats, err := file.parse(c.procPath(file.path))
+	if err != nil {
+		slog.Warn(
+			"unable to discover counters, they won't be described",
+			slog.String("source", file.name),
+			slog.Any("err", err),
+		)
+		return
+	}
+	for section, fields := range stats {
+		for field := range fields {
+			c.procExtraDescs[procNetKey{file.name, section, field}] = c.newProcExtraDesc(file, section, field)
+		}
+	}
+}
+
+// buildDescs precomputes the descriptors of every metric family the collector
+// may emit according to its options
+func (c *CosanetCollector) buildDescs() {
+	c.conntrackCurrDesc = c.newConntrackCurrDesc()
+	c.conntrackMaxDesc = c.newConntrackMaxDesc()
+	for i, stat := range conntrackStatNames {
+		c.conntrackStatDescs[i] = c.newConntrackStatDesc(stat)
+	}
+	c.conntrackEntriesDesc = c.newConntrackEntriesDesc()
+	c.conntrackZoneEntriesDesc = c.newConntrackZoneEntriesDesc()
+	c.conntrackBytesDesc = c.newConntrackAccountingDesc("bytes")
+	c.conntrackPacketsDesc = c.newConntrackAccountingDesc("packets")
+	c.conntrackEventsDesc = c.newConntrackEventsDesc()
+	c.conntrackSaturationDesc = c.newConntrackSaturationDesc()
+	c.hostNetworkPodDesc = c.newHostNetworkPodDesc()
+
+	c.sockProtoDescs = make(map[string]*metricDesc, len(knownSockProtos))
+	c.sockQueueDescs = make(map[string]*metricDesc, len(knownSockProtos))
+	c.sockQueueMaxDescs = make(map[string]*metricDesc, len(knownSockProtos))
+	for _, sockproto := range knownSockProtos {
+		c.sockProtoDescs[sockproto] = c.newSockProtoDesc(sockproto)
+		c.sockQueueDescs[sockproto] = c.newSockQueueDesc(sockproto)
+		c.sockQueueMaxDescs[sockproto] = c.newSockQueueMaxDesc(sockproto)
+	}
+	c.sockPortDescs = make(map[string]*metricDesc, len(sockPortProtos))
+	for _, sockproto := range sockPortProtos {
+		c.sockPortDescs[sockproto] = c.newSockPortDesc(sockproto)
+	}
+
+	c.tcpRTTDesc = c.newTCPRTTDesc()
+	c.tcpRetransSegmentsDesc = c.newTCPRetransSegmentsDesc()
+	c.tcpRetransmittingDesc = c.newTCPRetransmittingDesc()
+	c.tcpCAStateDesc = c.newTCPCAStateDesc()
+
+	c.netdevDescs = make([]*metricDesc, len(netdevCounters))
+	for i, counter := range netdevCounters {
+		c.netdevDescs[i] = c.newNetdevDesc(counter)
+	}
+
+	c.linkUpDesc = c.newLinkDesc("cosanet_network_up", "1 when the interface operational state is up")
+	c.linkCarrierDesc = c.newLinkDesc("cosanet_network_carrier", "1 when the interface has a carrier")
+	c.linkCarrierChangesDesc = c.newLinkDesc("cosanet_network_carrier_changes_total", "Number of carrier changes of the interface")
+	c.linkMTUDesc = c.newLinkDesc("cosanet_network_mtu_bytes", "MTU of the interface")
+
+	c.qdiscBytesDesc = c.newQdiscDesc("cosanet_qdisc_bytes_total", "Number of bytes sent by the qdisc")
+	c.qdiscPacketsDesc = c.newQdiscDesc("cosanet_qdisc_packets_total", "Number of packets sent by the qdisc")
+	c.qdiscQlenDesc = c.newQdiscDesc("cosanet_qdisc_qlen", "Number of packets queued in the qdisc")
+	c.qdiscBacklogDesc = c.newQdiscDesc("cosanet_qdisc_backlog_bytes", "Number of bytes queued in the qdisc")
+	c.qdiscDropsDesc = c.newQdiscDesc("cosanet_qdisc_drops_total", "Number of packets dropped by the qdisc")
+	c.qdiscRequeuesDesc = c.newQdiscDesc("cosanet_qdisc_requeues_total", "Number of packets requeued by the qdisc")
+	c.qdiscOverlimitsDesc = c.newQdiscDesc("cosanet_qdisc_overlimits_total", "Number of times the qdisc went over its limits")
+
+	c.criConnStateDesc = c.newCRIConnStateDesc()
+	c.criRequestsDesc = c.newCRIRequestsDesc()
+	c.criRequestDurationDesc = c.newCRIRequestDurationDesc()
+	c.collectionDurationDesc = c.newCollectionDurationDesc()
+	c.collectionPhaseDurationDesc = c.newCollectionPhaseDurationDesc()
+	c.collectorDurationDesc = c.newCollectorDurationDesc()
+	c.skippedSandboxesDesc = c.newSkippedSandboxesDesc()
+	c.collectionStalledDesc = c.newCollectionStalledDesc()
+	c.collectionStallsDesc = c.newCollectionStallsDesc()
+	c.resolverCacheDescs = c.newResolverCacheDescs()
+	c.logSuppressedDesc = c.newLogSuppressedDesc()
+	c.containerInfoDesc = c.newContainerInfoDesc()
+	c.softnetProcessedDesc = c.newSoftnetDesc("processed")
+	c.softnetDroppedDesc = c.newSoftnetDesc("dropped")
+	c.softnetTimeSqueezeDesc = c.newSoftnetDesc("time_squeeze")
+
+	c.unixSocketsDesc = c.newUnixSocketsDesc()
+	c.netlinkSocketsDesc = c.newNetlinkSocketsDesc()
+	c.packetSocketsDesc = c.newPacketSocketsDesc()
+	c.packetSocketInfoDesc = c.newPacketSocketInfoDesc()
+	c.multicastGroupsDesc = c.newMulticastGroupsDesc()
+	c.neighborEntriesDesc = c.newNeighborEntriesDesc()
+	c.interfacesDesc = c.newInterfacesDesc()
+	c.ipFamilyAvailableDesc = c.newIPFamilyAvailableDesc()
+	c.ipAddressesDesc = c.newIPAddressesDesc()
+	c.neighborGCThreshDesc = c.newNeighborGCThreshDesc()
+	c.sysctlDesc = c.newSysctlDesc()
+	c.ethtoolStatDesc = c.newEthtoolStatDesc()
+	c.bridgeFDBEntriesDesc = c.newBridgeFDBEntriesDesc()
+	c.bridgeVlansDesc = c.newBridgeVlansDesc()
+	c.vethPeerInfoDesc = c.newVethPeerInfoDesc()
+	c.skMemBytesDesc = c.newSkMemBytesDesc()
+	c.skMemDropsDesc = c.newSkMemDropsDesc()
+	c.skMemRcvBufUsageDesc = c.newSkMemRcvBufUsageDesc()
+	c.linkSpeedDesc = c.newLinkDesc(
+		"cosanet_network_speed_bytes",
+		"Link speed of the interface in bytes per second, from ethtool",
+	)
+
+	c.wireguardRxBytesDesc = c.newWireguardDesc(
+		"cosanet_wireguard_peer_receive_bytes_total",
+		"Bytes received from the WireGuard peer",
+	)
+	c.wireguardTxBytesDesc = c.newWireguardDesc(
+		"cosanet_wireguard_peer_transmit_bytes_total",
+		"Bytes sent to the WireGuard peer",
+	)
+	c.wireguardAllowedIPsDesc = c.newWireguardDesc(
+		"cosanet_wireguard_peer_allowed_ips",
+		"Number of allowed IPs of the WireGuard peer",
+	)
+	c.wireguardHandshakeDesc = c.newWireguardDesc(
+		"cosanet_wireguard_peer_last_handshake_age_seconds",
+		"Seconds since the last handshake with the WireGuard peer",
+	)
+
+	c.icmpMsgDesc = c.newIcmpMsgDesc()
+	c.packetDropsDesc = c.newPacketDropsDesc()
+	c.dnsQueriesDesc = c.newDNSQueriesDesc()
+	c.dnsResponsesDesc = c.newDNSResponsesDesc()
+	c.collectorSuccessDesc = c.newCollectorSuccessDesc()
+	c.procNetDescs = make(map[procNetKey]*metricDesc)
+	if c.options.Snmp.Enabled {
+		c.buildProcNetDescs("snmp", c.snmpParser.ParseFile)
+		c.buildProcNetDescs("snmp6", c.snmp6Parser.ParseFile)
+	}
+	if c.options.Netstat.Enabled {
+		c.buildProcNetDescs("netstat", c.netstatParser.ParseFile)
+	}
+	if c.options.Xfrm.Enabled {
+		c.buildProcNetDescs("xfrm_stat", c.xfrmParser.ParseFile)
+	}
+	c.procExtraDescs = make(map[procNetKey]*metricDesc)
+	for _, file := range c.procExtraFiles {
+		c.buildProcExtraDescs(file)
+	}
+}
+
+// procNetDesc returns the precomputed descriptor, or the one cached on the
+// first sample when the counter was not known at startup
+func (c *CosanetCollector) procNetDesc(source, proto, metric string) *metricDesc {
+	key := procNetKey{source, proto, metric}
+	if desc, ok := c.procNetDescs[key]; ok {
+		return desc
+	}
+	return c.lateProcNetDescs.get(key, func() *metricDesc {
+		return c.newProcNetDesc(source, proto, metric)
+	})
+}
+
+// procExtraDesc returns the precomputed descriptor, or the one cached on the
+// first sample when the counter was not known at startup
+func (c *CosanetCollector) procExtraDesc(file procExtraFile, section, field string) *metricDesc {
+	key := procNetKey{file.name, section, field}
+	if desc, ok := c.procExtraDescs[key]; ok {
+		return desc
+	}
+	return c.lateProcExtraDescs.get(key, func() *metricDesc {
+		return c.newProcExtraDesc(file, section, field)
+	})
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildNetnsLabels(t *testing.T) {
+	labels := buildNetnsLabels([]string{"app.kubernetes.io/name", "team"}, []string{"owner-email"}, false, false)
+	assert.Equal(t, baseNetnsLabels, labels[:len(baseNetnsLabels)])
+	assert.Equal(t, []string{
+		"cosanet_pod_label_app_kubernetes_io_name",
+		"cosanet_pod_label_team",
+		"cosanet_pod_annotation_owner_email",
+	}, labels[len(baseNetnsLabels):])
+}
+
+func TestBuildNetnsLabelsContainers(t *testing.T) {
+	labels := buildNetnsLabels(nil, nil, true, false)
+	assert.Contains(t, labels, "cosanet_container")
+	assert.Contains(t, labels, "cosanet_container_id")
+	assert.NotContains(t, labels, "cosanet_pod")
+	assert.NotContains(t, labels, "cosanet_pod_uid")
+	assert.Equal(t, "cosanet_pod", baseNetnsLabels[1])
+}
+
+func TestBuildNetnsLabelsRuntime(t *testing.T) {
+	labels := buildNetnsLabels([]string{"team"}, nil, false, true)
+	assert.Equal(t, []string{"cosanet_runtime", "cosanet_pod_label_team"}, labels[len(baseNetnsLabels):])
+}
+
+func TestMustCheckNetnsLabels(t *testing.T) {
+	check := func(podLabels, podAnnotations []string, metricNames string) func() {
+		return func() {
+			c := &CosanetCollector{podLabelKeys: podLabels, podAnnotationKeys: podAnnotations}
+			c.options.MetricNames = metricNames
+			c.netnsLabels = buildNetnsLabels(podLabels, podAnnotations, false, false)
+			c.mustCheckNetnsLabels()
+		}
+	}
+	assert.NotPanics(t, check([]string{"app", "team"}, []string{"app"}, ""))
+	assert.PanicsWithError(t,
+		`the pod label "app.name" and pod label "app_name" are both emitted as the cosanet_pod_label_app_name label`,
+		check([]string{"app.name", "app_name"}, nil, ""),
+	)
+	assert.Panics(t, check([]string{"team", "team"}, nil, ""))
+	assert.Panics(t, check(nil, []string{"appName", "app_name"}, MetricNamesSnakeCase))
+}
+
+func TestProcNetDescLate(t *testing.T) {
+	c := &CosanetCollector{
+		netnsLabels:  buildNetnsLabels(nil, nil, false, false),
+		procNetDescs: make(map[procNetKey]*metricDesc),
+	}
+	c.procNetDescs[procNetKey{"snmp", "Tcp", "CurrEstab"}] = c.newProcNetDesc("snmp", "Tcp", "CurrEstab")
+	assert.Same(t, c.procNetDescs[procNetKey{"snmp", "Tcp", "CurrEstab"}], c.procNetDesc("snmp", "Tcp", "CurrEstab"))
+
+	// Missing from the host file at startup, built once
+	late := c.procNetDesc("snmp", "Sctp", "CurrEstab")
+	assert.Same(t, late, c.procNetDesc("snmp", "Sctp", "CurrEstab"))
+	assert.NotSame(t, late, c.procNetDesc("snmp", "Sctp", "ActiveEstabs"))
+	assert.NotContains(t, c.procNetDescs, procNetKey{"snmp", "Sctp", "CurrEstab"}, "the startup descriptors are left as is")
+}
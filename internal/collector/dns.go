@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"github.com/cosanet/cosanet/internal/dns_queries"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// startDNSQueries starts the DNS tracer, nil when the DNS queries aren't
+// collected
+func startDNSQueries(options CosanetCollectorOptions) *bpfCounters {
+	if !options.DNS.Enabled {
+		return nil
+	}
+	tracer, err := dns_queries.Start()
+	if err != nil {
+		return unavailableBPFCounters(subCollectorDNS, err)
+	}
+	return newBPFCounters(subCollectorDNS, tracer, dns_queries.RcodeName)
+}
+
+// collectAndEmitDNSQueries publishes the DNS queries of the netns, and
+// whether they could be counted with cosanet_collector_success
+func (c *CosanetCollector) collectAndEmitDNSQueries(info PodInfo, ch chan<- prometheus.Metric) error {
+	err := c.emitDNSQueries(info, ch)
+	c.emitCollectorSuccess(subCollectorDNS, info, err, ch)
+	return err
+}
+
+func (c *CosanetCollector) emitDNSQueries(info PodInfo, ch chan<- prometheus.Metric) error {
+	if c.dnsQueries.err != nil {
+		return c.dnsQueries.err
+	}
+	counts, err := c.dnsQueries.netnsCounts()
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	// Zero for the pods which didn't resolve anything yet
+	ch <- c.dnsQueriesDesc.mustNewConstMetric(
+		prometheus.CounterValue,
+		float64(counts[dns_queries.Query]),
+		labelValues...,
+	)
+	for kind, count := range counts {
+		if kind == dns_queries.Query {
+			continue
+		}
+		ch <- c.dnsResponsesDesc.mustNewConstMetric(
+			prometheus.CounterValue,
+			float64(count),
+			append([]string{c.dnsQueries.kindName(kind)}, labelValues...)...,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+	"github.com/cosanet/cosanet/internal/dns_queries"
+)
+
+// fakeTracer counts like an eBPF tracer
+type fakeTracer map[uint32]map[uint32]uint64
+
+func (f fakeTracer) Counts() (map[uint32]map[uint32]uint64, error) {
+	return f, nil
+}
+
+func (f fakeTracer) Forget(forget func(netns uint32) bool) error {
+	for netns := range f {
+		if forget(netns) {
+			delete(f, netns)
+		}
+	}
+	return nil
+}
+
+func TestCollectAndEmitDNSQueries(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+	}
+	c.dnsQueriesDesc = c.newDNSQueriesDesc()
+	c.dnsResponsesDesc = c.newDNSResponsesDesc()
+	c.collectorSuccessDesc = c.newCollectorSuccessDesc()
+
+	var stat unix.Stat_t
+	require.NoError(t, unix.Stat("/proc/thread-self/ns/net", &stat))
+	netns := uint32(stat.Ino)
+	tracer := fakeTracer{
+		netns:     {dns_queries.Query: 10, 0: 7, 3: 2},
+		netns + 1: {dns_queries.Query: 1},
+	}
+	c.dnsQueries = newBPFCounters(subCollectorDNS, tracer, dns_queries.RcodeName)
+	c.dnsQueries.snapshot()
+
+	ch := make(chan prometheus.Metric, 10)
+	require.NoError(t, c.collectAndEmitDNSQueries(PodInfo{Name: "web", Namespace: "default"}, ch))
+	close(ch)
+	values := map[string]float64{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		labels := metricLabels(t, m)
+		assert.Equal(t, "web", labels["cosanet_pod"])
+		if labels["cosanet_collector"] != "" {
+			assert.Equal(t, subCollectorDNS, labels["cosanet_collector"])
+			assert.Equal(t, 1.0, out.GetGauge().GetValue())
+			continue
+		}
+		values[labels["cosanet_rcode"]] = out.GetCounter().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"": 10, "NOERROR": 7, "NXDOMAIN": 2}, values)
+
+	// The netns not collected are forgotten
+	c.dnsQueries.sweep()
+	assert.Contains(t, tracer, netns)
+	assert.NotContains(t, tracer, netns+1)
+}
+
+func TestCollectAndEmitDNSQueries_TracerUnavailable(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+	}
+	c.collectorSuccessDesc = c.newCollectorSuccessDesc()
+	c.dnsQueries = unavailableBPFCounters(subCollectorDNS, errors.New("kprobe __udp_enqueue_schedule_skb: not found"))
+
+	ch := make(chan prometheus.Metric, 10)
+	err := c.collectAndEmitDNSQueries(PodInfo{Name: "web", Namespace: "default"}, ch)
+	close(ch)
+	assert.ErrorIs(t, err, errTracerUnavailable)
+	require.Len(t, ch, 1)
+	var out dto.Metric
+	require.NoError(t, (<-ch).Write(&out))
+	assert.Equal(t, 0.0, out.GetGauge().GetValue())
+}
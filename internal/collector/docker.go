@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Labels set by dockershim / cri-dockerd on the containers it creates
+const (
+	dockerK8sTypeLabel      = "io.kubernetes.docker.type"
+	dockerK8sNameLabel      = "io.kubernetes.pod.name"
+	dockerK8sNamespaceLabel = "io.kubernetes.pod.namespace"
+	dockerK8sUIDLabel       = "io.kubernetes.pod.uid"
+	// Plain docker containers started by compose are grouped by project
+	dockerComposeProjectLabel = "com.docker.compose.project"
+)
+
+// dockerContainer is the subset of `docker inspect` used to build a PodInfo
+type dockerContainer struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	State struct {
+		Running bool `json:"Running"`
+		Pid     int  `json:"Pid"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		SandboxKey string `json:"SandboxKey"`
+	} `json:"NetworkSettings"`
+}
+
+// podInfo maps a container to a PodInfo. Containers joining another one's
+// netns (kubernetes app containers, `--network container:<id>`) are skipped
+// since their owner is listed on its own.
+func (d *dockerContainer) podInfo() (PodInfo, bool) {
+	if !d.State.Running || d.State.Pid == 0 {
+		return PodInfo{}, false
+	}
+	if strings.HasPrefix(d.HostConfig.NetworkMode, "container:") {
+		return PodInfo{}, false
+	}
+	labels := d.Config.Labels
+	info := PodInfo{
+		PID:         d.State.Pid,
+		UID:         d.ID,
+		Name:        strings.TrimPrefix(d.Name, "/"),
+		Namespace:   labels[dockerComposeProjectLabel],
+		Labels:      labels,
+		HostNetwork: d.HostConfig.NetworkMode == "host",
+		netNSPath:   d.NetworkSettings.SandboxKey,
+		netNSName:   filepath.Base(d.NetworkSettings.SandboxKey),
+	}
+	if kind, ok := labels[dockerK8sTypeLabel]; ok {
+		if kind != "podsandbox" {
+			return PodInfo{}, false
+		}
+		info.UID = labels[dockerK8sUIDLabel]
+		info.Name = labels[dockerK8sNameLabel]
+		info.Namespace = labels[dockerK8sNamespaceLabel]
+	}
+	if info.HostNetwork || info.netNSPath == "" {
+		info.netNSPath = "HOST"
+		info.netNSName = "HOST"
+	}
+	return info, true
+}
+
+// doListDockerSandboxes discovers the running containers through the Docker
+// Engine API, for nodes running docker without a CRI shim.
+func (c *criClient) doListDockerSandboxes(socketPath string) ([]PodInfo, error) {
+	client := newUnixHTTPClient(socketPath)
+	defer client.CloseIdleConnections()
+
+	var containers []struct {
+		ID string `json:"Id"`
+	}
+	err := c.withRetry("ContainerList", func(ctx context.Context) error {
+		return dockerGet(ctx, client, "/containers/json", &containers)
+	})
+	if err != nil {
+		slog.Error("Failed to list docker containers", slog.Any("err", err))
+		return nil, err
+	}
+
+	var podInfos []PodInfo
+	for _, ctr := range containers {
+		var inspect dockerContainer
+		err := c.withRetry("ContainerInspect", func(ctx context.Context) error {
+			return dockerGet(ctx, client, "/containers/"+url.PathEscape(ctr.ID)+"/json", &inspect)
+		})
+		if err != nil {
+			slog.Error("Failed to inspect docker container", slog.String("id", ctr.ID), slog.Any("err", err))
+			continue
+		}
+		if info, ok := inspect.podInfo(); ok {
+			podInfos = append(podInfos, info)
+		}
+	}
+	return podInfos, nil
+}
+
+// newUnixHTTPClient returns an HTTP client talking to the API served on a unix socket
+func newUnixHTTPClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// apiStatusError is a Docker (or Podman) API call answered with an error
+// status
+type apiStatusError struct {
+	path       string
+	status     string
+	statusCode int
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("API call %s: %s", e.path, e.status)
+}
+
+// dockerGet decodes the JSON answer of a Docker (or Podman) API GET call
+func dockerGet(ctx context.Context, client *http.Client, path string, v any) error {
+	// The host is ignored by the unix dialer
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &apiStatusError{path: path, status: resp.Status, statusCode: resp.StatusCode}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func getDockerSocketPath() (string, bool) {
+	socketPaths := []string{
+		"/var/run/docker.sock",
+		"/run/docker.sock",
+	}
+	if host, ok := strings.CutPrefix(os.Getenv("DOCKER_HOST"), "unix://"); ok {
+		socketPaths = []string{host}
+	}
+
+	for _, path := range socketPaths {
+		if stat, err := os.Stat(path); err == nil {
+			if stat.Mode()&os.ModeSocket != 0 {
+				slog.Info("Found docker socket", slog.String("path", path))
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inspectContainer(t *testing.T, payload string) dockerContainer {
+	var ctr dockerContainer
+	require.NoError(t, json.Unmarshal([]byte(payload), &ctr))
+	return ctr
+}
+
+func TestDockerContainerPodInfo(t *testing.T) {
+	ctr := inspectContainer(t, `{
+		"Id": "3f4e",
+		"Name": "/web",
+		"State": {"Running": true, "Pid": 4242},
+		"Config": {"Labels": {"com.docker.compose.project": "shop"}},
+		"HostConfig": {"NetworkMode": "bridge"},
+		"NetworkSettings": {"SandboxKey": "/var/run/docker/netns/a1b2c3"}
+	}`)
+	info, ok := ctr.podInfo()
+	require.True(t, ok)
+	assert.Equal(t, 4242, info.PID)
+	assert.Equal(t, "3f4e", info.UID)
+	assert.Equal(t, "web", info.Name)
+	assert.Equal(t, "shop", info.Namespace)
+	assert.False(t, info.HostNetwork)
+	assert.Equal(t, "/var/run/docker/netns/a1b2c3", info.netNSPath)
+	assert.Equal(t, "a1b2c3", info.netNSName)
+
+	// dockershim sandbox
+	ctr = inspectContainer(t, `{
+		"Id": "9c8d",
+		"Name": "/k8s_POD_api_prod_1234_0",
+		"State": {"Running": true, "Pid": 17},
+		"Config": {"Labels": {
+			"io.kubernetes.docker.type": "podsandbox",
+			"io.kubernetes.pod.name": "api",
+			"io.kubernetes.pod.namespace": "prod",
+			"io.kubernetes.pod.uid": "1234"
+		}},
+		"HostConfig": {"NetworkMode": "host"},
+		"NetworkSettings": {"SandboxKey": ""}
+	}`)
+	info, ok = ctr.podInfo()
+	require.True(t, ok)
+	assert.Equal(t, "api", info.Name)
+	assert.Equal(t, "prod", info.Namespace)
+	assert.Equal(t, "1234", info.UID)
+	assert.True(t, info.HostNetwork)
+	assert.Equal(t, "HOST", info.netNSPath)
+
+	for _, payload := range []string{
+		// app container of a dockershim pod
+		`{"State": {"Running": true, "Pid": 18}, "Config": {"Labels": {"io.kubernetes.docker.type": "container"}}}`,
+		// sharing another container's netns
+		`{"State": {"Running": true, "Pid": 19}, "HostConfig": {"NetworkMode": "container:9c8d"}}`,
+		`{"State": {"Running": false, "Pid": 0}}`,
+	} {
+		ctr := inspectContainer(t, payload)
+		_, ok := ctr.podInfo()
+		assert.False(t, ok, payload)
+	}
+}
+
+func TestPodmanPodInfo(t *testing.T) {
+	infra := inspectContainer(t, `{
+		"Id": "77aa",
+		"Name": "0d1e-infra",
+		"State": {"Running": true, "Pid": 900},
+		"HostConfig": {"NetworkMode": "bridge"},
+		"NetworkSettings": {"SandboxKey": "/run/netns/netns-5f2c"}
+	}`)
+	info, ok := podmanPodInfo(podmanListEntry{ID: "77aa", PodName: "edge", IsInfra: true}, &infra)
+	require.True(t, ok)
+	assert.Equal(t, "edge", info.Name)
+	assert.Equal(t, "edge", info.Namespace)
+	assert.Equal(t, "netns-5f2c", info.netNSName)
+
+	standalone := inspectContainer(t, `{
+		"Id": "88bb",
+		"Name": "mqtt",
+		"State": {"Running": true, "Pid": 901},
+		"HostConfig": {"NetworkMode": "bridge"},
+		"NetworkSettings": {"SandboxKey": "/run/netns/netns-6a3d"}
+	}`)
+	info, ok = podmanPodInfo(podmanListEntry{ID: "88bb"}, &standalone)
+	require.True(t, ok)
+	assert.Equal(t, "mqtt", info.Name)
+	assert.Equal(t, "", info.Namespace)
+
+	member := inspectContainer(t, `{
+		"Id": "99cc",
+		"State": {"Running": true, "Pid": 902},
+		"HostConfig": {"NetworkMode": "container:77aa"}
+	}`)
+	_, ok = podmanPodInfo(podmanListEntry{ID: "99cc", PodName: "edge"}, &member)
+	assert.False(t, ok)
+}
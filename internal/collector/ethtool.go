@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cosanet/cosanet/internal/ethtool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (c *CosanetCollector) collectAndEmitEthtool(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := dumpLinks()
+	if err != nil {
+		return err
+	}
+	client, err := ethtool.Open()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	labeler := c.newDeviceLabeler(info)
+	var errs []error
+	for _, link := range links {
+		if !c.netdevFilter.Match(link.name) {
+			continue
+		}
+		deviceLabelValues := labeler.values(link.name)
+
+		speed, known, err := client.Speed(link.name)
+		if err != nil && !errors.Is(err, ethtool.ErrNotSupported) {
+			errs = append(errs, err)
+		}
+		if known {
+			// Mb/s to bytes per second
+			ch <- c.linkSpeedDesc.mustNewConstMetric(prometheus.GaugeValue, float64(speed)*1e6/8, deviceLabelValues...)
+		}
+
+		stats, err := client.Stats(link.name)
+		if errors.Is(err, ethtool.ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", link.name, err))
+			continue
+		}
+		for stat, value := range stats {
+			if !c.ethtoolStatFilter.MatchString(stat) {
+				continue
+			}
+			ch <- c.ethtoolStatDesc.mustNewConstMetric(
+				prometheus.UntypedValue,
+				float64(value),
+				labeler.values(link.name, stat)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// icmpMsgSection is the /proc/net/snmp section with one column per ICMP type
+// seen by the kernel (InType3, OutType8...)
+const icmpMsgSection = "IcmpMsg"
+
+var icmpMsgDirections = []struct {
+	prefix    string
+	direction string
+}{
+	{"InType", "in"},
+	{"OutType", "out"},
+}
+
+// parseIcmpMsgField splits an IcmpMsg column (eg: OutType8) into its
+// direction and ICMP type
+func parseIcmpMsgField(field string) (string, string, bool) {
+	for _, d := range icmpMsgDirections {
+		icmpType, found := strings.CutPrefix(field, d.prefix)
+		if !found {
+			continue
+		}
+		if _, err := strconv.ParseUint(icmpType, 10, 8); err != nil {
+			return "", "", false
+		}
+		return d.direction, icmpType, true
+	}
+	return "", "", false
+}
+
+// icmpMsgInclude makes the snmp parser keep the IcmpMsg columns whatever the
+// metric filter, their family being emitted instead of one metric per column
+func icmpMsgInclude(include func(section, field string) bool) func(section, field string) bool {
+	return func(section, field string) bool {
+		return section == icmpMsgSection || include(section, field)
+	}
+}
+
+// icmpMsgFamily tells if the section is emitted as cosanet_icmp_messages_total
+// rather than one metric per column
+func (c *CosanetCollector) icmpMsgFamily(source, proto string) bool {
+	return c.options.Snmp.IcmpMsg && source == "snmp" && proto == icmpMsgSection
+}
+
+// publishIcmpMsg emits the IcmpMsg columns with their direction and type as
+// labels
+func (c *CosanetCollector) publishIcmpMsg(counters map[string]uint64, info PodInfo, ch chan<- prometheus.Metric) {
+	labelValues := c.netnsLabelValues(info)
+	for field, value := range counters {
+		direction, icmpType, ok := parseIcmpMsgField(field)
+		if !ok {
+			continue
+		}
+		ch <- c.icmpMsgDesc.mustNewConstMetric(
+			prometheus.CounterValue,
+			float64(value),
+			append([]string{direction, icmpType}, labelValues...)...,
+		)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestParseIcmpMsgField(t *testing.T) {
+	tests := []struct {
+		field     string
+		direction string
+		icmpType  string
+		ok        bool
+	}{
+		{"InType3", "in", "3", true},
+		{"OutType8", "out", "8", true},
+		{"OutType255", "out", "255", true},
+		{"OutType256", "", "", false},
+		{"InType", "", "", false},
+		{"InTypeX", "", "", false},
+		{"InMsgs", "", "", false},
+	}
+	for _, tt := range tests {
+		direction, icmpType, ok := parseIcmpMsgField(tt.field)
+		assert.Equal(t, tt.ok, ok, tt.field)
+		assert.Equal(t, tt.direction, direction, tt.field)
+		assert.Equal(t, tt.icmpType, icmpType, tt.field)
+	}
+}
+
+func TestIcmpMsgInclude(t *testing.T) {
+	include := icmpMsgInclude(func(section, field string) bool { return section == "Tcp" })
+	assert.True(t, include("IcmpMsg", "InType3"))
+	assert.True(t, include("Tcp", "CurrEstab"))
+	assert.False(t, include("Icmp", "InMsgs"))
+}
+
+func TestPublishIcmpMsg(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+	}
+	c.options.Snmp.IcmpMsg = true
+	c.icmpMsgDesc = c.newIcmpMsgDesc()
+	assert.True(t, c.icmpMsgFamily("snmp", "IcmpMsg"))
+	assert.False(t, c.icmpMsgFamily("snmp", "Icmp"))
+
+	ch := make(chan prometheus.Metric, 10)
+	c.publishIcmpMsg(map[string]uint64{"InType3": 12, "OutType8": 4, "Unknown": 1}, PodInfo{Name: "web", Namespace: "default"}, ch)
+	close(ch)
+	values := map[string]float64{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		labels := metricLabels(t, m)
+		values[labels["cosanet_direction"]+"/"+labels["cosanet_type"]] = out.GetCounter().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"in/3": 12, "out/8": 4}, values)
+}
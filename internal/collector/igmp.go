@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/cosanet/cosanet/pkg/netstat"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (c *CosanetCollector) collectAndEmitMulticastGroups(info PodInfo, ch chan<- prometheus.Metric) error {
+	labeler := c.newDeviceLabeler(info)
+	var errs []error
+	for _, source := range []struct {
+		ipversion string
+		read      func(string) (map[string]int, error)
+	}{
+		{"ipv4", netstat.IGMPGroupsFromProcfs},
+		{"ipv6", netstat.IGMP6GroupsFromProcfs},
+	} {
+		groups, err := source.read(c.options.ProcRoot)
+		if errors.Is(err, fs.ErrNotExist) {
+			// IPv6 disabled or kernel without multicast support
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for device, count := range groups {
+			if !c.netdevFilter.Match(device) {
+				continue
+			}
+			ch <- c.multicastGroupsDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				labeler.values(device, source.ipversion)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ipFamilyFiles exist in the netns when its kernel handles the family: IPv6
+// may be left out of the kernel or disabled at boot (ipv6.disable=1)
+var ipFamilyFiles = []struct {
+	name string
+	file string
+}{
+	{"ipv4", "net/snmp"},
+	{"ipv6", "net/if_inet6"},
+}
+
+// detectMissingIPFamilies lists the families the current netns lacks, the
+// kernel not handling them or IPv6 being disabled in the netns (the
+// disable_ipv6 sysctl)
+func (c *CosanetCollector) detectMissingIPFamilies() []string {
+	var missing []string
+	for _, family := range ipFamilyFiles {
+		if _, err := os.Stat(c.procPath(family.file)); err != nil {
+			missing = append(missing, family.name)
+		}
+	}
+	if !slices.Contains(missing, "ipv6") {
+		disabled, err := os.ReadFile(c.procPath("sys/net/ipv6/conf/all/disable_ipv6"))
+		if err == nil && strings.TrimSpace(string(disabled)) == "1" {
+			missing = append(missing, "ipv6")
+		}
+	}
+	return missing
+}
+
+// hasIPFamily tells whether the netns handles the family, named after
+// cosanet_ipversion
+func (info PodInfo) hasIPFamily(name string) bool {
+	return !slices.Contains(info.missingIPFamilies, name)
+}
+
+func (c *CosanetCollector) emitIPFamilies(info PodInfo, ch chan<- prometheus.Metric) {
+	labelValues := c.netnsLabelValues(info)
+	for _, family := range ipFamilyFiles {
+		ch <- c.ipFamilyAvailableDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			boolToFloat(info.hasIPFamily(family.name)),
+			append([]string{family.name}, labelValues...)...,
+		)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestDetectMissingIPFamilies(t *testing.T) {
+	procRoot := t.TempDir()
+	c := &CosanetCollector{}
+	c.options.ProcRoot = procRoot
+	write := func(name, content string) {
+		path := filepath.Join(procRoot, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	// Kernel without IPv6
+	write("net/snmp", "")
+	assert.Equal(t, []string{"ipv6"}, c.detectMissingIPFamilies())
+
+	write("net/if_inet6", "")
+	assert.Empty(t, c.detectMissingIPFamilies())
+
+	// IPv6 disabled in the netns
+	write("sys/net/ipv6/conf/all/disable_ipv6", "1\n")
+	assert.Equal(t, []string{"ipv6"}, c.detectMissingIPFamilies())
+	write("sys/net/ipv6/conf/all/disable_ipv6", "0\n")
+	assert.Empty(t, c.detectMissingIPFamilies())
+
+	// IPv6 only
+	require.NoError(t, os.Remove(filepath.Join(procRoot, "net/snmp")))
+	assert.Equal(t, []string{"ipv4"}, c.detectMissingIPFamilies())
+}
+
+func TestEmitIPFamilies(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+	}
+	c.ipFamilyAvailableDesc = c.newIPFamilyAvailableDesc()
+	info := PodInfo{Name: "web", Namespace: "default", missingIPFamilies: []string{"ipv4"}}
+	assert.False(t, info.hasIPFamily("ipv4"))
+	assert.True(t, info.hasIPFamily("ipv6"))
+	assert.True(t, PodInfo{}.hasIPFamily("ipv4"), "every family until detected")
+
+	ch := make(chan prometheus.Metric, 2)
+	c.emitIPFamilies(info, ch)
+	close(ch)
+	values := map[string]float64{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		values[metricLabels(t, m)["cosanet_ipversion"]] = out.GetGauge().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"ipv4": 0, "ipv6": 1}, values)
+}
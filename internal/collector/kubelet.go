@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Discovery modes
+const (
+	DiscoveryModeCRI        = "cri"
+	DiscoveryModeKubelet    = "kubelet"
+	DiscoveryModeContainerd = "containerd"
+)
+
+type KubeletOptions struct {
+	// URL of the kubelet API, eg: https://127.0.0.1:10250 or the read-only
+	// http://127.0.0.1:10255
+	URL string
+	// Bearer token sent to the kubelet, empty for none
+	TokenFile string
+	// CA bundle verifying the kubelet certificate, empty for the system roots
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+func mustParseDiscoveryMode(mode string) string {
+	switch mode {
+	case "", DiscoveryModeCRI:
+		return DiscoveryModeCRI
+	case DiscoveryModeKubelet, DiscoveryModeContainerd:
+		return mode
+	}
+	panic(fmt.Errorf("unknown discovery mode %q, expected cri, kubelet or containerd", mode))
+}
+
+// kubeletClient discovers the pods from the kubelet API, for the nodes where
+// the CRI socket can't be mounted. The kubelet doesn't know the pods PIDs,
+// they are found from the pod UID in the processes cgroup.
+type kubeletClient struct {
+	options  KubeletOptions
+	client   *http.Client
+	procRoot string
+}
+
+func newKubeletClient(options KubeletOptions, timeout time.Duration, procRoot string) (*kubeletClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", options.CAFile)
+		}
+	}
+	return &kubeletClient{
+		options: options,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		procRoot: procRoot,
+	}, nil
+}
+
+func mustNewKubeletClient(options CosanetCollectorOptions) *kubeletClient {
+	if options.Discovery.Mode != DiscoveryModeKubelet {
+		return nil
+	}
+	client, err := newKubeletClient(options.Discovery.Kubelet, options.CRI.Timeout, options.ProcRoot)
+	if err != nil {
+		panic(fmt.Errorf("invalid kubelet client configuration: %w", err))
+	}
+	return client
+}
+
+// listSandboxes returns the running pods of the node with the PID of one of
+// their processes, pods without any process found are skipped
+func (k *kubeletClient) listSandboxes() ([]PodInfo, error) {
+	pods, err := k.listPods()
+	if err != nil {
+		return nil, err
+	}
+	pids, err := podPIDsFromCgroups(k.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]PodInfo, 0, len(pods))
+	for _, pod := range pods {
+		info, ok := kubeletPodInfo(pod)
+		if !ok {
+			continue
+		}
+		// hostNetwork pods are collected with the host
+		if info.HostNetwork {
+			infos = append(infos, info)
+			continue
+		}
+		if info.PID, ok = pids[info.UID]; !ok {
+			slog.Debug(
+				"no process found for the pod",
+				slog.String("name", info.Name),
+				slog.String("namespace", info.Namespace),
+			)
+			continue
+		}
+		if inode, err := netnsInode(filepath.Join(k.procRoot, strconv.Itoa(info.PID), "ns/net")); err == nil {
+			info.netNSName = fmt.Sprintf("net:[%d]", inode)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (k *kubeletClient) listPods() ([]corev1.Pod, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(k.options.URL, "/")+"/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+	if k.options.TokenFile != "" {
+		// Read on every call, projected tokens are rotated
+		token, err := os.ReadFile(k.options.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("kubelet pods list: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var podList corev1.PodList
+	if err := json.NewDecoder(resp.Body).Decode(&podList); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// kubeletPodInfo maps a running pod to a PodInfo, without its PID
+func kubeletPodInfo(pod corev1.Pod) (PodInfo, bool) {
+	if pod.Status.Phase != corev1.PodRunning {
+		return PodInfo{}, false
+	}
+	info := PodInfo{
+		UID:         string(pod.UID),
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+		HostNetwork: pod.Spec.HostNetwork,
+	}
+	if info.HostNetwork {
+		info.netNSPath = "HOST"
+		info.netNSName = "HOST"
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil {
+			continue
+		}
+		// containerd://<id>, cri-o://<id>...
+		id := status.ContainerID
+		if _, after, found := strings.Cut(id, "://"); found {
+			id = after
+		}
+		info.Containers = append(info.Containers, ContainerInfo{
+			ID:      id,
+			Name:    status.Name,
+			Image:   status.Image,
+			ImageID: status.ImageID,
+		})
+	}
+	return info, true
+}
+
+// podUIDPattern matches the pod cgroup, either cgroupfs (pod<uid>) or systemd
+// (kubepods-<qos>-pod<uid with underscores>.slice) named
+var podUIDPattern = regexp.MustCompile(`[/-]pod([0-9a-f]{8}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{12})(?:\.slice)?(?:/|$)`)
+
+// podUIDFromCgroup returns the UID of the pod owning a process, given its
+// /proc/<pid>/cgroup content
+func podUIDFromCgroup(cgroup string) (string, bool) {
+	match := podUIDPattern.FindStringSubmatch(cgroup)
+	if match == nil {
+		return "", false
+	}
+	return strings.ReplaceAll(match[1], "_", "-"), true
+}
+
+// podPIDsFromCgroups returns the lowest PID of every pod running processes
+func podPIDsFromCgroups(procRoot string) (map[string]int, error) {
+	pids, err := procPIDs(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	podPIDs := make(map[string]int)
+	for _, pid := range pids {
+		cgroup, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cgroup"))
+		if err != nil {
+			// Process gone
+			continue
+		}
+		uid, ok := podUIDFromCgroup(string(cgroup))
+		if !ok {
+			continue
+		}
+		if _, found := podPIDs[uid]; !found {
+			podPIDs[uid] = pid
+		}
+	}
+	return podPIDs, nil
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodUIDFromCgroup(t *testing.T) {
+	for cgroup, expected := range map[string]string{
+		// cgroup v2, systemd driver
+		"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6b8e2c1a_4f3d_4a2b_9c1e_2d3f4a5b6c7d.slice/cri-containerd-abc.scope\n": "6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d",
+		// cgroup v1, cgroupfs driver
+		"12:memory:/kubepods/besteffort/pod6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d/0123abcd\n1:cpu:/kubepods/besteffort/pod6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d/0123abcd\n": "6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d",
+		// guaranteed QoS, static pod UID (config hash)
+		"0::/kubepods.slice/kubepods-pod0f1e2d3c4b5a69788796a5b4c3d2e1f0.slice/cri-containerd-abc.scope\n": "0f1e2d3c4b5a69788796a5b4c3d2e1f0",
+	} {
+		uid, ok := podUIDFromCgroup(cgroup)
+		assert.True(t, ok, cgroup)
+		assert.Equal(t, expected, uid, cgroup)
+	}
+
+	for _, cgroup := range []string{
+		"0::/system.slice/sshd.service\n",
+		"0::/kubepods.slice/kubepods-burstable.slice\n",
+	} {
+		_, ok := podUIDFromCgroup(cgroup)
+		assert.False(t, ok, cgroup)
+	}
+}
+
+func TestPodPIDsFromCgroups(t *testing.T) {
+	procRoot := t.TempDir()
+	for pid, cgroup := range map[string]string{
+		"1":   "0::/init.scope\n",
+		"120": "0::/kubepods/pod6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d/pause\n",
+		"98":  "0::/kubepods/pod6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d/app\n",
+		"300": "0::/kubepods/burstable/pod11111111-2222-3333-4444-555555555555/app\n",
+	} {
+		require.NoError(t, os.Mkdir(filepath.Join(procRoot, pid), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(procRoot, pid, "cgroup"), []byte(cgroup), 0o644))
+	}
+
+	pids, err := podPIDsFromCgroups(procRoot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d": 98,
+		"11111111-2222-3333-4444-555555555555": 300,
+	}, pids)
+}
+
+func TestKubeletPodInfo(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-0",
+			Namespace: "shop",
+			UID:       "6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d",
+			Labels:    map[string]string{"app": "web"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:        "app",
+					Image:       "nginx:1.27",
+					ImageID:     "docker.io/library/nginx@sha256:bbb",
+					ContainerID: "containerd://0123abcd",
+					State:       corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+				{
+					Name:  "migrate",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+				},
+			},
+		},
+	}
+	info, ok := kubeletPodInfo(pod)
+	require.True(t, ok)
+	assert.Equal(t, "web-0", info.Name)
+	assert.Equal(t, "shop", info.Namespace)
+	assert.Equal(t, "6b8e2c1a-4f3d-4a2b-9c1e-2d3f4a5b6c7d", info.UID)
+	assert.Equal(t, map[string]string{"app": "web"}, info.Labels)
+	assert.False(t, info.HostNetwork)
+	assert.Equal(t, []ContainerInfo{
+		{ID: "0123abcd", Name: "app", Image: "nginx:1.27", ImageID: "docker.io/library/nginx@sha256:bbb"},
+	}, info.Containers)
+
+	pod.Spec.HostNetwork = true
+	info, ok = kubeletPodInfo(pod)
+	require.True(t, ok)
+	assert.Equal(t, "HOST", info.netNSPath)
+
+	pod.Status.Phase = corev1.PodPending
+	_, ok = kubeletPodInfo(pod)
+	assert.False(t, ok)
+}
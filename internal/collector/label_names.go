@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Values of the pod labels of the host netns entry
+const (
+	// HostLabelsSentinel labels the host entry and the hostNetwork pods with
+	// HOST as namespace and netns name
+	HostLabelsSentinel = "sentinel"
+	// HostLabelsEmpty leaves them empty, ie: absent from the series
+	HostLabelsEmpty = "empty"
+)
+
+func mustParseHostLabels(values string) string {
+	switch values {
+	case "", HostLabelsSentinel:
+		return HostLabelsSentinel
+	case HostLabelsEmpty:
+		return values
+	}
+	panic(fmt.Errorf("unknown host labels %q, expected sentinel or empty", values))
+}
+
+// Labels renamed by CosanetCollectorOptions.LabelNames, per key
+var renamableLabels = map[string][]string{
+	"node":      {"cosanet_node"},
+	"pod":       {"cosanet_pod", containerNetnsLabels["cosanet_pod"]},
+	"namespace": {"cosanet_namespace"},
+	"netnsname": {"cosanet_netnsname"},
+}
+
+var validLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// mustParseLabelNames parses the comma separated key=name list of the label
+// renames, eg: node=node,namespace=namespace, into the new name per label
+func mustParseLabelNames(list string) map[string]string {
+	names := make(map[string]string)
+	used := make(map[string]string)
+	for _, item := range splitList(list) {
+		key, name, _ := strings.Cut(item, "=")
+		key, name = strings.TrimSpace(key), strings.TrimSpace(name)
+		labels, ok := renamableLabels[key]
+		if !ok {
+			panic(fmt.Errorf("unknown label %q in label names, expected node, pod, namespace or netnsname", key))
+		}
+		if !validLabelName.MatchString(name) {
+			panic(fmt.Errorf("invalid name %q for the %s label", name, key))
+		}
+		if other, found := used[name]; found && other != key {
+			panic(fmt.Errorf("the %s and %s labels can't both be named %s", other, key, name))
+		}
+		used[name] = key
+		for _, label := range labels {
+			names[label] = name
+		}
+	}
+	// The new names must not clash with the labels keeping theirs
+	emitted := make(map[string]bool, len(baseNetnsLabels))
+	for _, label := range baseNetnsLabels {
+		if name, ok := names[label]; ok {
+			label = name
+		}
+		if emitted[label] {
+			panic(fmt.Errorf("the label names give two labels the name %s", label))
+		}
+		emitted[label] = true
+	}
+	return names
+}
+
+// LabelNamer returns the naming of the labels of the collectors configured by
+// options, see labelNames, for the federation to find the labels of the pods
+// it federates
+func LabelNamer(options CosanetCollectorOptions) func(label string) string {
+	options.MetricNames = mustParseMetricNames(options.MetricNames)
+	c := &CosanetCollector{options: options, renamedLabels: mustParseLabelNames(options.LabelNames)}
+	return func(label string) string {
+		return c.labelNames([]string{label})[0]
+	}
+}
+
+// labelNames returns the emitted names of the labels: converted to the
+// naming scheme then renamed, or prefixed with the metric prefix when
+// LabelPrefix is set
+func (c *CosanetCollector) labelNames(labels []string) []string {
+	prefixed := c.options.LabelPrefix && c.customPrefix()
+	if c.options.MetricNames != MetricNamesSnakeCase && len(c.renamedLabels) == 0 && !prefixed {
+		return labels
+	}
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		if c.options.MetricNames == MetricNamesSnakeCase {
+			label = snakeCase(label)
+		}
+		if name, ok := c.renamedLabels[label]; ok {
+			label = name
+		} else if prefixed {
+			label = c.prefixed(label)
+		}
+		names[i] = label
+	}
+	return names
+}
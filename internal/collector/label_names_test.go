@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestMustParseLabelNames(t *testing.T) {
+	assert.Empty(t, mustParseLabelNames(""))
+	assert.Equal(t, map[string]string{
+		"cosanet_node":      "node",
+		"cosanet_pod":       "pod",
+		"cosanet_container": "pod",
+	}, mustParseLabelNames("node=node, pod=pod"))
+
+	for _, list := range []string{
+		"host=node",
+		"node=",
+		"node=k8s-node",
+		"node=node,pod=node",
+		"namespace=cosanet_pod_uid",
+	} {
+		assert.Panics(t, func() { mustParseLabelNames(list) }, list)
+	}
+	// Swapping names is fine
+	assert.NotPanics(t, func() { mustParseLabelNames("pod=cosanet_namespace,namespace=cosanet_pod") })
+}
+
+func TestLabelNames(t *testing.T) {
+	c := &CosanetCollector{
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+		renamedLabels:       mustParseLabelNames("node=node,namespace=namespace,netnsname=netns"),
+	}
+	c.options.MetricNames = MetricNamesSnakeCase
+	c.options.HostLabels = HostLabelsEmpty
+	c.relabelRules = mustCompileRelabelRules([]RelabelRule{
+		{Action: RelabelMapValue, Label: "namespace", Regex: "kube-(.*)", Replacement: "$1"},
+	})
+	c.conntrackCurrDesc = c.newConntrackCurrDesc()
+	c.skippedSandboxesDesc = c.newSkippedSandboxesDesc()
+
+	m := c.conntrackCurrDesc.mustNewConstMetric(prometheus.UntypedValue, 1, c.netnsLabelValues(PodInfo{
+		Namespace:   "HOST",
+		HostNetwork: true,
+		netNSPath:   "HOST",
+		netNSName:   "HOST",
+	})...)
+	labels := metricLabels(t, m)
+	assert.Equal(t, "", labels["namespace"])
+	assert.Equal(t, "", labels["netns"])
+	assert.Equal(t, "true", labels["cosanet_hostnetwork"])
+	assert.NotContains(t, labels, "cosanet_namespace")
+
+	// The hostNetwork pods keep their namespace
+	m = c.conntrackCurrDesc.mustNewConstMetric(prometheus.UntypedValue, 1, c.netnsLabelValues(PodInfo{
+		Name:        "proxy",
+		Namespace:   "kube-system",
+		HostNetwork: true,
+		netNSPath:   "HOST",
+		netNSName:   "HOST",
+	})...)
+	labels = metricLabels(t, m)
+	assert.Equal(t, "system", labels["namespace"], "relabel rules see the new names")
+	assert.Equal(t, "", labels["netns"])
+	assert.Equal(t, "proxy", labels["cosanet_pod"])
+
+	m = c.skippedSandboxesDesc.mustNewConstMetric(prometheus.GaugeValue, 0, "netns_unavailable", "node1")
+	assert.Equal(t, map[string]string{"cosanet_reason": "netns_unavailable", "node": "node1"}, metricLabels(t, m))
+}
+
+func TestLabelNamer(t *testing.T) {
+	name := LabelNamer(CosanetCollectorOptions{LabelNames: "node=node"})
+	assert.Equal(t, "node", name("cosanet_node"))
+	assert.Equal(t, "cosanet_namespace", name("cosanet_namespace"))
+
+	name = LabelNamer(CosanetCollectorOptions{MetricPrefix: "acme", LabelPrefix: true})
+	assert.Equal(t, "acme_node", name("cosanet_node"))
+	assert.Equal(t, "acme_pod_label_", name("cosanet_pod_label_"))
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// IF_OPER_UP, see RFC 2863 operational states
+const operStateUp = 6
+
+var errTruncatedLink = errors.New("truncated link message")
+
+// linkAttrs holds the link attributes the link collector exposes
+type linkAttrs struct {
+	index          int
+	name           string
+	mtu            uint32
+	operUp         bool
+	carrier        bool
+	carrierChanges uint32
+	// kind is the link type (veth, bridge...), empty for physical devices
+	kind string
+	// peerIndex is the ifindex of the veth peer, in its own netns
+	peerIndex int
+}
+
+// parseLinkMessage decodes a RTM_NEWLINK message
+func parseLinkMessage(m []byte) (linkAttrs, error) {
+	var link linkAttrs
+	if len(m) < unix.SizeofIfInfomsg {
+		return link, errTruncatedLink
+	}
+	link.index = int(nl.DeserializeIfInfomsg(m).Index)
+	attrs, err := nl.ParseRouteAttr(m[unix.SizeofIfInfomsg:])
+	if err != nil {
+		return link, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.IFLA_IFNAME:
+			link.name = string(bytes.TrimRight(attr.Value, "\x00"))
+		case unix.IFLA_MTU:
+			if len(attr.Value) >= 4 {
+				link.mtu = binary.NativeEndian.Uint32(attr.Value)
+			}
+		case unix.IFLA_OPERSTATE:
+			if len(attr.Value) >= 1 {
+				link.operUp = attr.Value[0] == operStateUp
+			}
+		case unix.IFLA_CARRIER:
+			if len(attr.Value) >= 1 {
+				link.carrier = attr.Value[0] != 0
+			}
+		case unix.IFLA_CARRIER_CHANGES:
+			if len(attr.Value) >= 4 {
+				link.carrierChanges = binary.NativeEndian.Uint32(attr.Value)
+			}
+		case unix.IFLA_LINK:
+			if len(attr.Value) >= 4 {
+				link.peerIndex = int(binary.NativeEndian.Uint32(attr.Value))
+			}
+		case unix.IFLA_LINKINFO:
+			infos, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				return link, err
+			}
+			for _, info := range infos {
+				if info.Attr.Type == unix.IFLA_INFO_KIND {
+					link.kind = string(bytes.TrimRight(info.Value, "\x00"))
+				}
+			}
+		}
+	}
+	return link, nil
+}
+
+// dumpLinks lists the links of the current network namespace
+func dumpLinks() ([]linkAttrs, error) {
+	req := nl.NewNetlinkRequest(unix.RTM_GETLINK, unix.NLM_F_DUMP)
+	req.AddData(nl.NewIfInfomsg(unix.AF_UNSPEC))
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWLINK)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]linkAttrs, 0, len(msgs))
+	for _, m := range msgs {
+		link, err := parseLinkMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (c *CosanetCollector) collectAndEmitLinks(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := dumpLinks()
+	if err != nil {
+		return err
+	}
+	labeler := c.newDeviceLabeler(info)
+	for _, link := range links {
+		if !c.netdevFilter.Match(link.name) {
+			continue
+		}
+		deviceLabelValues := labeler.values(link.name)
+		ch <- c.linkUpDesc.mustNewConstMetric(prometheus.GaugeValue, boolToFloat(link.operUp), deviceLabelValues...)
+		ch <- c.linkCarrierDesc.mustNewConstMetric(prometheus.GaugeValue, boolToFloat(link.carrier), deviceLabelValues...)
+		ch <- c.linkCarrierChangesDesc.mustNewConstMetric(prometheus.CounterValue, float64(link.carrierChanges), deviceLabelValues...)
+		ch <- c.linkMTUDesc.mustNewConstMetric(prometheus.GaugeValue, float64(link.mtu), deviceLabelValues...)
+	}
+	return nil
+}
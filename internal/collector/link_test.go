@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseLinkMessage(t *testing.T) {
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = 2
+	m := msg.Serialize()
+	linkInfo := nl.NewRtAttr(unix.IFLA_LINKINFO, nil)
+	linkInfo.AddRtAttr(unix.IFLA_INFO_KIND, nl.NonZeroTerminated("veth"))
+	for _, attr := range []*nl.RtAttr{
+		nl.NewRtAttr(unix.IFLA_IFNAME, nl.ZeroTerminated("eth0")),
+		nl.NewRtAttr(unix.IFLA_MTU, nl.Uint32Attr(1450)),
+		nl.NewRtAttr(unix.IFLA_OPERSTATE, []byte{operStateUp}),
+		nl.NewRtAttr(unix.IFLA_CARRIER, []byte{1}),
+		nl.NewRtAttr(unix.IFLA_CARRIER_CHANGES, nl.Uint32Attr(3)),
+		nl.NewRtAttr(unix.IFLA_LINK, nl.Uint32Attr(12)),
+		linkInfo,
+	} {
+		m = append(m, attr.Serialize()...)
+	}
+
+	link, err := parseLinkMessage(m)
+	require.NoError(t, err)
+	assert.Equal(t, linkAttrs{index: 2, name: "eth0", mtu: 1450, operUp: true, carrier: true, carrierChanges: 3, kind: "veth", peerIndex: 12}, link)
+
+	_, err = parseLinkMessage(m[:4])
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// emitLogSuppressed publishes the number of repeated log records suppressed
+// per level, absent when the logs aren't deduplicated
+func (c *CosanetCollector) emitLogSuppressed(ch chan<- prometheus.Metric) {
+	if c.options.LogDedup == nil {
+		return
+	}
+	for level, count := range c.options.LogDedup.Suppressed() {
+		ch <- c.logSuppressedDesc.mustNewConstMetric(
+			prometheus.CounterValue,
+			float64(count),
+			strings.ToLower(level.String()),
+			c.nodename,
+		)
+	}
+}
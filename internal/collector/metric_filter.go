@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// metricFilter matches the counters matched by any of the include regexes
+// (every counter when there is none) and by none of the exclude ones
+type metricFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// mustCompileMetricFilter compiles the regexes of the source, panicking on
+// invalid ones
+func mustCompileMetricFilter(source string, include, exclude []string) *metricFilter {
+	compile := func(kind string, exprs []string) []*regexp.Regexp {
+		regexes := make([]*regexp.Regexp, 0, len(exprs))
+		for _, expr := range exprs {
+			regex, err := regexp.Compile(expr)
+			if err != nil {
+				panic(fmt.Errorf("invalid %s metric %s regex: %w", source, kind, err))
+			}
+			regexes = append(regexes, regex)
+		}
+		return regexes
+	}
+	return &metricFilter{
+		include: compile("include", include),
+		exclude: compile("exclude", exclude),
+	}
+}
+
+func (f *metricFilter) MatchString(counter string) bool {
+	included := len(f.include) == 0
+	for _, regex := range f.include {
+		if regex.MatchString(counter) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, regex := range f.exclude {
+		if regex.MatchString(counter) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricFilter(t *testing.T) {
+	f := mustCompileMetricFilter("netstat", []string{"^TcpExt_", "^IpExt_(In|Out)Octets$"}, []string{"^TcpExt_TCPMD5"})
+	assert.True(t, f.MatchString("TcpExt_ListenDrops"))
+	assert.True(t, f.MatchString("IpExt_InOctets"))
+	assert.False(t, f.MatchString("IpExt_InBcastPkts"))
+	assert.False(t, f.MatchString("TcpExt_TCPMD5Failure"))
+
+	all := mustCompileMetricFilter("xfrm", nil, []string{"Error$"})
+	assert.True(t, all.MatchString("Xfrm_InStateExpired"))
+	assert.False(t, all.MatchString("Xfrm_InError"))
+
+	assert.Panics(t, func() { mustCompileMetricFilter("snmp", []string{"("}, nil) })
+	assert.Panics(t, func() { mustCompileMetricFilter("snmp", nil, []string{"("}) })
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Metric naming schemes
+const (
+	// MetricNamesLegacy keeps the kernel counter names as is, eg:
+	// cosanet_proc_net_snmp_Tcp_CurrEstab
+	MetricNamesLegacy = "legacy"
+	// MetricNamesSnakeCase follows the Prometheus conventions, eg:
+	// cosanet_proc_net_snmp_tcp_curr_estab
+	MetricNamesSnakeCase = "snake_case"
+)
+
+// DefaultMetricPrefix is the namespace of the metric and label names
+const DefaultMetricPrefix = "cosanet"
+
+func mustParseMetricPrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultMetricPrefix
+	}
+	if !validLabelName.MatchString(prefix) {
+		panic(fmt.Errorf("invalid metric prefix %q", prefix))
+	}
+	return prefix
+}
+
+// MetricPrefix returns the namespace of the metric names
+func (c *CosanetCollector) MetricPrefix() string {
+	return c.options.MetricPrefix
+}
+
+// customPrefix tells whether the metric prefix isn't the default one
+func (c *CosanetCollector) customPrefix() bool {
+	return c.options.MetricPrefix != "" && c.options.MetricPrefix != DefaultMetricPrefix
+}
+
+// prefixed replaces the default prefix of the name by the configured one
+func (c *CosanetCollector) prefixed(name string) string {
+	if !c.customPrefix() {
+		return name
+	}
+	if rest, found := strings.CutPrefix(name, DefaultMetricPrefix+"_"); found {
+		return c.options.MetricPrefix + "_" + rest
+	}
+	return name
+}
+
+func mustParseMetricNames(scheme string) string {
+	switch scheme {
+	case "", MetricNamesLegacy:
+		return MetricNamesLegacy
+	case MetricNamesSnakeCase:
+		return scheme
+	}
+	panic(fmt.Errorf("unknown metric names %q, expected legacy or snake_case", scheme))
+}
+
+// snakeCase lowers a metric or label name, splitting its CamelCase words:
+// TCPSackRecoveryFail becomes tcp_sack_recovery_fail and Icmp6InMsgs
+// icmp6_in_msgs
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	b.Grow(len(name) + 8)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"cosanet_proc_net_snmp_Tcp_CurrEstab", "cosanet_proc_net_snmp_tcp_curr_estab"},
+		{"cosanet_proc_net_netstat_TcpExt_TCPSackRecoveryFail", "cosanet_proc_net_netstat_tcp_ext_tcp_sack_recovery_fail"},
+		{"cosanet_proc_net_netstat_TcpExt_TW", "cosanet_proc_net_netstat_tcp_ext_tw"},
+		{"cosanet_proc_net_snmp6_Icmp6_OutType136", "cosanet_proc_net_snmp6_icmp6_out_type136"},
+		{"cosanet_proc_net_snmp6_Foo6InBars", "cosanet_proc_net_snmp6_foo6_in_bars"},
+		{"cosanet_proc_net_netstat_MPTcpExt_MPCapableSYNRX", "cosanet_proc_net_netstat_mp_tcp_ext_mp_capable_synrx"},
+		{"cosanet_pod_label_app_kubernetes_io_Name", "cosanet_pod_label_app_kubernetes_io_name"},
+		{"cosanet_conntrack_curr", "cosanet_conntrack_curr"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, snakeCase(tt.name), tt.name)
+	}
+}
+
+func TestMustParseMetricNames(t *testing.T) {
+	assert.Equal(t, MetricNamesLegacy, mustParseMetricNames(""))
+	assert.Equal(t, MetricNamesSnakeCase, mustParseMetricNames("snake_case"))
+	assert.Panics(t, func() { mustParseMetricNames("camel") })
+}
+
+func TestNewDesc_SnakeCase(t *testing.T) {
+	c := &CosanetCollector{}
+	c.options.MetricNames = MetricNamesSnakeCase
+	d := c.newDesc("cosanet_proc_net_snmp_Tcp_CurrEstab", "help", []string{"cosanet_pod_label_app_Tier"})
+	assert.Contains(t, d.desc.String(), `fqName: "cosanet_proc_net_snmp_tcp_curr_estab"`)
+	assert.Contains(t, d.desc.String(), `variableLabels: {cosanet_pod_label_app_tier}`)
+
+	c.options.MetricNames = MetricNamesLegacy
+	d = c.newDesc("cosanet_proc_net_snmp_Tcp_CurrEstab", "help", nil)
+	assert.Contains(t, d.desc.String(), `fqName: "cosanet_proc_net_snmp_Tcp_CurrEstab"`)
+}
+
+func TestNewDesc_MetricPrefix(t *testing.T) {
+	assert.Equal(t, DefaultMetricPrefix, mustParseMetricPrefix(""))
+	assert.Equal(t, "acme_net", mustParseMetricPrefix("acme_net"))
+	assert.Panics(t, func() { mustParseMetricPrefix("acme-net") })
+
+	c := &CosanetCollector{renamedLabels: mustParseLabelNames("node=node")}
+	c.options.MetricPrefix = "acme_net"
+	d := c.newDesc("cosanet_conntrack_curr", "help", []string{"cosanet_node", "cosanet_pod"})
+	assert.Contains(t, d.desc.String(), `fqName: "acme_net_conntrack_curr"`)
+	assert.Contains(t, d.desc.String(), `variableLabels: {node,cosanet_pod}`)
+
+	c.options.LabelPrefix = true
+	d = c.newDesc("cosanet_conntrack_curr", "help", []string{"cosanet_node", "cosanet_pod"})
+	assert.Contains(t, d.desc.String(), `variableLabels: {node,acme_net_pod}`, "renamed labels keep their name")
+	assert.Equal(t, "acme_net_node_label_topology_kubernetes_io_zone", c.NodeLabelName("topology.kubernetes.io/zone"))
+
+	c.options.LabelPrefix = false
+	c.options.MetricNames = MetricNamesSnakeCase
+	assert.Equal(t, "cosanet_node_label_node_pool", c.NodeLabelName("nodePool"))
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// Network attachments status set by Multus, the second one being deprecated
+const (
+	networkStatusAnnotation           = "k8s.v1.cni.cncf.io/network-status"
+	deprecatedNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/networks-status"
+)
+
+type networkStatus struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface"`
+}
+
+// networkAttachments maps the interfaces of the pod to the name of their
+// network attachment, from the Multus network status annotation
+func networkAttachments(annotations map[string]string) map[string]string {
+	status, found := annotations[networkStatusAnnotation]
+	if !found {
+		status, found = annotations[deprecatedNetworkStatusAnnotation]
+	}
+	if !found {
+		return nil
+	}
+	var statuses []networkStatus
+	if err := json.Unmarshal([]byte(status), &statuses); err != nil {
+		slog.Debug("invalid network status annotation", slog.Any("err", err))
+		return nil
+	}
+	networks := make(map[string]string, len(statuses))
+	for _, status := range statuses {
+		if status.Interface != "" {
+			networks[status.Interface] = status.Name
+		}
+	}
+	return networks
+}
+
+// deviceLabeler builds the label values of the interfaces of a netns, see
+// deviceLabels for their names
+type deviceLabeler struct {
+	withNetwork bool
+	networks    map[string]string
+	netns       []string
+}
+
+func (c *CosanetCollector) newDeviceLabeler(info PodInfo) deviceLabeler {
+	l := deviceLabeler{
+		withNetwork: c.options.Netdev.NetworkAttachment,
+		netns:       c.netnsLabelValues(info),
+	}
+	if l.withNetwork {
+		l.networks = networkAttachments(c.podAnnotations(info))
+	}
+	return l
+}
+
+func (l deviceLabeler) values(device string, extra ...string) []string {
+	values := make([]string, 0, 2+len(extra)+len(l.netns))
+	values = append(values, device)
+	if l.withNetwork {
+		values = append(values, l.networks[device])
+	}
+	values = append(values, extra...)
+	return append(values, l.netns...)
+}
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkAttachments(t *testing.T) {
+	status := `[
+		{"name": "cbr0", "interface": "eth0", "ips": ["10.244.1.5"], "default": true},
+		{"name": "default/macvlan-conf", "interface": "net1", "ips": ["192.168.1.200"]},
+		{"name": "default/sriov", "ips": ["192.168.2.3"]}
+	]`
+	assert.Equal(t,
+		map[string]string{"eth0": "cbr0", "net1": "default/macvlan-conf"},
+		networkAttachments(map[string]string{networkStatusAnnotation: status}),
+	)
+	assert.Equal(t,
+		map[string]string{"eth0": "cbr0", "net1": "default/macvlan-conf"},
+		networkAttachments(map[string]string{deprecatedNetworkStatusAnnotation: status}),
+	)
+	assert.Nil(t, networkAttachments(nil))
+	assert.Nil(t, networkAttachments(map[string]string{networkStatusAnnotation: "{"}))
+}
+
+func TestDeviceLabeler(t *testing.T) {
+	l := deviceLabeler{netns: []string{"ns", "pod"}}
+	assert.Equal(t, []string{"eth0", "fq_codel", "ns", "pod"}, l.values("eth0", "fq_codel"))
+
+	l = deviceLabeler{withNetwork: true, networks: map[string]string{"net1": "default/macvlan-conf"}, netns: []string{"ns", "pod"}}
+	assert.Equal(t, []string{"net1", "default/macvlan-conf", "ns", "pod"}, l.values("net1"))
+	assert.Equal(t, []string{"eth0", "", "ns", "pod"}, l.values("eth0"))
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Netns labels kept by the namespace aggregation, the other ones identifying
+// the pod
+var namespaceAggregationLabels = []string{"cosanet_node", "cosanet_namespace"}
+
+// How the metrics of an aggregated family are combined
+type aggregation int
+
+const (
+	aggregationNone aggregation = iota
+	aggregationSum
+	// The _max gauges keep the maximum of the pods
+	aggregationMax
+)
+
+// namespaceAggregation selects the metric families summed per Kubernetes
+// namespace, see CosanetCollectorOptions.NamespaceAggregation
+type namespaceAggregation struct {
+	metrics *regexp.Regexp
+	// drop_label rules of the pod level netns labels, applied before the
+	// relabel rules
+	rules []relabelRule
+}
+
+// mustNewNamespaceAggregation builds the aggregation of the metrics, given
+// the netns labels and their names once emitted
+func mustNewNamespaceAggregation(metrics string, netnsLabels, names []string) *namespaceAggregation {
+	regex, err := compileAnchored(metrics)
+	if err != nil {
+		panic(fmt.Errorf("invalid namespace aggregation metrics regex: %w", err))
+	}
+	if regex == nil {
+		return nil
+	}
+	a := &namespaceAggregation{metrics: regex}
+	for i, label := range netnsLabels {
+		if !slices.Contains(namespaceAggregationLabels, label) {
+			a.rules = append(a.rules, relabelRule{action: RelabelDropLabel, label: names[i]})
+		}
+	}
+	return a
+}
+
+// newMetricDesc builds the descriptor of the family, without the pod labels
+// when it is aggregated
+func (a *namespaceAggregation) newMetricDesc(rules []relabelRule, name, help string, labels []string) *metricDesc {
+	if a == nil || !a.metrics.MatchString(name) {
+		return newMetricDesc(rules, name, help, labels)
+	}
+	d := newMetricDesc(append(slices.Clip(a.rules), rules...), name, help, labels)
+	d.aggregation = aggregationSum
+	if strings.HasSuffix(name, "_max") {
+		d.aggregation = aggregationMax
+	}
+	return d
+}
+
+// aggregatedMetric is a metric of an aggregated family, combined with the
+// ones of the other pods of its namespace by aggregateByNamespace. It is a
+// regular metric otherwise, eg: for /api/v1/netns.
+type aggregatedMetric struct {
+	prometheus.Metric
+	desc        *metricDesc
+	labelValues []string
+	valueType   prometheus.ValueType
+	value       float64
+	histogram   bool
+	count       uint64
+	sum         float64
+	buckets     map[float64]uint64
+}
+
+func (m *aggregatedMetric) key() string {
+	return strings.Join(m.labelValues, "\xff")
+}
+
+// add combines other, of the same series, into m
+func (m *aggregatedMetric) add(other *aggregatedMetric) {
+	switch {
+	case m.histogram:
+		m.count += other.count
+		m.sum += other.sum
+		for bound, count := range other.buckets {
+			m.buckets[bound] += count
+		}
+	case m.desc.aggregation == aggregationMax:
+		m.value = max(m.value, other.value)
+	default:
+		m.value += other.value
+	}
+}
+
+func (m *aggregatedMetric) mustNewConstMetric() prometheus.Metric {
+	if m.histogram {
+		return prometheus.MustNewConstHistogram(m.desc.desc, m.count, m.sum, m.buckets, m.labelValues...)
+	}
+	return prometheus.MustNewConstMetric(m.desc.desc, m.valueType, m.value, m.labelValues...)
+}
+
+// aggregateByNamespace returns the channel combining the aggregated metrics
+// sent to it, passing the other ones to ch, and the func emitting the
+// combined metrics to ch once the collection is done.
+func (c *CosanetCollector) aggregateByNamespace(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	if c.namespaceAggregation == nil {
+		return ch, func() {}
+	}
+	return combineMetrics(ch, func(m prometheus.Metric) (prometheus.Metric, *aggregatedMetric) {
+		if agg, ok := m.(*aggregatedMetric); ok {
+			return nil, agg
+		}
+		return m, nil
+	})
+}
+
+// combineMetrics returns the channel combining the aggregated metrics split
+// tells apart, passing the rest to ch, and the func emitting the combined
+// metrics to ch once the collection is done.
+func combineMetrics(
+	ch chan<- prometheus.Metric,
+	split func(prometheus.Metric) (prometheus.Metric, *aggregatedMetric),
+) (chan<- prometheus.Metric, func()) {
+	aggCh := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	// In the order of their first metric, per family then series
+	var families []*metricDesc
+	series := make(map[*metricDesc]map[string]*aggregatedMetric)
+	order := make(map[*metricDesc][]string)
+	go func() {
+		defer close(done)
+		for m := range aggCh {
+			m, agg := split(m)
+			if m != nil {
+				ch <- m
+			}
+			if agg == nil {
+				continue
+			}
+			family, ok := series[agg.desc]
+			if !ok {
+				family = make(map[string]*aggregatedMetric)
+				series[agg.desc] = family
+				families = append(families, agg.desc)
+			}
+			key := agg.key()
+			if total, ok := family[key]; ok {
+				total.add(agg)
+				continue
+			}
+			total := *agg
+			if total.histogram {
+				total.buckets = make(map[float64]uint64, len(agg.buckets))
+				for bound, count := range agg.buckets {
+					total.buckets[bound] = count
+				}
+			}
+			family[key] = &total
+			order[agg.desc] = append(order[agg.desc], key)
+		}
+	}()
+	return aggCh, func() {
+		close(aggCh)
+		<-done
+		for _, d := range families {
+			for _, key := range order[d] {
+				ch <- series[d][key].mustNewConstMetric()
+			}
+		}
+	}
+}
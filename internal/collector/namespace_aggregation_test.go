@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateByNamespace(t *testing.T) {
+	c := &CosanetCollector{netnsLabels: buildNetnsLabels([]string{"app"}, nil, false, false)}
+	c.namespaceAggregation = mustNewNamespaceAggregation("cosanet_proc_net_tcp.*", c.netnsLabels, c.netnsLabels)
+	sockets := c.newSockProtoDesc("tcp")
+	queueMax := c.newSockQueueMaxDesc("tcp")
+	udp := c.newSockProtoDesc("udp")
+	assert.NotContains(t, sockets.desc.String(), "cosanet_pod")
+	assert.Contains(t, udp.desc.String(), "cosanet_pod_label_app")
+
+	netnsLabels := func(pod, namespace string) []string {
+		return []string{"node1", pod, namespace, "netns-" + pod, "uid-" + pod, "false", "", "", "web"}
+	}
+	ch := make(chan prometheus.Metric, 10)
+	aggCh, emitAggregated := c.aggregateByNamespace(ch)
+	for _, pod := range []struct {
+		name, namespace string
+		established     float64
+	}{{"a", "default", 3}, {"b", "default", 5}, {"c", "kube-system", 1}} {
+		labels := netnsLabels(pod.name, pod.namespace)
+		aggCh <- sockets.mustNewConstMetric(prometheus.GaugeValue, pod.established, append([]string{"ESTABLISHED", "4"}, labels...)...)
+		aggCh <- queueMax.mustNewConstMetric(prometheus.GaugeValue, pod.established, append([]string{"rx", "4"}, labels...)...)
+		aggCh <- udp.mustNewConstMetric(prometheus.GaugeValue, 1, append([]string{"CLOSE", "4"}, labels...)...)
+	}
+	emitAggregated()
+	close(ch)
+
+	type series struct{ family, namespace string }
+	values := map[series]float64{}
+	var perPod int
+	for m := range ch {
+		labels := metricLabels(t, m)
+		if labels["cosanet_pod"] != "" {
+			perPod++
+			continue
+		}
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		assert.Equal(t, "node1", labels["cosanet_node"])
+		values[series{labels["cosanet_queue"], labels["cosanet_namespace"]}] = out.GetGauge().GetValue()
+	}
+	assert.Equal(t, 3, perPod, "the other families are passed as is")
+	assert.Equal(t, map[series]float64{
+		{"", "default"}:       8,
+		{"", "kube-system"}:   1,
+		{"rx", "default"}:     5,
+		{"rx", "kube-system"}: 1,
+	}, values)
+}
+
+func TestMustNewNamespaceAggregation(t *testing.T) {
+	assert.Nil(t, mustNewNamespaceAggregation("", baseNetnsLabels, baseNetnsLabels))
+	assert.Panics(t, func() { mustNewNamespaceAggregation("(", baseNetnsLabels, baseNetnsLabels) })
+}
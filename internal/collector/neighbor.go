@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+)
+
+// Neighbor states, see NUD_* in include/uapi/linux/neighbour.h
+var neighStates = map[int]string{
+	netlink.NUD_NONE:       "NONE",
+	netlink.NUD_INCOMPLETE: "INCOMPLETE",
+	netlink.NUD_REACHABLE:  "REACHABLE",
+	netlink.NUD_STALE:      "STALE",
+	netlink.NUD_DELAY:      "DELAY",
+	netlink.NUD_PROBE:      "PROBE",
+	netlink.NUD_FAILED:     "FAILED",
+	netlink.NUD_NOARP:      "NOARP",
+	netlink.NUD_PERMANENT:  "PERMANENT",
+}
+
+var neighFamilies = []sockFamily{
+	{"ipv4", netlink.FAMILY_V4},
+	{"ipv6", netlink.FAMILY_V6},
+}
+
+// Neighbor table garbage collector thresholds, only exposed by the host netns
+var neighGCThresholds = []string{"gc_thresh1", "gc_thresh2", "gc_thresh3"}
+
+func neighStateName(state int) string {
+	if name, found := neighStates[state]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", state)
+}
+
+// countNeighbors counts the neighbor entries per state
+func countNeighbors(neighs []netlink.Neigh) map[string]int {
+	counts := make(map[string]int)
+	for _, neigh := range neighs {
+		counts[neighStateName(neigh.State)]++
+	}
+	return counts
+}
+
+func (c *CosanetCollector) collectAndEmitNeighbors(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+	var errs []error
+	for _, family := range neighFamilies {
+		if !info.hasIPFamily(family.name) {
+			continue
+		}
+		neighs, err := netlink.NeighList(0, int(family.family))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family.name, err))
+			continue
+		}
+		for state, count := range countNeighbors(neighs) {
+			ch <- c.neighborEntriesDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{family.name, state}, labelValues...)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectAndEmitNeighborGCThresholds publishes the neighbor table thresholds.
+// The kernel only exposes them in the host netns, so they are read once.
+func (c *CosanetCollector) collectAndEmitNeighborGCThresholds(ch chan<- prometheus.Metric) error {
+	var errs []error
+	for _, family := range neighFamilies {
+		for _, threshold := range neighGCThresholds {
+			content, err := os.ReadFile(c.procPath(fmt.Sprintf("sys/net/%s/neigh/default/%s", family.name, threshold)))
+			if errors.Is(err, os.ErrNotExist) && family.family == netlink.FAMILY_V6 {
+				// IPv6 disabled
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			value, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ch <- c.neighborGCThreshDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(value),
+				family.name, threshold, c.nodename,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,19 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestCountNeighbors(t *testing.T) {
+	counts := countNeighbors([]netlink.Neigh{
+		{State: netlink.NUD_REACHABLE},
+		{State: netlink.NUD_STALE},
+		{State: netlink.NUD_REACHABLE},
+		{State: netlink.NUD_FAILED},
+		{State: netlink.NUD_STALE | netlink.NUD_PROBE},
+	})
+	assert.Equal(t, map[string]int{"REACHABLE": 2, "STALE": 1, "FAILED": 1, "0x14": 1}, counts)
+}
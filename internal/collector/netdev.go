@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"regexp"
+
+	"github.com/cosanet/cosanet/internal/procnet_dev_parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// /proc/net/dev counters exposed by the netdev collector
+var netdevCounters = []string{
+	"rx_bytes",
+	"rx_packets",
+	"rx_errs",
+	"rx_drop",
+	"tx_bytes",
+	"tx_packets",
+	"tx_errs",
+	"tx_drop",
+}
+
+// deviceFilter selects the interfaces by name. Excludes take precedence over includes.
+type deviceFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func newDeviceFilter(include, exclude string) deviceFilter {
+	return deviceFilter{
+		include: compileOptional(include),
+		exclude: compileOptional(exclude),
+	}
+}
+
+func (f deviceFilter) Match(device string) bool {
+	if f.exclude != nil && f.exclude.MatchString(device) {
+		return false
+	}
+	return f.include == nil || f.include.MatchString(device)
+}
+
+func (c *CosanetCollector) collectAndEmitNetdev(info PodInfo, ch chan<- prometheus.Metric) error {
+	stats, err := procnet_dev_parser.ParseDevFile(c.procPath("net/dev"))
+	if err != nil {
+		return err
+	}
+	labeler := c.newDeviceLabeler(info)
+	for device, counters := range stats {
+		if !c.netdevFilter.Match(device) {
+			continue
+		}
+		deviceLabelValues := labeler.values(device)
+		for i, counter := range netdevCounters {
+			ch <- c.netdevDescs[i].mustNewConstMetric(
+				prometheus.CounterValue,
+				float64(counters[counter]),
+				deviceLabelValues...,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceFilter(t *testing.T) {
+	f := newDeviceFilter("^(eth|net)[0-9]+$", "^eth1$")
+	assert.True(t, f.Match("eth0"))
+	assert.True(t, f.Match("net1"))
+	assert.False(t, f.Match("eth1"))
+	assert.False(t, f.Match("lo"))
+
+	assert.True(t, newDeviceFilter("", "").Match("lo"))
+}
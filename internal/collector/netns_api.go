@@ -0,0 +1,250 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// netnsSample is a metric of a netns served by /api/v1/netns
+type netnsSample struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     *float64          `json:"value,omitempty"`
+	Histogram *netnsHistogram   `json:"histogram,omitempty"`
+}
+
+type netnsHistogram struct {
+	Count uint64  `json:"count"`
+	Sum   float64 `json:"sum"`
+	// Cumulative count per upper bound
+	Buckets map[string]uint64 `json:"buckets"`
+}
+
+// netnsStats is a pod collected by the last collection, with its metrics per
+// family
+type netnsStats struct {
+	Name        string                   `json:"name"`
+	Namespace   string                   `json:"namespace"`
+	UID         string                   `json:"uid"`
+	NetNSName   string                   `json:"netnsName"`
+	HostNetwork bool                     `json:"hostNetwork"`
+	Metrics     map[string][]netnsSample `json:"metrics"`
+}
+
+// netnsStatsResponse is the /api/v1/netns response
+type netnsStatsResponse struct {
+	CollectedAt time.Time    `json:"collectedAt"`
+	Pods        []netnsStats `json:"pods"`
+}
+
+// netnsRecord holds the metrics emitted in a netns by a collection
+type netnsRecord struct {
+	info    PodInfo
+	metrics []prometheus.Metric
+}
+
+// netnsRecords are the netns collected by a collection
+type netnsRecords struct {
+	collectedAt time.Time
+	records     []*netnsRecord
+}
+
+// tee returns a channel forwarding the metrics to ch while recording them for
+// the netns, and the function to call once the netns is collected
+func (r *netnsRecords) tee(info PodInfo, ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	record := &netnsRecord{info: info}
+	r.records = append(r.records, record)
+	teeCh := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range teeCh {
+			record.metrics = append(record.metrics, m)
+			ch <- m
+		}
+	}()
+	return teeCh, func() {
+		close(teeCh)
+		<-done
+	}
+}
+
+// lastNetns keeps the netns of the last collection for /api/v1/netns
+type lastNetns struct {
+	mu      sync.Mutex
+	records *netnsRecords
+}
+
+func (l *lastNetns) set(records *netnsRecords) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = records
+}
+
+func (l *lastNetns) get() *netnsRecords {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.records
+}
+
+// replayCollector collects recorded metrics again, for a registry to gather
+// them into named families
+type replayCollector []prometheus.Metric
+
+func (replayCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (rc replayCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range rc {
+		ch <- m
+	}
+}
+
+// netnsSelector selects the pods and metric families served by the APIs
+type netnsSelector struct {
+	namespace, name string
+	metric          *regexp.Regexp
+}
+
+// newNetnsSelector parses the pod (namespace/name) and metric (anchored
+// family name regex) selectors, empty for every pod and family
+func newNetnsSelector(pod, metric string) (netnsSelector, error) {
+	var s netnsSelector
+	if pod != "" {
+		var ok bool
+		if s.namespace, s.name, ok = strings.Cut(pod, "/"); !ok {
+			return s, errors.New("pod must be namespace/name")
+		}
+	}
+	if metric != "" {
+		var err error
+		if s.metric, err = regexp.Compile("^(?:" + metric + ")$"); err != nil {
+			return s, fmt.Errorf("invalid metric regex: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (s netnsSelector) matchesPod(info PodInfo) bool {
+	return s.name == "" || (info.Namespace == s.namespace && info.Name == s.name)
+}
+
+// gatherNetnsFamilies gathers the recorded metrics into families, keeping the
+// families matched by metric
+func gatherNetnsFamilies(metrics []prometheus.Metric, metric *regexp.Regexp) ([]*dto.MetricFamily, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(replayCollector(metrics))
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	if metric == nil {
+		return families, nil
+	}
+	return slices.DeleteFunc(families, func(family *dto.MetricFamily) bool {
+		return !metric.MatchString(family.GetName())
+	}), nil
+}
+
+// netnsFamilies converts the recorded metrics to samples per family, keeping
+// the families matched by metric
+func netnsFamilies(metrics []prometheus.Metric, metric *regexp.Regexp) (map[string][]netnsSample, error) {
+	families, err := gatherNetnsFamilies(metrics, metric)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]netnsSample, len(families))
+	for _, family := range families {
+		samples := make([]netnsSample, 0, len(family.GetMetric()))
+		for _, m := range family.GetMetric() {
+			samples = append(samples, newNetnsSample(m))
+		}
+		result[family.GetName()] = samples
+	}
+	return result, nil
+}
+
+func newNetnsSample(m *dto.Metric) netnsSample {
+	var sample netnsSample
+	if len(m.GetLabel()) > 0 {
+		sample.Labels = make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			sample.Labels[label.GetName()] = label.GetValue()
+		}
+	}
+	switch {
+	case m.Counter != nil:
+		value := m.Counter.GetValue()
+		sample.Value = &value
+	case m.Gauge != nil:
+		value := m.Gauge.GetValue()
+		sample.Value = &value
+	case m.Untyped != nil:
+		value := m.Untyped.GetValue()
+		sample.Value = &value
+	case m.Histogram != nil:
+		histogram := &netnsHistogram{
+			Count:   m.Histogram.GetSampleCount(),
+			Sum:     m.Histogram.GetSampleSum(),
+			Buckets: make(map[string]uint64, len(m.Histogram.GetBucket())),
+		}
+		for _, bucket := range m.Histogram.GetBucket() {
+			histogram.Buckets[strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)] = bucket.GetCumulativeCount()
+		}
+		sample.Histogram = histogram
+	}
+	return sample
+}
+
+// NetnsAPIHandler serves the metrics of the pods collected by the last
+// collection as JSON, per pod and family. The pod (namespace/name) and metric
+// (family name regex) query parameters select them.
+func (c *CosanetCollector) NetnsAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selector, err := newNetnsSelector(r.URL.Query().Get("pod"), r.URL.Query().Get("metric"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := netnsStatsResponse{Pods: []netnsStats{}}
+		if records := c.lastNetns.get(); records != nil {
+			resp.CollectedAt = records.collectedAt
+			for _, record := range records.records {
+				info := record.info
+				if !selector.matchesPod(info) {
+					continue
+				}
+				families, err := netnsFamilies(record.metrics, selector.metric)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				resp.Pods = append(resp.Pods, netnsStats{
+					Name:        info.Name,
+					Namespace:   info.Namespace,
+					UID:         info.UID,
+					NetNSName:   info.netNSName,
+					HostNetwork: info.HostNetwork,
+					Metrics:     families,
+				})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(resp); err != nil {
+			slog.Warn("failed to write the netns stats", slog.Any("err", err))
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetnsAPIHandler(t *testing.T) {
+	c := &CosanetCollector{}
+
+	// No collection yet
+	rec := httptest.NewRecorder()
+	c.NetnsAPIHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/netns", nil))
+	assert.JSONEq(t, `{"collectedAt": "0001-01-01T00:00:00Z", "pods": []}`, rec.Body.String())
+
+	conntrackDesc := prometheus.NewDesc("cosanet_conntrack_entries", "", []string{"cosanet_pod"}, nil)
+	stateDesc := prometheus.NewDesc("cosanet_socket_states", "", []string{"cosanet_pod", "state"}, nil)
+	rttDesc := prometheus.NewDesc("cosanet_tcp_rtt_seconds", "", nil, nil)
+
+	// The metrics go through the tee
+	ch := make(chan prometheus.Metric, 10)
+	records := &netnsRecords{}
+	for _, info := range []PodInfo{
+		{UID: "uid-web", Name: "web", Namespace: "default", netNSName: "cni-1"},
+		{UID: "uid-db", Name: "db", Namespace: "default", netNSName: "cni-2"},
+	} {
+		teeCh, done := records.tee(info, ch)
+		teeCh <- prometheus.MustNewConstMetric(conntrackDesc, prometheus.GaugeValue, 12, info.Name)
+		teeCh <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 3, info.Name, "established")
+		teeCh <- prometheus.MustNewConstHistogram(rttDesc, 2, 0.003, map[float64]uint64{0.001: 1, 0.01: 2})
+		done()
+	}
+	assert.Len(t, ch, 6)
+	c.lastNetns.set(records)
+
+	rec = httptest.NewRecorder()
+	c.NetnsAPIHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/netns?pod=default/web", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"collectedAt": "0001-01-01T00:00:00Z",
+		"pods": [{
+			"name": "web",
+			"namespace": "default",
+			"uid": "uid-web",
+			"netnsName": "cni-1",
+			"hostNetwork": false,
+			"metrics": {
+				"cosanet_conntrack_entries": [{"labels": {"cosanet_pod": "web"}, "value": 12}],
+				"cosanet_socket_states": [{"labels": {"cosanet_pod": "web", "state": "established"}, "value": 3}],
+				"cosanet_tcp_rtt_seconds": [{"histogram": {"count": 2, "sum": 0.003, "buckets": {"0.001": 1, "0.01": 2}}}]
+			}
+		}]
+	}`, rec.Body.String())
+
+	// Selected families of every pod
+	rec = httptest.NewRecorder()
+	c.NetnsAPIHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/netns?metric=cosanet_conntrack_.*", nil))
+	assert.JSONEq(t, `{
+		"collectedAt": "0001-01-01T00:00:00Z",
+		"pods": [
+			{"name": "web", "namespace": "default", "uid": "uid-web", "netnsName": "cni-1", "hostNetwork": false,
+			 "metrics": {"cosanet_conntrack_entries": [{"labels": {"cosanet_pod": "web"}, "value": 12}]}},
+			{"name": "db", "namespace": "default", "uid": "uid-db", "netnsName": "cni-2", "hostNetwork": false,
+			 "metrics": {"cosanet_conntrack_entries": [{"labels": {"cosanet_pod": "db"}, "value": 12}]}}
+		]
+	}`, rec.Body.String())
+
+	for _, query := range []string{"pod=web", "metric=("} {
+		rec = httptest.NewRecorder()
+		c.NetnsAPIHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/netns?"+query, nil))
+		require.Equal(t, 400, rec.Code, query)
+	}
+}
@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// netnsCollector is one of the collectors run in each collected netns
+type netnsCollector struct {
+	name string
+	// enabled tells whether the collector runs for the netns
+	enabled func(c *CosanetCollector, info PodInfo) bool
+	collect func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error
+}
+
+// netnsCollectors are run in order in each collected netns
+var netnsCollectors = []netnsCollector{
+	{
+		name:    subCollectorConntrack,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Conntrack.Enabled },
+		collect: func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error {
+			err := c.collectWithTimeout(subCollectorConntrack, info, ch, func(ch chan<- prometheus.Metric) error {
+				return c.collectAndEmitConntrackStats(info, ch)
+			})
+			if errors.Is(err, errCollectorTimeout) {
+				// Unblocks the abandoned call
+				c.conntrackPool.discard(info.netNSID)
+			}
+			if c.options.Conntrack.Events {
+				err = errors.Join(err, c.collectAndEmitConntrackEvents(info, ch))
+			}
+			return err
+		},
+	},
+	{
+		name:    subCollectorSockProto,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.SockProto.Enabled },
+		collect: func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error {
+			err := c.collectWithTimeout(subCollectorSockProto, info, ch, func(ch chan<- prometheus.Metric) error {
+				return c.collectAndEmitSockProtos(info, ch)
+			})
+			// The failing protos are already logged
+			if errors.Is(err, errCollectorTimeout) || errors.Is(err, errCollectorPending) {
+				return err
+			}
+			return nil
+		},
+	},
+	{
+		name:    "unix",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Unix.Enabled },
+		collect: (*CosanetCollector).collectAndEmitUnixSockets,
+	},
+	{
+		name:    "netlink_sockets",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.NetlinkSockets.Enabled },
+		collect: (*CosanetCollector).collectAndEmitNetlinkSockets,
+	},
+	{
+		name:    "packet",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Packet.Enabled },
+		collect: (*CosanetCollector).collectAndEmitPacketSockets,
+	},
+	{
+		name:    "tcpinfo",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.TCPInfo.Enabled },
+		collect: (*CosanetCollector).collectAndEmitTCPInfo,
+	},
+	{
+		name:    "netdev",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Netdev.Enabled },
+		collect: (*CosanetCollector).collectAndEmitNetdev,
+	},
+	{
+		name:    "link",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Link.Enabled },
+		collect: (*CosanetCollector).collectAndEmitLinks,
+	},
+	{
+		name:    "qdisc",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Qdisc.Enabled },
+		collect: (*CosanetCollector).collectAndEmitQdiscs,
+	},
+	{
+		name: "ethtool",
+		enabled: func(c *CosanetCollector, info PodInfo) bool {
+			return c.options.Ethtool.Enabled && (info.netNSPath == "HOST" || c.options.Ethtool.Pods)
+		},
+		collect: (*CosanetCollector).collectAndEmitEthtool,
+	},
+	{
+		name: "bridge",
+		// CNI bridges live on the host side
+		enabled: func(c *CosanetCollector, info PodInfo) bool {
+			return c.options.Bridge.Enabled && info.netNSPath == "HOST"
+		},
+		collect: (*CosanetCollector).collectAndEmitBridges,
+	},
+	{
+		name: "veth",
+		enabled: func(c *CosanetCollector, info PodInfo) bool {
+			return c.options.Veth.Enabled && info.netNSPath != "HOST"
+		},
+		collect: (*CosanetCollector).collectAndEmitVethPeers,
+	},
+	{
+		name:    "skmem",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.SkMem.Enabled },
+		collect: (*CosanetCollector).collectAndEmitSkMem,
+	},
+	{
+		name:    "wireguard",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Wireguard.Enabled },
+		collect: (*CosanetCollector).collectAndEmitWireguard,
+	},
+	{
+		name:    "multicast",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Multicast.Enabled },
+		collect: (*CosanetCollector).collectAndEmitMulticastGroups,
+	},
+	{
+		name:    "neighbor",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Neighbor.Enabled },
+		collect: (*CosanetCollector).collectAndEmitNeighbors,
+	},
+	{
+		name:    "addresses",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Addresses.Enabled },
+		collect: (*CosanetCollector).collectAndEmitAddresses,
+	},
+	{
+		name:    "sysctl",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Sysctl.Enabled },
+		collect: (*CosanetCollector).collectAndEmitSysctls,
+	},
+	{
+		name:    subCollectorSnmp,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Snmp.Enabled },
+		collect: func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error {
+			snmpParser, snmp6Parser := c.snmpParser, c.snmp6Parser
+			err := c.collectWithTimeout(subCollectorSnmp, info, ch, func(ch chan<- prometheus.Metric) error {
+				return c.collectAndEmitSnmp(snmpParser, snmp6Parser, info, ch)
+			})
+			if errors.Is(err, errCollectorTimeout) {
+				// The abandoned run keeps its parsers
+				c.snmpParser, c.snmp6Parser = c.newSnmpParsers()
+			}
+			return err
+		},
+	},
+	{
+		name:    subCollectorNetstat,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Netstat.Enabled },
+		collect: func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error {
+			netstatParser := c.netstatParser
+			err := c.collectWithTimeout(subCollectorNetstat, info, ch, func(ch chan<- prometheus.Metric) error {
+				netstat_stats, err := netstatParser.ParseFile(c.procPath("net/netstat"))
+				if err != nil {
+					return err
+				}
+				c.publishProcNet("netstat", netstat_stats, info, ch)
+				return nil
+			})
+			if errors.Is(err, errCollectorTimeout) {
+				// The abandoned run keeps its parser
+				c.netstatParser = c.newNetstatParser()
+			}
+			return err
+		},
+	},
+	{
+		name:    "xfrm",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.options.Xfrm.Enabled },
+		collect: func(c *CosanetCollector, info PodInfo, ch chan<- prometheus.Metric) error {
+			xfrm_stats, err := c.xfrmParser.ParseFile(c.procPath("net/xfrm_stat"))
+			if err != nil {
+				return err
+			}
+			c.publishProcNet("xfrm_stat", xfrm_stats, info, ch)
+			return nil
+		},
+	},
+	{
+		name:    "procextra",
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return len(c.procExtraFiles) > 0 },
+		collect: (*CosanetCollector).collectAndEmitProcExtra,
+	},
+	{
+		name:    subCollectorPacketDrops,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.packetDrops != nil },
+		collect: (*CosanetCollector).collectAndEmitPacketDrops,
+	},
+	{
+		name:    subCollectorDNS,
+		enabled: func(c *CosanetCollector, _ PodInfo) bool { return c.dnsQueries != nil },
+		collect: (*CosanetCollector).collectAndEmitDNSQueries,
+	},
+}
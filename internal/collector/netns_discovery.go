@@ -0,0 +1,222 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// namedNetnsDir is where `ip netns add` bind mounts the named namespaces
+const namedNetnsDir = "/var/run/netns"
+
+type DiscoveryOptions struct {
+	// Mode is either "cri" (container runtime sockets), "kubelet" or
+	// "containerd" (plain containerd containers)
+	Mode       string
+	Kubelet    KubeletOptions
+	Containerd ContainerdOptions
+	// Netns collects the named network namespaces when no container runtime
+	// socket is found, for plain Linux hosts
+	Netns bool
+	// ProcScan also collects the network namespaces of the host processes
+	ProcScan bool
+	// NamedNetns also collects, besides the pods, the named network
+	// namespaces no sandbox lives in, created by operators or CNIs (eg: VRFs)
+	NamedNetns bool
+}
+
+// listNamedNetns returns a PodInfo per namespace of dir, named after it.
+// The namespaces are entered through their path, hence the 0 PID.
+func listNamedNetns(dir string) ([]PodInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]PodInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		infos = append(infos, PodInfo{
+			Name:      entry.Name(),
+			netNSPath: filepath.Join(dir, entry.Name()),
+			netNSName: entry.Name(),
+		})
+	}
+	return infos, nil
+}
+
+// listHostNamedNetns lists the named network namespaces, looked up in the
+// host mount namespace when not mounted here. None when there's no named
+// namespace directory.
+func listHostNamedNetns(procRoot string) ([]PodInfo, error) {
+	infos, err := listNamedNetns(namedNetnsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		infos, err = listNamedNetns(filepath.Join(procRoot, "1/root", namedNetnsDir))
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// netnsInode returns the inode identifying the network namespace at path,
+// either a /proc/<pid>/ns/net link or a named namespace bind mount
+func netnsInode(path string) (uint64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Ino, nil
+}
+
+// procPIDs returns the sorted PIDs of the processes of procRoot
+func procPIDs(procRoot string) ([]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	slices.Sort(pids)
+	return pids, nil
+}
+
+// scanProcNetns returns a PodInfo per network namespace of the processes of
+// procRoot, skipping the host one and the ones in known. Each is entered
+// through its lowest PID and named after its inode, as shown by lsns.
+func scanProcNetns(procRoot string, known map[uint64]bool) ([]PodInfo, error) {
+	pids, err := procPIDs(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	hostInode, err := netnsInode(filepath.Join(procRoot, "1/ns/net"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uint64]bool{hostInode: true}
+	var infos []PodInfo
+	for _, pid := range pids {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+		inode, err := netnsInode(filepath.Join(dir, "ns/net"))
+		if err != nil {
+			// Process gone or kernel thread
+			continue
+		}
+		if seen[inode] || known[inode] {
+			continue
+		}
+		seen[inode] = true
+		comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+		infos = append(infos, PodInfo{
+			PID:       pid,
+			Name:      strings.TrimSpace(string(comm)),
+			netNSPath: filepath.Join(dir, "ns/net"),
+			netNSName: fmt.Sprintf("net:[%d]", inode),
+		})
+	}
+	return infos, nil
+}
+
+// discoverNetns lists the named network namespaces, looked up in the host
+// mount namespace when not mounted here, and the processes ones if enabled
+func (c *criClient) discoverNetns() ([]PodInfo, error) {
+	infos, err := listHostNamedNetns(c.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !c.discovery.ProcScan {
+		return infos, nil
+	}
+	known := make(map[uint64]bool, len(infos))
+	for _, info := range infos {
+		if inode, err := netnsInode(info.netNSPath); err == nil {
+			known[inode] = true
+		}
+	}
+	scanned, err := scanProcNetns(c.procRoot, known)
+	if err != nil {
+		slog.Warn("failed to scan the processes network namespaces", slog.Any("err", err))
+		return infos, nil
+	}
+	slog.Debug(
+		"discovered network namespaces",
+		slog.Int("named", len(infos)),
+		slog.Int("scanned", len(scanned)),
+	)
+	return append(infos, scanned...), nil
+}
+
+// sandboxNetnsInodes returns the inodes of the network namespaces of the
+// sandboxes and of the host, the ones gone being left out
+func (c *CosanetCollector) sandboxNetnsInodes(infos []PodInfo) map[uint64]bool {
+	inodes := make(map[uint64]bool, len(infos)+1)
+	if inode, err := netnsInode(c.procPath("1/ns/net")); err == nil {
+		inodes[inode] = true
+	}
+	for _, info := range infos {
+		if info.PID > 0 {
+			if inode, err := netnsInode(c.procPath(strconv.Itoa(info.PID) + "/ns/net")); err == nil {
+				inodes[inode] = true
+				continue
+			}
+		}
+		if info.netNSPath == "" || info.netNSPath == "HOST" {
+			continue
+		}
+		inode, err := netnsInode(info.netNSPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			inode, err = netnsInode(c.procPath(filepath.Join("1/root", info.netNSPath)))
+		}
+		if err == nil {
+			inodes[inode] = true
+		}
+	}
+	return inodes
+}
+
+// unownedNamedNetns filters out of named the namespaces of known, leaving the
+// ones no pod lives in. Not being pods, they're only labelled with their name.
+func unownedNamedNetns(named []PodInfo, known map[uint64]bool) []PodInfo {
+	var infos []PodInfo
+	for _, info := range named {
+		inode, err := netnsInode(info.netNSPath)
+		if err != nil {
+			slog.Debug(
+				"named network namespace gone",
+				slog.String("path", info.netNSPath),
+				slog.Any("err", err),
+			)
+			continue
+		}
+		if known[inode] {
+			continue
+		}
+		info.Name = ""
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// listUnownedNamedNetns lists the named network namespaces none of the
+// sandboxes lives in
+func (c *CosanetCollector) listUnownedNamedNetns(infos []PodInfo) ([]PodInfo, error) {
+	named, err := listHostNamedNetns(c.options.ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+	return unownedNamedNetns(named, c.sandboxNetnsInodes(infos)), nil
+}
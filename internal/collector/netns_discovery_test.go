@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListNamedNetns(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blue"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "red"), nil, 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o755))
+
+	infos, err := listNamedNetns(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []PodInfo{
+		{Name: "blue", netNSPath: filepath.Join(dir, "blue"), netNSName: "blue"},
+		{Name: "red", netNSPath: filepath.Join(dir, "red"), netNSName: "red"},
+	}, infos)
+
+	_, err = listNamedNetns(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// fakeProcess creates /proc/<pid>/{comm,ns/net}, hard linking ns/net to the
+// one of sharedWith so both report the same inode
+func fakeProcess(t *testing.T, procRoot string, pid int, comm string, sharedWith int) {
+	dir := filepath.Join(procRoot, fmt.Sprint(pid))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ns"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644))
+	if sharedWith == 0 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ns/net"), nil, 0o644))
+		return
+	}
+	require.NoError(t, os.Link(
+		filepath.Join(procRoot, fmt.Sprint(sharedWith), "ns/net"),
+		filepath.Join(dir, "ns/net"),
+	))
+}
+
+func TestScanProcNetns(t *testing.T) {
+	procRoot := t.TempDir()
+	fakeProcess(t, procRoot, 1, "systemd", 0)
+	fakeProcess(t, procRoot, 10, "sshd", 1)
+	fakeProcess(t, procRoot, 200, "dnsmasq", 0)
+	fakeProcess(t, procRoot, 201, "dnsmasq", 200)
+	fakeProcess(t, procRoot, 300, "bird", 0)
+	// kernel thread like entry, without namespace
+	require.NoError(t, os.Mkdir(filepath.Join(procRoot, "400"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "self"), nil, 0o644))
+
+	dnsmasq, err := netnsInode(filepath.Join(procRoot, "200/ns/net"))
+	require.NoError(t, err)
+	bird, err := netnsInode(filepath.Join(procRoot, "300/ns/net"))
+	require.NoError(t, err)
+
+	infos, err := scanProcNetns(procRoot, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []PodInfo{
+		{PID: 200, Name: "dnsmasq", netNSPath: filepath.Join(procRoot, "200/ns/net"), netNSName: fmt.Sprintf("net:[%d]", dnsmasq)},
+		{PID: 300, Name: "bird", netNSPath: filepath.Join(procRoot, "300/ns/net"), netNSName: fmt.Sprintf("net:[%d]", bird)},
+	}, infos)
+
+	// Already known through a named namespace
+	infos, err = scanProcNetns(procRoot, map[uint64]bool{dnsmasq: true})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "bird", infos[0].Name)
+}
+
+func TestSandboxNetnsInodes(t *testing.T) {
+	procRoot := t.TempDir()
+	fakeProcess(t, procRoot, 1, "systemd", 0)
+	fakeProcess(t, procRoot, 100, "pause", 0)
+	netnsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(netnsDir, "cni-pod"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(netnsDir, "vrf-red"), nil, 0o644))
+	// Sandbox of a runtime reporting a PID of the named netns
+	require.NoError(t, os.Link(filepath.Join(procRoot, "100/ns/net"), filepath.Join(netnsDir, "cni-other")))
+
+	c := &CosanetCollector{}
+	c.options.ProcRoot = procRoot
+	known := c.sandboxNetnsInodes([]PodInfo{
+		{Name: "web", Namespace: "default", PID: 100},
+		{Name: "db", Namespace: "default", netNSPath: filepath.Join(netnsDir, "cni-pod")},
+		{Name: "gone", Namespace: "default", PID: 999, netNSPath: filepath.Join(netnsDir, "missing")},
+		{Name: "node", Namespace: "default", HostNetwork: true, netNSPath: "HOST"},
+	})
+	assert.Len(t, known, 3, "host, web and db")
+
+	named, err := listNamedNetns(netnsDir)
+	require.NoError(t, err)
+	named = append(named, PodInfo{Name: "deleted", netNSPath: filepath.Join(netnsDir, "deleted"), netNSName: "deleted"})
+	assert.Equal(t, []PodInfo{
+		{netNSPath: filepath.Join(netnsDir, "vrf-red"), netNSName: "vrf-red"},
+	}, unownedNamedNetns(named, known))
+}
@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cosanet/cosanet/pkg/netnspb"
+)
+
+// defaultWatchInterval is the interval of the watches not giving any
+const defaultWatchInterval = time.Second
+
+// recordNetns tells whether the collections record the metrics of each netns
+// for the JSON or gRPC API
+func (c *CosanetCollector) recordNetns() bool {
+	return c.options.API.Enabled || c.options.API.GRPCListen != ""
+}
+
+// refresh goes through the collection loop like a scrape, collecting unless
+// the metrics cache is still fresh, to update the recorded netns
+func (c *CosanetCollector) refresh() {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range ch {
+		}
+	}()
+	c.Collect(ch)
+	close(ch)
+	<-done
+}
+
+// RegisterNetnsStatsServer registers the gRPC API of the recorded netns
+func (c *CosanetCollector) RegisterNetnsStatsServer(s grpc.ServiceRegistrar) {
+	netnspb.RegisterNetnsStatsServer(s, &netnsStatsServer{c: c})
+}
+
+type netnsStatsServer struct {
+	netnspb.UnimplementedNetnsStatsServer
+	c *CosanetCollector
+}
+
+func (s *netnsStatsServer) Get(_ context.Context, req *netnspb.GetRequest) (*netnspb.Snapshot, error) {
+	selector, err := newNetnsSelector(req.GetPod(), req.GetMetric())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.c.refresh()
+	snapshot, err := netnsSnapshot(s.c.lastNetns.get(), selector)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return snapshot, nil
+}
+
+func (s *netnsStatsServer) Watch(req *netnspb.WatchRequest, stream grpc.ServerStreamingServer[netnspb.Snapshot]) error {
+	selector, err := newNetnsSelector(req.GetPod(), req.GetMetric())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	interval := defaultWatchInterval
+	if req.GetInterval() != nil {
+		if interval = req.GetInterval().AsDuration(); interval <= 0 {
+			return status.Error(codes.InvalidArgument, "interval must be positive")
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var sent time.Time
+	for {
+		s.c.refresh()
+		// The cached scrapes bring nothing new
+		if records := s.c.lastNetns.get(); records != nil && !records.collectedAt.Equal(sent) {
+			snapshot, err := netnsSnapshot(records, selector)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if err := stream.Send(snapshot); err != nil {
+				return err
+			}
+			sent = records.collectedAt
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// netnsSnapshot converts the selected pods of the recorded netns, empty when
+// nothing was collected yet
+func netnsSnapshot(records *netnsRecords, selector netnsSelector) (*netnspb.Snapshot, error) {
+	snapshot := &netnspb.Snapshot{}
+	if records == nil {
+		return snapshot, nil
+	}
+	snapshot.CollectedAt = timestamppb.New(records.collectedAt)
+	for _, record := range records.records {
+		info := record.info
+		if !selector.matchesPod(info) {
+			continue
+		}
+		families, err := netnsPBFamilies(record.metrics, selector.metric)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Pods = append(snapshot.Pods, &netnspb.Pod{
+			Name:        info.Name,
+			Namespace:   info.Namespace,
+			Uid:         info.UID,
+			NetnsName:   info.netNSName,
+			HostNetwork: info.HostNetwork,
+			Families:    families,
+		})
+	}
+	return snapshot, nil
+}
+
+func netnsPBFamilies(metrics []prometheus.Metric, metric *regexp.Regexp) ([]*netnspb.Family, error) {
+	families, err := gatherNetnsFamilies(metrics, metric)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*netnspb.Family, 0, len(families))
+	for _, family := range families {
+		samples := make([]*netnspb.Sample, 0, len(family.GetMetric()))
+		for _, m := range family.GetMetric() {
+			samples = append(samples, newNetnsPBSample(m))
+		}
+		result = append(result, &netnspb.Family{Name: family.GetName(), Samples: samples})
+	}
+	return result, nil
+}
+
+func newNetnsPBSample(m *dto.Metric) *netnspb.Sample {
+	sample := &netnspb.Sample{}
+	if len(m.GetLabel()) > 0 {
+		sample.Labels = make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			sample.Labels[label.GetName()] = label.GetValue()
+		}
+	}
+	switch {
+	case m.Counter != nil:
+		sample.Data = &netnspb.Sample_Value{Value: m.Counter.GetValue()}
+	case m.Gauge != nil:
+		sample.Data = &netnspb.Sample_Value{Value: m.Gauge.GetValue()}
+	case m.Untyped != nil:
+		sample.Data = &netnspb.Sample_Value{Value: m.Untyped.GetValue()}
+	case m.Histogram != nil:
+		histogram := &netnspb.Histogram{
+			Count:   m.Histogram.GetSampleCount(),
+			Sum:     m.Histogram.GetSampleSum(),
+			Buckets: make([]*netnspb.Bucket, 0, len(m.Histogram.GetBucket())),
+		}
+		for _, bucket := range m.Histogram.GetBucket() {
+			histogram.Buckets = append(histogram.Buckets, &netnspb.Bucket{
+				UpperBound:      bucket.GetUpperBound(),
+				CumulativeCount: bucket.GetCumulativeCount(),
+			})
+		}
+		sample.Data = &netnspb.Sample_Histogram{Histogram: histogram}
+	}
+	return sample
+}
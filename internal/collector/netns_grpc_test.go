@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/cosanet/cosanet/pkg/netnspb"
+)
+
+func TestNetnsStatsServer(t *testing.T) {
+	conntrackDesc := prometheus.NewDesc("cosanet_conntrack_entries", "", []string{"cosanet_pod"}, nil)
+	rttDesc := prometheus.NewDesc("cosanet_tcp_rtt_seconds", "", nil, nil)
+
+	// Every collection request records a new collection, as the main thread
+	c := &CosanetCollector{chanToFeed: make(chan CollectRequest)}
+	collections := 0
+	go func() {
+		for req := range c.chanToFeed {
+			collections++
+			records := &netnsRecords{collectedAt: time.Unix(int64(collections), 0)}
+			for _, info := range []PodInfo{
+				{UID: "uid-web", Name: "web", Namespace: "default", netNSName: "cni-1"},
+				{UID: "uid-db", Name: "db", Namespace: "default", netNSName: "cni-2"},
+			} {
+				teeCh, done := records.tee(info, req.Feed)
+				teeCh <- prometheus.MustNewConstMetric(conntrackDesc, prometheus.GaugeValue, float64(collections), info.Name)
+				teeCh <- prometheus.MustNewConstHistogram(rttDesc, 2, 0.003, map[float64]uint64{0.001: 1, 0.01: 2})
+				done()
+			}
+			c.lastNetns.set(records)
+			req.Done <- true
+		}
+	}()
+	t.Cleanup(func() { close(c.chanToFeed) })
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	c.RegisterNetnsStatsServer(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := netnspb.NewNetnsStatsClient(conn)
+	ctx := context.Background()
+
+	snapshot, err := client.Get(ctx, &netnspb.GetRequest{Pod: "default/web", Metric: "cosanet_tcp_.*"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), snapshot.GetCollectedAt().GetSeconds())
+	require.Len(t, snapshot.GetPods(), 1)
+	pod := snapshot.GetPods()[0]
+	assert.Equal(t, "web", pod.GetName())
+	assert.Equal(t, "uid-web", pod.GetUid())
+	assert.Equal(t, "cni-1", pod.GetNetnsName())
+	require.Len(t, pod.GetFamilies(), 1)
+	histogram := pod.GetFamilies()[0].GetSamples()[0].GetHistogram()
+	assert.Equal(t, uint64(2), histogram.GetCount())
+	assert.Equal(t, 0.01, histogram.GetBuckets()[1].GetUpperBound())
+
+	_, err = client.Get(ctx, &netnspb.GetRequest{Pod: "web"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Watch(watchCtx, &netnspb.WatchRequest{
+		Metric:   "cosanet_conntrack_entries",
+		Interval: durationpb.New(10 * time.Millisecond),
+	})
+	require.NoError(t, err)
+	var last float64
+	for range 2 {
+		snapshot, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, snapshot.GetPods(), 2)
+		value := snapshot.GetPods()[0].GetFamilies()[0].GetSamples()[0].GetValue()
+		assert.Greater(t, value, last, "a snapshot per new collection")
+		last = value
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"github.com/cosanet/cosanet/internal/packet_drops"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// startPacketDrops starts the drops tracer, nil when the drops aren't
+// collected
+func startPacketDrops(options CosanetCollectorOptions) *bpfCounters {
+	if !options.PacketDrops.Enabled {
+		return nil
+	}
+	tracer, err := packet_drops.Start()
+	if err != nil {
+		return unavailableBPFCounters(subCollectorPacketDrops, err)
+	}
+	return newBPFCounters(subCollectorPacketDrops, tracer, tracer.ReasonName)
+}
+
+// collectAndEmitPacketDrops publishes the drops of the netns, and whether
+// they could be counted with cosanet_collector_success
+func (c *CosanetCollector) collectAndEmitPacketDrops(info PodInfo, ch chan<- prometheus.Metric) error {
+	err := c.emitPacketDrops(info, ch)
+	c.emitCollectorSuccess(subCollectorPacketDrops, info, err, ch)
+	return err
+}
+
+func (c *CosanetCollector) emitPacketDrops(info PodInfo, ch chan<- prometheus.Metric) error {
+	if c.packetDrops.err != nil {
+		return c.packetDrops.err
+	}
+	counts, err := c.packetDrops.netnsCounts()
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	for reason, count := range counts {
+		ch <- c.packetDropsDesc.mustNewConstMetric(
+			prometheus.CounterValue,
+			float64(count),
+			append([]string{c.packetDrops.kindName(reason)}, labelValues...)...,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/labels"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// PodFilterOptions holds the include/exclude regexes for namespaces and pods.
+// An empty include matches everything, an empty exclude matches nothing.
+type PodFilterOptions struct {
+	NamespaceInclude string
+	NamespaceExclude string
+	PodInclude       string
+	PodExclude       string
+
+	// ScrapeAnnotation is the pod annotation teams use to opt in or out
+	ScrapeAnnotation string
+	// ScrapeAnnotationMode is either "opt-out" (collect unless the annotation
+	// is "false") or "opt-in" (only collect when the annotation is "true")
+	ScrapeAnnotationMode string
+
+	// PodLabelSelector is a Kubernetes label selector (eg: app=web,tier!=db)
+	PodLabelSelector string
+	// PodAnnotationSelector is a label selector matched against the pod
+	// annotations (eg: sidecar.istio.io/inject=true)
+	PodAnnotationSelector string
+}
+
+// podNameFilter decides if a sandbox is collected based on its namespace and
+// name. Excludes take precedence over includes.
+type podNameFilter struct {
+	namespaceInclude *regexp.Regexp
+	namespaceExclude *regexp.Regexp
+	podInclude       *regexp.Regexp
+	podExclude       *regexp.Regexp
+}
+
+func compileOptional(expr string) *regexp.Regexp {
+	if expr == "" {
+		return nil
+	}
+	return regexp.MustCompile(expr)
+}
+
+func newPodNameFilter(options PodFilterOptions) podNameFilter {
+	return podNameFilter{
+		namespaceInclude: compileOptional(options.NamespaceInclude),
+		namespaceExclude: compileOptional(options.NamespaceExclude),
+		podInclude:       compileOptional(options.PodInclude),
+		podExclude:       compileOptional(options.PodExclude),
+	}
+}
+
+// Match returns whether the pod is to be collected, and the reason otherwise
+func (f podNameFilter) Match(namespace, name string) (bool, string) {
+	if f.namespaceExclude != nil && f.namespaceExclude.MatchString(namespace) {
+		return false, "namespace excluded"
+	}
+	if f.namespaceInclude != nil && !f.namespaceInclude.MatchString(namespace) {
+		return false, "namespace not included"
+	}
+	if f.podExclude != nil && f.podExclude.MatchString(name) {
+		return false, "pod excluded"
+	}
+	if f.podInclude != nil && !f.podInclude.MatchString(name) {
+		return false, "pod not included"
+	}
+	return true, ""
+}
+
+const (
+	ScrapeAnnotationOptOut = "opt-out"
+	ScrapeAnnotationOptIn  = "opt-in"
+)
+
+// podAnnotationFilter lets pods opt in or out of the collection through an
+// annotation, without the cluster operator editing the exporter settings.
+type podAnnotationFilter struct {
+	annotation string
+	optIn      bool
+}
+
+func newPodAnnotationFilter(options PodFilterOptions) podAnnotationFilter {
+	switch options.ScrapeAnnotationMode {
+	case ScrapeAnnotationOptOut, "":
+		return podAnnotationFilter{annotation: options.ScrapeAnnotation}
+	case ScrapeAnnotationOptIn:
+		return podAnnotationFilter{annotation: options.ScrapeAnnotation, optIn: true}
+	default:
+		panic(fmt.Errorf(
+			"unknown scrape annotation mode %q, expected %s or %s",
+			options.ScrapeAnnotationMode,
+			ScrapeAnnotationOptOut,
+			ScrapeAnnotationOptIn,
+		))
+	}
+}
+
+// Match returns whether the pod is to be collected, and the reason otherwise
+func (f podAnnotationFilter) Match(annotations map[string]string) (bool, string) {
+	if f.annotation == "" {
+		return true, ""
+	}
+	value, found := annotations[f.annotation]
+	if f.optIn {
+		if !found || value != "true" {
+			return false, "pod did not opt in"
+		}
+		return true, ""
+	}
+	if found && value == "false" {
+		return false, "pod opted out"
+	}
+	return true, ""
+}
+
+// mustParseLabelSelector parses a Kubernetes label selector, panicking if malformed
+func mustParseLabelSelector(name, selector string) labels.Selector {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		panic(fmt.Errorf("malformed pod %s selector %q: %w", name, selector, err))
+	}
+	return parsed
+}
+
+// kubeletPodUIDLabel is the pod UID label kubelet sets on the CRI sandbox
+const kubeletPodUIDLabel = "io.kubernetes.pod.uid"
+
+// kubeletSandboxLabels are the labels kubelet adds to the pod's own ones on
+// the CRI sandbox
+var kubeletSandboxLabels = []string{
+	"io.kubernetes.pod.name",
+	"io.kubernetes.pod.namespace",
+	kubeletPodUIDLabel,
+}
+
+// sandboxPodUID returns the UID of the sandbox's pod, from the kubelet label
+// keying the controller resolver cache, else from the sandbox metadata
+func sandboxPodUID(status *criruntime.PodSandboxStatus) string {
+	if uid := status.GetLabels()[kubeletPodUIDLabel]; uid != "" {
+		return uid
+	}
+	return status.GetMetadata().GetUid()
+}
+
+// sandboxPodLabels returns the pod labels of a CRI sandbox, without the ones
+// kubelet adds, so that they match the labels of the pod object
+func sandboxPodLabels(sandboxLabels map[string]string) map[string]string {
+	podLabels := make(map[string]string, len(sandboxLabels))
+	for key, value := range sandboxLabels {
+		if !slices.Contains(kubeletSandboxLabels, key) {
+			podLabels[key] = value
+		}
+	}
+	return podLabels
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func TestPodNameFilter(t *testing.T) {
+	f := newPodNameFilter(PodFilterOptions{
+		NamespaceExclude: "^(kube-system|monitoring)$",
+		PodExclude:       "^debug-",
+	})
+
+	tests := []struct {
+		namespace string
+		name      string
+		want      bool
+	}{
+		{"default", "web-1", true},
+		{"kube-system", "coredns-1", false},
+		{"monitoring", "prometheus-0", false},
+		{"kube-system-extra", "foo", true},
+		{"default", "debug-shell", false},
+	}
+	for _, tt := range tests {
+		got, _ := f.Match(tt.namespace, tt.name)
+		assert.Equal(t, tt.want, got, "%s/%s", tt.namespace, tt.name)
+	}
+}
+
+func TestPodNameFilter_Includes(t *testing.T) {
+	f := newPodNameFilter(PodFilterOptions{
+		NamespaceInclude: "^team-",
+		PodInclude:       "^api-",
+		PodExclude:       "-canary$",
+	})
+
+	got, _ := f.Match("team-a", "api-1")
+	assert.True(t, got)
+	got, reason := f.Match("default", "api-1")
+	assert.False(t, got)
+	assert.Equal(t, "namespace not included", reason)
+	got, reason = f.Match("team-a", "worker-1")
+	assert.False(t, got)
+	assert.Equal(t, "pod not included", reason)
+	got, reason = f.Match("team-a", "api-canary")
+	assert.False(t, got)
+	assert.Equal(t, "pod excluded", reason)
+}
+
+func TestPodNameFilter_Empty(t *testing.T) {
+	got, _ := newPodNameFilter(PodFilterOptions{}).Match("any", "thing")
+	assert.True(t, got)
+}
+
+func TestPodAnnotationFilter(t *testing.T) {
+	optOut := newPodAnnotationFilter(PodFilterOptions{ScrapeAnnotation: "cosanet.io/scrape"})
+	optIn := newPodAnnotationFilter(PodFilterOptions{
+		ScrapeAnnotation:     "cosanet.io/scrape",
+		ScrapeAnnotationMode: ScrapeAnnotationOptIn,
+	})
+
+	tests := []struct {
+		annotations map[string]string
+		wantOptOut  bool
+		wantOptIn   bool
+	}{
+		{nil, true, false},
+		{map[string]string{"cosanet.io/scrape": "true"}, true, true},
+		{map[string]string{"cosanet.io/scrape": "false"}, false, false},
+		{map[string]string{"other": "false"}, true, false},
+	}
+	for _, tt := range tests {
+		got, _ := optOut.Match(tt.annotations)
+		assert.Equal(t, tt.wantOptOut, got, "opt-out %v", tt.annotations)
+		got, _ = optIn.Match(tt.annotations)
+		assert.Equal(t, tt.wantOptIn, got, "opt-in %v", tt.annotations)
+	}
+
+	assert.Panics(t, func() {
+		newPodAnnotationFilter(PodFilterOptions{ScrapeAnnotationMode: "maybe"})
+	})
+}
+
+func TestSandboxPodLabels(t *testing.T) {
+	got := sandboxPodLabels(map[string]string{
+		"app":                         "web",
+		"io.kubernetes.pod.name":      "web-0",
+		"io.kubernetes.pod.namespace": "default",
+		"io.kubernetes.pod.uid":       "0b7c3f5e-1d4a-4c6b-9f2e-7a8d9e0f1a2b",
+	})
+	assert.Equal(t, map[string]string{"app": "web"}, got)
+}
+
+func TestSandboxPodUID(t *testing.T) {
+	status := &criruntime.PodSandboxStatus{
+		Metadata: &criruntime.PodSandboxMetadata{Uid: "metadata-uid"},
+		Labels:   map[string]string{"io.kubernetes.pod.uid": "0b7c3f5e-1d4a-4c6b-9f2e-7a8d9e0f1a2b"},
+	}
+	assert.Equal(t, "0b7c3f5e-1d4a-4c6b-9f2e-7a8d9e0f1a2b", sandboxPodUID(status))
+
+	status.Labels = nil
+	assert.Equal(t, "metadata-uid", sandboxPodUID(status))
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// podShard selects the pods whose hash falls into the shard Index (1-based)
+// out of Count shards. The zero value selects everything.
+type podShard struct {
+	Index int
+	Count int
+}
+
+// parseShard parses a "index/count" shard selector such as "1/3".
+// An empty string disables sharding.
+func parseShard(s string) (podShard, error) {
+	if s == "" {
+		return podShard{}, nil
+	}
+	idx, count, found := strings.Cut(s, "/")
+	if !found {
+		return podShard{}, fmt.Errorf("malformed shard %q, expected index/count (eg: 1/3)", s)
+	}
+	index, err := strconv.Atoi(idx)
+	if err != nil {
+		return podShard{}, fmt.Errorf("malformed shard index %q: %w", idx, err)
+	}
+	total, err := strconv.Atoi(count)
+	if err != nil {
+		return podShard{}, fmt.Errorf("malformed shard count %q: %w", count, err)
+	}
+	if total < 1 || index < 1 || index > total {
+		return podShard{}, fmt.Errorf("invalid shard %q, index must be between 1 and count", s)
+	}
+	return podShard{Index: index, Count: total}, nil
+}
+
+// mustParseShard is like parseShard but panics if the selector is malformed
+func mustParseShard(s string) podShard {
+	shard, err := parseShard(s)
+	if err != nil {
+		panic(err)
+	}
+	return shard
+}
+
+// podKey is the stable identity used for sharding and ordering
+func podKey(info PodInfo) string {
+	if info.UID != "" {
+		return info.UID
+	}
+	return info.Namespace + "/" + info.Name
+}
+
+// netnsKey identifies the netns of a pod across collections, the named netns
+// without a pod by their path
+func netnsKey(info PodInfo) string {
+	if info.Name == "" && info.Namespace == "" {
+		return "netns:" + info.netNSPath
+	}
+	return podKey(info)
+}
+
+// Contains tells if the pod belongs to the shard
+func (s podShard) Contains(info PodInfo) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(podKey(info)))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+// primary tells if the instance collects the host netns and the node wide
+// metrics, the first shard only so that they aren't duplicated across shards
+func (s podShard) primary() bool {
+	return s.Index <= 1
+}
+
+// sandboxesInShard keeps the CRI sandboxes of the shard, so that the status
+// of the other ones is never fetched
+func sandboxesInShard(sandboxes []*criruntime.PodSandbox, shard podShard) []*criruntime.PodSandbox {
+	if shard.Count <= 1 {
+		return sandboxes
+	}
+	kept := make([]*criruntime.PodSandbox, 0, len(sandboxes))
+	for _, sb := range sandboxes {
+		uid := sb.GetLabels()[kubeletPodUIDLabel]
+		if uid == "" {
+			uid = sb.GetMetadata().GetUid()
+		}
+		info := PodInfo{
+			UID:       uid,
+			Name:      sb.GetMetadata().GetName(),
+			Namespace: sb.GetMetadata().GetNamespace(),
+		}
+		if shard.Contains(info) {
+			kept = append(kept, sb)
+		}
+	}
+	return kept
+}
+
+// selectPods keeps the pods of the shard, capped to maxPods (0: unlimited).
+// Pods are ordered by namespace/name so the same ones are kept across scrapes.
+func selectPods(infos []PodInfo, shard podShard, maxPods int) []PodInfo {
+	selected := make([]PodInfo, 0, len(infos))
+	for _, info := range infos {
+		if !shard.Contains(info) {
+			slog.Debug(
+				"sandbox skipped, not in shard",
+				slog.String("name", info.Name),
+				slog.String("namespace", info.Namespace),
+				slog.Int("shard_index", shard.Index),
+				slog.Int("shard_count", shard.Count),
+			)
+			continue
+		}
+		selected = append(selected, info)
+	}
+
+	if maxPods > 0 && len(selected) > maxPods {
+		slices.SortFunc(selected, func(a, b PodInfo) int {
+			return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+		})
+		slog.Warn(
+			"too many sandboxes, some won't be collected",
+			slog.Int("max_pods", maxPods),
+			slog.Int("sandboxes", len(selected)),
+		)
+		selected = selected[:maxPods]
+	}
+	return selected
+}
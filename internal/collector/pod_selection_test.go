@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	criruntime "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    podShard
+		wantErr bool
+	}{
+		{"", podShard{}, false},
+		{"1/3", podShard{Index: 1, Count: 3}, false},
+		{"3/3", podShard{Index: 3, Count: 3}, false},
+		{"0/3", podShard{}, true},
+		{"4/3", podShard{}, true},
+		{"1/0", podShard{}, true},
+		{"a/3", podShard{}, true},
+		{"13", podShard{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseShard(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestSelectPods_ShardsArePartitions(t *testing.T) {
+	var infos []PodInfo
+	for i := range 100 {
+		infos = append(infos, PodInfo{UID: fmt.Sprintf("uid-%d", i)})
+	}
+	seen := map[string]int{}
+	for i := 1; i <= 3; i++ {
+		for _, info := range selectPods(infos, podShard{Index: i, Count: 3}, 0) {
+			seen[info.UID]++
+		}
+	}
+	assert.Len(t, seen, 100)
+	for uid, count := range seen {
+		assert.Equal(t, 1, count, uid)
+	}
+}
+
+func TestSelectPods_MaxPods(t *testing.T) {
+	infos := []PodInfo{
+		{Namespace: "b", Name: "x"},
+		{Namespace: "a", Name: "z"},
+		{Namespace: "a", Name: "y"},
+	}
+	got := selectPods(infos, podShard{}, 2)
+	assert.Equal(t, []PodInfo{
+		{Namespace: "a", Name: "y"},
+		{Namespace: "a", Name: "z"},
+	}, got)
+	assert.Len(t, selectPods(infos, podShard{}, 0), 3)
+}
+
+func TestSandboxesInShard(t *testing.T) {
+	var sandboxes []*criruntime.PodSandbox
+	var infos []PodInfo
+	for i := range 20 {
+		uid := fmt.Sprintf("uid-%d", i)
+		sandboxes = append(sandboxes, &criruntime.PodSandbox{Id: uid, Metadata: &criruntime.PodSandboxMetadata{Uid: uid}})
+		infos = append(infos, PodInfo{UID: uid})
+	}
+	shard := podShard{Index: 2, Count: 3}
+	var got []string
+	for _, sb := range sandboxesInShard(sandboxes, shard) {
+		got = append(got, sb.Id)
+	}
+	var want []string
+	for _, info := range selectPods(infos, shard, 0) {
+		want = append(want, info.UID)
+	}
+	assert.Equal(t, want, got, "same split as the collected pods")
+	assert.Len(t, sandboxesInShard(sandboxes, podShard{}), 20)
+
+	assert.True(t, podShard{}.primary())
+	assert.True(t, podShard{Index: 1, Count: 3}.primary())
+	assert.False(t, shard.primary())
+}
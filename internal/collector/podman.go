@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// podmanAPIPrefix is the libpod API version served by podman 4 and later
+const podmanAPIPrefix = "/v4.0.0/libpod"
+
+// podmanListEntry is the subset of a libpod container listing entry used to
+// name the pods' infra containers
+type podmanListEntry struct {
+	ID      string `json:"Id"`
+	PodName string `json:"PodName"`
+	IsInfra bool   `json:"IsInfra"`
+}
+
+// podmanPodInfo maps a libpod container to a PodInfo. Its inspect payload is
+// docker's one, members of a podman pod join the infra container's netns and
+// are skipped, the infra container is reported under the pod's name.
+func podmanPodInfo(entry podmanListEntry, inspect *dockerContainer) (PodInfo, bool) {
+	info, ok := inspect.podInfo()
+	if !ok {
+		return PodInfo{}, false
+	}
+	info.Namespace = entry.PodName
+	if entry.IsInfra && entry.PodName != "" {
+		info.Name = entry.PodName
+	}
+	return info, true
+}
+
+// doListPodmanSandboxes discovers the running containers through the libpod
+// API, for podman hosts outside of Kubernetes.
+func (c *criClient) doListPodmanSandboxes(socketPath string) ([]PodInfo, error) {
+	client := newUnixHTTPClient(socketPath)
+	defer client.CloseIdleConnections()
+
+	var containers []podmanListEntry
+	err := c.withRetry("ContainerList", func(ctx context.Context) error {
+		return dockerGet(ctx, client, podmanAPIPrefix+"/containers/json", &containers)
+	})
+	if err != nil {
+		slog.Error("Failed to list podman containers", slog.Any("err", err))
+		return nil, err
+	}
+
+	var podInfos []PodInfo
+	for _, ctr := range containers {
+		var inspect dockerContainer
+		err := c.withRetry("ContainerInspect", func(ctx context.Context) error {
+			return dockerGet(ctx, client, podmanAPIPrefix+"/containers/"+url.PathEscape(ctr.ID)+"/json", &inspect)
+		})
+		if err != nil {
+			slog.Error("Failed to inspect podman container", slog.String("id", ctr.ID), slog.Any("err", err))
+			continue
+		}
+		if info, ok := podmanPodInfo(ctr, &inspect); ok {
+			podInfos = append(podInfos, info)
+		}
+	}
+	return podInfos, nil
+}
+
+func getPodmanSocketPath() (string, bool) {
+	socketPaths := []string{
+		"/run/podman/podman.sock",
+		"/var/run/podman/podman.sock",
+	}
+	if host, ok := strings.CutPrefix(os.Getenv("CONTAINER_HOST"), "unix://"); ok {
+		socketPaths = []string{host}
+	}
+
+	for _, path := range socketPaths {
+		if stat, err := os.Stat(path); err == nil {
+			if stat.Mode()&os.ModeSocket != 0 {
+				slog.Info("Found podman socket", slog.String("path", path))
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cosanet/cosanet/pkg/procnet_2l_parser"
+	"github.com/cosanet/cosanet/pkg/procnet_v6_parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/yaml"
+)
+
+// Formats of the extra procfs files
+const (
+	// ProcExtraTwoLines is the header and value line pairs format of
+	// /proc/net/snmp and /proc/net/netstat
+	ProcExtraTwoLines = "two-line"
+	// ProcExtraKeyValue is the "key value" lines format of /proc/net/snmp6
+	ProcExtraKeyValue = "key-value"
+)
+
+var procExtraNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ProcExtraFile is an additional procfs file collected in every netns and
+// emitted under cosanet_proc_extra_<name>_*. Path is relative to the procfs
+// root (eg: net/sctp/snmp) and Include filters the counters with a regex
+// tested against <section>_<field>, all of them when empty.
+type ProcExtraFile struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Format  string `json:"format"`
+	Include string `json:"include,omitempty"`
+}
+
+// ProcExtraConfig is the content of the extra procfs files config
+type ProcExtraConfig struct {
+	Files []ProcExtraFile `json:"files"`
+}
+
+// LoadProcExtraConfig reads the extra procfs files from a YAML (or JSON) file
+func LoadProcExtraConfig(path string) ([]ProcExtraFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config ProcExtraConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse proc extra config %s: %w", path, err)
+	}
+	if _, err := compileProcExtraFiles(config.Files); err != nil {
+		return nil, fmt.Errorf("invalid proc extra config %s: %w", path, err)
+	}
+	return config.Files, nil
+}
+
+// procExtraFile is a validated extra file with its parser, kept between the
+// pods like the /proc/net ones
+type procExtraFile struct {
+	name  string
+	path  string
+	parse func(string) (map[string]map[string]uint64, error)
+}
+
+func compileProcExtraFiles(files []ProcExtraFile) ([]procExtraFile, error) {
+	compiled := make([]procExtraFile, 0, len(files))
+	names := make(map[string]bool, len(files))
+	for i, file := range files {
+		if !procExtraNameRegexp.MatchString(file.Name) {
+			return nil, fmt.Errorf("file %d: invalid name %q", i, file.Name)
+		}
+		if names[file.Name] {
+			return nil, fmt.Errorf("file %d: duplicate name %q", i, file.Name)
+		}
+		names[file.Name] = true
+		if path.IsAbs(file.Path) || path.Clean(file.Path) != file.Path || file.Path == "." ||
+			file.Path == ".." || strings.HasPrefix(file.Path, "../") {
+			return nil, fmt.Errorf("file %d: path %q must be relative to the procfs root", i, file.Path)
+		}
+		include, err := regexp.Compile(file.Include)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: invalid include regex: %w", i, err)
+		}
+		extra := procExtraFile{name: file.Name, path: file.Path}
+		switch file.Format {
+		case ProcExtraTwoLines:
+			extra.parse = procnet_2l_parser.NewParser(procNetInclude(include)).ParseFile
+		case ProcExtraKeyValue:
+			extra.parse = procnet_v6_parser.NewParser(procNetInclude(include)).ParseFile
+		default:
+			return nil, fmt.Errorf("file %d: unknown format %q", i, file.Format)
+		}
+		compiled = append(compiled, extra)
+	}
+	return compiled, nil
+}
+
+// mustCompileProcExtraFiles is like compileProcExtraFiles but panics on error
+func mustCompileProcExtraFiles(files []ProcExtraFile) []procExtraFile {
+	compiled, err := compileProcExtraFiles(files)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+// collectAndEmitProcExtra publishes the counters of the extra procfs files.
+// Files missing from the netns (module not loaded) are skipped.
+func (c *CosanetCollector) collectAndEmitProcExtra(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+	var errs []error
+	for _, file := range c.procExtraFiles {
+		stats, err := file.parse(c.procPath(file.path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.name, err))
+			continue
+		}
+		for section, fields := range stats {
+			for field, value := range fields {
+				ch <- c.procExtraDesc(file, section, field).mustNewConstMetric(
+					prometheus.UntypedValue,
+					float64(value),
+					labelValues...,
+				)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+)
+
+func TestCompileProcExtraFiles_Invalid(t *testing.T) {
+	for _, file := range []ProcExtraFile{
+		{Name: "sctp-snmp", Path: "net/sctp/snmp", Format: ProcExtraKeyValue},
+		{Name: "sctp", Path: "/proc/net/sctp/snmp", Format: ProcExtraKeyValue},
+		{Name: "sctp", Path: "../etc/shadow", Format: ProcExtraKeyValue},
+		{Name: "sctp", Path: "net/./sctp/snmp", Format: ProcExtraKeyValue},
+		{Name: "sctp", Path: "net/sctp/snmp", Format: "table"},
+		{Name: "sctp", Path: "net/sctp/snmp", Format: ProcExtraKeyValue, Include: "("},
+	} {
+		_, err := compileProcExtraFiles([]ProcExtraFile{file})
+		assert.Error(t, err, "%+v", file)
+	}
+
+	sctp := ProcExtraFile{Name: "sctp", Path: "net/sctp/snmp", Format: ProcExtraKeyValue}
+	_, err := compileProcExtraFiles([]ProcExtraFile{sctp, sctp})
+	assert.Error(t, err)
+}
+
+func TestLoadProcExtraConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proc-extra.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`files:
+  - name: sctp
+    path: net/sctp/snmp
+    format: key-value
+    include: ^SctpCurrEstab$
+`), 0o600))
+	files, err := LoadProcExtraConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []ProcExtraFile{
+		{Name: "sctp", Path: "net/sctp/snmp", Format: ProcExtraKeyValue, Include: "^SctpCurrEstab$"},
+	}, files)
+
+	require.NoError(t, os.WriteFile(path, []byte("files:\n  - name: sctp\n    path: net/sctp/snmp\n"), 0o600))
+	_, err = LoadProcExtraConfig(path)
+	assert.Error(t, err)
+}
+
+func TestCollectAndEmitProcExtra(t *testing.T) {
+	procRoot := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "net/sctp"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "net/sctp/snmp"), []byte("SctpCurrEstab 3\nSctpActiveEstabs 12\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "net/mptcp"), []byte("MPTcpExt: MPCapableSYNRX MPJoinSynRx\nMPTcpExt: 5 7\n"), 0o600))
+
+	c := &CosanetCollector{
+		options:             CosanetCollectorOptions{ProcRoot: procRoot},
+		controller_resolver: controller_resolver.NewNoopResolver(),
+		netnsLabels:         buildNetnsLabels(nil, nil, false, false),
+		procExtraFiles: mustCompileProcExtraFiles([]ProcExtraFile{
+			{Name: "sctp", Path: "net/sctp/snmp", Format: ProcExtraKeyValue, Include: "^SctpCurrEstab$"},
+			{Name: "mptcp", Path: "net/mptcp", Format: ProcExtraTwoLines},
+			// Module not loaded
+			{Name: "dccp", Path: "net/dccp/snmp", Format: ProcExtraKeyValue},
+		}),
+		procExtraDescs: make(map[procNetKey]*metricDesc),
+	}
+	for _, file := range c.procExtraFiles {
+		c.buildProcExtraDescs(file)
+	}
+	assert.Len(t, c.procExtraDescs, 3)
+
+	assert.Contains(t, c.procExtraDescs[procNetKey{"mptcp", "MPTcpExt", "MPJoinSynRx"}].desc.String(), `fqName: "cosanet_proc_extra_mptcp_MPTcpExt_MPJoinSynRx"`)
+
+	ch := make(chan prometheus.Metric, 10)
+	require.NoError(t, c.collectAndEmitProcExtra(PodInfo{Name: "web", Namespace: "default"}, ch))
+	close(ch)
+	values := map[*prometheus.Desc]float64{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		values[m.Desc()] = out.GetUntyped().GetValue()
+	}
+	assert.Equal(t, map[*prometheus.Desc]float64{
+		c.procExtraDescs[procNetKey{"sctp", "", "SctpCurrEstab"}].desc:           3,
+		c.procExtraDescs[procNetKey{"mptcp", "MPTcpExt", "MPCapableSYNRX"}].desc: 5,
+		c.procExtraDescs[procNetKey{"mptcp", "MPTcpExt", "MPJoinSynRx"}].desc:    7,
+	}, values)
+}
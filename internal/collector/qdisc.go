@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+)
+
+// qdiscLabelValues returns the labels identifying a qdisc of a device
+func qdiscLabelValues(labeler deviceLabeler, device string, qdisc netlink.Qdisc) []string {
+	attrs := qdisc.Attrs()
+	return labeler.values(
+		device,
+		qdisc.Type(),
+		netlink.HandleStr(attrs.Handle),
+		netlink.HandleStr(attrs.Parent),
+	)
+}
+
+func (c *CosanetCollector) collectAndEmitQdiscs(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := dumpLinks()
+	if err != nil {
+		return err
+	}
+	devices := make(map[int]string, len(links))
+	for _, link := range links {
+		devices[link.index] = link.name
+	}
+
+	qdiscs, err := netlink.QdiscList(nil)
+	// An interrupted dump is still worth publishing, the next one will be consistent
+	if err != nil && !errors.Is(err, netlink.ErrDumpInterrupted) {
+		return err
+	}
+
+	labeler := c.newDeviceLabeler(info)
+	for _, qdisc := range qdiscs {
+		attrs := qdisc.Attrs()
+		device, found := devices[attrs.LinkIndex]
+		if !found || !c.netdevFilter.Match(device) || attrs.Statistics == nil {
+			continue
+		}
+		qdiscLabels := qdiscLabelValues(labeler, device, qdisc)
+		if basic := attrs.Statistics.Basic; basic != nil {
+			ch <- c.qdiscBytesDesc.mustNewConstMetric(prometheus.CounterValue, float64(basic.Bytes), qdiscLabels...)
+			ch <- c.qdiscPacketsDesc.mustNewConstMetric(prometheus.CounterValue, float64(basic.Packets), qdiscLabels...)
+		}
+		if queue := attrs.Statistics.Queue; queue != nil {
+			ch <- c.qdiscQlenDesc.mustNewConstMetric(prometheus.GaugeValue, float64(queue.Qlen), qdiscLabels...)
+			ch <- c.qdiscBacklogDesc.mustNewConstMetric(prometheus.GaugeValue, float64(queue.Backlog), qdiscLabels...)
+			ch <- c.qdiscDropsDesc.mustNewConstMetric(prometheus.CounterValue, float64(queue.Drops), qdiscLabels...)
+			ch <- c.qdiscRequeuesDesc.mustNewConstMetric(prometheus.CounterValue, float64(queue.Requeues), qdiscLabels...)
+			ch <- c.qdiscOverlimitsDesc.mustNewConstMetric(prometheus.CounterValue, float64(queue.Overlimits), qdiscLabels...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"net/http"
+)
+
+// Ready tells whether a collection listed the sandboxes successfully and
+// completed. Until then the scrapes may only hold the host metrics.
+func (c *CosanetCollector) Ready() bool {
+	return c.ready.Load()
+}
+
+// checkReady collects through the collection loop while not ready, for the
+// readiness to progress without waiting for a scrape
+func (c *CosanetCollector) checkReady() bool {
+	if c.ready.Load() {
+		return true
+	}
+	c.refresh()
+	return c.ready.Load()
+}
+
+// ReadyHandler answers 200 once ready, 503 until then, for the readiness
+// probe.
+func (c *CosanetCollector) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.checkReady() {
+			http.Error(w, "waiting for the first successful sandbox listing", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready\n"))
+	})
+}
+
+// GateUntilReady answers 503 in place of next until ready, rather than serve
+// the partial metrics of the collections missing the sandboxes.
+func (c *CosanetCollector) GateUntilReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.checkReady() {
+			http.Error(w, "no successful sandbox listing yet", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	// The first collection fails to list the sandboxes, the second succeeds
+	c := &CosanetCollector{chanToFeed: make(chan CollectRequest)}
+	collections := 0
+	go func() {
+		for req := range c.chanToFeed {
+			collections++
+			if collections == 2 {
+				c.ready.Store(true)
+			}
+			req.Done <- true
+		}
+	}()
+	t.Cleanup(func() { close(c.chanToFeed) })
+
+	metrics := c.GateUntilReady(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("cosanet_build_info 1\n"))
+	}))
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.False(t, c.Ready())
+
+	rec = httptest.NewRecorder()
+	c.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "the probe collects on its own")
+	assert.True(t, c.Ready())
+
+	rec = httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "cosanet_build_info 1\n", rec.Body.String())
+	assert.Equal(t, 2, collections, "no more collections once ready")
+}
@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+)
+
+// Relabel actions
+const (
+	// RelabelRename rewrites the name of the metrics matching Metric to Replacement
+	RelabelRename = "rename"
+	// RelabelDropLabel removes Label from the metrics matching Metric
+	RelabelDropLabel = "drop_label"
+	// RelabelMapValue rewrites values of Label matching Regex to Replacement
+	RelabelMapValue = "map_value"
+)
+
+// RelabelRule is a single rule of the relabel config. Regexes are anchored and
+// Replacement may reference capture groups ($1, ${name}). An empty Metric
+// selects every metric.
+type RelabelRule struct {
+	Action      string `json:"action"`
+	Metric      string `json:"metric,omitempty"`
+	Label       string `json:"label,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RelabelConfig is the content of the relabel config file
+type RelabelConfig struct {
+	Rules []RelabelRule `json:"rules"`
+}
+
+// LoadRelabelConfig reads the rules from a YAML (or JSON) file
+func LoadRelabelConfig(path string) ([]RelabelRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config RelabelConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse relabel config %s: %w", path, err)
+	}
+	if _, err := compileRelabelRules(config.Rules); err != nil {
+		return nil, fmt.Errorf("invalid relabel config %s: %w", path, err)
+	}
+	return config.Rules, nil
+}
+
+type relabelRule struct {
+	action      string
+	metric      *regexp.Regexp
+	label       string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func compileRelabelRules(rules []RelabelRule) ([]relabelRule, error) {
+	compiled := make([]relabelRule, 0, len(rules))
+	for i, rule := range rules {
+		metric, err := compileAnchored(rule.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid metric regex: %w", i, err)
+		}
+		regex, err := compileAnchored(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid regex: %w", i, err)
+		}
+		switch rule.Action {
+		case RelabelRename:
+			if metric == nil || rule.Replacement == "" {
+				return nil, fmt.Errorf("rule %d: %s requires metric and replacement", i, rule.Action)
+			}
+			// The replacements referencing capture groups are checked once expanded
+			if !strings.Contains(rule.Replacement, "$") && !model.IsValidMetricName(model.LabelValue(rule.Replacement)) {
+				return nil, fmt.Errorf("rule %d: invalid metric name %q", i, rule.Replacement)
+			}
+		case RelabelDropLabel:
+			if rule.Label == "" {
+				return nil, fmt.Errorf("rule %d: %s requires label", i, rule.Action)
+			}
+		case RelabelMapValue:
+			if rule.Label == "" || regex == nil {
+				return nil, fmt.Errorf("rule %d: %s requires label and regex", i, rule.Action)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, rule.Action)
+		}
+		compiled = append(compiled, relabelRule{
+			action:      rule.Action,
+			metric:      metric,
+			label:       rule.Label,
+			regex:       regex,
+			replacement: rule.Replacement,
+		})
+	}
+	return compiled, nil
+}
+
+// mustCompileRelabelRules is like compileRelabelRules but panics on error
+func mustCompileRelabelRules(rules []RelabelRule) []relabelRule {
+	compiled, err := compileRelabelRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+type labelValueMapping struct {
+	index       int
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// metricDesc is a descriptor with the relabel rules already applied to its
+// name and label names. Label values are given in the original label order
+// and rewritten on emission.
+type metricDesc struct {
+	desc     *prometheus.Desc
+	keep     []int
+	mappings []labelValueMapping
+	// Set for the families summed per namespace, see namespaceAggregation
+	aggregation aggregation
+	// Family the metrics of the pods with a controller are also summed in,
+	// see controllerAggregation, and the index of their controller kind
+	perController  *metricDesc
+	controllerKind int
+}
+
+// newMetricDesc builds the descriptor of a metric family going through the relabel rules
+func newMetricDesc(rules []relabelRule, name, help string, labels []string) *metricDesc {
+	keep := make([]int, len(labels))
+	for i := range labels {
+		keep[i] = i
+	}
+	var mappings []labelValueMapping
+	for _, rule := range rules {
+		if rule.metric != nil && !rule.metric.MatchString(name) {
+			continue
+		}
+		switch rule.action {
+		case RelabelRename:
+			renamed := rule.metric.ReplaceAllString(name, rule.replacement)
+			if !model.IsValidMetricName(model.LabelValue(renamed)) {
+				slog.Warn(
+					"relabel rule renames the metric to an invalid name, keeping its name",
+					slog.String("metric", name),
+					slog.String("name", renamed),
+				)
+				continue
+			}
+			name = renamed
+		case RelabelDropLabel:
+			keep = slices.DeleteFunc(keep, func(i int) bool { return labels[i] == rule.label })
+		case RelabelMapValue:
+			if i := slices.Index(labels, rule.label); i != -1 {
+				mappings = append(mappings, labelValueMapping{i, rule.regex, rule.replacement})
+			}
+		}
+	}
+	keptLabels := make([]string, len(keep))
+	for i, idx := range keep {
+		keptLabels[i] = labels[idx]
+	}
+	return &metricDesc{
+		desc:     prometheus.NewDesc(name, help, keptLabels, nil),
+		keep:     keep,
+		mappings: mappings,
+	}
+}
+
+// labelValues relabels the given label values
+func (d *metricDesc) labelValues(labelValues []string) []string {
+	if len(d.mappings) > 0 {
+		labelValues = slices.Clone(labelValues)
+		for _, m := range d.mappings {
+			if m.regex.MatchString(labelValues[m.index]) {
+				labelValues[m.index] = m.regex.ReplaceAllString(labelValues[m.index], m.replacement)
+			}
+		}
+	}
+	if len(d.keep) != len(labelValues) {
+		kept := make([]string, len(d.keep))
+		for i, idx := range d.keep {
+			kept[i] = labelValues[idx]
+		}
+		labelValues = kept
+	}
+	return labelValues
+}
+
+// mustNewConstMetric creates the metric, relabeling the given label values
+func (d *metricDesc) mustNewConstMetric(valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
+	relabeled := d.labelValues(labelValues)
+	m := prometheus.MustNewConstMetric(d.desc, valueType, value, relabeled...)
+	if d.aggregation != aggregationNone {
+		m = &aggregatedMetric{Metric: m, desc: d, labelValues: relabeled, valueType: valueType, value: value}
+	}
+	return d.withController(m, labelValues, func(perController *metricDesc) prometheus.Metric {
+		return perController.mustNewConstMetric(valueType, value, labelValues...)
+	})
+}
+
+// mustNewConstHistogram creates the histogram, relabeling the given label values
+func (d *metricDesc) mustNewConstHistogram(count uint64, sum float64, buckets map[float64]uint64, labelValues ...string) prometheus.Metric {
+	relabeled := d.labelValues(labelValues)
+	m := prometheus.MustNewConstHistogram(d.desc, count, sum, buckets, relabeled...)
+	if d.aggregation != aggregationNone {
+		m = &aggregatedMetric{
+			Metric: m, desc: d, labelValues: relabeled,
+			histogram: true, count: count, sum: sum, buckets: buckets,
+		}
+	}
+	return d.withController(m, labelValues, func(perController *metricDesc) prometheus.Metric {
+		return perController.mustNewConstHistogram(count, sum, buckets, labelValues...)
+	})
+}
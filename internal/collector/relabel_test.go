@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricLabels(t *testing.T, m prometheus.Metric) map[string]string {
+	t.Helper()
+	var out dto.Metric
+	require.NoError(t, m.Write(&out))
+	labels := map[string]string{}
+	for _, pair := range out.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+func TestMetricDesc_Relabel(t *testing.T) {
+	rules := mustCompileRelabelRules([]RelabelRule{
+		{Action: RelabelRename, Metric: "cosanet_proc_net_snmp_Tcp_(.*)", Replacement: "cosanet_tcp_$1"},
+		{Action: RelabelDropLabel, Label: "cosanet_pod_uid"},
+		{Action: RelabelMapValue, Label: "cosanet_state", Regex: "TIME_WAIT", Replacement: "time_wait"},
+	})
+
+	d := newMetricDesc(rules, "cosanet_proc_net_snmp_Tcp_CurrEstab", "help", []string{"cosanet_state", "cosanet_pod_uid"})
+	assert.Contains(t, d.desc.String(), `fqName: "cosanet_tcp_CurrEstab"`)
+
+	labelValues := []string{"TIME_WAIT", "1234"}
+	m := d.mustNewConstMetric(prometheus.GaugeValue, 1, labelValues...)
+	assert.Equal(t, map[string]string{"cosanet_state": "time_wait"}, metricLabels(t, m))
+	assert.Equal(t, []string{"TIME_WAIT", "1234"}, labelValues, "caller's values must not be altered")
+
+	other := newMetricDesc(rules, "cosanet_conntrack_curr", "help", []string{"cosanet_state"})
+	assert.Contains(t, other.desc.String(), `fqName: "cosanet_conntrack_curr"`)
+	m = other.mustNewConstMetric(prometheus.GaugeValue, 1, "LISTEN")
+	assert.Equal(t, map[string]string{"cosanet_state": "LISTEN"}, metricLabels(t, m))
+}
+
+func TestMetricDesc_RenameInvalid(t *testing.T) {
+	// Referencing a missing capture group expands to an empty name
+	rules := mustCompileRelabelRules([]RelabelRule{
+		{Action: RelabelRename, Metric: "cosanet_proc_net_snmp_Tcp_(.*)", Replacement: "$2"},
+	})
+	d := newMetricDesc(rules, "cosanet_proc_net_snmp_Tcp_CurrEstab", "help", []string{"cosanet_pod"})
+	assert.Contains(t, d.desc.String(), `fqName: "cosanet_proc_net_snmp_Tcp_CurrEstab"`)
+	assert.NotPanics(t, func() { d.mustNewConstMetric(prometheus.GaugeValue, 1, "web") })
+}
+
+func TestCompileRelabelRules_Invalid(t *testing.T) {
+	for _, rule := range []RelabelRule{
+		{Action: "explode"},
+		{Action: RelabelRename, Metric: "foo"},
+		{Action: RelabelDropLabel},
+		{Action: RelabelMapValue, Label: "foo"},
+		{Action: RelabelMapValue, Label: "foo", Regex: "("},
+		{Action: RelabelRename, Metric: "foo", Replacement: "bar\xff"},
+	} {
+		_, err := compileRelabelRules([]RelabelRule{rule})
+		assert.Error(t, err, "%+v", rule)
+	}
+}
+
+func TestLoadRelabelConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relabel.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+  - action: drop_label
+    label: cosanet_pod_uid
+  - action: rename
+    metric: cosanet_proc_net_(.*)
+    replacement: cosanet_$1
+`), 0o600))
+	rules, err := LoadRelabelConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []RelabelRule{
+		{Action: RelabelDropLabel, Label: "cosanet_pod_uid"},
+		{Action: RelabelRename, Metric: "cosanet_proc_net_(.*)", Replacement: "cosanet_$1"},
+	}, rules)
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - action: nope\n"), 0o600))
+	_, err = LoadRelabelConfig(path)
+	assert.Error(t, err)
+}
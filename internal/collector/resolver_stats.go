@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type resolverCacheDescs struct {
+	hits           *metricDesc
+	misses         *metricDesc
+	evictions      *metricDesc
+	entries        *metricDesc
+	capacity       *metricDesc
+	lookupDuration *metricDesc
+	capability     *metricDesc
+}
+
+func (d resolverCacheDescs) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.hits.desc
+	ch <- d.misses.desc
+	ch <- d.evictions.desc
+	ch <- d.entries.desc
+	ch <- d.capacity.desc
+	ch <- d.lookupDuration.desc
+	ch <- d.capability.desc
+}
+
+// emitResolverStats publishes the controller resolver caches counters,
+// apiserver lookups latency and capabilities, absent when the resolver is
+// disabled
+func (c *CosanetCollector) emitResolverStats(ch chan<- prometheus.Metric) {
+	stats, ok := c.controller_resolver.Stats()
+	if !ok {
+		return
+	}
+	d := c.resolverCacheDescs
+	for name, cache := range stats.Caches {
+		ch <- d.hits.mustNewConstMetric(prometheus.CounterValue, float64(cache.Hits), name, c.nodename)
+		ch <- d.misses.mustNewConstMetric(prometheus.CounterValue, float64(cache.Misses), name, c.nodename)
+		ch <- d.evictions.mustNewConstMetric(prometheus.CounterValue, float64(cache.Evictions), name, c.nodename)
+		ch <- d.entries.mustNewConstMetric(prometheus.GaugeValue, float64(cache.Entries), name, c.nodename)
+		ch <- d.capacity.mustNewConstMetric(prometheus.GaugeValue, float64(cache.Capacity), name, c.nodename)
+	}
+	for kind, lookups := range stats.Lookups {
+		ch <- d.lookupDuration.mustNewConstHistogram(lookups.Count, lookups.Sum, lookups.Buckets, kind, c.nodename)
+	}
+	for capability, active := range stats.Capabilities {
+		ch <- d.capability.mustNewConstMetric(prometheus.GaugeValue, boolToFloat(active), capability, c.nodename)
+	}
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netns"
+)
+
+// selftestResult is the outcome of a collector run once in a netns
+type selftestResult struct {
+	netns     string
+	collector string
+	samples   int
+	duration  time.Duration
+	err       error
+}
+
+// Selftest runs every enabled collector once in the current netns, then in the
+// netns of a discovered pod when the sandboxes can be listed, and writes the
+// pass/fail, sample counts and timings of each to w. It tells whether every
+// collector passed. Like CollectFromMainThread, it must run on the locked main
+// thread.
+func (c *CosanetCollector) Selftest(w io.Writer) bool {
+	origns, err := netns.Get()
+	if err != nil {
+		fmt.Fprintf(w, "failed to get the current network namespace: %v\n", err)
+		return false
+	}
+	defer origns.Close()
+
+	if c.options.Veth.Enabled {
+		hostDevices, err := hostDeviceNames()
+		if err != nil {
+			slog.Error("failed to list host interfaces", slog.Any("err", err))
+		}
+		c.hostDevices = hostDevices
+	}
+	for _, counters := range c.bpfCounters() {
+		counters.snapshot()
+	}
+
+	results := c.selftestNetns(PodInfo{
+		Namespace:   "HOST",
+		HostNetwork: true,
+		netNSPath:   "HOST",
+		netNSName:   "HOST",
+		netNSID:     origns.UniqueId(),
+	})
+	if c.options.Mode == ModeSidecar {
+		fmt.Fprintln(w, "sidecar mode: no sandbox discovery")
+	} else if info, found, err := c.selftestPod(); err != nil {
+		fmt.Fprintf(w, "no pod collected, failed to list sandboxes: %v\n", err)
+	} else if !found {
+		fmt.Fprintln(w, "no pod collected, no sandbox with its own netns")
+	} else {
+		results = append(results, c.selftestInPodNetns(info, origns)...)
+	}
+	return writeSelftestResults(w, results)
+}
+
+// selftestPod picks the first collectable sandbox not sharing the host netns
+func (c *CosanetCollector) selftestPod() (PodInfo, bool, error) {
+	infos, err := c.listSandboxes(podShard{})
+	if err != nil {
+		return PodInfo{}, false, err
+	}
+	for _, info := range c.filterPods(infos, newPodsReport(infos)) {
+		if !info.HostNetwork && info.skipReason == "" {
+			return info, true, nil
+		}
+	}
+	return PodInfo{}, false, nil
+}
+
+func (c *CosanetCollector) selftestInPodNetns(info PodInfo, origns netns.NsHandle) []selftestResult {
+	name := info.Namespace + "/" + info.Name
+	nsHandle, err := c.podNetns(info)
+	if err != nil {
+		return []selftestResult{{netns: name, collector: phaseNetnsSwitch, err: err}}
+	}
+	defer nsHandle.Close()
+	info.netNSID = nsHandle.UniqueId()
+	if err := netns.Set(nsHandle); err != nil {
+		return []selftestResult{{netns: name, collector: phaseNetnsSwitch, err: err}}
+	}
+	results := c.selftestNetns(info)
+	if err := netns.Set(origns); err != nil {
+		slog.Error(
+			"failed to switch back to the original network namespace",
+			slog.Any("err", err),
+		)
+		os.Exit(1)
+	}
+	return results
+}
+
+// selftestNetns runs the enabled collectors in the current netns, counting
+// the samples of each
+func (c *CosanetCollector) selftestNetns(info PodInfo) []selftestResult {
+	name := info.Namespace
+	if info.Name != "" {
+		name += "/" + info.Name
+	}
+	info.missingIPFamilies = c.detectMissingIPFamilies()
+	var results []selftestResult
+	for _, collector := range netnsCollectors {
+		if !collector.enabled(c, info) {
+			continue
+		}
+		ch := make(chan prometheus.Metric)
+		samples := make(chan int)
+		go func() {
+			count := 0
+			for range ch {
+				count++
+			}
+			samples <- count
+		}()
+		start := time.Now()
+		err := collector.collect(c, info, ch)
+		duration := time.Since(start)
+		close(ch)
+		results = append(results, selftestResult{
+			netns:     name,
+			collector: collector.name,
+			samples:   <-samples,
+			duration:  duration,
+			err:       err,
+		})
+	}
+	return results
+}
+
+// writeSelftestResults writes a row per result and tells whether all passed
+func writeSelftestResults(w io.Writer, results []selftestResult) bool {
+	passed := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NETNS\tCOLLECTOR\tRESULT\tSAMPLES\tDURATION\tERROR")
+	for _, result := range results {
+		status, message := "pass", ""
+		if result.err != nil {
+			status, message = "FAIL", result.err.Error()
+			passed = false
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%s\t%s\n",
+			result.netns,
+			result.collector,
+			status,
+			result.samples,
+			result.duration.Round(time.Microsecond),
+			message,
+		)
+	}
+	tw.Flush()
+	return passed
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSelftestResults(t *testing.T) {
+	var out bytes.Buffer
+	passed := writeSelftestResults(&out, []selftestResult{
+		{netns: "HOST", collector: "netdev", samples: 42, duration: 1500 * time.Microsecond},
+		{netns: "default/web", collector: "conntrack", err: errors.New("permission denied")},
+	})
+	assert.False(t, passed)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"HOST", "netdev", "pass", "42", "1.5ms"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"default/web", "conntrack", "FAIL", "0", "0s", "permission", "denied"}, strings.Fields(lines[2]))
+
+	out.Reset()
+	assert.True(t, writeSelftestResults(&out, nil))
+}
+
+func TestNetnsCollectors_Enabled(t *testing.T) {
+	c := &CosanetCollector{}
+	c.options.Bridge.Enabled = true
+	c.options.Veth.Enabled = true
+	enabled := func(info PodInfo) []string {
+		var names []string
+		for _, collector := range netnsCollectors {
+			if collector.enabled(c, info) {
+				names = append(names, collector.name)
+			}
+		}
+		return names
+	}
+	assert.Equal(t, []string{"bridge"}, enabled(PodInfo{netNSPath: "HOST"}))
+	assert.Equal(t, []string{"veth"}, enabled(PodInfo{netNSPath: "/var/run/netns/cni-1"}))
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netns"
+)
+
+// Deployment modes
+const (
+	// ModeDaemonSet discovers the sandboxes of the node and collects them from
+	// their netns
+	ModeDaemonSet = "daemonset"
+	// ModeSidecar only collects the netns cosanet runs in, the one of its pod
+	ModeSidecar = "sidecar"
+)
+
+func mustParseMode(mode string) string {
+	switch mode {
+	case "", ModeDaemonSet:
+		return ModeDaemonSet
+	case ModeSidecar:
+		return mode
+	}
+	panic(fmt.Errorf("unknown mode %q, expected daemonset or sidecar", mode))
+}
+
+// SidecarOptions identify the pod of cosanet in sidecar mode, as given by the
+// downward API
+type SidecarOptions struct {
+	PodName      string
+	PodNamespace string
+	PodUID       string
+	// Downward API files of the pod labels and annotations, empty for none
+	LabelsFile      string
+	AnnotationsFile string
+}
+
+func mustCheckSidecarOptions(options CosanetCollectorOptions) {
+	if options.Mode != ModeSidecar {
+		return
+	}
+	if options.Sidecar.PodName == "" || options.Sidecar.PodNamespace == "" {
+		panic(fmt.Errorf("the sidecar mode needs the pod name and namespace (POD_NAME and POD_NAMESPACE from the downward API)"))
+	}
+}
+
+// parseDownwardAPIFile parses the key="value" lines of a downward API labels
+// or annotations file
+func parseDownwardAPIFile(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, quoted, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("malformed downward API line: %q", line)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("malformed downward API value of %s: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// sidecarPodInfo returns the pod of cosanet, its labels and annotations read
+// again on each collection as the downward API updates them
+func (c *CosanetCollector) sidecarPodInfo() PodInfo {
+	info := PodInfo{
+		Name:      c.options.Sidecar.PodName,
+		Namespace: c.options.Sidecar.PodNamespace,
+		UID:       c.options.Sidecar.PodUID,
+	}
+	for _, file := range []struct {
+		path   string
+		values *map[string]string
+	}{
+		{c.options.Sidecar.LabelsFile, &info.Labels},
+		{c.options.Sidecar.AnnotationsFile, &info.Annotations},
+	} {
+		if file.path == "" {
+			continue
+		}
+		values, err := parseDownwardAPIFile(file.path)
+		if err != nil {
+			slog.Error("failed to read the downward API file", slog.String("path", file.path), slog.Any("err", err))
+			continue
+		}
+		*file.values = values
+	}
+	return info
+}
+
+// collectSidecar collects the netns of cosanet's own pod, without any
+// sandbox discovery nor netns switch. The node wide metrics are left out.
+func (c *CosanetCollector) collectSidecar(origns netns.NsHandle, ch chan<- prometheus.Metric) {
+	info := c.sidecarPodInfo()
+	info.netNSID = origns.UniqueId()
+	report := newPodsReport([]PodInfo{info})
+	report.decide(info, podDecisionCollected, "")
+	defer c.lastPods.set(report)
+	for _, counters := range c.bpfCounters() {
+		counters.snapshot()
+	}
+	var records *netnsRecords
+	if c.recordNetns() {
+		records = &netnsRecords{collectedAt: report.collectedAt}
+		defer c.lastNetns.set(records)
+	}
+	c.emitResolverStats(ch)
+	c.emitLogSuppressed(ch)
+	c.collectNetnsStats(info, records, ch)
+	c.conntrackPool.sweep()
+	c.conntrackEventWatchers.sweep()
+	c.conntrackSaturation.sweep()
+	for _, counters := range c.bpfCounters() {
+		counters.sweep()
+	}
+	c.ready.Store(true)
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMustParseMode(t *testing.T) {
+	assert.Equal(t, ModeDaemonSet, mustParseMode(""))
+	assert.Equal(t, ModeSidecar, mustParseMode("sidecar"))
+	assert.Panics(t, func() { mustParseMode("standalone") })
+
+	options := CosanetCollectorOptions{Mode: ModeSidecar}
+	assert.Panics(t, func() { mustCheckSidecarOptions(options) })
+	options.Sidecar = SidecarOptions{PodName: "web-0", PodNamespace: "shop"}
+	assert.NotPanics(t, func() { mustCheckSidecarOptions(options) })
+}
+
+func TestParseDownwardAPIFile(t *testing.T) {
+	dir := t.TempDir()
+	labels := filepath.Join(dir, "labels")
+	require.NoError(t, os.WriteFile(labels, []byte(
+		"app=\"web\"\n"+
+			"app.kubernetes.io/part-of=\"shop\"\n"+
+			"note=\"a \\\"quoted\\\" value\"\n",
+	), 0o644))
+
+	values, err := parseDownwardAPIFile(labels)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app":                       "web",
+		"app.kubernetes.io/part-of": "shop",
+		"note":                      `a "quoted" value`,
+	}, values)
+
+	malformed := filepath.Join(dir, "annotations")
+	require.NoError(t, os.WriteFile(malformed, []byte("app=web\n"), 0o644))
+	_, err = parseDownwardAPIFile(malformed)
+	assert.Error(t, err)
+}
+
+func TestSidecarPodInfo(t *testing.T) {
+	labels := filepath.Join(t.TempDir(), "labels")
+	require.NoError(t, os.WriteFile(labels, []byte("app=\"web\"\n"), 0o644))
+
+	c := &CosanetCollector{}
+	c.options.Sidecar = SidecarOptions{
+		PodName:         "web-0",
+		PodNamespace:    "shop",
+		PodUID:          "uid-1",
+		LabelsFile:      labels,
+		AnnotationsFile: filepath.Join(t.TempDir(), "missing"),
+	}
+	info := c.sidecarPodInfo()
+	assert.Equal(t, "web-0", info.Name)
+	assert.Equal(t, "shop", info.Namespace)
+	assert.Equal(t, "uid-1", info.UID)
+	assert.False(t, info.HostNetwork)
+	assert.Equal(t, map[string]string{"app": "web"}, info.Labels)
+	assert.Nil(t, info.Annotations, "unreadable files are left out")
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cosanet/cosanet/pkg/netstat"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// mustParseSkMemProtos returns the protocols of the comma separated list,
+// panicking on the ones INET_DIAG can't dump
+func mustParseSkMemProtos(list string) []string {
+	protos := splitList(list)
+	for _, proto := range protos {
+		if _, found := sockDiagProtocols[proto]; !found {
+			panic(fmt.Errorf("unsupported socket memory protocol %q, expected tcp, udp, udplite or raw", proto))
+		}
+	}
+	slices.Sort(protos)
+	return slices.Compact(protos)
+}
+
+func (c *CosanetCollector) collectAndEmitSkMem(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+
+	var errs []error
+	for _, proto := range c.skMemProtos {
+		for _, family := range c.sockFamilies {
+			if !info.hasIPFamily(family.name) {
+				continue
+			}
+			stats, err := netstat.DiagSkMem(family.family, sockDiagProtocols[proto])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", proto, family.name, err))
+				continue
+			}
+			protoLabelValues := append([]string{proto, family.name}, labelValues...)
+			for memory, value := range map[string]uint64{
+				"rmem_alloc": stats.RmemAlloc,
+				"wmem_alloc": stats.WmemAlloc,
+				"backlog":    stats.Backlog,
+			} {
+				ch <- c.skMemBytesDesc.mustNewConstMetric(
+					prometheus.GaugeValue,
+					float64(value),
+					append([]string{proto, family.name, memory}, labelValues...)...,
+				)
+			}
+			ch <- c.skMemDropsDesc.mustNewConstMetric(prometheus.GaugeValue, float64(stats.Drops), protoLabelValues...)
+			ch <- c.skMemRcvBufUsageDesc.mustNewConstMetric(prometheus.GaugeValue, stats.MaxRcvBufUsage, protoLabelValues...)
+		}
+	}
+	return errors.Join(errs...)
+}
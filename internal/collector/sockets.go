@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/cosanet/cosanet/pkg/netstat"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (c *CosanetCollector) collectAndEmitUnixSockets(info PodInfo, ch chan<- prometheus.Metric) error {
+	stats, err := netstat.UnixStatsFromProcfs(c.options.ProcRoot)
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	for sockType, states := range stats {
+		for state, count := range states {
+			ch <- c.unixSocketsDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{sockType, state}, labelValues...)...,
+			)
+		}
+	}
+	return nil
+}
+
+func (c *CosanetCollector) collectAndEmitNetlinkSockets(info PodInfo, ch chan<- prometheus.Metric) error {
+	stats, err := netstat.NetlinkStatsFromProcfs(c.options.ProcRoot)
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	for protocol, count := range stats {
+		ch <- c.netlinkSocketsDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(count),
+			append([]string{protocol}, labelValues...)...,
+		)
+	}
+	return nil
+}
+
+type packetSocketKey struct {
+	sockType, protocol string
+}
+
+func (c *CosanetCollector) collectAndEmitPacketSockets(info PodInfo, ch chan<- prometheus.Metric) error {
+	sockets, err := netstat.PacketSocketsFromProcfs(c.options.ProcRoot)
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	counts := make(map[packetSocketKey]int)
+	for _, sock := range sockets {
+		counts[packetSocketKey{sock.Type, sock.Protocol}]++
+		if c.options.Packet.Details {
+			ch <- c.packetSocketInfoDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				1,
+				append([]string{
+					sock.Type,
+					sock.Protocol,
+					strconv.Itoa(sock.Ifindex),
+					strconv.FormatUint(sock.Inode, 10),
+				}, labelValues...)...,
+			)
+		}
+	}
+	for key, count := range counts {
+		ch <- c.packetSocketsDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(count),
+			append([]string{key.sockType, key.protocol}, labelValues...)...,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/cosanet/cosanet/internal/procnet_softnet_parser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectAndEmitSoftnet publishes the per CPU packet processing counters.
+// They are global to the node, so only read once from the host.
+func (c *CosanetCollector) collectAndEmitSoftnet(ch chan<- prometheus.Metric) error {
+	stats, err := procnet_softnet_parser.ParseSoftnetFile(c.procPath("net/softnet_stat"))
+	if err != nil {
+		return err
+	}
+	for _, stat := range stats {
+		labelValues := []string{strconv.Itoa(stat.CPU), c.nodename}
+		ch <- c.softnetProcessedDesc.mustNewConstMetric(prometheus.CounterValue, float64(stat.Processed), labelValues...)
+		ch <- c.softnetDroppedDesc.mustNewConstMetric(prometheus.CounterValue, float64(stat.Dropped), labelValues...)
+		ch <- c.softnetTimeSqueezeDesc.mustNewConstMetric(prometheus.CounterValue, float64(stat.TimeSqueeze), labelValues...)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netns"
+)
+
+// spreadJitter is the share of the interval between two pods by which their
+// collection is randomly moved
+const spreadJitter = 0.25
+
+// SpreadCollection collects the pods one at a time, spread evenly over the
+// cache window with some jitter, rather than all at once when the cache
+// expires. A round lists the sandboxes when it starts, collects a pod per
+// step then the host when the window ends. The scrapes are served the last
+// metrics of every pod. Only used from the main thread.
+type SpreadCollection struct {
+	c      *CosanetCollector
+	window time.Duration
+	// Current round, nil until the first step
+	round    *collectionRound
+	start    time.Time
+	interval time.Duration
+	next     int
+	busy     time.Duration
+	// Metrics of the beginning of the current round and of the end of the
+	// last one
+	begin []prometheus.Metric
+	end   []prometheus.Metric
+	// Last metrics of every pod, the pods gone being forgotten at the end
+	// of the round, and the pods collected by the current round
+	pods map[string][]prometheus.Metric
+	keys []string
+}
+
+// NewSpreadCollection spreads the collections over window, see
+// SpreadCollection
+func (c *CosanetCollector) NewSpreadCollection(window time.Duration) *SpreadCollection {
+	return &SpreadCollection{c: c, window: window, pods: make(map[string][]prometheus.Metric)}
+}
+
+// collectMetrics returns the metrics sent by collect
+func collectMetrics(collect func(ch chan<- prometheus.Metric)) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
+	}()
+	collect(ch)
+	close(ch)
+	return <-done
+}
+
+// StepFromMainThread runs the next step of the round, beginning a new one
+// when the last is over, and returns the delay until the next step
+func (s *SpreadCollection) StepFromMainThread() time.Duration {
+	c := s.c
+	if c.options.Mode == ModeSidecar {
+		s.begin = collectMetrics(c.collect)
+		return s.window
+	}
+	c.watchdog.begin()
+	defer c.watchdog.end()
+	stepStart := time.Now()
+	if s.round == nil {
+		s.start = stepStart
+		s.busy = 0
+		c.phaseTimer.reset()
+		origns, _ := netns.Get()
+		s.begin = collectMetrics(func(ch chan<- prometheus.Metric) {
+			s.round = c.beginRound(origns, ch)
+		})
+		s.interval = s.window / time.Duration(len(s.round.pods)+1)
+		s.next = 0
+		s.keys = s.keys[:0]
+	} else if s.next < len(s.round.pods) {
+		info := s.round.pods[s.next]
+		s.next++
+		key := netnsKey(info)
+		s.pods[key] = collectMetrics(func(ch chan<- prometheus.Metric) {
+			s.round.collectPod(c, info, ch)
+		})
+		s.keys = append(s.keys, key)
+	} else {
+		round := s.round
+		s.end = collectMetrics(func(ch chan<- prometheus.Metric) {
+			c.endRound(round, ch)
+			s.busy += time.Since(stepStart)
+			c.emitCollectionStats(s.busy, ch)
+		})
+		round.origns.Close()
+		s.forgetGonePods()
+		s.round = nil
+		return time.Until(s.start.Add(s.window))
+	}
+	s.busy += time.Since(stepStart)
+	return time.Until(s.due(s.next + 1))
+}
+
+// due returns when the step-th pod of the round is collected, the host one
+// after the last pod being collected at the end of the window
+func (s *SpreadCollection) due(step int) time.Time {
+	if step > len(s.round.pods) {
+		return s.start.Add(s.window)
+	}
+	jitter := time.Duration((rand.Float64()*2 - 1) * spreadJitter * float64(s.interval))
+	return s.start.Add(time.Duration(step)*s.interval + jitter)
+}
+
+// forgetGonePods drops the metrics of the pods not collected by the round
+func (s *SpreadCollection) forgetGonePods() {
+	collected := make(map[string]bool, len(s.keys))
+	for _, key := range s.keys {
+		collected[key] = true
+	}
+	for key := range s.pods {
+		if !collected[key] {
+			delete(s.pods, key)
+		}
+	}
+}
+
+// CollectFromMainThread sends the last metrics of the node and of every pod,
+// aggregated as CosanetCollector.CollectFromMainThread does
+func (s *SpreadCollection) CollectFromMainThread(ch chan<- prometheus.Metric) {
+	ch, emitAggregated := s.c.aggregateByNamespace(ch)
+	defer emitAggregated()
+	ch, emitPerController := s.c.aggregateByController(ch)
+	defer emitPerController()
+	for _, m := range s.begin {
+		ch <- m
+	}
+	for _, metrics := range s.pods {
+		for _, m := range metrics {
+			ch <- m
+		}
+	}
+	for _, m := range s.end {
+		ch <- m
+	}
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpreadCollectionDue(t *testing.T) {
+	start := time.Now()
+	s := &SpreadCollection{
+		window:   time.Second,
+		round:    &collectionRound{pods: make([]PodInfo, 3)},
+		start:    start,
+		interval: 250 * time.Millisecond,
+	}
+	for step := 1; step <= 3; step++ {
+		due := s.due(step).Sub(start)
+		expected := time.Duration(step) * s.interval
+		assert.InDelta(t, expected, due, float64(s.interval)*spreadJitter, "pod %d", step)
+	}
+	assert.Equal(t, start.Add(time.Second), s.due(4), "the host at the end of the window")
+}
+
+func TestSpreadCollectionForgetGonePods(t *testing.T) {
+	c := &CosanetCollector{}
+	s := c.NewSpreadCollection(time.Second)
+	web := PodInfo{Name: "web", Namespace: "default", UID: "uid-web"}
+	vrf := PodInfo{netNSPath: "/var/run/netns/vrf-red", netNSName: "vrf-red"}
+	assert.Equal(t, "uid-web", netnsKey(web))
+	assert.Equal(t, "netns:/var/run/netns/vrf-red", netnsKey(vrf))
+
+	metric := prometheus.MustNewConstMetric(prometheus.NewDesc("m", "m", nil, nil), prometheus.GaugeValue, 1)
+	s.pods = map[string][]prometheus.Metric{
+		netnsKey(web): {metric},
+		netnsKey(vrf): {metric},
+		"uid-gone":    {metric},
+	}
+	s.keys = []string{netnsKey(web), netnsKey(vrf)}
+	s.forgetGonePods()
+	assert.Len(t, s.pods, 2)
+	assert.NotContains(t, s.pods, "uid-gone")
+}
+
+func TestSpreadCollectionCollect(t *testing.T) {
+	c := &CosanetCollector{}
+	s := c.NewSpreadCollection(time.Second)
+	gauge := func(name string) prometheus.Metric {
+		return prometheus.MustNewConstMetric(prometheus.NewDesc(name, name, nil, nil), prometheus.GaugeValue, 1)
+	}
+	s.begin = []prometheus.Metric{gauge("begin")}
+	s.pods["uid-web"] = []prometheus.Metric{gauge("web")}
+	s.end = []prometheus.Metric{gauge("end")}
+
+	ch := make(chan prometheus.Metric, 3)
+	s.CollectFromMainThread(ch)
+	close(ch)
+	var names []string
+	for m := range ch {
+		names = append(names, m.Desc().String())
+	}
+	assert.Len(t, names, 3)
+	assert.Contains(t, names[0], "begin")
+	assert.Contains(t, names[2], "end")
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// mustParseSysctlNames returns the sysctls of the comma separated list,
+// panicking on names outside of the net tree
+func mustParseSysctlNames(list string) []string {
+	names := splitList(list)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "net.") || strings.Contains(name, "/") || strings.Contains(name, "..") {
+			panic(fmt.Errorf("invalid sysctl %q, expected a net.* dotted name", name))
+		}
+	}
+	return names
+}
+
+// parseSysctlValues splits a sysctl content into its numeric values, some of
+// them hold several (ip_local_port_range, tcp_mem...)
+func parseSysctlValues(content string) ([]float64, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return nil, errors.New("empty sysctl")
+	}
+	values := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, float64(value))
+	}
+	return values, nil
+}
+
+// collectAndEmitSysctls publishes the configured sysctls. /proc/sys/net
+// follows the netns of the reading thread, so they are read per netns.
+func (c *CosanetCollector) collectAndEmitSysctls(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+	var errs []error
+	for _, name := range c.sysctlNames {
+		content, err := os.ReadFile(c.procPath("sys/" + strings.ReplaceAll(name, ".", "/")))
+		if errors.Is(err, os.ErrNotExist) {
+			// Not namespaced (host only) or module not loaded
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values, err := parseSysctlValues(string(content))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for index, value := range values {
+			ch <- c.sysctlDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				value,
+				append([]string{name, strconv.Itoa(index)}, labelValues...)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMustParseSysctlNames(t *testing.T) {
+	assert.Equal(t, []string{"net.core.somaxconn", "net.ipv4.tcp_mem"}, mustParseSysctlNames("net.core.somaxconn, net.ipv4.tcp_mem"))
+	assert.Nil(t, mustParseSysctlNames(""))
+	assert.Panics(t, func() { mustParseSysctlNames("kernel.pid_max") })
+	assert.Panics(t, func() { mustParseSysctlNames("net.../../kernel/pid_max") })
+}
+
+func TestParseSysctlValues(t *testing.T) {
+	values, err := parseSysctlValues("4096\n")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{4096}, values)
+
+	values, err = parseSysctlValues("32768\t60999\n")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{32768, 60999}, values)
+
+	_, err = parseSysctlValues("cubic\n")
+	assert.Error(t, err)
+	_, err = parseSysctlValues("\n")
+	assert.Error(t, err)
+}
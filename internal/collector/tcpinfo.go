@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/cosanet/cosanet/pkg/netstat"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names of the TCP congestion avoidance states, indexed by sockdiag.CA*
+var tcpCAStates = [...]string{"open", "disorder", "cwr", "recovery", "loss"}
+
+// mustParseBuckets parses a comma separated list of histogram upper bounds,
+// panicking if malformed
+func mustParseBuckets(list string) []float64 {
+	var buckets []float64
+	for _, item := range splitList(list) {
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			panic(fmt.Errorf("malformed histogram bucket %q: %w", item, err))
+		}
+		buckets = append(buckets, bound)
+	}
+	slices.Sort(buckets)
+	return slices.Compact(buckets)
+}
+
+func (c *CosanetCollector) collectAndEmitTCPInfo(info PodInfo, ch chan<- prometheus.Metric) error {
+	labelValues := c.netnsLabelValues(info)
+
+	var errs []error
+	for _, family := range c.sockFamilies {
+		if !info.hasIPFamily(family.name) {
+			continue
+		}
+		stats, err := netstat.DiagTCPInfo(family.family, c.tcpInfoRTTBuckets)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family.name, err))
+			continue
+		}
+		familyLabelValues := append([]string{family.name}, labelValues...)
+		ch <- c.tcpRTTDesc.mustNewConstHistogram(
+			stats.Connections,
+			stats.RTTSum,
+			stats.RTTBuckets,
+			familyLabelValues...,
+		)
+		ch <- c.tcpRetransSegmentsDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(stats.TotalRetrans),
+			familyLabelValues...,
+		)
+		ch <- c.tcpRetransmittingDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			float64(stats.Retransmitting),
+			familyLabelValues...,
+		)
+		for state, count := range stats.CAStates {
+			ch <- c.tcpCAStateDesc.mustNewConstMetric(
+				prometheus.GaugeValue,
+				float64(count),
+				append([]string{tcpCAStates[state]}, familyLabelValues...)...,
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hostDeviceNames maps the ifindex of the links of the current netns to their
+// name, to be called from the host netns
+func hostDeviceNames() (map[int]string, error) {
+	links, err := dumpLinks()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(links))
+	for _, link := range links {
+		names[link.index] = link.name
+	}
+	return names, nil
+}
+
+// collectAndEmitVethPeers maps the veths of the pod netns to their host side
+// peer. The host side name is left empty when the peer is not in the host
+// netns, e.g. between two pods.
+func (c *CosanetCollector) collectAndEmitVethPeers(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := dumpLinks()
+	if err != nil {
+		return err
+	}
+	labelValues := c.netnsLabelValues(info)
+	for _, link := range links {
+		if link.kind != "veth" {
+			continue
+		}
+		ch <- c.vethPeerInfoDesc.mustNewConstMetric(
+			prometheus.GaugeValue,
+			1,
+			append([]string{
+				link.name,
+				strconv.Itoa(link.index),
+				strconv.Itoa(link.peerIndex),
+				c.hostDevices[link.peerIndex],
+			}, labelValues...)...,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package collector
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reason of cosanet_skipped_sandboxes of the netns abandoned by the watchdog
+const skipReasonStalled = "stalled"
+
+// Rounds an abandoned netns is skipped for before being collected again, in
+// case it only stalled transiently
+const watchdogAbandonRounds = 10
+
+// watchdog detects the main thread making no progress in a collection for
+// longer than the deadline, e.g. a netlink call hanging in a foreign netns,
+// a collection slowly going through many netns being fine. It then serves
+// the scrapes without waiting for it, abandons the netns being collected
+// (closing its conntrack connection, skipping it for watchdogAbandonRounds
+// rounds) and exits once stuck for another deadline, for Kubernetes to
+// restart the process. Nil when disabled.
+type watchdog struct {
+	deadline time.Duration
+	// Called with the exit code of the last resort, os.Exit
+	exit func(code int)
+
+	mu sync.Mutex
+	// Last progress of the running collection, its start, the netns or the
+	// collector it entered, zero while idle
+	progress time.Time
+	// Netns being collected
+	current PodInfo
+	// Closed while the running collection is stalled
+	stalledCh chan struct{}
+	stalled   bool
+	stalls    uint64
+	// Rounds since the netns were abandoned
+	abandoned map[string]int
+}
+
+func newWatchdog(deadline time.Duration) *watchdog {
+	if deadline <= 0 {
+		return nil
+	}
+	return &watchdog{
+		deadline:  deadline,
+		exit:      os.Exit,
+		stalledCh: make(chan struct{}),
+		abandoned: make(map[string]int),
+	}
+}
+
+// begin marks the start of a collection on the main thread
+func (w *watchdog) begin() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress = time.Now()
+	w.current = PodInfo{}
+}
+
+// end marks the end of the collection, recovering from a stall
+func (w *watchdog) end() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress = time.Time{}
+	if w.stalled {
+		slog.Warn("stalled collection recovered", slog.String("netns", w.current.netNSName))
+		w.stalled = false
+		w.stalledCh = make(chan struct{})
+	}
+}
+
+// enter records the netns the collection goes through
+func (w *watchdog) enter(info PodInfo) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current = info
+	w.progress = time.Now()
+}
+
+// advance records the progress of the collection within the current netns
+func (w *watchdog) advance() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress = time.Now()
+}
+
+// endRound forgets the abandoned netns no longer listed, and the ones
+// abandoned for watchdogAbandonRounds rounds, to be collected again
+func (w *watchdog) endRound(pods []PodInfo) {
+	if w == nil {
+		return
+	}
+	listed := make(map[string]bool, len(pods))
+	for _, info := range pods {
+		listed[netnsKey(info)] = true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, rounds := range w.abandoned {
+		if !listed[key] || rounds+1 >= watchdogAbandonRounds {
+			delete(w.abandoned, key)
+			continue
+		}
+		w.abandoned[key] = rounds + 1
+	}
+}
+
+// isAbandoned tells whether a collection stalled in the netns of the pod
+func (w *watchdog) isAbandoned(info PodInfo) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, abandoned := w.abandoned[netnsKey(info)]
+	return abandoned
+}
+
+// stalledChan returns the channel closed while the collection is stalled,
+// nil, blocking forever, when disabled
+func (w *watchdog) stalledChan() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalledCh
+}
+
+// check detects a stall of the running collection, no progress for longer
+// than the deadline, returning the netns it stalled in when newly detected.
+// Past twice the deadline, it exits.
+func (w *watchdog) check(now time.Time) (PodInfo, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.progress.IsZero() {
+		return PodInfo{}, false
+	}
+	elapsed := now.Sub(w.progress)
+	switch {
+	case elapsed > 2*w.deadline:
+		slog.Error(
+			"collection still stalled after abandoning its netns, exiting",
+			slog.String("name", w.current.Name),
+			slog.String("namespace", w.current.Namespace),
+			slog.String("netns", w.current.netNSName),
+			slog.Duration("elapsed", elapsed),
+		)
+		w.exit(1)
+	case elapsed > w.deadline && !w.stalled:
+		w.stalled = true
+		w.stalls++
+		close(w.stalledCh)
+		if w.current.netNSPath != "HOST" {
+			w.abandoned[netnsKey(w.current)] = 0
+		}
+		return w.current, true
+	}
+	return PodInfo{}, false
+}
+
+// runWatchdog checks the collections until the process exits
+func (c *CosanetCollector) runWatchdog() {
+	ticker := time.NewTicker(c.watchdog.deadline / 4)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		info, stalled := c.watchdog.check(now)
+		if !stalled {
+			continue
+		}
+		slog.Error(
+			"collection stalled, abandoning its netns",
+			slog.String("name", info.Name),
+			slog.String("namespace", info.Namespace),
+			slog.String("netns", info.netNSName),
+			slog.Duration("deadline", c.watchdog.deadline),
+		)
+		// Unblocks a conntrack query, as the collector timeout does
+		if info.netNSID != "" {
+			c.conntrackPool.discard(info.netNSID)
+		}
+	}
+}
+
+// emitWatchdog publishes whether the collection is stalled and the stalls so
+// far, straight from the scrape as the main thread may be stuck
+func (c *CosanetCollector) emitWatchdog(ch chan<- prometheus.Metric) {
+	w := c.watchdog
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	stalled, stalls := w.stalled, w.stalls
+	w.mu.Unlock()
+	ch <- c.collectionStalledDesc.mustNewConstMetric(prometheus.GaugeValue, boolToFloat(stalled), c.nodename)
+	ch <- c.collectionStallsDesc.mustNewConstMetric(prometheus.CounterValue, float64(stalls), c.nodename)
+}
+
+// requestMainThread has the request served by the main thread, forwarding
+// its metrics to ch. It gives up when the collection stalls, the late
+// metrics being dropped.
+func (c *CosanetCollector) requestMainThread(request CollectRequest, ch chan<- prometheus.Metric) {
+	stalled := c.watchdog.stalledChan()
+	feed := make(chan prometheus.Metric)
+	// Buffered for the main thread not to block once given up
+	done := make(chan bool, 1)
+	request.Feed, request.Done = feed, done
+	select {
+	case c.chanToFeed <- request:
+	case <-stalled:
+		return
+	}
+	for {
+		select {
+		case m := <-feed:
+			ch <- m
+		case <-done:
+			return
+		case <-stalled:
+			go func() {
+				for {
+					select {
+					case <-feed:
+					case <-done:
+						return
+					}
+				}
+			}()
+			return
+		}
+	}
+}
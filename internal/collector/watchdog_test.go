@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogCheck(t *testing.T) {
+	assert.Nil(t, newWatchdog(0))
+	w := newWatchdog(time.Second)
+	var exited int
+	w.exit = func(code int) { exited = code }
+
+	_, stalled := w.check(time.Now().Add(time.Hour))
+	assert.False(t, stalled, "idle")
+
+	w.begin()
+	web := PodInfo{Name: "web", Namespace: "default", UID: "uid-web", netNSID: "ns-web"}
+	w.enter(web)
+	_, stalled = w.check(time.Now())
+	assert.False(t, stalled)
+
+	info, stalled := w.check(time.Now().Add(1500 * time.Millisecond))
+	require.True(t, stalled)
+	assert.Equal(t, web, info)
+	assert.True(t, w.isAbandoned(web))
+	assert.False(t, w.isAbandoned(PodInfo{Name: "db", Namespace: "default", UID: "uid-db"}))
+	select {
+	case <-w.stalledChan():
+	default:
+		t.Fatal("the scrapes aren't released")
+	}
+	_, stalled = w.check(time.Now().Add(1500 * time.Millisecond))
+	assert.False(t, stalled, "reported once")
+	assert.Zero(t, exited)
+
+	w.check(time.Now().Add(3 * time.Second))
+	assert.Equal(t, 1, exited, "last resort")
+
+	w.end()
+	select {
+	case <-w.stalledChan():
+		t.Fatal("still stalled once recovered")
+	default:
+	}
+	assert.Equal(t, uint64(1), w.stalls)
+
+	// The host isn't abandoned
+	w.begin()
+	w.enter(PodInfo{Namespace: "HOST", netNSPath: "HOST"})
+	_, stalled = w.check(time.Now().Add(1500 * time.Millisecond))
+	assert.True(t, stalled)
+	assert.Len(t, w.abandoned, 1)
+}
+
+func TestWatchdogProgress(t *testing.T) {
+	w := newWatchdog(time.Second)
+	w.exit = func(int) { t.Fatal("exited while progressing") }
+	start := time.Now()
+	w.begin()
+	// A slow collection entering a netns every 900ms isn't stalled, past the
+	// deadline overall
+	for i, name := range []string{"web", "db", "cache"} {
+		w.enter(PodInfo{Name: name, Namespace: "default", UID: "uid-" + name})
+		w.progress = start.Add(time.Duration(i) * 900 * time.Millisecond)
+		_, stalled := w.check(w.progress.Add(900 * time.Millisecond))
+		assert.False(t, stalled, name)
+	}
+	// Nor one going through the collectors of a netns
+	w.advance()
+	_, stalled := w.check(time.Now().Add(900 * time.Millisecond))
+	assert.False(t, stalled)
+
+	info, stalled := w.check(time.Now().Add(1500 * time.Millisecond))
+	require.True(t, stalled)
+	assert.Equal(t, "cache", info.Name)
+	assert.Equal(t, map[string]int{"uid-cache": 0}, w.abandoned)
+}
+
+func TestWatchdogEndRound(t *testing.T) {
+	w := newWatchdog(time.Second)
+	web := PodInfo{Name: "web", Namespace: "default", UID: "uid-web"}
+	db := PodInfo{Name: "db", Namespace: "default", UID: "uid-db"}
+	w.abandoned[netnsKey(web)] = 0
+	w.abandoned[netnsKey(db)] = 0
+
+	// db is gone
+	w.endRound([]PodInfo{web})
+	assert.True(t, w.isAbandoned(web))
+	assert.False(t, w.isAbandoned(db))
+
+	for range watchdogAbandonRounds - 2 {
+		w.endRound([]PodInfo{web})
+	}
+	assert.True(t, w.isAbandoned(web))
+	w.endRound([]PodInfo{web})
+	assert.False(t, w.isAbandoned(web), "collected again")
+}
+
+func TestRequestMainThreadStalled(t *testing.T) {
+	c := &CosanetCollector{chanToFeed: make(chan CollectRequest), watchdog: newWatchdog(time.Second)}
+	c.watchdog.begin()
+	served := make(chan struct{})
+	// Main thread sending a metric then stuck
+	go func() {
+		request := <-c.chanToFeed
+		request.Feed <- prometheus.MustNewConstMetric(prometheus.NewDesc("m", "m", nil, nil), prometheus.GaugeValue, 1)
+		<-served
+		request.Done <- true
+	}()
+
+	ch := make(chan prometheus.Metric, 1)
+	returned := make(chan struct{})
+	go func() {
+		c.requestMainThread(CollectRequest{}, ch)
+		close(returned)
+	}()
+	<-ch
+	c.watchdog.check(time.Now().Add(1500 * time.Millisecond))
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("the scrape waits for the stalled main thread")
+	}
+	close(served)
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// WireGuard generic netlink API, see include/uapi/linux/wireguard.h
+const (
+	wgGenlName     = "wireguard"
+	wgGenlVersion  = 1
+	wgCmdGetDevice = 0
+
+	wgDeviceAIfname = 2
+	wgDeviceAPeers  = 8
+
+	wgPeerAPublicKey         = 1
+	wgPeerALastHandshakeTime = 6
+	wgPeerARxBytes           = 7
+	wgPeerATxBytes           = 8
+	wgPeerAAllowedIPs        = 9
+
+	// struct __kernel_timespec
+	sizeofKernelTimespec = 16
+)
+
+var errTruncatedWireguard = errors.New("truncated wireguard message")
+
+// wgPeer holds the peer attributes the wireguard collector exposes
+type wgPeer struct {
+	publicKey string
+	// Zero when no handshake happened yet
+	lastHandshake time.Time
+	rxBytes       uint64
+	txBytes       uint64
+	allowedIPs    int
+}
+
+// wgPeers accumulates the peers of a device dump. Peers with many allowed IPs
+// are split over several messages, continuations only carrying the public key
+// and the remaining allowed IPs.
+type wgPeers struct {
+	order []string
+	peers map[string]*wgPeer
+}
+
+func (p *wgPeers) merge(peer wgPeer) {
+	if p.peers == nil {
+		p.peers = make(map[string]*wgPeer)
+	}
+	known, found := p.peers[peer.publicKey]
+	if !found {
+		p.order = append(p.order, peer.publicKey)
+		p.peers[peer.publicKey] = &peer
+		return
+	}
+	known.allowedIPs += peer.allowedIPs
+	if !peer.lastHandshake.IsZero() {
+		known.lastHandshake = peer.lastHandshake
+	}
+	known.rxBytes = max(known.rxBytes, peer.rxBytes)
+	known.txBytes = max(known.txBytes, peer.txBytes)
+}
+
+func (p *wgPeers) list() []wgPeer {
+	peers := make([]wgPeer, 0, len(p.order))
+	for _, key := range p.order {
+		peers = append(peers, *p.peers[key])
+	}
+	return peers
+}
+
+func parseWireguardPeer(b []byte) (wgPeer, error) {
+	var peer wgPeer
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return peer, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case wgPeerAPublicKey:
+			peer.publicKey = base64.StdEncoding.EncodeToString(attr.Value)
+		case wgPeerALastHandshakeTime:
+			if len(attr.Value) < sizeofKernelTimespec {
+				return peer, errTruncatedWireguard
+			}
+			sec := int64(binary.NativeEndian.Uint64(attr.Value[0:8]))
+			nsec := int64(binary.NativeEndian.Uint64(attr.Value[8:16]))
+			if sec != 0 || nsec != 0 {
+				peer.lastHandshake = time.Unix(sec, nsec)
+			}
+		case wgPeerARxBytes:
+			if len(attr.Value) < 8 {
+				return peer, errTruncatedWireguard
+			}
+			peer.rxBytes = binary.NativeEndian.Uint64(attr.Value)
+		case wgPeerATxBytes:
+			if len(attr.Value) < 8 {
+				return peer, errTruncatedWireguard
+			}
+			peer.txBytes = binary.NativeEndian.Uint64(attr.Value)
+		case wgPeerAAllowedIPs:
+			allowedIPs, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				return peer, err
+			}
+			peer.allowedIPs = len(allowedIPs)
+		}
+	}
+	return peer, nil
+}
+
+// parseWireguardMessage decodes the peers of a WG_CMD_GET_DEVICE message
+func parseWireguardMessage(m []byte, peers *wgPeers) error {
+	if len(m) < nl.SizeofGenlmsg {
+		return errTruncatedWireguard
+	}
+	attrs, err := nl.ParseRouteAttr(m[nl.SizeofGenlmsg:])
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		if attr.Attr.Type&nl.NLA_TYPE_MASK != wgDeviceAPeers {
+			continue
+		}
+		nested, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return err
+		}
+		for _, peerAttr := range nested {
+			peer, err := parseWireguardPeer(peerAttr.Value)
+			if err != nil {
+				return err
+			}
+			peers.merge(peer)
+		}
+	}
+	return nil
+}
+
+// dumpWireguardPeers lists the peers of a WireGuard device of the current
+// network namespace
+func dumpWireguardPeers(family uint16, device string) ([]wgPeer, error) {
+	req := nl.NewNetlinkRequest(int(family), unix.NLM_F_DUMP)
+	req.AddData(&nl.Genlmsg{Command: wgCmdGetDevice, Version: wgGenlVersion})
+	req.AddData(nl.NewRtAttr(wgDeviceAIfname, nl.ZeroTerminated(device)))
+	msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var peers wgPeers
+	for _, m := range msgs {
+		if err := parseWireguardMessage(m, &peers); err != nil {
+			return nil, err
+		}
+	}
+	return peers.list(), nil
+}
+
+func (c *CosanetCollector) collectAndEmitWireguard(info PodInfo, ch chan<- prometheus.Metric) error {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+	var devices []string
+	for _, link := range links {
+		if link.Type() == wgGenlName {
+			devices = append(devices, link.Attrs().Name)
+		}
+	}
+	// No need to look the family up, and fail without the module, if there
+	// is nothing to dump
+	if len(devices) == 0 {
+		return nil
+	}
+	family, err := netlink.GenlFamilyGet(wgGenlName)
+	if err != nil {
+		return err
+	}
+
+	labelValues := c.netnsLabelValues(info)
+	now := time.Now()
+	var errs []error
+	for _, device := range devices {
+		peers, err := dumpWireguardPeers(family.ID, device)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", device, err))
+			continue
+		}
+		for _, peer := range peers {
+			peerLabelValues := append([]string{device, peer.publicKey}, labelValues...)
+			ch <- c.wireguardRxBytesDesc.mustNewConstMetric(prometheus.CounterValue, float64(peer.rxBytes), peerLabelValues...)
+			ch <- c.wireguardTxBytesDesc.mustNewConstMetric(prometheus.CounterValue, float64(peer.txBytes), peerLabelValues...)
+			ch <- c.wireguardAllowedIPsDesc.mustNewConstMetric(prometheus.GaugeValue, float64(peer.allowedIPs), peerLabelValues...)
+			if !peer.lastHandshake.IsZero() {
+				ch <- c.wireguardHandshakeDesc.mustNewConstMetric(
+					prometheus.GaugeValue,
+					now.Sub(peer.lastHandshake).Seconds(),
+					peerLabelValues...,
+				)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+)
+
+func TestParseWireguardMessage(t *testing.T) {
+	key := make([]byte, 32)
+	key[31] = 1
+	peerMessage := func(withStats bool, allowedIPs int) []byte {
+		peers := nl.NewRtAttr(wgDeviceAPeers|int(nl.NLA_F_NESTED), nil)
+		peer := peers.AddRtAttr(0|int(nl.NLA_F_NESTED), nil)
+		peer.AddRtAttr(wgPeerAPublicKey, key)
+		if withStats {
+			ts := make([]byte, sizeofKernelTimespec)
+			binary.NativeEndian.PutUint64(ts[0:8], 1700000000)
+			peer.AddRtAttr(wgPeerALastHandshakeTime, ts)
+			peer.AddRtAttr(wgPeerARxBytes, nl.Uint64Attr(1000))
+			peer.AddRtAttr(wgPeerATxBytes, nl.Uint64Attr(2000))
+		}
+		ips := peer.AddRtAttr(wgPeerAAllowedIPs|int(nl.NLA_F_NESTED), nil)
+		for i := range allowedIPs {
+			ips.AddRtAttr(i|int(nl.NLA_F_NESTED), nil)
+		}
+		genl := &nl.Genlmsg{Command: wgCmdGetDevice, Version: wgGenlVersion}
+		return append(genl.Serialize(), peers.Serialize()...)
+	}
+
+	var peers wgPeers
+	require.NoError(t, parseWireguardMessage(peerMessage(true, 2), &peers))
+	require.NoError(t, parseWireguardMessage(peerMessage(false, 3), &peers))
+	assert.Equal(t, []wgPeer{{
+		publicKey:     "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE=",
+		lastHandshake: time.Unix(1700000000, 0),
+		rxBytes:       1000,
+		txBytes:       2000,
+		allowedIPs:    5,
+	}}, peers.list())
+
+	assert.ErrorIs(t, parseWireguardMessage([]byte{0}, &peers), errTruncatedWireguard)
+}
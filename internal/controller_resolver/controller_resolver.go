@@ -6,31 +6,70 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	kubecache "k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
 	cache "github.com/Code-Hex/go-generics-cache"
-	"github.com/Code-Hex/go-generics-cache/policy/lru"
 )
 
 // ResolverOptions contains configuration options for the Resolver.
 // ParentCacheCapacity is the maximum number of parent controllers to cache (def: 750).
 // PodCacheCapacity is the maximum number of pods to cache (def: 500).
 // Nodename is the name of the node where the resolver is running.
+// CustomOwners is a comma separated list of Kind.group (eg: Rollout.argoproj.io)
+// custom owners looked up through the dynamic client to find their own controller.
+// MaxOwnerDepth is the maximum number of owners walked up from a pod (def: 5).
+// CacheTTL is the lifetime of the cached entries, refreshed every half TTL (0: no expiration).
+// NamespaceInclude is a comma separated list of the only namespaces watched (def: all).
+// NamespaceExclude is a comma separated list of namespaces never watched.
+// Resync is the pod informer resync period, refreshing the cached entries (def: half CacheTTL).
+// Config is the apiserver client configuration (def: $KUBECONFIG, else in-cluster).
+// Client and MetadataClient are the apiserver clients (def: created from Config).
 type ResolverOptions struct {
 	ParentCacheCapacity int
 	PodCacheCapacity    int
 	Nodename            string
+	CustomOwners        string
+	MaxOwnerDepth       int
+	CacheTTL            time.Duration
+	NamespaceInclude    string
+	NamespaceExclude    string
+	Resync              time.Duration
+	Config              *rest.Config
+	Client              kubernetes.Interface
+	MetadataClient      metadata.Interface
 }
 
 const (
 	orphanSentinel = "ORPHAN"
+
+	// Maximum time spent creating a pod event
+	eventTimeout = 5 * time.Second
+
+	// Maximum time spent getting a pod not delivered by the informer yet
+	podLookupTimeout = 5 * time.Second
+	// Minimum time between two background resolutions of a pod
+	podLookupBackoff = time.Minute
 )
 
 // PodControllerResolver is an abstract resolver type that can determine the
@@ -40,11 +79,30 @@ type PodControllerResolver interface {
 	// GetControllerForUid returns the cached controller ref for the Pod with the given UID, if present.
 	GetControllerForUid(uid string) (*PodControllerRef, bool)
 
+	// ResolveControllerForUid returns the cached controller ref for the Pod with the given UID,
+	// else resolves it in the background so that it's cached by the next call.
+	ResolveControllerForUid(uid, namespace, name string) (*PodControllerRef, bool)
+
 	// ResolvePodControllerRef resolves and caches the top-level controller for the given Pod.
 	ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef, error)
 
 	// RemovePodControllerRef removes the cached controller ref for the given Pod.
 	RemovePodControllerRef(pod *corev1.Pod)
+
+	// GetPodLabels returns the Kubernetes labels of the given Pod as seen by the informer, if present.
+	GetPodLabels(namespace, name string) (map[string]string, bool)
+
+	// GetPodAnnotations returns the annotations of the given Pod as seen by the informer, if present.
+	GetPodAnnotations(namespace, name string) (map[string]string, bool)
+
+	// RecordPodWarning asynchronously creates a Warning Event on the given Pod.
+	RecordPodWarning(namespace, name, uid, reason, message string)
+
+	// Stats returns the caches and apiserver lookups counters, if any.
+	Stats() (ResolverStats, bool)
+
+	// Shutdown stops watching the apiserver.
+	Shutdown()
 }
 
 // PodControllerRef is a compact reference to the controlling object of a Pod.
@@ -63,108 +121,291 @@ func getInt(val, def int) int {
 	return val
 }
 
-func checkClientHasPermission(clientset kubernetes.Interface) (bool, []error) {
+// ownerInformerResources are the owners watched by the resolver, the most
+// common intermediate ones, per kind
+var ownerInformerResources = map[schema.GroupKind]schema.GroupVersionResource{
+	{Group: "apps", Kind: "ReplicaSet"}: {Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "batch", Kind: "Job"}:       {Group: "batch", Version: "v1", Resource: "jobs"},
+}
+
+// stripOwnerMetadata only keeps what the owners walk needs of the informers
+// objects, to save memory on the cluster wide owners
+func stripOwnerMetadata(obj interface{}) (interface{}, error) {
+	if partial, ok := obj.(*metav1.PartialObjectMetadata); ok {
+		partial.ManagedFields = nil
+		partial.Annotations = nil
+		partial.Labels = nil
+	}
+	return obj, nil
+}
+
+// parseCustomOwners parses the comma separated Kind.group list of custom owners
+func parseCustomOwners(list string) map[schema.GroupKind]bool {
+	owners := make(map[schema.GroupKind]bool)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			owners[schema.ParseGroupKind(item)] = true
+		}
+	}
+	return owners
+}
+
+// Capabilities of the resolver, the resources it can list. Pods are required,
+// the owners of the others kinds are reported as is.
+const (
+	CapabilityPods        = "pods"
+	CapabilityReplicaSets = "replicasets"
+	CapabilityJobs        = "jobs"
+)
+
+// Capabilities lists all the resolver capabilities.
+var Capabilities = []string{CapabilityPods, CapabilityReplicaSets, CapabilityJobs}
+
+// checkClientCapabilities tells which resources the client can list in the
+// namespaces, with the permission errors of the others
+func checkClientCapabilities(clientset kubernetes.Interface, namespaces []string) (map[string]bool, []error) {
 	ctx := context.TODO()
 	var err error
 	errors := []error{}
-
-	// List Pods in all namespaces
-	_, err = clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{Limit: 1})
-	if err != nil {
-		errors = append(errors, fmt.Errorf("failed to list pods: %w", err))
+	capabilities := map[string]bool{
+		CapabilityPods:        true,
+		CapabilityReplicaSets: true,
+		CapabilityJobs:        true,
 	}
 
-	// List ReplicaSets in all namespaces
-	_, err = clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{Limit: 1})
-	if err != nil {
-		errors = append(errors, fmt.Errorf("failed to list pods: %w", err))
-	}
+	for _, namespace := range namespaces {
+		scope := "all namespaces"
+		if namespace != metav1.NamespaceAll {
+			scope = "namespace " + namespace
+		}
 
-	// List Jobs in all namespaces
-	_, err = clientset.BatchV1().Jobs("").List(ctx, metav1.ListOptions{Limit: 1})
-	if err != nil {
-		errors = append(errors, fmt.Errorf("failed to list pods: %w", err))
+		// List Pods
+		_, err = clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			capabilities[CapabilityPods] = false
+			errors = append(errors, fmt.Errorf("failed to list pods in %s: %w", scope, err))
+		}
+
+		// List ReplicaSets
+		_, err = clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			capabilities[CapabilityReplicaSets] = false
+			errors = append(errors, fmt.Errorf("failed to list replicasets in %s: %w", scope, err))
+		}
+
+		// List Jobs
+		_, err = clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			capabilities[CapabilityJobs] = false
+			errors = append(errors, fmt.Errorf("failed to list jobs in %s: %w", scope, err))
+		}
 	}
 
-	return len(errors) == 0, errors
+	return capabilities, errors
 }
 
 // NewResolver constructs a Resolver that can determine the top-level controller
 // (Deployment/StatefulSet/DaemonSet/CronJob/etc.) managing a Pod. It uses
 // small in-memory LRU caches to reduce API calls to the Kubernetes apiserver.
-func NewResolver(opts *ResolverOptions) PodControllerResolver {
-
-	var config *rest.Config
+func NewResolver(opts *ResolverOptions) (PodControllerResolver, error) {
 	var err error
 
-	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			panic(fmt.Errorf("failed to build config from kubeconfig: %w", err))
-		}
-	} else {
-		config, err = rest.InClusterConfig()
+	// The configuration is only needed by the clients not injected
+	config := opts.Config
+	if config == nil && (opts.Client == nil || opts.MetadataClient == nil || opts.CustomOwners != "") {
+		config, err = defaultConfig()
 		if err != nil {
-			panic(fmt.Errorf("failed to build in-cluster config: %w", err))
+			return nil, err
 		}
 	}
 
 	// Create clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(fmt.Errorf("failed to create clientset: %w", err))
+	clientset := opts.Client
+	if clientset == nil {
+		clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create clientset: %w", err)
+		}
 	}
 
-	// Test client capabilities
-	ok, errs := checkClientHasPermission(clientset)
-	if !ok {
-		for _, err := range errs {
-			slog.Warn("client permission error", slog.Any("error", err))
+	namespaces := newNamespaceScope(opts.NamespaceInclude, opts.NamespaceExclude)
+
+	// Test client capabilities, degrading per kind
+	capabilities, errs := checkClientCapabilities(clientset, namespaces.watched())
+	for _, err := range errs {
+		slog.Warn("client permission error", slog.Any("error", err))
+	}
+	if !capabilities[CapabilityPods] {
+		slog.Error("current resolver won't resolve any controller, please add necessary permissions (list Pods, ReplicaSets, Jobs across all namespaces or in the included ones)")
+		return &noopResolver{capabilities: capabilities}, nil
+	}
+	for _, capability := range Capabilities {
+		if !capabilities[capability] {
+			slog.Warn(
+				"resolver degraded, owners of this resource are reported as is rather than their controller",
+				slog.String("resource", capability),
+			)
 		}
-		slog.Error("current resolver won't resolve any controller, please add necessary permissions (list Pods, ReplicaSets, Jobs across all namespaces)")
-		return &noopResolver{}
 	}
 
 	r := &resolver{
-		client: clientset,
+		client:        clientset,
+		nodename:      opts.Nodename,
+		namespaces:    namespaces,
+		capabilities:  capabilities,
+		stopCh:        make(chan struct{}),
+		resolving:     cache.New[string, struct{}](),
+		informers:     make(map[string]*namespaceInformers),
+		customOwners:  parseCustomOwners(opts.CustomOwners),
+		maxOwnerDepth: getInt(opts.MaxOwnerDepth, 5),
+		cacheTTL:      opts.CacheTTL,
 
 		// 750 seems a reasonable amount to protect the api server without consuming that much RAM
-		parentCache: cache.New(
-			cache.AsLRU[string, *PodControllerRef](lru.WithCapacity(getInt(opts.PodCacheCapacity, 750))),
-		),
+		parentCache: newLRUCache(getInt(opts.ParentCacheCapacity, 750)),
 
 		// 500 is a reasonable pods count per nodes
 		// (according to kube official doc [even if you crank up the quotas])
-		podCache: cache.New(
-			cache.AsLRU[string, *PodControllerRef](lru.WithCapacity(getInt(opts.PodCacheCapacity, 500))),
-		),
+		podCache: newLRUCache(getInt(opts.PodCacheCapacity, 500)),
 	}
 
-	// Create a shared informer factory for all namespaces and the pod informer
-	factory := informers.NewSharedInformerFactory(clientset, 0)
-	podInformer := factory.Core().V1().Pods().Informer()
+	if len(r.customOwners) > 0 {
+		r.dynamic, err = dynamic.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+		// Kinds are mapped to their resource through the discovery API, refreshed
+		// when a kind is unknown (CRD installed after the start)
+		r.mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	}
 
 	// If node name is missing, don't filter on node
-	allNodes := opts.Nodename != ""
+	allNodes := opts.Nodename == ""
 
-	podInformer.AddEventHandler(kubecache.ResourceEventHandlerFuncs{
+	// Metadata only informers of the owners, the parents are resolved from
+	// their listers instead of a GET per owner
+	metadataClient := opts.MetadataClient
+	if metadataClient == nil {
+		metadataClient, err = metadata.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metadata client: %w", err)
+		}
+	}
+
+	// The excluded namespaces are filtered out by the apiserver
+	excluded := namespaces.fieldSelector()
+	// The resync refreshes the cached entries, from the informer's store
+	resync := opts.Resync
+	if resync == 0 {
+		resync = r.cacheTTL / 2
+	}
+	var cacheSyncs []func()
+	// Informers per included namespace, for a namespaced Role to be enough,
+	// else cluster wide
+	for _, namespace := range namespaces.watched() {
+		// Shared informer factory and pod informer, only watching the pods of
+		// the node so that the apiserver does the filtering
+		factory := informers.NewSharedInformerFactoryWithOptions(
+			clientset,
+			resync,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				selectors := []fields.Selector{}
+				if !allNodes {
+					selectors = append(selectors, fields.OneTermEqualSelector("spec.nodeName", opts.Nodename))
+				}
+				if excluded != nil {
+					selectors = append(selectors, excluded)
+				}
+				if len(selectors) > 0 {
+					options.FieldSelector = fields.AndSelectors(selectors...).String()
+				}
+			}),
+		)
+		podInformer := factory.Core().V1().Pods().Informer()
+		podInformer.AddEventHandler(r.podEventHandler(allNodes))
+		nsInformers := &namespaceInformers{
+			podLister:    factory.Core().V1().Pods().Lister(),
+			ownerListers: make(map[schema.GroupKind]kubecache.GenericLister, len(ownerInformerResources)),
+		}
+
+		ownerFactory := metadatainformer.NewFilteredSharedInformerFactory(
+			metadataClient,
+			0,
+			namespace,
+			func(options *metav1.ListOptions) {
+				if excluded != nil {
+					options.FieldSelector = excluded.String()
+				}
+			},
+		)
+		for gk, gvr := range ownerInformerResources {
+			if !capabilities[gvr.Resource] {
+				continue
+			}
+			informer := ownerFactory.ForResource(gvr)
+			if err := informer.Informer().SetTransform(stripOwnerMetadata); err != nil {
+				r.Shutdown()
+				return nil, fmt.Errorf("failed to set the %s informer transform: %w", gvr.Resource, err)
+			}
+			nsInformers.ownerListers[gk] = informer.Lister()
+		}
+		r.informers[namespace] = nsInformers
+
+		factory.Start(r.stopCh)
+		ownerFactory.Start(r.stopCh)
+		r.factories = append(r.factories, factory, ownerFactory)
+		cacheSyncs = append(cacheSyncs, func() {
+			factory.WaitForCacheSync(r.stopCh)
+			ownerFactory.WaitForCacheSync(r.stopCh)
+		})
+	}
+	for _, waitForCacheSync := range cacheSyncs {
+		waitForCacheSync()
+	}
+	slog.Info("Pod controller cache ready.")
+
+	return r, nil
+}
+
+// defaultConfig returns the configuration of $KUBECONFIG, else the in-cluster one
+func defaultConfig() (*rest.Config, error) {
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build config from kubeconfig: %w", err)
+		}
+		return config, nil
+	}
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build in-cluster config: %w", err)
+	}
+	return config, nil
+}
+
+// Shutdown stops the informers and waits for them to terminate.
+func (r *resolver) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	for _, factory := range r.factories {
+		factory.Shutdown()
+	}
+}
+
+// podEventHandler resolves the pods controller from the pod informer events,
+// allNodes when the pods of every node are watched
+func (r *resolver) podEventHandler(allNodes bool) kubecache.ResourceEventHandlerFuncs {
+	return kubecache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			pod := obj.(*corev1.Pod)
 			if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodPending {
 				return
 			}
-			if !allNodes && pod.Spec.NodeName != opts.Nodename || pod.Spec.NodeName == "" {
+			if !allNodes && pod.Spec.NodeName != r.nodename || pod.Spec.NodeName == "" {
 				return
 			}
-			_, err := r.ResolvePodControllerRef(pod)
-			if err != nil {
-				slog.Warn(
-					"issue while resolving pod's controller",
-					slog.String("pod", pod.Name),
-					slog.String("namespace", pod.Namespace),
-					slog.Any("err", err),
-				)
-			}
+			r.resolveOnEvent(pod, false)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			oldPod := oldObj.(*corev1.Pod)
@@ -172,41 +413,124 @@ func NewResolver(opts *ResolverOptions) PodControllerResolver {
 			if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodPending {
 				return
 			}
-			if oldPod.ResourceVersion != pod.ResourceVersion {
-				podHasJustBeenAssigned := oldPod.Spec.NodeName == "" && pod.Spec.NodeName != ""
-				if podHasJustBeenAssigned && (pod.Spec.NodeName == opts.Nodename || allNodes) {
-					_, err := r.ResolvePodControllerRef(pod)
-					if err != nil {
-						slog.Warn(
-							"issue while resolving pod's controller",
-							slog.String("pod", pod.Name),
-							slog.String("namespace", pod.Namespace),
-							slog.Any("err", err),
-						)
-					}
+			if oldPod.ResourceVersion == pod.ResourceVersion {
+				// Periodic resync: refresh the entries before they expire
+				if r.cacheTTL > 0 {
+					r.resolveOnEvent(pod, true)
 				}
+				return
+			}
+			if !equality.Semantic.DeepEqual(oldPod.OwnerReferences, pod.OwnerReferences) {
+				// Adopted or released pod
+				r.resolveOnEvent(pod, true)
+				return
+			}
+			podHasJustBeenAssigned := oldPod.Spec.NodeName == "" && pod.Spec.NodeName != ""
+			if podHasJustBeenAssigned && (pod.Spec.NodeName == r.nodename || allNodes) {
+				r.resolveOnEvent(pod, false)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			pod := obj.(*corev1.Pod)
 			r.RemovePodControllerRef(pod)
 		},
-	})
-
-	stopCh := make(chan struct{})
-	factory.Start(stopCh)
-
-	factory.WaitForCacheSync(stopCh)
-	slog.Info("Pod controller cache ready.")
-
-	return r
+	}
 }
 
 // resolver resolves a Pod's managing controller and caches intermediate results.
 type resolver struct {
 	client      kubernetes.Interface
-	parentCache *cache.Cache[string, *PodControllerRef]
-	podCache    *cache.Cache[string, *PodControllerRef]
+	nodename    string
+	parentCache *lruCache
+	podCache    *lruCache
+	lookups     lookupStats
+	// Resolved namespaces and their informers, per watched namespace
+	namespaces namespaceScope
+	informers  map[string]*namespaceInformers
+	// Listable resources, the owners of the others aren't walked up
+	capabilities  map[string]bool
+	maxOwnerDepth int
+	cacheTTL      time.Duration
+	// Custom owners kinds looked up through the dynamic client
+	customOwners map[schema.GroupKind]bool
+	dynamic      dynamic.Interface
+	mapper       meta.RESTMapper
+	// UIDs of the pods resolved in the background, expiring after the backoff
+	resolving *cache.Cache[string, struct{}]
+	// Informers factories, stopped by Shutdown
+	factories []interface{ Shutdown() }
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// GetPodLabels returns the labels of the Pod from the informer's store, if present.
+func (r *resolver) GetPodLabels(namespace, name string) (map[string]string, bool) {
+	nsInformers, ok := r.informersFor(namespace)
+	if !ok {
+		return nil, false
+	}
+	pod, err := nsInformers.podLister.Pods(namespace).Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return pod.GetLabels(), true
+}
+
+// GetPodAnnotations returns the annotations of the Pod from the informer's store, if present.
+func (r *resolver) GetPodAnnotations(namespace, name string) (map[string]string, bool) {
+	nsInformers, ok := r.informersFor(namespace)
+	if !ok {
+		return nil, false
+	}
+	pod, err := nsInformers.podLister.Pods(namespace).Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return pod.GetAnnotations(), true
+}
+
+// RecordPodWarning creates a Warning Event on the Pod, in the background so the
+// collection isn't slowed down by the apiserver. Failures are only logged.
+func (r *resolver) RecordPodWarning(namespace, name, uid, reason, message string) {
+	// The create permission may only be granted in the resolved namespaces
+	if !r.namespaces.contains(namespace) {
+		return
+	}
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  namespace,
+			Name:       name,
+			UID:        types.UID(uid),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "cosanet", Host: r.nodename},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		_, err := r.client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+		if err != nil {
+			slog.Warn(
+				"failed to create pod event",
+				slog.String("pod", name),
+				slog.String("namespace", namespace),
+				slog.String("reason", reason),
+				slog.Any("err", err),
+			)
+		}
+	}()
 }
 
 // RemovePodControllerRef evicts a cached entry for the given Pod from the pod cache.
@@ -250,6 +574,57 @@ func (r *resolver) GetControllerForUid(uid string) (*PodControllerRef, bool) {
 	return r.podCache.Get(podKey)
 }
 
+// ResolveControllerForUid returns the cached controller ref for the Pod, else
+// resolves it in the background, from the informer's store or from the
+// apiserver when the informer hasn't delivered the pod yet. The caller isn't
+// blocked by the apiserver, the controller being cached by a later call.
+func (r *resolver) ResolveControllerForUid(uid, namespace, name string) (*PodControllerRef, bool) {
+	if ref, ok := r.GetControllerForUid(uid); ok {
+		return ref, true
+	}
+	if uid == "" || name == "" || !r.namespaces.contains(namespace) {
+		return nil, false
+	}
+	// A single resolution per pod and backoff, so that the sandboxes which
+	// aren't pods (or orphans, never cached) aren't looked up on every call
+	if _, resolving := r.resolving.GetOrSet(uid, struct{}{}, cache.WithExpiration(podLookupBackoff)); resolving {
+		return nil, false
+	}
+	go func() {
+		pod, err := r.getPod(namespace, name)
+		if err != nil {
+			slog.Debug(
+				"failed to get the pod to resolve its controller",
+				slog.String("pod", name),
+				slog.String("namespace", namespace),
+				slog.Any("err", err),
+			)
+			return
+		}
+		// Pod recreated with the same name
+		if string(pod.GetUID()) != uid {
+			return
+		}
+		r.resolveOnEvent(pod, false)
+	}()
+	return nil, false
+}
+
+// getPod gets the Pod from the informer's store, else from the apiserver
+func (r *resolver) getPod(namespace, name string) (*corev1.Pod, error) {
+	if nsInformers, ok := r.informersFor(namespace); ok {
+		if pod, err := nsInformers.podLister.Pods(namespace).Get(name); err == nil {
+			return pod, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), podLookupTimeout)
+	defer cancel()
+	start := time.Now()
+	pod, err := r.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	r.lookups.observe("Pod", time.Since(start))
+	return pod, err
+}
+
 // GetCachedPodControllerRef returns the cached controller ref for the Pod, if present.
 // Return object (PodControllerRef) and if found (bool)
 func (r *resolver) ControllerForPod(pod *corev1.Pod) (*PodControllerRef, bool) {
@@ -272,8 +647,36 @@ func (r *resolver) ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef,
 		slog.Debug("pod cache hit", slog.String("key", podKey))
 		return cached, nil
 	}
+	return r.resolvePodControllerRef(pod)
+}
+
+// resolveOnEvent resolves the Pod's controller from an informer event, also
+// replacing its cached entry when refresh is set. Errors are only logged.
+func (r *resolver) resolveOnEvent(pod *corev1.Pod, refresh bool) {
+	var err error
+	if refresh {
+		_, err = r.resolvePodControllerRef(pod)
+	} else {
+		_, err = r.ResolvePodControllerRef(pod)
+	}
+	if err != nil {
+		slog.Warn(
+			"issue while resolving pod's controller",
+			slog.String("pod", pod.Name),
+			slog.String("namespace", pod.Namespace),
+			slog.Any("err", err),
+		)
+	}
+}
 
+// resolvePodControllerRef resolves the top-level controller for the Pod,
+// regardless of its cached entry, and caches it.
+func (r *resolver) resolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef, error) {
+	podKey := generatePodCacheKey(pod)
 	namespace := pod.GetNamespace()
+	if !r.namespaces.contains(namespace) {
+		return nil, fmt.Errorf("namespace %s isn't resolved", namespace)
+	}
 	orefs := pod.GetOwnerReferences()
 	var res *PodControllerRef
 	var err error
@@ -285,7 +688,9 @@ func (r *resolver) ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef,
 			slog.String("namespace", pod.GetNamespace()),
 			slog.String("reason", "no owner references found"),
 		)
-		// Don't cache orphan pods, *could* be adopted later on
+		// Don't cache orphan pods, *could* be adopted later on, which is
+		// resolved on the pod update
+		r.podCache.Delete(podKey)
 		return &PodControllerRef{
 			UID:        orphanSentinel,
 			APIVersion: orphanSentinel,
@@ -295,24 +700,9 @@ func (r *resolver) ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef,
 		}, nil
 	}
 
-	// Check cache
-	if res, found := r.podCache.Get(podKey); found {
-		return res, nil
-	}
-
 	ownerRef := getControllerOwnerReference(orefs)
 
 	switch ownerRef.Kind {
-	case "ReplicaSet", "Job":
-		res, err = r.getParentDetail(namespace, ownerRef)
-	case "StatefulSet", "DaemonSet", "Deployment", "CronJob":
-		res = &PodControllerRef{
-			UID:        string(ownerRef.UID),
-			APIVersion: ownerRef.APIVersion,
-			Kind:       ownerRef.Kind,
-			Namespace:  namespace,
-			Name:       ownerRef.Name,
-		}
 	case "Node":
 		res = &PodControllerRef{
 			UID:        string(ownerRef.UID),
@@ -328,10 +718,19 @@ func (r *resolver) ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerRef,
 	if err != nil {
 		return nil, err
 	}
-	r.podCache.Set(podKey, res)
+	r.podCache.Set(podKey, res, r.cacheItemOptions()...)
 	return res, nil
 }
 
+// cacheItemOptions returns the options of the cached entries, expiring after
+// the cache TTL if any
+func (r *resolver) cacheItemOptions() []cache.ItemOption {
+	if r.cacheTTL <= 0 {
+		return nil
+	}
+	return []cache.ItemOption{cache.WithExpiration(r.cacheTTL)}
+}
+
 func getControllerOwnerReference(orefs []metav1.OwnerReference) metav1.OwnerReference {
 	for _, ref := range orefs {
 		if ref.Controller != nil && *ref.Controller {
@@ -341,10 +740,9 @@ func getControllerOwnerReference(orefs []metav1.OwnerReference) metav1.OwnerRefe
 	return orefs[0]
 }
 
+// getParentDetail returns the top-level controller of the pod's owner, walking
+// up the controlling owners up to maxOwnerDepth levels above the pod.
 func (r *resolver) getParentDetail(namespace string, ownerRef metav1.OwnerReference) (*PodControllerRef, error) {
-	var err error
-	var obj metav1.Object
-
 	cacheKey := generateCacheKey(namespace, ownerRef)
 	if cached, ok := r.parentCache.Get(cacheKey); ok {
 		slog.Debug("parent cache hit", slog.String("key", cacheKey))
@@ -358,51 +756,126 @@ func (r *resolver) getParentDetail(namespace string, ownerRef metav1.OwnerRefere
 		slog.String("name", ownerRef.Name),
 	)
 	ctx := context.TODO()
-	switch ownerRef.Kind {
-	case "ReplicaSet":
-		// Seek for the underlying deployment
-		obj, err = r.client.AppsV1().ReplicaSets(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
-	case "Job":
-		// Seek for the possible CronJob
-		obj, err = r.client.BatchV1().Jobs(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
-	default:
-		// Directly return the ownerRef as top-level
-		res := &PodControllerRef{
-			UID:        string(ownerRef.UID),
-			APIVersion: ownerRef.APIVersion,
-			Kind:       ownerRef.Kind,
-			Namespace:  namespace,
-			Name:       ownerRef.Name,
+	current := ownerRef
+	visited := map[types.UID]bool{}
+	// The pod's owner is the first level
+	for depth := 1; depth < r.maxOwnerDepth; depth++ {
+		if visited[current.UID] {
+			slog.Warn(
+				"owner references cycle, stopping at the current owner",
+				slog.String("kind", current.Kind),
+				slog.String("name", current.Name),
+				slog.String("namespace", namespace),
+			)
+			break
 		}
-		r.parentCache.Set(cacheKey, res)
-		return res, nil
+		visited[current.UID] = true
+
+		obj, err := r.getOwner(ctx, namespace, current)
+		if err != nil {
+			// Kinds beyond ReplicaSets and Jobs may be forbidden with the
+			// minimal permissions, other errors are retried on the next event
+			if apierrors.IsForbidden(err) {
+				slog.Debug(
+					"owner lookup forbidden, stopping at the current owner",
+					slog.String("kind", current.Kind),
+					slog.String("name", current.Name),
+					slog.Any("err", err),
+				)
+				break
+			}
+			return nil, err
+		}
+		if obj == nil || len(obj.GetOwnerReferences()) == 0 {
+			break
+		}
+		current = getControllerOwnerReference(obj.GetOwnerReferences())
 	}
 
-	if err != nil {
-		return nil, err
+	result := &PodControllerRef{
+		UID:        string(current.UID),
+		APIVersion: current.APIVersion,
+		Kind:       current.Kind,
+		Namespace:  namespace,
+		Name:       current.Name,
 	}
+	r.parentCache.Set(cacheKey, result, r.cacheItemOptions()...)
 
-	parent := obj.GetOwnerReferences()
-	var result *PodControllerRef
-	if len(parent) == 0 {
-		result = &PodControllerRef{
-			UID:        string(ownerRef.UID),
-			APIVersion: ownerRef.APIVersion,
-			Kind:       ownerRef.Kind,
-			Namespace:  namespace,
-			Name:       ownerRef.Name,
-		}
-	} else {
-		controlling := getControllerOwnerReference(parent)
-		result = &PodControllerRef{
-			UID:        string(controlling.UID),
-			APIVersion: controlling.APIVersion,
-			Kind:       controlling.Kind,
-			Namespace:  namespace,
-			Name:       controlling.Name,
+	return result, nil
+}
+
+// getOwner gets the owner object to find its own owners, from the informers
+// for ReplicaSets and Jobs, nil for the kinds that can't be looked up or
+// listed
+func (r *resolver) getOwner(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (metav1.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind)
+	if gvr, ok := ownerInformerResources[gvk.GroupKind()]; ok && !r.capabilities[gvr.Resource] {
+		// Unresolved without the list permission, reported as is
+		return nil, nil
+	}
+	if nsInformers, ok := r.informersFor(namespace); ok {
+		if lister, ok := nsInformers.ownerListers[gvk.GroupKind()]; ok {
+			obj, err := lister.ByNamespace(namespace).Get(ownerRef.Name)
+			if err == nil {
+				return obj.(metav1.Object), nil
+			}
+			// Owners created along with the pod may not be seen by the informer yet
+			slog.Debug(
+				"owner not found in the informer, getting it from the apiserver",
+				slog.String("kind", ownerRef.Kind),
+				slog.String("name", ownerRef.Name),
+				slog.String("namespace", namespace),
+			)
 		}
 	}
-	r.parentCache.Set(cacheKey, result)
 
-	return result, nil
+	start := time.Now()
+	obj, err := r.getOwnerFromAPI(ctx, namespace, ownerRef)
+	if obj != nil || err != nil {
+		r.lookups.observe(ownerRef.Kind, time.Since(start))
+	}
+	return obj, err
+}
+
+// getOwnerFromAPI gets the owner object from the apiserver, nil for the kinds
+// that can't be looked up
+func (r *resolver) getOwnerFromAPI(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (metav1.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind)
+	switch gvk.GroupKind() {
+	case schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}:
+		return r.client.AppsV1().ReplicaSets(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}:
+		return r.client.AppsV1().Deployments(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		return r.client.AppsV1().StatefulSets(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	case schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return r.client.AppsV1().DaemonSets(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return r.client.BatchV1().Jobs(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	case schema.GroupKind{Group: "batch", Kind: "CronJob"}:
+		return r.client.BatchV1().CronJobs(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	}
+	if r.isCustomOwner(ownerRef) {
+		return r.getCustomOwner(ctx, namespace, ownerRef)
+	}
+	return nil, nil
+}
+
+// isCustomOwner tells if the owner is one of the allowed custom owners
+func (r *resolver) isCustomOwner(ownerRef metav1.OwnerReference) bool {
+	gvk := schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind)
+	return r.customOwners[gvk.GroupKind()]
+}
+
+// getCustomOwner gets a custom owner through the dynamic client
+func (r *resolver) getCustomOwner(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (metav1.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind)
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return r.dynamic.Resource(mapping.Resource).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+	}
+	return r.dynamic.Resource(mapping.Resource).Namespace(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
 }
@@ -0,0 +1,238 @@
+package controller_resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func controllerRef(apiVersion, kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(kind + "-" + name),
+		Controller: &controller,
+	}}
+}
+
+func newPod(namespace, name string, owners []metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			UID:             types.UID("pod-" + name),
+			OwnerReferences: owners,
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func newOwnerMetadata(apiVersion, kind, namespace, name string, owners []metav1.OwnerReference) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			UID:             types.UID(kind + "-" + name),
+			OwnerReferences: owners,
+			Labels:          map[string]string{"app": name},
+		},
+	}
+}
+
+func newDeployment(namespace, name string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID("Deployment-" + name)},
+	}
+}
+
+// newTestResolver starts a resolver on the fake clients, the owners only
+// being known by the metadata informers
+func newTestResolver(t *testing.T, opts ResolverOptions, client *fake.Clientset, owners ...runtime.Object) PodControllerResolver {
+	scheme := metadatafake.NewTestScheme()
+	require.NoError(t, metav1.AddMetaToScheme(scheme))
+	opts.Nodename = "node-1"
+	opts.Client = client
+	opts.MetadataClient = metadatafake.NewSimpleMetadataClient(scheme, owners...)
+	r, err := NewResolver(&opts)
+	require.NoError(t, err)
+	t.Cleanup(r.Shutdown)
+	return r
+}
+
+func TestResolveFromOwnerInformers(t *testing.T) {
+	pod := newPod("default", "web-1", controllerRef("apps/v1", "ReplicaSet", "web-abc"))
+	r := newTestResolver(t, ResolverOptions{}, fake.NewSimpleClientset(pod, newDeployment("default", "web")),
+		newOwnerMetadata("apps/v1", "ReplicaSet", "default", "web-abc", controllerRef("apps/v1", "Deployment", "web")),
+	)
+
+	// Resolved from the pod informer event
+	require.Eventually(t, func() bool {
+		_, ok := r.GetControllerForUid("pod-web-1")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	ref, err := r.ResolvePodControllerRef(pod)
+	require.NoError(t, err)
+	assert.Equal(t, &PodControllerRef{
+		UID:        "Deployment-web",
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Namespace:  "default",
+		Name:       "web",
+	}, ref)
+
+	// The ReplicaSet comes from the lister, only the Deployment is fetched
+	stats, ok := r.Stats()
+	require.True(t, ok)
+	assert.NotContains(t, stats.Lookups, "ReplicaSet")
+	assert.Contains(t, stats.Lookups, "Deployment")
+	assert.Equal(t, map[string]bool{CapabilityPods: true, CapabilityReplicaSets: true, CapabilityJobs: true}, stats.Capabilities)
+}
+
+func TestResolveControllerForUid(t *testing.T) {
+	pod := newPod("default", "web-1", controllerRef("apps/v1", "ReplicaSet", "web-abc"))
+	client := fake.NewSimpleClientset(pod, newDeployment("default", "web"))
+	// The informer doesn't deliver the pod
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.PodList{}, nil
+	})
+	r := newTestResolver(t, ResolverOptions{}, client,
+		newOwnerMetadata("apps/v1", "ReplicaSet", "default", "web-abc", controllerRef("apps/v1", "Deployment", "web")),
+	)
+
+	// Resolved in the background from the apiserver
+	_, ok := r.ResolveControllerForUid("pod-web-1", "default", "web-1")
+	assert.False(t, ok)
+	require.Eventually(t, func() bool {
+		ref, ok := r.ResolveControllerForUid("pod-web-1", "default", "web-1")
+		return ok && ref.Kind == "Deployment"
+	}, 5*time.Second, 10*time.Millisecond)
+	stats, _ := r.Stats()
+	assert.Contains(t, stats.Lookups, "Pod")
+
+	// Sandboxes which aren't pods are only looked up once per backoff
+	countGets := func() int {
+		gets := 0
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "get" && action.GetResource().Resource == "pods" {
+				gets++
+			}
+		}
+		return gets
+	}
+	before := countGets()
+	_, ok = r.ResolveControllerForUid("0123456789ab", "default", "nerdctl-web")
+	assert.False(t, ok)
+	require.Eventually(t, func() bool { return countGets() == before+1 }, 5*time.Second, 10*time.Millisecond)
+	_, ok = r.ResolveControllerForUid("0123456789ab", "default", "nerdctl-web")
+	assert.False(t, ok)
+	assert.Equal(t, before+1, countGets())
+}
+
+func TestResolveOrphanPod(t *testing.T) {
+	pod := newPod("default", "debug", nil)
+	r := newTestResolver(t, ResolverOptions{}, fake.NewSimpleClientset())
+
+	ref, err := r.ResolvePodControllerRef(pod)
+	require.NoError(t, err)
+	assert.Equal(t, orphanSentinel, ref.Kind)
+	_, ok := r.GetControllerForUid("pod-debug")
+	assert.False(t, ok)
+}
+
+func TestResolverDegradesWithoutJobs(t *testing.T) {
+	client := fake.NewSimpleClientset(newDeployment("default", "web"))
+	client.PrependReactor("list", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "jobs"}, "", nil)
+	})
+	r := newTestResolver(t, ResolverOptions{}, client,
+		newOwnerMetadata("apps/v1", "ReplicaSet", "default", "web-abc", controllerRef("apps/v1", "Deployment", "web")),
+	)
+
+	// Deployments are still resolved through their ReplicaSet
+	ref, err := r.ResolvePodControllerRef(newPod("default", "web-1", controllerRef("apps/v1", "ReplicaSet", "web-abc")))
+	require.NoError(t, err)
+	assert.Equal(t, "Deployment", ref.Kind)
+
+	// Jobs are reported as is
+	ref, err = r.ResolvePodControllerRef(newPod("default", "backup-1", controllerRef("batch/v1", "Job", "backup-28000000")))
+	require.NoError(t, err)
+	assert.Equal(t, "Job", ref.Kind)
+	assert.Equal(t, "backup-28000000", ref.Name)
+
+	stats, ok := r.Stats()
+	require.True(t, ok)
+	assert.False(t, stats.Capabilities[CapabilityJobs])
+	assert.True(t, stats.Capabilities[CapabilityReplicaSets])
+}
+
+func TestResolverWithoutPodsPermission(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+	})
+	r := newTestResolver(t, ResolverOptions{}, client)
+
+	_, err := r.ResolvePodControllerRef(newPod("default", "web-1", nil))
+	assert.Error(t, err)
+	stats, ok := r.Stats()
+	require.True(t, ok)
+	assert.False(t, stats.Capabilities[CapabilityPods])
+}
+
+func TestResolverNamespaceScope(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r := newTestResolver(t, ResolverOptions{NamespaceInclude: "team-a, team-b", NamespaceExclude: "team-b"}, client)
+
+	_, err := r.ResolvePodControllerRef(newPod("team-a", "web-1", nil))
+	assert.NoError(t, err)
+	_, err = r.ResolvePodControllerRef(newPod("team-b", "web-1", nil))
+	assert.Error(t, err)
+	_, err = r.ResolvePodControllerRef(newPod("default", "web-1", nil))
+	assert.Error(t, err)
+
+	// The permissions are only checked in the included namespace
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" {
+			assert.Equal(t, "team-a", action.GetNamespace())
+		}
+	}
+}
+
+func TestNamespaceScope(t *testing.T) {
+	scope := newNamespaceScope("", "kube-system")
+	assert.True(t, scope.cluster())
+	assert.Equal(t, []string{metav1.NamespaceAll}, scope.watched())
+	assert.False(t, scope.contains("kube-system"))
+	assert.True(t, scope.contains("default"))
+	assert.Equal(t, "metadata.namespace!=kube-system", scope.fieldSelector().String())
+
+	scope = newNamespaceScope("team-a,team-b", "team-b")
+	assert.False(t, scope.cluster())
+	assert.Equal(t, []string{"team-a"}, scope.watched())
+	assert.True(t, scope.contains("team-a"))
+	assert.False(t, scope.contains("default"))
+	assert.Nil(t, scope.fieldSelector())
+}
+
+func TestResolverShutdown(t *testing.T) {
+	r := newTestResolver(t, ResolverOptions{Resync: time.Minute}, fake.NewSimpleClientset())
+	// Shutdown is idempotent, also called by the test cleanup
+	r.Shutdown()
+	r.Shutdown()
+}
@@ -0,0 +1,76 @@
+package controller_resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// endpointsLookupTimeout bounds a lookup of the service endpoints
+const endpointsLookupTimeout = 5 * time.Second
+
+// Endpoint is a ready address of a service, host:port
+type Endpoint struct {
+	Address  string
+	NodeName string
+}
+
+// EndpointsLister lists the ready endpoints of a service from the Endpoints
+// API, e.g. the pods of the cosanet DaemonSet behind a headless service
+type EndpointsLister struct {
+	client    kubernetes.Interface
+	namespace string
+	service   string
+	// Name of the port, empty for the first one
+	port string
+}
+
+// NewEndpointsLister lists the endpoints of the port of namespace/service,
+// through the configuration of $KUBECONFIG else the in-cluster one
+func NewEndpointsLister(namespace, service, port string) (*EndpointsLister, error) {
+	config, err := defaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return &EndpointsLister{client: clientset, namespace: namespace, service: service, port: port}, nil
+}
+
+// List returns the ready endpoints of the service
+func (l *EndpointsLister) List() ([]Endpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), endpointsLookupTimeout)
+	defer cancel()
+	endpoints, err := l.client.CoreV1().Endpoints(l.namespace).Get(ctx, l.service, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints %s/%s: %w", l.namespace, l.service, err)
+	}
+	var list []Endpoint
+	for _, subset := range endpoints.Subsets {
+		port := -1
+		for _, p := range subset.Ports {
+			if l.port == "" || p.Name == l.port {
+				port = int(p.Port)
+				break
+			}
+		}
+		if port < 0 {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			endpoint := Endpoint{Address: net.JoinHostPort(address.IP, strconv.Itoa(port))}
+			if address.NodeName != nil {
+				endpoint.NodeName = *address.NodeName
+			}
+			list = append(list, endpoint)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,56 @@
+package controller_resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEndpointsLister(t *testing.T) {
+	node1, node2 := "node-1", "node-2"
+	client := fake.NewSimpleClientset(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "cosanet", Namespace: "monitoring"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.1", NodeName: &node1},
+					{IP: "fd00::2", NodeName: &node2},
+				},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.3"}},
+				Ports: []corev1.EndpointPort{
+					{Name: "grpc", Port: 9157},
+					{Name: "metrics", Port: 9156},
+				},
+			},
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.4"}},
+				Ports:     []corev1.EndpointPort{{Name: "other", Port: 8080}},
+			},
+		},
+	})
+
+	lister := &EndpointsLister{client: client, namespace: "monitoring", service: "cosanet", port: "metrics"}
+	endpoints, err := lister.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Address: "10.0.0.1:9156", NodeName: "node-1"},
+		{Address: "[fd00::2]:9156", NodeName: "node-2"},
+	}, endpoints)
+
+	lister.port = ""
+	endpoints, err = lister.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Address: "10.0.0.1:9157", NodeName: "node-1"},
+		{Address: "[fd00::2]:9157", NodeName: "node-2"},
+		{Address: "10.0.0.4:8080"},
+	}, endpoints)
+
+	lister.service = "missing"
+	_, err = lister.List()
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package controller_resolver
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	kubecache "k8s.io/client-go/tools/cache"
+)
+
+// namespaceScope is the allow/deny list of the namespaces watched and looked
+// up by the resolver
+type namespaceScope struct {
+	include []string
+	exclude map[string]bool
+}
+
+func newNamespaceScope(include, exclude string) namespaceScope {
+	scope := namespaceScope{exclude: make(map[string]bool)}
+	for _, namespace := range splitNamespaces(exclude) {
+		scope.exclude[namespace] = true
+	}
+	for _, namespace := range splitNamespaces(include) {
+		if !scope.exclude[namespace] {
+			scope.include = append(scope.include, namespace)
+		}
+	}
+	return scope
+}
+
+func splitNamespaces(list string) []string {
+	var namespaces []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			namespaces = append(namespaces, item)
+		}
+	}
+	return namespaces
+}
+
+// cluster tells if the resolver watches all the namespaces, else it needs
+// the permissions on the included ones only
+func (s namespaceScope) cluster() bool {
+	return len(s.include) == 0
+}
+
+// watched returns the namespaces of the informers, NamespaceAll when no
+// namespace is included
+func (s namespaceScope) watched() []string {
+	if s.cluster() {
+		return []string{metav1.NamespaceAll}
+	}
+	return s.include
+}
+
+// contains tells if the namespace is resolved
+func (s namespaceScope) contains(namespace string) bool {
+	if s.exclude[namespace] {
+		return false
+	}
+	if s.cluster() {
+		return true
+	}
+	for _, included := range s.include {
+		if included == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldSelector returns the selector of the cluster wide informers leaving
+// the excluded namespaces to the apiserver, nil without any
+func (s namespaceScope) fieldSelector() fields.Selector {
+	if !s.cluster() || len(s.exclude) == 0 {
+		return nil
+	}
+	selectors := make([]fields.Selector, 0, len(s.exclude))
+	for namespace := range s.exclude {
+		selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", namespace))
+	}
+	return fields.AndSelectors(selectors...)
+}
+
+// namespaceInformers are the listers of a watched namespace, NamespaceAll
+// for the cluster wide informers
+type namespaceInformers struct {
+	podLister corelisters.PodLister
+	// Listers of the owners, per kind
+	ownerListers map[schema.GroupKind]kubecache.GenericLister
+}
+
+// informersFor returns the listers watching the namespace, false when it
+// isn't resolved
+func (r *resolver) informersFor(namespace string) (*namespaceInformers, bool) {
+	if !r.namespaces.contains(namespace) {
+		return nil, false
+	}
+	if r.namespaces.cluster() {
+		namespace = metav1.NamespaceAll
+	}
+	nsInformers, ok := r.informers[namespace]
+	return nsInformers, ok
+}
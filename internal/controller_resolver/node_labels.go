@@ -0,0 +1,42 @@
+package controller_resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeLookupTimeout bounds the lookup of the node labels at startup
+const nodeLookupTimeout = 10 * time.Second
+
+// FetchNodeLabels returns the given labels of the node, through the
+// configuration of $KUBECONFIG else the in-cluster one. The labels the node
+// doesn't have are empty, for every node to expose the same label names.
+func FetchNodeLabels(nodename string, keys []string) (map[string]string, error) {
+	config, err := defaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return nodeLabels(clientset, nodename, keys)
+}
+
+func nodeLabels(client kubernetes.Interface, nodename string, keys []string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), nodeLookupTimeout)
+	defer cancel()
+	node, err := client.CoreV1().Nodes().Get(ctx, nodename, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", nodename, err)
+	}
+	labels := make(map[string]string, len(keys))
+	for _, key := range keys {
+		labels[key] = node.Labels[key]
+	}
+	return labels, nil
+}
@@ -0,0 +1,32 @@
+package controller_resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeLabels(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Labels: map[string]string{
+				"topology.kubernetes.io/zone":      "eu-west-1a",
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+		},
+	})
+	labels, err := nodeLabels(client, "node-1", []string{"topology.kubernetes.io/zone", "cloud.google.com/gke-nodepool"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"topology.kubernetes.io/zone":   "eu-west-1a",
+		"cloud.google.com/gke-nodepool": "",
+	}, labels)
+
+	_, err = nodeLabels(client, "node-2", []string{"topology.kubernetes.io/zone"})
+	assert.Error(t, err)
+}
@@ -7,12 +7,24 @@ import (
 )
 
 type noopResolver struct {
+	// Capabilities found missing by NewResolver, nil when disabled
+	capabilities map[string]bool
+}
+
+// NewNoopResolver returns a resolver which never resolves anything, for when
+// controller resolution is disabled.
+func NewNoopResolver() PodControllerResolver {
+	return &noopResolver{}
 }
 
 func (n *noopResolver) GetControllerForUid(uid string) (*PodControllerRef, bool) {
 	return nil, false
 }
 
+func (n *noopResolver) ResolveControllerForUid(uid, namespace, name string) (*PodControllerRef, bool) {
+	return nil, false
+}
+
 func (n *noopResolver) ControllerForPod(pod *corev1.Pod) (*PodControllerRef, bool) {
 	return nil, false
 }
@@ -24,3 +36,26 @@ func (n *noopResolver) ResolvePodControllerRef(pod *corev1.Pod) (*PodControllerR
 func (n *noopResolver) RemovePodControllerRef(pod *corev1.Pod) {
 	// noop: nothing to remove from cache
 }
+
+func (n *noopResolver) GetPodLabels(namespace, name string) (map[string]string, bool) {
+	return nil, false
+}
+
+func (n *noopResolver) GetPodAnnotations(namespace, name string) (map[string]string, bool) {
+	return nil, false
+}
+
+func (n *noopResolver) RecordPodWarning(namespace, name, uid, reason, message string) {
+	// noop: no Kubernetes client to create events with
+}
+
+func (n *noopResolver) Stats() (ResolverStats, bool) {
+	if n.capabilities == nil {
+		return ResolverStats{}, false
+	}
+	return ResolverStats{Capabilities: n.capabilities}, true
+}
+
+func (n *noopResolver) Shutdown() {
+	// noop: no informer to stop
+}
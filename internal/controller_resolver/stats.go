@@ -0,0 +1,138 @@
+package controller_resolver
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/Code-Hex/go-generics-cache"
+	"github.com/Code-Hex/go-generics-cache/policy/lru"
+)
+
+// LookupBuckets are the upper bounds of the apiserver lookups latency histogram
+var LookupBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// ResolverStats is a snapshot of the resolver caches and apiserver lookups.
+type ResolverStats struct {
+	// Caches per name: pod and parent
+	Caches map[string]CacheStats
+	// Lookups per owner kind
+	Lookups map[string]LookupStats
+	// Capabilities, the resources listed by the resolver
+	Capabilities map[string]bool
+}
+
+// CacheStats are the counters of a resolver cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Capacity  int
+}
+
+// LookupStats is the latency histogram of the apiserver lookups of a kind.
+type LookupStats struct {
+	Count   uint64
+	Sum     float64
+	Buckets map[float64]uint64
+}
+
+// lruCache is an LRU cache of controller refs counting its hits, misses and
+// evictions
+type lruCache struct {
+	*cache.Cache[string, *PodControllerRef]
+	capacity  int
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		Cache:    cache.New(cache.AsLRU[string, *PodControllerRef](lru.WithCapacity(capacity))),
+		capacity: capacity,
+	}
+}
+
+func (c *lruCache) Get(key string) (*PodControllerRef, bool) {
+	value, ok := c.Cache.Get(key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return value, ok
+}
+
+func (c *lruCache) Set(key string, value *PodControllerRef, opts ...cache.ItemOption) {
+	// A new key in a full cache evicts the least recently used one
+	if !c.Cache.Contains(key) && c.Cache.Len() >= c.capacity {
+		c.evictions.Add(1)
+	}
+	c.Cache.Set(key, value, opts...)
+}
+
+func (c *lruCache) stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   c.Cache.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
+// lookupStats accumulates the latency of the apiserver lookups per kind
+type lookupStats struct {
+	mu    sync.Mutex
+	kinds map[string]*LookupStats
+}
+
+func (s *lookupStats) observe(kind string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kinds == nil {
+		s.kinds = make(map[string]*LookupStats)
+	}
+	stats, ok := s.kinds[kind]
+	if !ok {
+		stats = &LookupStats{Buckets: make(map[float64]uint64, len(LookupBuckets))}
+		s.kinds[kind] = stats
+	}
+	seconds := duration.Seconds()
+	stats.Count++
+	stats.Sum += seconds
+	for _, bound := range LookupBuckets {
+		if seconds <= bound {
+			stats.Buckets[bound]++
+		}
+	}
+}
+
+func (s *lookupStats) snapshot() map[string]LookupStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]LookupStats, len(s.kinds))
+	for kind, stats := range s.kinds {
+		snapshot[kind] = LookupStats{
+			Count:   stats.Count,
+			Sum:     stats.Sum,
+			Buckets: maps.Clone(stats.Buckets),
+		}
+	}
+	return snapshot
+}
+
+// Stats returns the current counters of the caches and apiserver lookups.
+func (r *resolver) Stats() (ResolverStats, bool) {
+	return ResolverStats{
+		Caches: map[string]CacheStats{
+			"pod":    r.podCache.stats(),
+			"parent": r.parentCache.stats(),
+		},
+		Lookups:      r.lookups.snapshot(),
+		Capabilities: r.capabilities,
+	}, true
+}
@@ -0,0 +1,262 @@
+// Package dns_queries counts the DNS queries sent and the responses received
+// over UDP per network namespace and response code, from eBPF programs on
+// kprobes of the kernel IP send and UDP receive functions.
+package dns_queries
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cosanet/cosanet/internal/bpf_counters"
+)
+
+// Query is the kind counting the queries, the responses being counted by
+// response code (0 to 15)
+const Query = 16
+
+const (
+	dnsPort  = 53
+	udpProto = 17
+	// Size of the UDP and DNS headers
+	udpHeaderSize = 8
+	dnsHeaderSize = 12
+)
+
+// Names of the response codes of RFC 1035 and RFC 2136
+var rcodeNames = []string{
+	"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED",
+	"YXDOMAIN", "YXRRSET", "NXRRSET", "NOTAUTH", "NOTZONE",
+}
+
+// RcodeName returns the name of the response code, its number when unassigned
+func RcodeName(rcode uint32) string {
+	if int(rcode) < len(rcodeNames) {
+		return rcodeNames[rcode]
+	}
+	return strconv.FormatUint(uint64(rcode), 10)
+}
+
+// kernelOffsets are the offsets of the struct members the programs read, from
+// the kernel BTF
+type kernelOffsets struct {
+	// sk_buff.head, the network and transport headers offsets from head,
+	// and sk_buff.sk
+	skbHead            int32
+	skbNetworkHeader   int32
+	skbTransportHeader int32
+	skbSk              int32
+	// sock.__sk_common.skc_net.net
+	skNet int32
+	// net.ns.inum, the inode of the netns
+	netInum int32
+	// Offsets of the first two arguments in pt_regs
+	args []int16
+}
+
+func loadKernelOffsets(spec *btf.Spec) (kernelOffsets, error) {
+	var offsets kernelOffsets
+	err := bpf_counters.MemberOffsets(spec,
+		bpf_counters.Member{Offset: &offsets.skbHead, Type: "sk_buff", Path: []string{"head"}},
+		bpf_counters.Member{Offset: &offsets.skbNetworkHeader, Type: "sk_buff", Path: []string{"network_header"}},
+		bpf_counters.Member{Offset: &offsets.skbTransportHeader, Type: "sk_buff", Path: []string{"transport_header"}},
+		bpf_counters.Member{Offset: &offsets.skbSk, Type: "sk_buff", Path: []string{"sk"}},
+		bpf_counters.Member{Offset: &offsets.skNet, Type: "sock", Path: []string{"__sk_common", "skc_net", "net"}},
+		bpf_counters.Member{Offset: &offsets.netInum, Type: "net", Path: []string{"ns", "inum"}},
+	)
+	if err != nil {
+		return kernelOffsets{}, err
+	}
+	offsets.args, err = bpf_counters.KprobeArgs(spec, 2)
+	return offsets, err
+}
+
+// Tracer counts the DNS queries and responses per netns inode and kind (Query
+// or the response code) until closed.
+type Tracer struct {
+	*bpf_counters.Map
+	progs []*ebpf.Program
+	links []link.Link
+}
+
+// Start loads the programs and attaches them, which requires the kernel 5.5,
+// BTF, kprobes and CAP_BPF and CAP_PERFMON (or CAP_SYS_ADMIN).
+func Start() (*Tracer, error) {
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, fmt.Errorf("kernel BTF unavailable: %w", err)
+	}
+	offsets, err := loadKernelOffsets(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tracer{}
+	if t.Map, err = bpf_counters.NewMap("cosanet_dns"); err != nil {
+		return nil, err
+	}
+	for _, program := range []struct {
+		function string
+		insns    asm.Instructions
+	}{
+		// The queries once their UDP header is built, whatever the socket
+		// family: udpv6_sendmsg gives the IPv4-mapped destinations to
+		// udp_sendmsg
+		{"ip_send_skb", queryProgram(offsets, false, t.FD())},
+		{"ip6_send_skb", queryProgram(offsets, true, t.FD())},
+		// The responses once queued to their socket
+		{"__udp_enqueue_schedule_skb", responseProgram(offsets, t.FD())},
+	} {
+		if err := t.attach(program.function, program.insns); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Tracer) attach(function string, insns asm.Instructions) error {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         "cosanet_dns",
+		Type:         ebpf.Kprobe,
+		Instructions: insns,
+		// bpf_probe_read_kernel is GPL only
+		License: "GPL",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load the %s program: %w", function, err)
+	}
+	t.progs = append(t.progs, prog)
+	l, err := link.Kprobe(function, prog, nil)
+	if err != nil {
+		return fmt.Errorf("failed to attach to %s: %w", function, err)
+	}
+	t.links = append(t.links, l)
+	return nil
+}
+
+// readHeader sets R9 to the address of the header of the skb in R7, whose
+// head is in R8
+func readHeader(header int32) asm.Instructions {
+	insns := bpf_counters.ProbeRead(bpf_counters.Scratch, 2, asm.R7, header)
+	return append(insns,
+		asm.LoadMem(asm.R9, asm.RFP, bpf_counters.Scratch, asm.Half),
+		asm.Add.Reg(asm.R9, asm.R8),
+	)
+}
+
+// readHead sets R8 to the head of the skb in R7
+func readHead(offsets kernelOffsets) asm.Instructions {
+	insns := bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R7, offsets.skbHead)
+	return append(insns, asm.LoadMem(asm.R8, asm.RFP, bpf_counters.Scratch, asm.DWord))
+}
+
+// readNetns sets the netns of the key from the struct net in R6
+func readNetns(offsets kernelOffsets) asm.Instructions {
+	insns := asm.Instructions{asm.JEq.Imm(asm.R6, 0, "exit")}
+	return append(insns, bpf_counters.ProbeRead(bpf_counters.KeyNetns, 4, asm.R6, offsets.netInum)...)
+}
+
+// readSockNet sets R6 to the struct net of the sock in R6
+func readSockNet(offsets kernelOffsets) asm.Instructions {
+	insns := asm.Instructions{asm.JEq.Imm(asm.R6, 0, "exit")}
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R6, offsets.skNet)...)
+	return append(insns, asm.LoadMem(asm.R6, asm.RFP, bpf_counters.Scratch, asm.DWord))
+}
+
+// queryProgram counts in the counters map the UDP datagrams sent to the port
+// 53, from ip_send_skb(struct net *, struct sk_buff *) or
+// ip6_send_skb(struct sk_buff *)
+func queryProgram(offsets kernelOffsets, ipv6 bool, counters int) asm.Instructions {
+	// R6 = net, R7 = skb
+	insns := asm.Instructions{
+		asm.LoadMem(asm.R6, asm.R1, offsets.args[0], asm.DWord),
+		asm.LoadMem(asm.R7, asm.R1, offsets.args[1], asm.DWord),
+	}
+	// The protocol field of the IPv4 header, the next header one of IPv6
+	protocol := int32(9)
+	if ipv6 {
+		insns = asm.Instructions{asm.LoadMem(asm.R7, asm.R1, offsets.args[0], asm.DWord)}
+		insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R7, offsets.skbSk)...)
+		insns = append(insns, asm.LoadMem(asm.R6, asm.RFP, bpf_counters.Scratch, asm.DWord))
+		insns = append(insns, readSockNet(offsets)...)
+		protocol = 6
+	}
+	insns = append(insns,
+		asm.Mov.Imm(asm.R1, 0),
+		asm.StoreMem(asm.RFP, bpf_counters.Scratch, asm.R1, asm.DWord),
+		asm.StoreImm(asm.RFP, bpf_counters.KeyKind, Query, asm.Word),
+	)
+	insns = append(insns, readHead(offsets)...)
+	insns = append(insns, readHeader(offsets.skbNetworkHeader)...)
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 1, asm.R9, protocol)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch, asm.Byte),
+		asm.JNE.Imm(asm.R1, udpProto, "exit"),
+	)
+	insns = append(insns, readHeader(offsets.skbTransportHeader)...)
+	// The destination port of the UDP header
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 2, asm.R9, 2)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.JNE.Imm(asm.R1, dnsPort, "exit"),
+	)
+	insns = append(insns, readNetns(offsets)...)
+	return append(insns, bpf_counters.Increment(counters)...)
+}
+
+// responseProgram counts in the counters map the DNS responses from the port
+// 53 by response code, from
+// __udp_enqueue_schedule_skb(struct sock *, struct sk_buff *)
+func responseProgram(offsets kernelOffsets, counters int) asm.Instructions {
+	// R6 = sk, R7 = skb
+	insns := asm.Instructions{
+		asm.LoadMem(asm.R6, asm.R1, offsets.args[0], asm.DWord),
+		asm.LoadMem(asm.R7, asm.R1, offsets.args[1], asm.DWord),
+	}
+	insns = append(insns, readSockNet(offsets)...)
+	insns = append(insns, readHead(offsets)...)
+	insns = append(insns, readHeader(offsets.skbTransportHeader)...)
+	// The source port and length of the UDP header
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, udpHeaderSize, asm.R9, 0)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.JNE.Imm(asm.R1, dnsPort, "exit"),
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch+4, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.JLT.Imm(asm.R1, udpHeaderSize+dnsHeaderSize, "exit"),
+	)
+	// The flags of the DNS header: QR, set for the responses, then RCODE
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 2, asm.R9, udpHeaderSize+2)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch, asm.Byte),
+		asm.JSet.Imm(asm.R1, 0x80, "response"),
+		asm.Ja.Label("exit"),
+		asm.LoadMem(asm.R1, asm.RFP, bpf_counters.Scratch+1, asm.Byte).WithSymbol("response"),
+		asm.And.Imm(asm.R1, 0x0f),
+		asm.StoreMem(asm.RFP, bpf_counters.KeyKind, asm.R1, asm.Word),
+	)
+	insns = append(insns, readNetns(offsets)...)
+	return append(insns, bpf_counters.Increment(counters)...)
+}
+
+// Close detaches the programs and releases the map.
+func (t *Tracer) Close() error {
+	var errs []error
+	for _, l := range t.links {
+		errs = append(errs, l.Close())
+	}
+	for _, prog := range t.progs {
+		errs = append(errs, prog.Close())
+	}
+	if t.Map != nil {
+		errs = append(errs, t.Map.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package dns_queries
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestRcodeName(t *testing.T) {
+	assert.Equal(t, "NOERROR", RcodeName(0))
+	assert.Equal(t, "SERVFAIL", RcodeName(2))
+	assert.Equal(t, "NXDOMAIN", RcodeName(3))
+	assert.Equal(t, "12", RcodeName(12))
+}
+
+func TestPrograms(t *testing.T) {
+	for _, insns := range []asm.Instructions{
+		queryProgram(kernelOffsets{args: []int16{112, 104}}, false, 0),
+		queryProgram(kernelOffsets{args: []int16{112, 104}}, true, 0),
+		responseProgram(kernelOffsets{args: []int16{112, 104}}, 0),
+	} {
+		for _, insn := range insns {
+			assert.NotEqual(t, asm.InvalidOpCode, insn.OpCode, insn)
+		}
+	}
+}
+
+// query is a query for example.com, its response having the QR flag set
+var query = []byte{
+	0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0x00, 0x01, 0x00, 0x01,
+}
+
+func TestTracer(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("loading the programs requires root")
+	}
+	tracer, err := Start()
+	if err != nil {
+		t.Skipf("eBPF unavailable: %v", err)
+	}
+	defer tracer.Close()
+
+	// A server answering NXDOMAIN on the loopback
+	server, err := net.ListenPacket("udp", "127.0.0.1:53")
+	require.NoError(t, err)
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		buf[2] |= 0x80
+		buf[3] = 0x80 | 3
+		_, _ = server.WriteTo(buf[:n], addr)
+	}()
+
+	conn, err := net.Dial("udp", "127.0.0.1:53")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(query)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = conn.Read(make([]byte, 512))
+	require.NoError(t, err)
+
+	var stat unix.Stat_t
+	require.NoError(t, unix.Stat("/proc/thread-self/ns/net", &stat))
+	counts, err := tracer.Counts()
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]uint64{Query: 1, 3: 1}, counts[uint32(stat.Ino)])
+}
@@ -0,0 +1,167 @@
+// Package ethtool is a minimal SIOCETHTOOL client reading the driver
+// statistics and the speed of the interfaces of the network namespace of the
+// calling thread.
+package ethtool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ETH_GSTRING_LEN
+	stringLen = 32
+	// ETH_SS_STATS
+	stringSetStats = 1
+
+	sizeofGstrings = 12
+	sizeofStats    = 8
+	sizeofCmd      = 44
+
+	// SPEED_UNKNOWN, for both the 16 bits and reassembled 32 bits speeds
+	speedUnknown = 0xffffffff
+)
+
+// ErrNotSupported is returned for devices without ethtool support
+var ErrNotSupported = errors.New("ethtool: not supported by the device")
+
+var errTruncated = errors.New("ethtool: truncated reply")
+
+// ifreqData is a struct ifreq whose union holds a pointer, see ifreqData in
+// golang.org/x/sys/unix
+type ifreqData struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [24 - unsafe.Sizeof(uintptr(0))]byte
+}
+
+// Client issues ethtool ioctls through a socket of the current netns
+type Client struct {
+	fd int
+}
+
+// Open creates the socket, in the network namespace of the calling thread
+func Open() (*Client, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ethtool: socket: %w", err)
+	}
+	return &Client{fd: fd}, nil
+}
+
+func (c *Client) Close() error {
+	return unix.Close(c.fd)
+}
+
+// ioctl runs an ethtool command whose request and reply are held in buf
+func (c *Client) ioctl(device string, buf []byte) error {
+	var ifr ifreqData
+	if len(device) >= len(ifr.name) {
+		return fmt.Errorf("ethtool: device name too long: %q", device)
+	}
+	copy(ifr.name[:], device)
+	ifr.data = unsafe.Pointer(&buf[0])
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(c.fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	runtime.KeepAlive(buf)
+	switch errno {
+	case 0:
+		return nil
+	case unix.EOPNOTSUPP:
+		return ErrNotSupported
+	default:
+		return fmt.Errorf("ethtool: %s: %w", device, errno)
+	}
+}
+
+// Stats returns the driver statistics of the device, as shown by ethtool -S
+func (c *Client) Stats(device string) (map[string]uint64, error) {
+	drvinfo, err := unix.IoctlGetEthtoolDrvinfo(c.fd, device)
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		return nil, ErrNotSupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ethtool: %s: %w", device, err)
+	}
+	count := int(drvinfo.N_stats)
+	if count == 0 {
+		return nil, nil
+	}
+
+	gstrings := make([]byte, sizeofGstrings+count*stringLen)
+	binary.NativeEndian.PutUint32(gstrings[0:4], unix.ETHTOOL_GSTRINGS)
+	binary.NativeEndian.PutUint32(gstrings[4:8], stringSetStats)
+	binary.NativeEndian.PutUint32(gstrings[8:12], uint32(count))
+	if err := c.ioctl(device, gstrings); err != nil {
+		return nil, err
+	}
+	names, err := parseStrings(gstrings)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]byte, sizeofStats+count*8)
+	binary.NativeEndian.PutUint32(stats[0:4], unix.ETHTOOL_GSTATS)
+	binary.NativeEndian.PutUint32(stats[4:8], uint32(count))
+	if err := c.ioctl(device, stats); err != nil {
+		return nil, err
+	}
+	return parseStats(names, stats)
+}
+
+// Speed returns the link speed of the device in Mb/s, false when unknown
+// (link down or virtual device)
+func (c *Client) Speed(device string) (uint32, bool, error) {
+	cmd := make([]byte, sizeofCmd)
+	binary.NativeEndian.PutUint32(cmd[0:4], unix.ETHTOOL_GSET)
+	if err := c.ioctl(device, cmd); err != nil {
+		return 0, false, err
+	}
+	speed, known := parseSpeed(cmd)
+	return speed, known, nil
+}
+
+// parseStrings decodes a struct ethtool_gstrings
+func parseStrings(b []byte) ([]string, error) {
+	count := int(binary.NativeEndian.Uint32(b[8:12]))
+	if len(b) < sizeofGstrings+count*stringLen {
+		return nil, errTruncated
+	}
+	names := make([]string, count)
+	for i := range names {
+		offset := sizeofGstrings + i*stringLen
+		names[i] = string(bytes.TrimRight(b[offset:offset+stringLen], "\x00"))
+	}
+	return names, nil
+}
+
+// parseStats decodes a struct ethtool_stats, the values following the
+// order of the names
+func parseStats(names []string, b []byte) (map[string]uint64, error) {
+	count := int(binary.NativeEndian.Uint32(b[4:8]))
+	if count != len(names) || len(b) < sizeofStats+count*8 {
+		return nil, errTruncated
+	}
+	stats := make(map[string]uint64, count)
+	for i, name := range names {
+		offset := sizeofStats + i*8
+		// Drivers may expose the same name several times, e.g. per queue
+		// stats without the queue number, sum them
+		stats[name] += binary.NativeEndian.Uint64(b[offset : offset+8])
+	}
+	return stats, nil
+}
+
+// parseSpeed decodes the speed of a struct ethtool_cmd
+func parseSpeed(b []byte) (uint32, bool) {
+	speed := uint32(binary.NativeEndian.Uint16(b[12:14])) | uint32(binary.NativeEndian.Uint16(b[28:30]))<<16
+	if speed == speedUnknown || speed == 0xffff || speed == 0 {
+		return 0, false
+	}
+	return speed, true
+}
@@ -0,0 +1,49 @@
+package ethtool
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStringsAndStats(t *testing.T) {
+	gstrings := make([]byte, sizeofGstrings+3*stringLen)
+	binary.NativeEndian.PutUint32(gstrings[8:12], 3)
+	for i, name := range []string{"rx_missed_errors", "rx_queue_drops", "rx_queue_drops"} {
+		copy(gstrings[sizeofGstrings+i*stringLen:], name)
+	}
+	names, err := parseStrings(gstrings)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rx_missed_errors", "rx_queue_drops", "rx_queue_drops"}, names)
+
+	stats := make([]byte, sizeofStats+3*8)
+	binary.NativeEndian.PutUint32(stats[4:8], 3)
+	for i, value := range []uint64{42, 3, 4} {
+		binary.NativeEndian.PutUint64(stats[sizeofStats+i*8:], value)
+	}
+	got, err := parseStats(names, stats)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"rx_missed_errors": 42, "rx_queue_drops": 7}, got)
+
+	_, err = parseStats(names[:2], stats)
+	assert.ErrorIs(t, err, errTruncated)
+	binary.NativeEndian.PutUint32(gstrings[8:12], 4)
+	_, err = parseStrings(gstrings)
+	assert.ErrorIs(t, err, errTruncated)
+}
+
+func TestParseSpeed(t *testing.T) {
+	cmd := make([]byte, sizeofCmd)
+	binary.NativeEndian.PutUint16(cmd[12:14], 0x86a0)
+	binary.NativeEndian.PutUint16(cmd[28:30], 0x1)
+	speed, known := parseSpeed(cmd)
+	assert.True(t, known)
+	assert.Equal(t, uint32(100000), speed)
+
+	binary.NativeEndian.PutUint16(cmd[12:14], 0xffff)
+	binary.NativeEndian.PutUint16(cmd[28:30], 0xffff)
+	_, known = parseSpeed(cmd)
+	assert.False(t, known)
+}
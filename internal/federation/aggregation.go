@@ -0,0 +1,171 @@
+package federation
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Aggregations of the federated metrics
+const (
+	// AggregationNone federates the metrics as is
+	AggregationNone = "none"
+	// AggregationNamespace sums the pod metrics per Kubernetes namespace,
+	// over every node
+	AggregationNamespace = "namespace"
+	// AggregationController sums the pod metrics per pod controller
+	// (Deployment, StatefulSet...), over every node
+	AggregationController = "controller"
+)
+
+// Labels identifying the pod or its node, without the cosanet_ prefix, dropped by
+// the aggregations. The node labels of -node-labels are kept, for the metrics
+// to be summed e.g. per zone.
+var (
+	aggregatedLabels = []string{
+		"node",
+		"pod",
+		"netnsname",
+		"pod_uid",
+		"hostnetwork",
+		"container",
+		"container_id",
+		"runtime",
+	}
+	aggregatedLabelPrefixes = []string{"pod_label_", "pod_annotation_"}
+	controllerLabels        = []string{"pod_controller_kind", "pod_controller_name"}
+)
+
+// aggregation sums the metrics of the pods of a namespace or controller, the
+// ones not of a pod (e.g. of the host netns) being left as is
+type aggregation struct {
+	namespaceLabel string
+	dropped        map[string]bool
+	droppedPrefix  []string
+}
+
+func mustNewAggregation(mode string, labelName func(string) string) *aggregation {
+	var dropped []string
+	switch mode {
+	case "", AggregationNone:
+		return nil
+	case AggregationNamespace:
+		dropped = append(slices.Clone(aggregatedLabels), controllerLabels...)
+	case AggregationController:
+		dropped = aggregatedLabels
+	default:
+		panic(fmt.Errorf("unknown federation aggregation %q, expected none, namespace or controller", mode))
+	}
+	a := &aggregation{namespaceLabel: labelName("cosanet_namespace"), dropped: make(map[string]bool)}
+	for _, label := range dropped {
+		a.dropped[labelName("cosanet_"+label)] = true
+	}
+	for _, prefix := range aggregatedLabelPrefixes {
+		a.droppedPrefix = append(a.droppedPrefix, labelName("cosanet_"+prefix))
+	}
+	return a
+}
+
+// isDropped tells whether the label identifies the pod or its node
+func (a *aggregation) isDropped(name string) bool {
+	if a.dropped[name] {
+		return true
+	}
+	for _, prefix := range a.droppedPrefix {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ofPod tells whether the metric is of a pod, by its namespace
+func (a *aggregation) ofPod(m *dto.Metric) bool {
+	for _, pair := range m.Label {
+		if pair.GetName() == a.namespaceLabel {
+			return pair.GetValue() != ""
+		}
+	}
+	return false
+}
+
+// aggregate sums the pod metrics of the family sharing their other labels,
+// the _max gauges keeping the maximum. Summaries can't be summed and are left
+// as is.
+func (a *aggregation) aggregate(family *dto.MetricFamily) {
+	if family.GetType() == dto.MetricType_SUMMARY || family.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
+		return
+	}
+	keepMax := family.GetType() == dto.MetricType_GAUGE && strings.HasSuffix(family.GetName(), "_max")
+	metrics := family.Metric[:0:0]
+	series := make(map[string]*dto.Metric)
+	for _, m := range family.Metric {
+		if !a.ofPod(m) {
+			metrics = append(metrics, m)
+			continue
+		}
+		var labels []*dto.LabelPair
+		var key strings.Builder
+		for _, pair := range m.Label {
+			if a.isDropped(pair.GetName()) {
+				continue
+			}
+			labels = append(labels, pair)
+			key.WriteString(pair.GetName())
+			key.WriteByte(0xff)
+			key.WriteString(pair.GetValue())
+			key.WriteByte(0xff)
+		}
+		existing, ok := series[key.String()]
+		if !ok {
+			aggregated := proto.Clone(m).(*dto.Metric)
+			aggregated.Label = labels
+			aggregated.TimestampMs = nil
+			series[key.String()] = aggregated
+			metrics = append(metrics, aggregated)
+			continue
+		}
+		combine(existing, m, keepMax)
+	}
+	family.Metric = metrics
+}
+
+// combine adds the value of m to the aggregated metric
+func combine(aggregated, m *dto.Metric, keepMax bool) {
+	switch {
+	case aggregated.Counter != nil:
+		aggregated.Counter.Value = proto.Float64(aggregated.Counter.GetValue() + m.Counter.GetValue())
+	case aggregated.Gauge != nil && keepMax:
+		aggregated.Gauge.Value = proto.Float64(max(aggregated.Gauge.GetValue(), m.Gauge.GetValue()))
+	case aggregated.Gauge != nil:
+		aggregated.Gauge.Value = proto.Float64(aggregated.Gauge.GetValue() + m.Gauge.GetValue())
+	case aggregated.Untyped != nil:
+		aggregated.Untyped.Value = proto.Float64(aggregated.Untyped.GetValue() + m.Untyped.GetValue())
+	case aggregated.Histogram != nil:
+		combineHistograms(aggregated.Histogram, m.Histogram)
+	}
+}
+
+// combineHistograms adds the buckets of h to the aggregated histogram, by
+// upper bound
+func combineHistograms(aggregated, h *dto.Histogram) {
+	aggregated.SampleCount = proto.Uint64(aggregated.GetSampleCount() + h.GetSampleCount())
+	aggregated.SampleSum = proto.Float64(aggregated.GetSampleSum() + h.GetSampleSum())
+	for _, bucket := range h.Bucket {
+		i := slices.IndexFunc(aggregated.Bucket, func(b *dto.Bucket) bool {
+			return b.GetUpperBound() == bucket.GetUpperBound()
+		})
+		if i < 0 {
+			aggregated.Bucket = append(aggregated.Bucket, proto.Clone(bucket).(*dto.Bucket))
+			continue
+		}
+		aggregated.Bucket[i].CumulativeCount = proto.Uint64(aggregated.Bucket[i].GetCumulativeCount() + bucket.GetCumulativeCount())
+	}
+	slices.SortFunc(aggregated.Bucket, func(a, b *dto.Bucket) int {
+		return cmp.Compare(a.GetUpperBound(), b.GetUpperBound())
+	})
+}
@@ -0,0 +1,279 @@
+// Package federation scrapes the cosanet pods of every node and merges their
+// metrics, for a single scrape target to cover the whole cluster
+package federation
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Mode runs cosanet as the federation of the cosanet pods of the cluster,
+// rather than collecting the netns of its node
+const Mode = "federation"
+
+// acceptHeader prefers the protobuf format, cheaper to decode
+var acceptHeader = string(expfmt.NewFormat(expfmt.TypeProtoDelim)) + ";q=0.7,text/plain;version=0.0.4;q=0.3"
+
+// Options of the federation
+type Options struct {
+	// Service of the cosanet pods, namespace/name
+	Service string
+	// Name of the metrics port of the service, empty for the first one
+	Port string
+	// Path of the metrics on the pods
+	Path string
+	// Timeout of the scrape of a pod
+	Timeout time.Duration
+	// Aggregation of the federated metrics, see Aggregation*
+	Aggregation string
+	// Namespace of the federation metric names (def: cosanet)
+	MetricPrefix string
+	// LabelName names the cosanet labels, given with their default name
+	// (e.g. cosanet_node), as the federated pods do (def: as is)
+	LabelName func(label string) string
+}
+
+// Lister lists the cosanet pods, see controller_resolver.EndpointsLister
+type Lister interface {
+	List() ([]controller_resolver.Endpoint, error)
+}
+
+// Federator is the prometheus.Gatherer of the merged metrics of the pods,
+// and the prometheus.Collector of the state of their scrapes
+type Federator struct {
+	options     Options
+	lister      Lister
+	client      *http.Client
+	aggregation *aggregation
+	// Label given the node of the pod to the federated metrics without it
+	nodeLabel string
+
+	targetUpDesc        *prometheus.Desc
+	targetDurationDesc  *prometheus.Desc
+	discoveryErrorsDesc *prometheus.Desc
+
+	mu              sync.Mutex
+	targets         []*target
+	discoveryErrors uint64
+}
+
+// target is the last scrape of a pod
+type target struct {
+	controller_resolver.Endpoint
+	up       bool
+	duration time.Duration
+	families []*dto.MetricFamily
+}
+
+// MustNewFederator builds the federation of the pods listed by lister,
+// panicking on invalid options
+func MustNewFederator(options Options, lister Lister) *Federator {
+	options.MetricPrefix = cmp.Or(options.MetricPrefix, "cosanet")
+	options.Path = cmp.Or(options.Path, "/metrics")
+	if options.LabelName == nil {
+		options.LabelName = func(label string) string { return label }
+	}
+	nodeLabel := options.LabelName("cosanet_node")
+	labels := []string{nodeLabel, options.LabelName("cosanet_target")}
+	return &Federator{
+		options:     options,
+		lister:      lister,
+		client:      &http.Client{},
+		aggregation: mustNewAggregation(options.Aggregation, options.LabelName),
+		nodeLabel:   nodeLabel,
+		targetUpDesc: prometheus.NewDesc(
+			options.MetricPrefix+"_federation_target_up",
+			"Whether the last scrape of the cosanet pod succeeded",
+			labels, nil,
+		),
+		targetDurationDesc: prometheus.NewDesc(
+			options.MetricPrefix+"_federation_target_scrape_duration_seconds",
+			"Duration of the last scrape of the cosanet pod",
+			labels, nil,
+		),
+		discoveryErrorsDesc: prometheus.NewDesc(
+			options.MetricPrefix+"_federation_discovery_errors_total",
+			"Failed listings of the cosanet pods from the Endpoints API",
+			nil, nil,
+		),
+	}
+}
+
+// Gather scrapes every pod and returns their merged metrics, aggregated as
+// configured. The pods failing are left out, see Collect.
+func (f *Federator) Gather() ([]*dto.MetricFamily, error) {
+	endpoints, err := f.lister.List()
+	if err != nil {
+		slog.Error("Failed to list the cosanet pods", slog.Any("err", err))
+		f.mu.Lock()
+		f.discoveryErrors++
+		f.targets = nil
+		f.mu.Unlock()
+		return nil, nil
+	}
+	targets := make([]*target, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		targets[i] = &target{Endpoint: endpoint}
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			f.scrape(t)
+		}(targets[i])
+	}
+	wg.Wait()
+	f.mu.Lock()
+	f.targets = targets
+	f.mu.Unlock()
+
+	families := f.merge(targets)
+	for _, t := range targets {
+		t.families = nil
+	}
+	if f.aggregation != nil {
+		for _, family := range families {
+			f.aggregation.aggregate(family)
+		}
+	}
+	return families, nil
+}
+
+// scrape fetches the metrics of the pod, labeling them with its node
+func (f *Federator) scrape(t *target) {
+	start := time.Now()
+	families, err := f.fetch(t.Address)
+	t.duration = time.Since(start)
+	if err != nil {
+		slog.Warn(
+			"Failed to scrape the cosanet pod",
+			slog.String("node", t.NodeName),
+			slog.String("target", t.Address),
+			slog.Any("err", err),
+		)
+		return
+	}
+	node := cmp.Or(t.NodeName, t.Address)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			addMissingLabel(m, f.nodeLabel, node)
+		}
+	}
+	t.up, t.families = true, families
+}
+
+func (f *Federator) fetch(address string) ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.options.Timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+f.options.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", acceptHeader)
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	decoder := expfmt.NewDecoder(response.Body, expfmt.ResponseFormat(response.Header))
+	var families []*dto.MetricFamily
+	for {
+		family := &dto.MetricFamily{}
+		err := decoder.Decode(family)
+		if errors.Is(err, io.EOF) {
+			return families, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+}
+
+// addMissingLabel adds the label to m unless it has it, keeping the labels
+// sorted
+func addMissingLabel(m *dto.Metric, name, value string) {
+	for _, pair := range m.Label {
+		if pair.GetName() == name {
+			return
+		}
+	}
+	m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	slices.SortFunc(m.Label, compareLabels)
+}
+
+func compareLabels(a, b *dto.LabelPair) int {
+	return cmp.Compare(a.GetName(), b.GetName())
+}
+
+// merge returns the families of the targets, merging the ones of the same
+// name. The metrics of a family of another type than the first pod's are
+// dropped, e.g. while rolling out a new version.
+func (f *Federator) merge(targets []*target) []*dto.MetricFamily {
+	merged := make(map[string]*dto.MetricFamily)
+	var families []*dto.MetricFamily
+	for _, t := range targets {
+		for _, family := range t.families {
+			existing, ok := merged[family.GetName()]
+			switch {
+			case !ok:
+				merged[family.GetName()] = family
+				families = append(families, family)
+			case existing.GetType() != family.GetType():
+				slog.Debug(
+					"Dropping the metrics of a family of another type",
+					slog.String("family", family.GetName()),
+					slog.String("node", t.NodeName),
+					slog.String("type", family.GetType().String()),
+					slog.String("expected", existing.GetType().String()),
+				)
+			default:
+				existing.Metric = append(existing.Metric, family.Metric...)
+			}
+		}
+	}
+	slices.SortFunc(families, func(a, b *dto.MetricFamily) int {
+		return cmp.Compare(a.GetName(), b.GetName())
+	})
+	return families
+}
+
+// Describe implements prometheus.Collector
+func (f *Federator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.targetUpDesc
+	ch <- f.targetDurationDesc
+	ch <- f.discoveryErrorsDesc
+}
+
+// Collect implements prometheus.Collector, publishing the state of the last
+// scrapes of the pods
+func (f *Federator) Collect(ch chan<- prometheus.Metric) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.targets {
+		up := 0.0
+		if t.up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(f.targetUpDesc, prometheus.GaugeValue, up, t.NodeName, t.Address)
+		ch <- prometheus.MustNewConstMetric(f.targetDurationDesc, prometheus.GaugeValue, t.duration.Seconds(), t.NodeName, t.Address)
+	}
+	ch <- prometheus.MustNewConstMetric(f.discoveryErrorsDesc, prometheus.CounterValue, float64(f.discoveryErrors))
+}
@@ -0,0 +1,187 @@
+package federation
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosanet/cosanet/internal/controller_resolver"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLister struct {
+	endpoints []controller_resolver.Endpoint
+	err       error
+}
+
+func (l *staticLister) List() ([]controller_resolver.Endpoint, error) {
+	return l.endpoints, l.err
+}
+
+var netnsLabels = []string{"cosanet_node", "cosanet_pod", "cosanet_namespace", "cosanet_pod_controller_kind", "cosanet_pod_controller_name"}
+
+// podServer serves the metrics of pods of node, given their name, namespace
+// and controller name
+func podServer(t *testing.T, node string, pods ...[3]string) string {
+	registry := prometheus.NewRegistry()
+	established := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cosanet_proc_net_tcp",
+		Help: "TCP sockets",
+	}, netnsLabels)
+	retrans := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cosanet_tcp_retrans_total",
+		Help: "TCP retransmissions",
+	}, netnsLabels)
+	build := prometheus.NewGauge(prometheus.GaugeOpts{Name: "cosanet_build_info", Help: "Build"})
+	build.Set(1)
+	registry.MustRegister(established, retrans, build)
+	established.WithLabelValues(node, "", "", "", "").Set(10)
+	for i, pod := range pods {
+		established.WithLabelValues(node, pod[0], pod[1], "Deployment", pod[2]).Set(float64(i + 1))
+		retrans.WithLabelValues(node, pod[0], pod[1], "Deployment", pod[2]).Add(float64(10 * (i + 1)))
+	}
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func newTestFederator(t *testing.T, aggregation string) *Federator {
+	lister := &staticLister{endpoints: []controller_resolver.Endpoint{
+		{Address: podServer(t, "node-1", [3]string{"web-1", "shop", "web"}, [3]string{"db-0", "shop", "db"}), NodeName: "node-1"},
+		{Address: podServer(t, "node-2", [3]string{"web-2", "shop", "web"}), NodeName: "node-2"},
+		{Address: "127.0.0.1:1", NodeName: "node-3"},
+	}}
+	return MustNewFederator(Options{Timeout: time.Second, Aggregation: aggregation}, lister)
+}
+
+func gather(t *testing.T, f *Federator) map[string]*dto.MetricFamily {
+	families, err := f.Gather()
+	require.NoError(t, err)
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range m.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+func collect(f *Federator) []prometheus.Metric {
+	ch := make(chan prometheus.Metric, 100)
+	f.Collect(ch)
+	close(ch)
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestFederatorMerge(t *testing.T) {
+	f := newTestFederator(t, AggregationNone)
+	families := gather(t, f)
+
+	require.Contains(t, families, "cosanet_proc_net_tcp")
+	assert.Len(t, families["cosanet_proc_net_tcp"].Metric, 5)
+	// The metrics without node are given the one of their pod
+	build := families["cosanet_build_info"]
+	require.Len(t, build.Metric, 2)
+	assert.Equal(t, map[string]string{"cosanet_node": "node-1"}, labelsOf(build.Metric[0]))
+	assert.Equal(t, map[string]string{"cosanet_node": "node-2"}, labelsOf(build.Metric[1]))
+
+	// up and duration of the 3 pods, and the discovery errors
+	assert.Len(t, collect(f), 7)
+	var up []string
+	for _, target := range f.targets {
+		if target.up {
+			up = append(up, target.NodeName)
+		}
+	}
+	assert.Equal(t, []string{"node-1", "node-2"}, up)
+
+	f.lister.(*staticLister).err = errors.New("forbidden")
+	families = gather(t, f)
+	assert.Empty(t, families)
+	metrics := collect(f)
+	require.Len(t, metrics, 1)
+	var m dto.Metric
+	require.NoError(t, metrics[0].Write(&m))
+	assert.Equal(t, 1.0, m.Counter.GetValue())
+}
+
+func TestFederatorAggregation(t *testing.T) {
+	families := gather(t, newTestFederator(t, AggregationController))
+	var tcp []map[string]string
+	for _, m := range families["cosanet_proc_net_tcp"].Metric {
+		labels := labelsOf(m)
+		labels["value"] = strconv.FormatFloat(m.Gauge.GetValue(), 'g', -1, 64)
+		tcp = append(tcp, labels)
+	}
+	assert.ElementsMatch(t, []map[string]string{
+		// The host netns are left as is
+		{"cosanet_node": "node-1", "cosanet_pod": "", "cosanet_namespace": "", "cosanet_pod_controller_kind": "", "cosanet_pod_controller_name": "", "value": "10"},
+		{"cosanet_node": "node-2", "cosanet_pod": "", "cosanet_namespace": "", "cosanet_pod_controller_kind": "", "cosanet_pod_controller_name": "", "value": "10"},
+		{"cosanet_namespace": "shop", "cosanet_pod_controller_kind": "Deployment", "cosanet_pod_controller_name": "web", "value": "2"},
+		{"cosanet_namespace": "shop", "cosanet_pod_controller_kind": "Deployment", "cosanet_pod_controller_name": "db", "value": "2"},
+	}, tcp)
+
+	families = gather(t, newTestFederator(t, AggregationNamespace))
+	retrans := families["cosanet_tcp_retrans_total"]
+	require.Len(t, retrans.Metric, 1)
+	assert.Equal(t, map[string]string{"cosanet_namespace": "shop"}, labelsOf(retrans.Metric[0]))
+	assert.Equal(t, 40.0, retrans.Metric[0].Counter.GetValue())
+
+	assert.Panics(t, func() { mustNewAggregation("pod", nil) })
+}
+
+func TestFederatorLabelName(t *testing.T) {
+	renamed := map[string]string{"cosanet_node": "node", "cosanet_namespace": "namespace"}
+	f := MustNewFederator(Options{
+		Aggregation: AggregationNamespace,
+		LabelName: func(label string) string {
+			if name, ok := renamed[label]; ok {
+				return name
+			}
+			return label
+		},
+	}, &staticLister{})
+	assert.Equal(t, "node", f.nodeLabel)
+	assert.Equal(t, "namespace", f.aggregation.namespaceLabel)
+	assert.True(t, f.aggregation.isDropped("node"))
+	assert.False(t, f.aggregation.isDropped("cosanet_node"))
+	assert.True(t, f.aggregation.isDropped("cosanet_pod_label_app"))
+}
+
+func TestCombineHistograms(t *testing.T) {
+	h := prometheus.MustNewConstHistogram(
+		prometheus.NewDesc("h", "", nil, nil), 3, 1.5, map[float64]uint64{0.1: 1, 1: 3},
+	)
+	other := prometheus.MustNewConstHistogram(
+		prometheus.NewDesc("h", "", nil, nil), 2, 4, map[float64]uint64{0.1: 0, 1: 2},
+	)
+	var aggregated, m dto.Metric
+	require.NoError(t, h.Write(&aggregated))
+	require.NoError(t, other.Write(&m))
+	combine(&aggregated, &m, false)
+	assert.Equal(t, uint64(5), aggregated.Histogram.GetSampleCount())
+	assert.Equal(t, 5.5, aggregated.Histogram.GetSampleSum())
+	var counts []uint64
+	for _, bucket := range aggregated.Histogram.Bucket {
+		counts = append(counts, bucket.GetCumulativeCount())
+	}
+	assert.Equal(t, []uint64{1, 5}, counts)
+}
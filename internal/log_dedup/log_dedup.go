@@ -0,0 +1,177 @@
+// Package log_dedup suppresses the repeated log records, so that a runtime
+// briefly unavailable or a broken netns doesn't flood the logs with the same
+// error on every scrape.
+package log_dedup
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler wraps a slog.Handler: a record at or above the level identical to
+// one logged less than a window ago (same level, message, attributes and
+// logger context) is counted instead of logged. Once the window is over, the
+// first record is logged again with the number of suppressed ones in a
+// "repeated" attribute.
+type Handler struct {
+	next   slog.Handler
+	level  slog.Leveler
+	window time.Duration
+	// Shared with the handlers derived by WithAttrs and WithGroup
+	state *state
+	// Context of WithAttrs and WithGroup, part of the records key
+	scope string
+}
+
+type state struct {
+	mu         sync.Mutex
+	entries    map[string]*entry
+	suppressed map[slog.Level]uint64
+	now        func() time.Time
+}
+
+// entry is a record logged in the current window
+type entry struct {
+	// Handler and record of the first occurrence, logging the summary
+	handler  slog.Handler
+	record   slog.Record
+	since    time.Time
+	repeated uint64
+}
+
+// New returns a handler deduplicating the records at or above level over the
+// window. The summaries are logged by a goroutine ticking every window.
+func New(next slog.Handler, level slog.Leveler, window time.Duration) *Handler {
+	h := newHandler(next, level, window, time.Now)
+	go func() {
+		for range time.Tick(window) {
+			h.Flush()
+		}
+	}()
+	return h
+}
+
+func newHandler(next slog.Handler, level slog.Leveler, window time.Duration, now func() time.Time) *Handler {
+	return &Handler{
+		next:   next,
+		level:  level,
+		window: window,
+		state: &state{
+			entries:    make(map[string]*entry),
+			suppressed: make(map[slog.Level]uint64),
+			now:        now,
+		},
+	}
+}
+
+// Enabled tells if the wrapped handler logs the level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle logs the record, unless it was already logged in the window.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level.Level() {
+		return h.next.Handle(ctx, r)
+	}
+	key := h.key(r)
+	s := h.state
+	s.mu.Lock()
+	now := s.now()
+	e, ok := s.entries[key]
+	if ok && now.Sub(e.since) < h.window {
+		e.repeated++
+		s.suppressed[r.Level]++
+		s.mu.Unlock()
+		return nil
+	}
+	s.entries[key] = &entry{handler: h.next, record: r.Clone(), since: now}
+	s.mu.Unlock()
+	if ok && e.repeated > 0 {
+		// Not flushed yet
+		e.log(ctx, h.window)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// key identifies the record by its level, message and attributes
+func (h *Handler) key(r slog.Record) string {
+	var key strings.Builder
+	key.WriteString(h.scope)
+	key.WriteString(r.Level.String())
+	key.WriteByte(' ')
+	key.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		key.WriteByte(' ')
+		key.WriteString(a.String())
+		return true
+	})
+	return key.String()
+}
+
+// log logs the summary of the suppressed records
+func (e *entry) log(ctx context.Context, window time.Duration) {
+	r := e.record.Clone()
+	r.AddAttrs(slog.Uint64("repeated", e.repeated), slog.Duration("window", window))
+	_ = e.handler.Handle(ctx, r)
+}
+
+// Flush logs the summary of the records whose window is over and forgets
+// them.
+func (h *Handler) Flush() {
+	s := h.state
+	s.mu.Lock()
+	now := s.now()
+	var repeated []*entry
+	for key, e := range s.entries {
+		if now.Sub(e.since) < h.window {
+			continue
+		}
+		if e.repeated > 0 {
+			repeated = append(repeated, e)
+		}
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	for _, e := range repeated {
+		e.log(context.Background(), h.window)
+	}
+}
+
+// Suppressed returns the number of records suppressed so far per level.
+func (h *Handler) Suppressed() map[slog.Level]uint64 {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.suppressed)
+}
+
+// WithAttrs returns a handler adding the attributes, the records of the
+// loggers with other attributes not being merged.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	var scope strings.Builder
+	scope.WriteString(h.scope)
+	for _, a := range attrs {
+		scope.WriteString(a.String())
+		scope.WriteByte(' ')
+	}
+	clone.scope = scope.String()
+	return &clone
+}
+
+// WithGroup returns a handler grouping the next attributes.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.scope = h.scope + name + "."
+	return &clone
+}
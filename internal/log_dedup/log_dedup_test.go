@@ -0,0 +1,112 @@
+package log_dedup
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLogger returns a logger deduplicating the warnings over a minute,
+// the time being advanced by the test
+func newTestLogger(out *bytes.Buffer, now *time.Time) (*slog.Logger, *Handler) {
+	text := slog.NewTextHandler(out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	h := newHandler(text, slog.LevelWarn, time.Minute, func() time.Time { return *now })
+	return slog.New(h), h
+}
+
+func lines(out *bytes.Buffer) []string {
+	defer out.Reset()
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestDedup(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	logger, h := newTestLogger(&out, &now)
+
+	for range 3 {
+		logger.Error("collection failed", "err", errors.New("connection refused"))
+		logger.Error("collection failed", "err", errors.New("no such netns"))
+		logger.Info("scraped")
+	}
+	assert.Equal(t, []string{
+		`level=ERROR msg="collection failed" err="connection refused"`,
+		`level=ERROR msg="collection failed" err="no such netns"`,
+		`level=INFO msg=scraped`,
+		`level=INFO msg=scraped`,
+		`level=INFO msg=scraped`,
+	}, lines(&out))
+	assert.Equal(t, map[slog.Level]uint64{slog.LevelError: 4}, h.Suppressed())
+
+	// Nothing to summarize before the end of the window
+	now = now.Add(30 * time.Second)
+	h.Flush()
+	assert.Empty(t, out.String())
+
+	now = now.Add(30 * time.Second)
+	h.Flush()
+	assert.ElementsMatch(t, []string{
+		`level=ERROR msg="collection failed" err="connection refused" repeated=2 window=1m0s`,
+		`level=ERROR msg="collection failed" err="no such netns" repeated=2 window=1m0s`,
+	}, lines(&out))
+
+	// Forgotten, logged again
+	logger.Error("collection failed", "err", errors.New("connection refused"))
+	assert.Equal(t, []string{`level=ERROR msg="collection failed" err="connection refused"`}, lines(&out))
+}
+
+func TestDedupExpiredBeforeFlush(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	logger, _ := newTestLogger(&out, &now)
+
+	logger.Warn("netns unavailable")
+	logger.Warn("netns unavailable")
+	now = now.Add(time.Minute)
+	// The summary goes first
+	logger.Warn("netns unavailable")
+	assert.Equal(t, []string{
+		`level=WARN msg="netns unavailable"`,
+		`level=WARN msg="netns unavailable" repeated=1 window=1m0s`,
+		`level=WARN msg="netns unavailable"`,
+	}, lines(&out))
+}
+
+func TestDedupContext(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	logger, h := newTestLogger(&out, &now)
+
+	// The loggers with other attributes aren't merged
+	web := logger.With("pod", "web").WithGroup("netns")
+	db := logger.With("pod", "db").WithGroup("netns")
+	for range 2 {
+		web.Warn("failed", "path", "/run/netns/a")
+		db.Warn("failed", "path", "/run/netns/a")
+	}
+	assert.Equal(t, []string{
+		`level=WARN msg=failed pod=web netns.path=/run/netns/a`,
+		`level=WARN msg=failed pod=db netns.path=/run/netns/a`,
+	}, lines(&out))
+
+	// The summaries keep the logger context
+	now = now.Add(time.Minute)
+	h.Flush()
+	assert.ElementsMatch(t, []string{
+		`level=WARN msg=failed pod=web netns.path=/run/netns/a netns.repeated=1 netns.window=1m0s`,
+		`level=WARN msg=failed pod=db netns.path=/run/netns/a netns.repeated=1 netns.window=1m0s`,
+	}, lines(&out))
+	assert.Equal(t, map[slog.Level]uint64{slog.LevelWarn: 2}, h.Suppressed())
+}
@@ -0,0 +1,48 @@
+package packet_drops
+
+import (
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cosanet/cosanet/internal/bpf_counters"
+)
+
+// kernelOffsets are the offsets of the struct members the program reads to
+// find the netns of a packet, from the kernel BTF
+type kernelOffsets struct {
+	// sk_buff.dev and sk_buff.sk
+	skbDev int32
+	skbSk  int32
+	// net_device.nd_net.net and sock.__sk_common.skc_net.net
+	devNet int32
+	skNet  int32
+	// net.ns.inum, the inode of the netns
+	netInum int32
+}
+
+func loadKernelOffsets(spec *btf.Spec) (kernelOffsets, error) {
+	var offsets kernelOffsets
+	err := bpf_counters.MemberOffsets(spec,
+		bpf_counters.Member{Offset: &offsets.skbDev, Type: "sk_buff", Path: []string{"dev"}},
+		bpf_counters.Member{Offset: &offsets.skbSk, Type: "sk_buff", Path: []string{"sk"}},
+		bpf_counters.Member{Offset: &offsets.devNet, Type: "net_device", Path: []string{"nd_net", "net"}},
+		bpf_counters.Member{Offset: &offsets.skNet, Type: "sock", Path: []string{"__sk_common", "skc_net", "net"}},
+		bpf_counters.Member{Offset: &offsets.netInum, Type: "net", Path: []string{"ns", "inum"}},
+	)
+	return offsets, err
+}
+
+// loadDropReasons returns the names of the skb_drop_reason values without
+// their prefix (eg: NETFILTER_DROP), empty before the kernel 5.17
+func loadDropReasons(spec *btf.Spec) map[uint32]string {
+	reasons := make(map[uint32]string)
+	var enum *btf.Enum
+	if err := spec.TypeByName("skb_drop_reason", &enum); err != nil {
+		return reasons
+	}
+	for _, value := range enum.Values {
+		name := strings.TrimPrefix(value.Name, "SKB_DROP_REASON_")
+		reasons[uint32(value.Value)] = strings.TrimPrefix(name, "SKB_")
+	}
+	return reasons
+}
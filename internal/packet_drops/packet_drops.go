@@ -0,0 +1,160 @@
+// Package packet_drops counts the packets dropped by the kernel per network
+// namespace and drop reason, from an eBPF program on the skb:kfree_skb
+// tracepoint.
+package packet_drops
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cosanet/cosanet/internal/bpf_counters"
+)
+
+// Tracer counts the dropped packets per netns inode and reason until closed.
+type Tracer struct {
+	*bpf_counters.Map
+	prog    *ebpf.Program
+	link    link.Link
+	reasons map[uint32]string
+}
+
+// Start loads the program and attaches it to the skb:kfree_skb tracepoint,
+// which requires CAP_BPF and CAP_PERFMON (or CAP_SYS_ADMIN) and tracefs.
+func Start() (*Tracer, error) {
+	format, err := tracepointFormat("skb", "kfree_skb")
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseFormatOffsets(format)
+	format.Close()
+	if err != nil {
+		return nil, err
+	}
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, fmt.Errorf("kernel BTF unavailable: %w", err)
+	}
+	offsets, err := loadKernelOffsets(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tracer{reasons: loadDropReasons(spec)}
+	if t.Map, err = bpf_counters.NewMap("cosanet_drops"); err != nil {
+		return nil, err
+	}
+	insns, err := program(fields, offsets, t.FD())
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.prog, err = ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         "cosanet_drops",
+		Type:         ebpf.TracePoint,
+		Instructions: insns,
+		// bpf_probe_read_kernel is GPL only
+		License: "GPL",
+	})
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to load the program: %w", err)
+	}
+	t.link, err = link.Tracepoint("skb", "kfree_skb", t.prog, nil)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to attach to skb:kfree_skb: %w", err)
+	}
+	return t, nil
+}
+
+// program counts the drops in the counters map by netns inode and reason. The
+// netns comes from the device of the packet, or its socket for the packets
+// without device (eg: dropped by the local stack), 0 when it has neither.
+func program(fields map[string]int16, offsets kernelOffsets, counters int) (asm.Instructions, error) {
+	skbaddr, ok := fields["skbaddr"]
+	if !ok {
+		return nil, errors.New("skb:kfree_skb tracepoint without skbaddr")
+	}
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R7, asm.R6, skbaddr, asm.DWord),
+		asm.StoreImm(asm.RFP, bpf_counters.KeyNetns, 0, asm.Word),
+		asm.StoreImm(asm.RFP, bpf_counters.KeyKind, 0, asm.Word),
+	}
+	// The drop reason came with the kernel 5.17
+	if reason, ok := fields["reason"]; ok {
+		insns = append(insns,
+			asm.LoadMem(asm.R8, asm.R6, reason, asm.Word),
+			asm.StoreMem(asm.RFP, bpf_counters.KeyKind, asm.R8, asm.Word),
+		)
+	}
+	// R9 = skb->dev
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R7, offsets.skbDev)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R9, asm.RFP, bpf_counters.Scratch, asm.DWord),
+		asm.JEq.Imm(asm.R9, 0, "sock"),
+	)
+	// dev->nd_net.net
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R9, offsets.devNet)...)
+	insns = append(insns, asm.Ja.Label("net"))
+	// R9 = skb->sk
+	sock := bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R7, offsets.skbSk)
+	sock[0] = sock[0].WithSymbol("sock")
+	insns = append(insns, sock...)
+	insns = append(insns,
+		asm.LoadMem(asm.R9, asm.RFP, bpf_counters.Scratch, asm.DWord),
+		asm.JEq.Imm(asm.R9, 0, "count"),
+	)
+	// sk->__sk_common.skc_net.net
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.Scratch, 8, asm.R9, offsets.skNet)...)
+	// net->ns.inum
+	insns = append(insns,
+		asm.LoadMem(asm.R9, asm.RFP, bpf_counters.Scratch, asm.DWord).WithSymbol("net"),
+		asm.JEq.Imm(asm.R9, 0, "count"),
+	)
+	insns = append(insns, bpf_counters.ProbeRead(bpf_counters.KeyNetns, 4, asm.R9, offsets.netInum)...)
+	return append(insns, bpf_counters.Increment(counters)...), nil
+}
+
+// Counts returns the drops counted so far per netns inode and reason.
+func (t *Tracer) Counts() (map[uint32]map[uint32]uint64, error) {
+	counts, err := t.Map.Counts()
+	// Some kernels trace the consumed packets too, which aren't drops
+	for _, reasons := range counts {
+		for reason := range reasons {
+			if t.reasons[reason] == "CONSUMED" {
+				delete(reasons, reason)
+			}
+		}
+	}
+	return counts, err
+}
+
+// ReasonName returns the name of the drop reason, its number when unknown to
+// the kernel BTF
+func (t *Tracer) ReasonName(reason uint32) string {
+	if name, ok := t.reasons[reason]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(reason), 10)
+}
+
+// Close detaches the program and releases the map.
+func (t *Tracer) Close() error {
+	var errs []error
+	if t.link != nil {
+		errs = append(errs, t.link.Close())
+	}
+	if t.prog != nil {
+		errs = append(errs, t.prog.Close())
+	}
+	if t.Map != nil {
+		errs = append(errs, t.Map.Close())
+	}
+	return errors.Join(errs...)
+}
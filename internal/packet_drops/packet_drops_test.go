@@ -0,0 +1,101 @@
+package packet_drops
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+const kfreeSkbFormat = `name: kfree_skb
+ID: 2210
+format:
+	field:unsigned short common_type;	offset:0;	size:2;	signed:0;
+	field:unsigned char common_flags;	offset:2;	size:1;	signed:0;
+	field:unsigned char common_preempt_count;	offset:3;	size:1;	signed:0;
+	field:int common_pid;	offset:4;	size:4;	signed:1;
+
+	field:void * skbaddr;	offset:8;	size:8;	signed:0;
+	field:void * location;	offset:16;	size:8;	signed:0;
+	field:void * rx_sk;	offset:24;	size:8;	signed:0;
+	field:unsigned short protocol;	offset:32;	size:2;	signed:0;
+	field:enum skb_drop_reason reason;	offset:36;	size:4;	signed:0;
+	field:char comm[16];	offset:40;	size:16;	signed:0;
+
+print fmt: "skbaddr=%p rx_sk=%p protocol=%u location=%pS reason: %s", REC->skbaddr
+`
+
+func TestParseFormatOffsets(t *testing.T) {
+	offsets, err := parseFormatOffsets(strings.NewReader(kfreeSkbFormat))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int16{
+		"common_type":          0,
+		"common_flags":         2,
+		"common_preempt_count": 3,
+		"common_pid":           4,
+		"skbaddr":              8,
+		"location":             16,
+		"rx_sk":                24,
+		"protocol":             32,
+		"reason":               36,
+		"comm":                 40,
+	}, offsets)
+
+	_, err = parseFormatOffsets(strings.NewReader("\tfield:void * skbaddr;\toffset:x;\tsize:8;\n"))
+	assert.Error(t, err)
+}
+
+func TestProgram(t *testing.T) {
+	_, err := program(map[string]int16{"location": 16}, kernelOffsets{}, 0)
+	assert.Error(t, err)
+
+	// Without reason before the kernel 5.17
+	insns, err := program(map[string]int16{"skbaddr": 8}, kernelOffsets{}, 0)
+	require.NoError(t, err)
+	for _, insn := range insns {
+		assert.NotEqual(t, asm.InvalidOpCode, insn.OpCode, insn)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("loading the program requires root")
+	}
+	tracer, err := Start()
+	if err != nil {
+		t.Skipf("eBPF unavailable: %v", err)
+	}
+	defer tracer.Close()
+
+	// A datagram to a closed port is dropped without socket
+	conn, err := net.Dial("udp", "127.0.0.1:9")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("drop"))
+	require.NoError(t, err)
+	var stat unix.Stat_t
+	require.NoError(t, unix.Stat("/proc/thread-self/ns/net", &stat))
+	netns := uint32(stat.Ino)
+
+	assert.Eventually(t, func() bool {
+		counts, err := tracer.Counts()
+		require.NoError(t, err)
+		for reason, count := range counts[netns] {
+			if tracer.ReasonName(reason) == "NO_SOCKET" && count > 0 {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, tracer.Forget(func(inode uint32) bool { return inode == netns }))
+	counts, err := tracer.Counts()
+	require.NoError(t, err)
+	assert.NotContains(t, counts, netns)
+}
@@ -0,0 +1,62 @@
+package packet_drops
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount points of tracefs, the debugfs one for the kernels before 4.1
+var tracefsRoots = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// tracepointFormat opens the format of the tracepoint in the first tracefs
+// mount found
+func tracepointFormat(group, name string) (*os.File, error) {
+	for _, root := range tracefsRoots {
+		file, err := os.Open(filepath.Join(root, "events", group, name, "format"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return file, err
+	}
+	return nil, fmt.Errorf("format of the %s:%s tracepoint not found, is tracefs mounted on %s?", group, name, tracefsRoots[0])
+}
+
+// parseFormatOffsets returns the offset of every field of a tracepoint format
+// (eg: "field:void * skbaddr;	offset:8;	size:8;	signed:0;")
+func parseFormatOffsets(r io.Reader) (map[string]int16, error) {
+	offsets := make(map[string]int16)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "field:") {
+			continue
+		}
+		var decl, offset string
+		for _, part := range strings.Split(line, ";") {
+			part = strings.TrimSpace(part)
+			if value, ok := strings.CutPrefix(part, "field:"); ok {
+				decl = value
+			} else if value, ok := strings.CutPrefix(part, "offset:"); ok {
+				offset = value
+			}
+		}
+		fields := strings.Fields(decl)
+		if len(fields) == 0 || offset == "" {
+			return nil, fmt.Errorf("malformed tracepoint field: %s", line)
+		}
+		// Arrays are declared as name[size]
+		name, _, _ := strings.Cut(fields[len(fields)-1], "[")
+		value, err := strconv.ParseInt(offset, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tracepoint field offset: %s", line)
+		}
+		offsets[name] = int16(value)
+	}
+	return offsets, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package procnet_dev_parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseDevHeader builds the counter names from the second header line of
+// /proc/net/dev, prefixing the receive ones with rx_ and transmit ones with tx_
+func parseDevHeader(line string) ([]string, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed dev header: %s", line)
+	}
+	var names []string
+	for _, name := range strings.Fields(parts[1]) {
+		names = append(names, "rx_"+name)
+	}
+	for _, name := range strings.Fields(parts[2]) {
+		names = append(names, "tx_"+name)
+	}
+	return names, nil
+}
+
+// parseDevLine parses an interface line, returning the interface name and its counters
+func parseDevLine(line string, names []string) (string, map[string]uint64, error) {
+	iface, rest, found := strings.Cut(line, ":")
+	if !found {
+		return "", nil, fmt.Errorf("malformed dev line: %s", line)
+	}
+	fields := strings.Fields(rest)
+	if len(fields) != len(names) {
+		return "", nil, fmt.Errorf("expected %d counters, got %d: %s", len(names), len(fields), line)
+	}
+	counters := make(map[string]uint64, len(names))
+	for i, field := range fields {
+		val, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return "", nil, err
+		}
+		counters[names[i]] = val
+	}
+	return strings.TrimSpace(iface), counters, nil
+}
+
+// parseDevFromScanner parses /proc/net/dev contents from a bufio.Scanner.
+// It returns a nested map: interface → counter → value.
+func parseDevFromScanner(scanner *bufio.Scanner) (map[string]map[string]uint64, error) {
+	// First header line only holds the Receive/Transmit titles
+	if !scanner.Scan() || !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("missing dev header")
+	}
+	names, err := parseDevHeader(scanner.Text())
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]uint64)
+	for scanner.Scan() {
+		iface, counters, err := parseDevLine(scanner.Text(), names)
+		if err != nil {
+			return nil, err
+		}
+		result[iface] = counters
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParseDevFile opens the file and passes the scanner to the parser.
+func ParseDevFile(filename string) (map[string]map[string]uint64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	return parseDevFromScanner(scanner)
+}
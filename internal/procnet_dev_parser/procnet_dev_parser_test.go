@@ -0,0 +1,44 @@
+package procnet_dev_parser
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const devContent = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1200      12    0    0    0     0          0         0     1200      12    0    0    0     0       0          0
+  eth0: 78324368    4774    1    2    0     0          0         3   590960    6142    4    5    0     0       0          0
+`
+
+func TestParseDevFromScanner(t *testing.T) {
+	result, err := parseDevFromScanner(bufio.NewScanner(strings.NewReader(devContent)))
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, uint64(1200), result["lo"]["rx_bytes"])
+	assert.Equal(t, uint64(78324368), result["eth0"]["rx_bytes"])
+	assert.Equal(t, uint64(1), result["eth0"]["rx_errs"])
+	assert.Equal(t, uint64(2), result["eth0"]["rx_drop"])
+	assert.Equal(t, uint64(3), result["eth0"]["rx_multicast"])
+	assert.Equal(t, uint64(6142), result["eth0"]["tx_packets"])
+	assert.Equal(t, uint64(5), result["eth0"]["tx_drop"])
+	assert.Len(t, result["eth0"], 16)
+}
+
+func TestParseDevFromScanner_Malformed(t *testing.T) {
+	_, err := parseDevFromScanner(bufio.NewScanner(strings.NewReader("Inter-|\n")))
+	assert.Error(t, err)
+
+	truncated := strings.Replace(devContent, "     1200      12    0    0    0     0       0          0", "", 1)
+	_, err = parseDevFromScanner(bufio.NewScanner(strings.NewReader(truncated)))
+	assert.Error(t, err)
+}
+
+func TestParseDevFile(t *testing.T) {
+	_, err := ParseDevFile("/nonexistent/dev")
+	assert.Error(t, err)
+}
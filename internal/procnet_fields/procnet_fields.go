@@ -0,0 +1,82 @@
+// Package procnet_fields holds the allocation free helpers shared by the
+// /proc/net parsers, which run for every pod of every scrape.
+package procnet_fields
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+)
+
+// ProcPath returns the path of a file relative to the procfs root, /proc
+// when empty
+func ProcPath(procRoot, name string) string {
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	return filepath.Join(procRoot, name)
+}
+
+// NextLine returns the first line of data, without its newline, and the
+// remaining data.
+func NextLine(data []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, nil
+}
+
+// Split appends the whitespace separated fields of the line to dst, the
+// fields sharing the memory of the line. Passing the previous result as dst
+// reuses its backing array.
+func Split(dst [][]byte, line []byte) [][]byte {
+	start := -1
+	for i, b := range line {
+		if b == ' ' || b == '\t' {
+			if start >= 0 {
+				dst = append(dst, line[start:i])
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		dst = append(dst, line[start:])
+	}
+	return dst
+}
+
+// Cut returns the first whitespace separated field of the line and the
+// remaining of the line, sharing its memory.
+func Cut(line []byte) (field, rest []byte) {
+	start := 0
+	for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+		start++
+	}
+	end := start
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		end++
+	}
+	return line[start:end], line[end:]
+}
+
+// ParseUint parses a decimal unsigned integer, false when the field isn't one
+// or overflows 64 bits (eg: Tcp MaxConn's -1).
+func ParseUint(field []byte) (uint64, bool) {
+	if len(field) == 0 {
+		return 0, false
+	}
+	var val uint64
+	for _, b := range field {
+		if b < '0' || b > '9' {
+			return 0, false
+		}
+		digit := uint64(b - '0')
+		if val > (math.MaxUint64-digit)/10 {
+			return 0, false
+		}
+		val = val*10 + digit
+	}
+	return val, true
+}
@@ -0,0 +1,74 @@
+package procnet_fields
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcPath(t *testing.T) {
+	assert.Equal(t, "/proc/net/snmp", ProcPath("", "net/snmp"))
+	assert.Equal(t, "/host/proc/net/snmp", ProcPath("/host/proc", "net/snmp"))
+}
+
+func TestNextLine(t *testing.T) {
+	line, rest := NextLine([]byte("Ip: Forwarding\nIp: 1\n"))
+	assert.Equal(t, "Ip: Forwarding", string(line))
+	line, rest = NextLine(rest)
+	assert.Equal(t, "Ip: 1", string(line))
+	assert.Empty(t, rest)
+	line, rest = NextLine([]byte("Ip: 1"))
+	assert.Equal(t, "Ip: 1", string(line))
+	assert.Nil(t, rest)
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"TcpExt: SyncookiesSent SyncookiesRecv", []string{"TcpExt:", "SyncookiesSent", "SyncookiesRecv"}},
+		{"Icmp6InMsgs     \t42  ", []string{"Icmp6InMsgs", "42"}},
+		{"   ", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, field := range Split(nil, []byte(tt.line)) {
+			got = append(got, string(field))
+		}
+		assert.Equal(t, tt.want, got, tt.line)
+	}
+}
+
+func TestCut(t *testing.T) {
+	field, rest := Cut([]byte("Icmp6InMsgs     \t42"))
+	assert.Equal(t, "Icmp6InMsgs", string(field))
+	assert.Equal(t, "     \t42", string(rest))
+	field, rest = Cut([]byte("  TcpExt:"))
+	assert.Equal(t, "TcpExt:", string(field))
+	assert.Empty(t, rest)
+	field, _ = Cut(nil)
+	assert.Empty(t, field)
+}
+
+func TestParseUint(t *testing.T) {
+	tests := []struct {
+		field string
+		want  uint64
+		ok    bool
+	}{
+		{"0", 0, true},
+		{"42", 42, true},
+		{"18446744073709551615", 18446744073709551615, true},
+		{"18446744073709551616", 0, false},
+		{"-1", 0, false},
+		{"notanint", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseUint([]byte(tt.field))
+		assert.Equal(t, tt.ok, ok, tt.field)
+		assert.Equal(t, tt.want, got, tt.field)
+	}
+}
@@ -0,0 +1,81 @@
+package procnet_softnet_parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Columns of /proc/net/softnet_stat, see softnet_seq_show in the kernel
+const (
+	colProcessed   = 0
+	colDropped     = 1
+	colTimeSqueeze = 2
+	// Only printed since Linux 5.10
+	colCPU = 12
+)
+
+// SoftnetStat holds the counters of a CPU
+type SoftnetStat struct {
+	CPU         int
+	Processed   uint32
+	Dropped     uint32
+	TimeSqueeze uint32
+}
+
+// parseSoftnetLine parses the line of the index-th printed CPU. Offline CPUs
+// are skipped by the kernel so the index is only used when the line doesn't
+// carry the CPU id.
+func parseSoftnetLine(line string, index int) (SoftnetStat, error) {
+	fields := strings.Fields(line)
+	if len(fields) <= colTimeSqueeze {
+		return SoftnetStat{}, fmt.Errorf("malformed softnet_stat line: %s", line)
+	}
+	values := make([]uint32, len(fields))
+	for i, field := range fields {
+		val, err := strconv.ParseUint(field, 16, 32)
+		if err != nil {
+			return SoftnetStat{}, err
+		}
+		values[i] = uint32(val)
+	}
+	stat := SoftnetStat{
+		CPU:         index,
+		Processed:   values[colProcessed],
+		Dropped:     values[colDropped],
+		TimeSqueeze: values[colTimeSqueeze],
+	}
+	if len(values) > colCPU {
+		stat.CPU = int(values[colCPU])
+	}
+	return stat, nil
+}
+
+// parseSoftnetFromScanner parses /proc/net/softnet_stat contents from a bufio.Scanner.
+func parseSoftnetFromScanner(scanner *bufio.Scanner) ([]SoftnetStat, error) {
+	var result []SoftnetStat
+	for scanner.Scan() {
+		stat, err := parseSoftnetLine(scanner.Text(), len(result))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, stat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParseSoftnetFile opens the file and passes the scanner to the parser.
+func ParseSoftnetFile(filename string) ([]SoftnetStat, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	return parseSoftnetFromScanner(scanner)
+}
@@ -0,0 +1,37 @@
+package procnet_softnet_parser
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSoftnetFromScanner(t *testing.T) {
+	content := "0000a2c1 00000002 0000001f 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n" +
+		"00001000 00000000 00000003 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000003\n"
+	result, err := parseSoftnetFromScanner(bufio.NewScanner(strings.NewReader(content)))
+	require.NoError(t, err)
+	assert.Equal(t, []SoftnetStat{
+		{CPU: 0, Processed: 0xa2c1, Dropped: 2, TimeSqueeze: 0x1f},
+		{CPU: 3, Processed: 0x1000, Dropped: 0, TimeSqueeze: 3},
+	}, result)
+}
+
+func TestParseSoftnetFromScanner_OldKernel(t *testing.T) {
+	content := "00000010 00000000 00000001 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n" +
+		"00000020 00000001 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000\n"
+	result, err := parseSoftnetFromScanner(bufio.NewScanner(strings.NewReader(content)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result[1].CPU)
+	assert.Equal(t, uint32(0x20), result[1].Processed)
+}
+
+func TestParseSoftnetFromScanner_Malformed(t *testing.T) {
+	_, err := parseSoftnetFromScanner(bufio.NewScanner(strings.NewReader("00000010 0000zz00 00000001\n")))
+	assert.Error(t, err)
+	_, err = parseSoftnetFromScanner(bufio.NewScanner(strings.NewReader("00000010\n")))
+	assert.Error(t, err)
+}
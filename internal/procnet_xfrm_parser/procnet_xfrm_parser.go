@@ -0,0 +1,110 @@
+package procnet_xfrm_parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+// Prefix shared by every /proc/net/xfrm_stat counter, used as section
+const section = "Xfrm"
+
+// xfrmKey is a counter key without its prefix
+type xfrmKey struct {
+	name     string
+	included bool
+}
+
+// Parser parses /proc/net/xfrm_stat. It is meant to be kept between the
+// pods: the file buffer, the keys and the result maps are reused, the
+// returned map being only valid until the next parse. A Parser isn't safe for
+// concurrent use.
+type Parser struct {
+	// Tells if a counter is parsed, nil for all of them
+	include  func(section, field string) bool
+	data     bytes.Buffer
+	fields   [][]byte
+	keys     map[string]xfrmKey
+	counters map[string]uint64
+	result   map[string]map[string]uint64
+}
+
+// NewParser returns a parser converting the counters matched by include, or
+// all of them when nil.
+func NewParser(include func(section, field string) bool) *Parser {
+	p := &Parser{
+		include:  include,
+		keys:     make(map[string]xfrmKey),
+		counters: make(map[string]uint64),
+	}
+	p.result = map[string]map[string]uint64{section: p.counters}
+	return p
+}
+
+// key returns the key without its prefix, trimming it only the first time it
+// is seen
+func (p *Parser) key(raw []byte) xfrmKey {
+	if key, ok := p.keys[string(raw)]; ok {
+		return key
+	}
+	key := xfrmKey{name: string(raw[len(section):])}
+	key.included = p.include == nil || p.include(section, key.name)
+	p.keys[string(raw)] = key
+	return key
+}
+
+// parseXfrmLine parses a single line from /proc/net/xfrm_stat (eg: XfrmInError 0).
+func (p *Parser) parseXfrmLine(line []byte) error {
+	p.fields = procnet_fields.Split(p.fields[:0], line)
+	if len(p.fields) != 2 || !bytes.HasPrefix(p.fields[0], []byte(section)) || len(p.fields[0]) == len(section) {
+		return fmt.Errorf("malformed xfrm_stat line: %s", line)
+	}
+	key := p.key(p.fields[0])
+	if !key.included {
+		return nil
+	}
+	val, ok := procnet_fields.ParseUint(p.fields[1])
+	if !ok {
+		return fmt.Errorf("invalid xfrm_stat value: %s", line)
+	}
+	p.counters[key.name] = val
+	return nil
+}
+
+// Parse parses /proc/net/xfrm_stat contents. It returns a nested map like the
+// other /proc/net parsers: Xfrm → field → uint64.
+func (p *Parser) Parse(r io.Reader) (map[string]map[string]uint64, error) {
+	clear(p.counters)
+
+	p.data.Reset()
+	if _, err := p.data.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	for data := p.data.Bytes(); len(data) > 0; {
+		var line []byte
+		line, data = procnet_fields.NextLine(data)
+		if err := p.parseXfrmLine(line); err != nil {
+			return nil, err
+		}
+	}
+	return p.result, nil
+}
+
+// ParseFile opens the file and passes it to the parser.
+func (p *Parser) ParseFile(filename string) (map[string]map[string]uint64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return p.Parse(file)
+}
+
+// ParseXfrmFile parses the file with a parser of its own, the returned map
+// being owned by the caller.
+func ParseXfrmFile(filename string) (map[string]map[string]uint64, error) {
+	return NewParser(nil).ParseFile(filename)
+}
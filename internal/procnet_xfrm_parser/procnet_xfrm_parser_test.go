@@ -0,0 +1,37 @@
+package procnet_xfrm_parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXfrm(t *testing.T) {
+	content := "XfrmInError                     3\nXfrmInNoStates                  12\nXfrmOutError                    0\n"
+	result, err := NewParser(nil).Parse(strings.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"Xfrm": {"InError": 3, "InNoStates": 12, "OutError": 0},
+	}, result)
+}
+
+func TestParseXfrmLine_Malformed(t *testing.T) {
+	p := NewParser(nil)
+	for _, line := range []string{"XfrmInError", "Xfrm 3", "TcpInError 3", "XfrmInError three"} {
+		assert.Error(t, p.parseXfrmLine([]byte(line)), line)
+	}
+}
+
+func TestParseXfrmInclude(t *testing.T) {
+	p := NewParser(func(section, field string) bool { return field == "InError" })
+	result, err := p.Parse(strings.NewReader("XfrmInError 3\nXfrmOutError 1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{"Xfrm": {"InError": 3}}, result)
+
+	// Reused, the previous values don't leak
+	result, err = p.Parse(strings.NewReader("XfrmOutError 1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{"Xfrm": {}}, result)
+}
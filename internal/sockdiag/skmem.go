@@ -0,0 +1,55 @@
+package sockdiag
+
+import "encoding/binary"
+
+// AttrSkMemInfo is INET_DIAG_SKMEMINFO, the socket memory counters
+const AttrSkMemInfo = 7
+
+// ExtSkMemInfo is the Request.Ext bit requesting AttrSkMemInfo
+const ExtSkMemInfo = 1 << (AttrSkMemInfo - 1)
+
+// Indexes of the u32 array of AttrSkMemInfo, see SK_MEMINFO_* in the kernel.
+// SK_MEMINFO_DROPS was added last, the attribute may be shorter on older ones.
+const (
+	skMemInfoRmemAlloc = iota
+	skMemInfoRcvBuf
+	skMemInfoWmemAlloc
+	skMemInfoSndBuf
+	skMemInfoFwdAlloc
+	skMemInfoWmemQueued
+	skMemInfoOptmem
+	skMemInfoBacklog
+	skMemInfoDrops
+)
+
+// SkMemInfo is the subset of the socket memory counters cosanet uses, in bytes
+// except Drops
+type SkMemInfo struct {
+	RmemAlloc uint32
+	RcvBuf    uint32
+	WmemAlloc uint32
+	SndBuf    uint32
+	Backlog   uint32
+	Drops     uint32
+}
+
+// ParseSkMemInfo decodes the AttrSkMemInfo payload of a socket
+func ParseSkMemInfo(b []byte) (SkMemInfo, error) {
+	if len(b) < (skMemInfoBacklog+1)*4 {
+		return SkMemInfo{}, ErrTruncated
+	}
+	value := func(i int) uint32 {
+		return binary.NativeEndian.Uint32(b[i*4:])
+	}
+	info := SkMemInfo{
+		RmemAlloc: value(skMemInfoRmemAlloc),
+		RcvBuf:    value(skMemInfoRcvBuf),
+		WmemAlloc: value(skMemInfoWmemAlloc),
+		SndBuf:    value(skMemInfoSndBuf),
+		Backlog:   value(skMemInfoBacklog),
+	}
+	if len(b) >= (skMemInfoDrops+1)*4 {
+		info.Drops = value(skMemInfoDrops)
+	}
+	return info, nil
+}
@@ -0,0 +1,124 @@
+package sockdiag
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func nlMsg(msgType uint16, seq uint32, payload []byte) []byte {
+	b := make([]byte, nlmAlign(sizeofNlMsghdr+len(payload)))
+	binary.NativeEndian.PutUint32(b[0:4], uint32(sizeofNlMsghdr+len(payload)))
+	binary.NativeEndian.PutUint16(b[4:6], msgType)
+	binary.NativeEndian.PutUint32(b[8:12], seq)
+	copy(b[sizeofNlMsghdr:], payload)
+	return b
+}
+
+func diagMsg(state uint8, sport uint16, rqueue uint32, attrs ...[]byte) []byte {
+	b := make([]byte, sizeofInetDiagMsg)
+	b[0] = unix.AF_INET
+	b[1] = state
+	binary.BigEndian.PutUint16(b[4:6], sport)
+	binary.NativeEndian.PutUint32(b[56:60], rqueue)
+	for _, attr := range attrs {
+		b = append(b, attr...)
+	}
+	return b
+}
+
+func rtAttr(attrType uint16, payload []byte) []byte {
+	b := make([]byte, nlmAlign(sizeofRtAttr+len(payload)))
+	binary.NativeEndian.PutUint16(b[0:2], uint16(sizeofRtAttr+len(payload)))
+	binary.NativeEndian.PutUint16(b[2:4], attrType)
+	copy(b[sizeofRtAttr:], payload)
+	return b
+}
+
+func TestParseMessages(t *testing.T) {
+	var buf []byte
+	buf = append(buf, nlMsg(unix.SOCK_DIAG_BY_FAMILY, 1, diagMsg(10, 8080, 0))...)
+	buf = append(buf, nlMsg(unix.SOCK_DIAG_BY_FAMILY, 2, diagMsg(1, 1, 0))...) // other sequence
+	buf = append(buf, nlMsg(unix.SOCK_DIAG_BY_FAMILY, 1, diagMsg(1, 443, 42))...)
+
+	var states []uint8
+	var ports []uint16
+	var rqueues []uint32
+	done, err := parseMessages(buf, 1, &Socket{}, func(s *Socket) error {
+		states = append(states, s.State)
+		ports = append(ports, s.SrcPort)
+		rqueues = append(rqueues, s.RQueue)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, []uint8{10, 1}, states)
+	assert.Equal(t, []uint16{8080, 443}, ports)
+	assert.Equal(t, []uint32{0, 42}, rqueues)
+
+	done, err = parseMessages(nlMsg(unix.NLMSG_DONE, 1, make([]byte, 4)), 1, &Socket{}, nil)
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestParseMessages_Attrs(t *testing.T) {
+	buf := nlMsg(unix.SOCK_DIAG_BY_FAMILY, 1, diagMsg(1, 80, 0, rtAttr(2, []byte{1, 2, 3}), rtAttr(7, []byte{4})))
+	sock := &Socket{Attrs: map[uint16][]byte{}}
+	var got map[uint16][]byte
+	_, err := parseMessages(buf, 1, sock, func(s *Socket) error {
+		got = map[uint16][]byte{}
+		for k, v := range s.Attrs {
+			got[k] = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint16][]byte{2: {1, 2, 3}, 7: {4}}, got)
+}
+
+func TestParseMessages_Errors(t *testing.T) {
+	errno := -int32(unix.ENOENT)
+	errPayload := make([]byte, 4)
+	binary.NativeEndian.PutUint32(errPayload, uint32(errno))
+	_, err := parseMessages(nlMsg(unix.NLMSG_ERROR, 1, errPayload), 1, &Socket{}, nil)
+	assert.ErrorIs(t, err, unix.ENOENT)
+
+	truncated := nlMsg(unix.SOCK_DIAG_BY_FAMILY, 1, make([]byte, 10))
+	_, err = parseMessages(truncated, 1, &Socket{}, func(*Socket) error { return nil })
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestParseTCPInfo(t *testing.T) {
+	_, err := ParseTCPInfo(make([]byte, 32))
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	b := make([]byte, 232)
+	b[offTCPInfoCAState] = CARecovery
+	b[offTCPInfoRetransmits] = 2
+	binary.NativeEndian.PutUint32(b[offTCPInfoRTT:], 1500)
+	binary.NativeEndian.PutUint32(b[offTCPInfoRTTVar:], 300)
+	binary.NativeEndian.PutUint32(b[offTCPInfoTotalRetrans:], 7)
+	info, err := ParseTCPInfo(b)
+	require.NoError(t, err)
+	assert.Equal(t, TCPInfo{CAState: CARecovery, Retransmits: 2, RTT: 1500, RTTVar: 300, TotalRetrans: 7}, info)
+}
+
+func TestParseSkMemInfo(t *testing.T) {
+	_, err := ParseSkMemInfo(make([]byte, 16))
+	assert.ErrorIs(t, err, ErrTruncated)
+
+	b := make([]byte, 36)
+	for i, value := range []uint32{1024, 212992, 512, 46080, 0, 0, 0, 64, 3} {
+		binary.NativeEndian.PutUint32(b[i*4:], value)
+	}
+	info, err := ParseSkMemInfo(b)
+	require.NoError(t, err)
+	assert.Equal(t, SkMemInfo{RmemAlloc: 1024, RcvBuf: 212992, WmemAlloc: 512, SndBuf: 46080, Backlog: 64, Drops: 3}, info)
+
+	info, err = ParseSkMemInfo(b[:32])
+	require.NoError(t, err)
+	assert.Zero(t, info.Drops)
+}
@@ -0,0 +1,55 @@
+package sockdiag
+
+import "encoding/binary"
+
+// INET_DIAG extensions attribute types
+const (
+	AttrInfo = 2 // INET_DIAG_INFO, struct tcp_info for TCP sockets
+)
+
+// ExtInfo is the Request.Ext bit requesting AttrInfo
+const ExtInfo = 1 << (AttrInfo - 1)
+
+// TCP congestion avoidance states, see enum tcp_ca_state in the kernel
+const (
+	CAOpen     = 0
+	CADisorder = 1
+	CACWR      = 2
+	CARecovery = 3
+	CALoss     = 4
+)
+
+// Offsets of the struct tcp_info fields we decode. Later kernels only append
+// fields, the attribute may be shorter on older ones.
+const (
+	offTCPInfoCAState      = 1
+	offTCPInfoRetransmits  = 2
+	offTCPInfoRTT          = 68
+	offTCPInfoRTTVar       = 72
+	offTCPInfoTotalRetrans = 100
+	sizeofTCPInfoMin       = offTCPInfoTotalRetrans + 4
+)
+
+// TCPInfo is the subset of struct tcp_info cosanet uses
+type TCPInfo struct {
+	CAState     uint8
+	Retransmits uint8
+	// Smoothed RTT and its variance, in microseconds
+	RTT          uint32
+	RTTVar       uint32
+	TotalRetrans uint32
+}
+
+// ParseTCPInfo decodes the AttrInfo payload of a TCP socket
+func ParseTCPInfo(b []byte) (TCPInfo, error) {
+	if len(b) < sizeofTCPInfoMin {
+		return TCPInfo{}, ErrTruncated
+	}
+	return TCPInfo{
+		CAState:      b[offTCPInfoCAState],
+		Retransmits:  b[offTCPInfoRetransmits],
+		RTT:          binary.NativeEndian.Uint32(b[offTCPInfoRTT:]),
+		RTTVar:       binary.NativeEndian.Uint32(b[offTCPInfoRTTVar:]),
+		TotalRetrans: binary.NativeEndian.Uint32(b[offTCPInfoTotalRetrans:]),
+	}, nil
+}
@@ -3,17 +3,103 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// Log output formats
+const (
+	// Colorized output for humans, see PrettyHandler
+	logFormatText = "text"
+	// key=value pairs, as parsed by Loki and most log pipelines
+	logFormatLogfmt = "logfmt"
+	logFormatJSON   = "json"
+	// Structured entries sent to the local journald, see JournalHandler
+	logFormatJournald = "journald"
+	// logfmt entries sent to the local syslog daemon, see SyslogHandler
+	logFormatSyslog = "syslog"
+)
+
+// LogFileOptions sends the logs to a file rotated by size and age, for the
+// hosts where stdout isn't captured
+type LogFileOptions struct {
+	Path string
+	// MaxSize is the size in megabytes rotating the file
+	MaxSize int
+	// RotateEvery also rotates the file periodically, 0 disables it
+	RotateEvery time.Duration
+	// MaxAge and MaxBackups bound the rotated files kept, 0 keeps them all
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+}
+
+// newLogOutput returns the writer of the logs, stdout without log file
+func newLogOutput(opts LogFileOptions) io.Writer {
+	if opts.Path == "" {
+		return os.Stdout
+	}
+	// No terminal to colorize
+	color.NoColor = true
+	file := &lumberjack.Logger{
+		Filename: opts.Path,
+		MaxSize:  opts.MaxSize,
+		// Retention in days, rounded up
+		MaxAge:     int(math.Ceil(opts.MaxAge.Hours() / 24)),
+		MaxBackups: opts.MaxBackups,
+		LocalTime:  true,
+		Compress:   opts.Compress,
+	}
+	if opts.RotateEvery > 0 {
+		go func() {
+			for range time.Tick(opts.RotateEvery) {
+				if err := file.Rotate(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to rotate %s: %v\n", opts.Path, err)
+				}
+			}
+		}()
+	}
+	return file
+}
+
+// newLogHandler returns the handler of the log format, JSON unless another
+// one is asked. The stream formats are written to stdout or the log file.
+func newLogHandler(format string, file LogFileOptions, opts *slog.HandlerOptions) (slog.Handler, error) {
+	switch format {
+	case logFormatJournald:
+		return newJournalHandler(opts)
+	case logFormatSyslog:
+		return newSyslogHandler(opts)
+	case logFormatText:
+		return &PrettyHandler{Out: newLogOutput(file), Level: opts.Level.Level(), AddSource: opts.AddSource}, nil
+	case logFormatLogfmt:
+		// The text handler output (time=... level=INFO msg=... key=value)
+		// quotes the values as logfmt does
+		return slog.NewTextHandler(newLogOutput(file), opts), nil
+	default:
+		return slog.NewJSONHandler(newLogOutput(file), opts), nil
+	}
+}
+
 // PrettyHandler is a custom slog.Handler for colorful log output.
 type PrettyHandler struct {
-	Out   *os.File
-	Level slog.Level
+	Out       io.Writer
+	Level     slog.Level
+	AddSource bool
+	// Attributes of WithAttrs, already formatted
+	attrs []string
+	// Key prefix of the groups of WithGroup (eg: pod.)
+	prefix string
 }
 
 // Enabled enables all log levels.
@@ -45,21 +131,63 @@ func (h *PrettyHandler) Handle(_ context.Context, r slog.Record) error {
 	// Message
 	msg := r.Message
 
-	// Collect key-values into a slice
-	var attrs []string
+	// Collect key-values into a slice, after the ones of WithAttrs
+	attrs := slices.Clone(h.attrs)
 	r.Attrs(func(a slog.Attr) bool {
-		keyCol := color.CyanString(a.Key)          // colorize key
-		valCol := color.GreenString("%v", a.Value) // colorize value
-		attrs = append(attrs, fmt.Sprintf("%s=%s", keyCol, valCol))
+		attrs = appendPrettyAttr(attrs, h.prefix, a)
 		return true
 	})
 
+	// Source file and line, as dir/file.go:42
+	if h.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		source := fmt.Sprintf("%s:%d", filepath.Join(filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File)), frame.Line)
+		msg = color.HiBlackString(source) + " " + msg
+	}
+
 	// Join and print all
 	out := fmt.Sprintf("[%s] %s %-5s %s %s", ts, coloredLevel, r.Level, msg, strings.Join(attrs, " "))
 	fmt.Fprintln(h.Out, out)
 	return nil
 }
 
-// WithAttrs returns self (for slog.Handler interface).
-func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
-func (h *PrettyHandler) WithGroup(name string) slog.Handler       { return h }
+// appendPrettyAttr formats the attribute, the groups being flattened into
+// dotted keys as the text handler does
+func appendPrettyAttr(attrs []string, prefix string, a slog.Attr) []string {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendPrettyAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	keyCol := color.CyanString(prefix + a.Key) // colorize key
+	valCol := color.GreenString("%v", a.Value) // colorize value
+	return append(attrs, fmt.Sprintf("%s=%s", keyCol, valCol))
+}
+
+// WithAttrs returns a handler printing the attributes with every record.
+func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = slices.Clone(h.attrs)
+	for _, a := range attrs {
+		clone.attrs = appendPrettyAttr(clone.attrs, h.prefix, a)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler qualifying the next attributes with the group.
+func (h *PrettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
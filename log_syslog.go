@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Identifier of the journal and syslog entries
+const syslogIdentifier = "cosanet"
+
+// Socket of the journald native protocol
+const journaldSocket = "/run/systemd/journal/socket"
+
+// logPriority maps the slog levels to the syslog priorities, the levels in
+// between being rounded down
+func logPriority(level slog.Level) syslog.Priority {
+	switch {
+	case level >= slog.LevelError:
+		return syslog.LOG_ERR
+	case level >= slog.LevelWarn:
+		return syslog.LOG_WARNING
+	case level >= slog.LevelInfo:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// JournalHandler is a slog.Handler sending structured entries to the local
+// journald: the message and the priority, plus a field per attribute named
+// after its groups and key (eg: pod.name → POD_NAME).
+type JournalHandler struct {
+	conn  *net.UnixConn
+	Level slog.Level
+	// Adds the CODE_FILE, CODE_LINE and CODE_FUNC fields
+	AddSource bool
+	// Attributes of WithAttrs, already encoded
+	fields []byte
+	// Field name prefix of the groups of WithGroup (eg: POD_)
+	prefix string
+}
+
+// newJournalHandler connects to the journald socket
+func newJournalHandler(opts *slog.HandlerOptions) (*JournalHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald unavailable: %w", err)
+	}
+	return &JournalHandler{conn: conn, Level: opts.Level.Level(), AddSource: opts.AddSource}, nil
+}
+
+// Enabled tells if the level is logged.
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.Level
+}
+
+// Handle sends the record as a single datagram. Entries above the socket
+// datagram size, which journald only takes through a memfd, are rejected.
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	var entry bytes.Buffer
+	appendJournalField(&entry, "MESSAGE", r.Message)
+	appendJournalField(&entry, "PRIORITY", fmt.Sprint(int(logPriority(r.Level))))
+	appendJournalField(&entry, "SYSLOG_IDENTIFIER", syslogIdentifier)
+	if h.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		appendJournalField(&entry, "CODE_FILE", frame.File)
+		appendJournalField(&entry, "CODE_LINE", fmt.Sprint(frame.Line))
+		appendJournalField(&entry, "CODE_FUNC", frame.Function)
+	}
+	entry.Write(h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		appendJournalAttr(&entry, h.prefix, a)
+		return true
+	})
+	_, err := h.conn.Write(entry.Bytes())
+	return err
+}
+
+// WithAttrs returns a handler adding the attributes to every entry.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := bytes.NewBuffer(bytes.Clone(h.fields))
+	for _, a := range attrs {
+		appendJournalAttr(fields, h.prefix, a)
+	}
+	clone := *h
+	clone.fields = fields.Bytes()
+	return &clone
+}
+
+// WithGroup returns a handler prefixing the next attributes with the group.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + journalFieldName(name) + "_"
+	return &clone
+}
+
+// appendJournalAttr encodes the attribute, the groups being flattened
+func appendJournalAttr(entry *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += journalFieldName(a.Key) + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			appendJournalAttr(entry, prefix, ga)
+		}
+		return
+	}
+	appendJournalField(entry, prefix+journalFieldName(a.Key), a.Value.String())
+}
+
+// appendJournalField encodes a field in the journald native protocol: NAME=value
+// lines, or the length prefixed form for the multi-line values
+func appendJournalField(entry *bytes.Buffer, name, value string) {
+	entry.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		entry.WriteByte('=')
+		entry.WriteString(value)
+		entry.WriteByte('\n')
+		return
+	}
+	entry.WriteByte('\n')
+	entry.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(value))))
+	entry.WriteString(value)
+	entry.WriteByte('\n')
+}
+
+// journalFieldName converts a key to a journal field name, made of uppercase
+// letters, digits and underscores, not starting with an underscore (reserved
+// to the trusted fields) nor a digit
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	trimmed := strings.TrimLeft(string(name), "_")
+	if trimmed == "" || (trimmed[0] >= '0' && trimmed[0] <= '9') {
+		return "X_" + trimmed
+	}
+	return trimmed
+}
+
+// SyslogHandler is a slog.Handler sending logfmt formatted entries to the
+// local syslog daemon, at the priority of their level. The time is left to
+// syslog.
+type SyslogHandler struct {
+	slog.Handler
+	out *syslogOutput
+}
+
+// syslogOutput is shared by a SyslogHandler and the handlers derived from it
+type syslogOutput struct {
+	mu     sync.Mutex
+	writer *syslog.Writer
+	buf    bytes.Buffer
+}
+
+// newSyslogHandler connects to the local syslog daemon
+func newSyslogHandler(opts *slog.HandlerOptions) (*SyslogHandler, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, syslogIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("syslog unavailable: %w", err)
+	}
+	out := &syslogOutput{writer: writer}
+	text := slog.NewTextHandler(&out.buf, &slog.HandlerOptions{
+		Level:     opts.Level,
+		AddSource: opts.AddSource,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Carried by the syslog header and priority
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return &SyslogHandler{Handler: text, out: out}, nil
+}
+
+// Handle formats the record and sends it at its priority.
+func (h *SyslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.buf.Reset()
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(h.out.buf.String(), "\n")
+	switch logPriority(r.Level) {
+	case syslog.LOG_ERR:
+		return h.out.writer.Err(msg)
+	case syslog.LOG_WARNING:
+		return h.out.writer.Warning(msg)
+	case syslog.LOG_INFO:
+		return h.out.writer.Info(msg)
+	default:
+		return h.out.writer.Debug(msg)
+	}
+}
+
+// WithAttrs returns a handler adding the attributes to every entry.
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SyslogHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+// WithGroup returns a handler grouping the next attributes.
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	return &SyslogHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
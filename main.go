@@ -1,18 +1,32 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cosanet/cosanet/internal/collector"
 	"github.com/cosanet/cosanet/internal/controller_resolver"
+	"github.com/cosanet/cosanet/internal/federation"
+	"github.com/cosanet/cosanet/internal/log_dedup"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	// Offers the zstd compression of the /metrics responses
+	_ "github.com/prometheus/client_golang/prometheus/promhttp/zstd"
+	"github.com/prometheus/common/model"
+	"google.golang.org/grpc"
 )
 
 // Very long story short: we can't collect other netns stats in non locked thread
@@ -21,11 +35,28 @@ func init() {
 }
 
 type CliOpts struct {
-	LogFormat        string
-	ListenAddr       string
-	CacheDuration    time.Duration
-	Verbosity        string
-	CollectorOptions collector.CosanetCollectorOptions
+	LogFormat                 string
+	LogFile                   LogFileOptions
+	LogSource                 bool
+	LogDedupWindow            time.Duration
+	ListenAddr                string
+	CacheDuration             time.Duration
+	CacheSpread               bool
+	MetricsHandler            promhttp.HandlerOpts
+	MetricsGateUntilReady     bool
+	MetricsCompression        string
+	TextfileDirectory         string
+	TextfileInterval          time.Duration
+	Verbosity                 string
+	ControllerResolverEnabled bool
+	ControllerResolver        controller_resolver.ResolverOptions
+	Federation                federation.Options
+	ExtraLabels               string
+	NodeLabels                string
+	RelabelConfigFile         string
+	ProcExtraConfigFile       string
+	ListCollectors            bool
+	CollectorOptions          collector.CosanetCollectorOptions
 }
 
 var (
@@ -45,14 +76,110 @@ func main() {
 	flag.StringVar(
 		&opts.LogFormat,
 		"logformat",
-		"json",
-		"Log output format: json or text",
+		logFormatJSON,
+		"Log output format: json, logfmt, text (colorized, for humans), journald or syslog (to the local daemons)",
+	)
+	flag.BoolVar(
+		&opts.LogSource,
+		"log-source",
+		false,
+		"add the source file and line of the log calls",
+	)
+	flag.DurationVar(
+		&opts.LogDedupWindow,
+		"log-dedup.window",
+		30*time.Second,
+		"log the repeated warnings and errors once per window, with their count, 0 disables it",
+	)
+	flag.StringVar(
+		&opts.LogFile.Path,
+		"log-file",
+		"",
+		"write the logs to this file instead of stdout, rotated according to the log-file.* flags",
+	)
+	flag.IntVar(
+		&opts.LogFile.MaxSize,
+		"log-file.max-size",
+		100,
+		"size in megabytes rotating the log file",
+	)
+	flag.DurationVar(
+		&opts.LogFile.RotateEvery,
+		"log-file.rotate-every",
+		0,
+		"also rotate the log file periodically (e.g. 24h), 0 disables it",
+	)
+	flag.DurationVar(
+		&opts.LogFile.MaxAge,
+		"log-file.max-age",
+		7*24*time.Hour,
+		"remove the rotated log files older than this, rounded up to days, 0 keeps them",
+	)
+	flag.IntVar(
+		&opts.LogFile.MaxBackups,
+		"log-file.max-backups",
+		5,
+		"number of rotated log files kept, 0 keeps them all",
+	)
+	flag.BoolVar(
+		&opts.LogFile.Compress,
+		"log-file.compress",
+		false,
+		"gzip the rotated log files",
 	)
 	flag.StringVar(
 		&opts.ListenAddr,
 		"listen",
 		":9156",
-		"Address and port to listen on (e.g. :8080 or 0.0.0.0:9988)",
+		"Address and port to listen on (e.g. :8080 or 0.0.0.0:9988), empty to only write the textfile",
+	)
+	flag.IntVar(
+		&opts.MetricsHandler.MaxRequestsInFlight,
+		"metrics.max-requests-in-flight",
+		0,
+		"Maximum number of concurrent /metrics requests, the next ones getting a 503 (0 for no limit)",
+	)
+	flag.DurationVar(
+		&opts.MetricsHandler.Timeout,
+		"metrics.timeout",
+		0,
+		"Timeout of the /metrics requests, answered with a 503 past it (0 for no timeout)",
+	)
+	flag.BoolVar(
+		&opts.MetricsGateUntilReady,
+		"metrics.gate-until-ready",
+		false,
+		"Answer /metrics with a 503 until a collection listed the sandboxes successfully, as /ready does",
+	)
+	flag.StringVar(
+		&opts.MetricsCompression,
+		"metrics.compression",
+		"gzip,zstd",
+		"Comma separated /metrics response compressions offered to the scrapers (available: gzip, zstd), none to disable compression",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.API.Enabled,
+		"api.enabled",
+		false,
+		"Serve the metrics of every netns of the last collection as JSON on /api/v1/netns",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.API.GRPCListen,
+		"api.grpc-listen",
+		"",
+		"Address serving the netns stats gRPC API (eg: 127.0.0.1:9157 or unix:/run/cosanet/grpc.sock), empty to disable",
+	)
+	flag.StringVar(
+		&opts.TextfileDirectory,
+		"textfile.directory",
+		"",
+		"Directory to write the metrics to periodically as "+textfileName+", for the node_exporter textfile collector (empty to disable)",
+	)
+	flag.DurationVar(
+		&opts.TextfileInterval,
+		"textfile.interval",
+		15*time.Second,
+		"Interval between the writes of the textfile",
 	)
 	flag.DurationVar(
 		&opts.CacheDuration,
@@ -60,6 +187,12 @@ func main() {
 		500*time.Millisecond,
 		"Cache duration for metrics collection (e.g. 500ms, 2s, 1m)",
 	)
+	flag.BoolVar(
+		&opts.CacheSpread,
+		"cache-duration.spread",
+		false,
+		"collect the pods one at a time, spread over -cache-duration with jitter, rather than all at once when the cache expires",
+	)
 	flag.StringVar(
 		&opts.Verbosity,
 		"verbosity",
@@ -67,14 +200,403 @@ func main() {
 		"Log verbosity: debug, info, warn, error",
 	)
 
+	flag.StringVar(
+		&opts.ExtraLabels,
+		"extra-labels",
+		os.Getenv("COSANET_EXTRA_LABELS"),
+		"comma separated name=value labels added to every metric (eg: cluster=prod-eu,region=eu-west-1), defaults to $COSANET_EXTRA_LABELS",
+	)
+	flag.StringVar(
+		&opts.NodeLabels,
+		"node-labels",
+		"",
+		"comma separated labels of the Node object added to every metric as cosanet_node_label_<name>, read from the API at startup (eg: topology.kubernetes.io/zone,node.kubernetes.io/instance-type)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.ProcRoot,
+		"path.procfs",
+		"/proc",
+		"procfs mountpoint (eg: /host/proc when the host's /proc is mounted there)",
+	)
+	flag.BoolVar(
+		&opts.ControllerResolverEnabled,
+		"controller-resolver.enabled",
+		true,
+		"resolve pods' top-level controller (Deployment, StatefulSet, DaemonSet, CronJob...) through the Kubernetes API to label metrics",
+	)
+	flag.StringVar(
+		&opts.ControllerResolver.CustomOwners,
+		"controller-resolver.custom-owners",
+		"",
+		"comma separated Kind.group custom owners walked up through the dynamic client (eg: Rollout.argoproj.io,CloneSet.apps.kruise.io)",
+	)
+	flag.StringVar(
+		&opts.ControllerResolver.NamespaceInclude,
+		"controller-resolver.namespace-include",
+		"",
+		"comma separated namespaces the resolver is restricted to, watched one by one so that a Role in each is enough (empty for all)",
+	)
+	flag.StringVar(
+		&opts.ControllerResolver.NamespaceExclude,
+		"controller-resolver.namespace-exclude",
+		"",
+		"comma separated namespaces the resolver never watches nor resolves (eg: kube-system)",
+	)
+	flag.IntVar(
+		&opts.ControllerResolver.MaxOwnerDepth,
+		"controller-resolver.max-owner-depth",
+		5,
+		"maximum number of owners walked up from a pod to find its top-level controller",
+	)
+	flag.DurationVar(
+		&opts.ControllerResolver.CacheTTL,
+		"controller-resolver.cache-ttl",
+		time.Hour,
+		"lifetime of the resolved controllers, refreshed from the informer every half TTL (0 to never expire)",
+	)
+	flag.DurationVar(
+		&opts.ControllerResolver.Resync,
+		"controller-resolver.resync",
+		0,
+		"pod informer resync period, refreshing the resolved controllers (0 for half the cache TTL)",
+	)
+	flag.IntVar(
+		&opts.ControllerResolver.PodCacheCapacity,
+		"controller-resolver.pod-cache-capacity",
+		500,
+		"maximum number of pods whose controller is cached",
+	)
+	flag.IntVar(
+		&opts.ControllerResolver.ParentCacheCapacity,
+		"controller-resolver.parent-cache-capacity",
+		750,
+		"maximum number of owners (ReplicaSets, Jobs...) whose controller is cached",
+	)
+
 	// Collector settings
 
 	// Pod filtering
 	flag.StringVar(
-		&opts.CollectorOptions.PodFilter,
-		"collector.pod-filter",
-		"^.+$",
-		"filter namespace/pod based on regex (eg: ^default/.*$)",
+		&opts.CollectorOptions.PodFilter.NamespaceInclude,
+		"collector.namespace-include",
+		"",
+		"only collect pods in namespaces matching this regex (eg: ^team-.*$)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.NamespaceExclude,
+		"collector.namespace-exclude",
+		"",
+		"skip pods in namespaces matching this regex (eg: ^(kube-system|monitoring)$)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.PodInclude,
+		"collector.pod-include",
+		"",
+		"only collect pods whose name matches this regex",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.PodExclude,
+		"collector.pod-exclude",
+		"",
+		"skip pods whose name matches this regex",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.ScrapeAnnotation,
+		"collector.scrape-annotation",
+		"cosanet.io/scrape",
+		"pod annotation used to opt in or out of the collection (empty to disable)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.ScrapeAnnotationMode,
+		"collector.scrape-annotation-mode",
+		collector.ScrapeAnnotationOptOut,
+		"opt-out: collect pods unless annotated \"false\", opt-in: only collect pods annotated \"true\"",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.PodLabelSelector,
+		"collector.pod-label-selector",
+		"",
+		"only collect pods matching this Kubernetes label selector (eg: app=web,tier!=db)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodFilter.PodAnnotationSelector,
+		"collector.pod-annotation-selector",
+		"",
+		"only collect pods whose annotations match this label selector",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodLabels,
+		"collector.pod-labels",
+		"",
+		"comma separated pod labels added as cosanet_pod_label_<name> metric labels (eg: app.kubernetes.io/name,team)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.PodAnnotations,
+		"collector.pod-annotations",
+		"",
+		"comma separated pod annotations added as cosanet_pod_annotation_<name> metric labels",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.ContainerLabels,
+		"collector.container-labels",
+		false,
+		"label metrics with cosanet_container and cosanet_container_id instead of cosanet_pod and cosanet_pod_uid (docker/podman hosts outside of Kubernetes)",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.CNILabels,
+		"collector.cni-labels",
+		false,
+		"label metrics with cosanet_cni, the convention of the netns path (cni, crio, docker, podman, proc, host or other), and cosanet_netns_id, a netns identifier stable across the sandboxes of a pod",
+	)
+	flag.IntVar(
+		&opts.CollectorOptions.MaxPods,
+		"collector.max-pods",
+		0,
+		"maximum number of pods collected per scrape, 0 for unlimited",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Shard,
+		"collector.shard",
+		"",
+		"only collect the pods of the given shard, as index/count (eg: 1/3), based on pod UID hash, the host and node wide metrics being collected by the first shard",
+	)
+
+	// CRI related
+	flag.DurationVar(
+		&opts.CollectorOptions.CRI.Timeout,
+		"collector.cri.timeout",
+		2*time.Second,
+		"timeout applied to each CRI call",
+	)
+	flag.IntVar(
+		&opts.CollectorOptions.CRI.Retries,
+		"collector.cri.retries",
+		2,
+		"number of retries (exponential backoff) of a CRI call failing transiently (unavailable, deadline exceeded, resource exhausted)",
+	)
+	flag.IntVar(
+		&opts.CollectorOptions.CRI.BreakerThreshold,
+		"collector.cri.breaker-threshold",
+		3,
+		"consecutive failed sandbox listings or status calls of a runtime socket before skipping its sandboxes (0 disables the circuit breaker)",
+	)
+	flag.DurationVar(
+		&opts.CollectorOptions.CRI.BreakerCooldown,
+		"collector.cri.breaker-cooldown",
+		30*time.Second,
+		"time during which CRI based collection is skipped once the circuit breaker is open",
+	)
+	flag.Float64Var(
+		&opts.CollectorOptions.CRI.RateLimit,
+		"collector.cri.rate-limit",
+		0,
+		"maximum calls per second to the container runtime, 0 for unlimited",
+	)
+	flag.IntVar(
+		&opts.CollectorOptions.CRI.RateBurst,
+		"collector.cri.rate-burst",
+		10,
+		"calls allowed in a burst above -collector.cri.rate-limit",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.CRI.VMRuntimes,
+		"collector.cri.vm-runtimes",
+		"kata|firecracker|cloud-hypervisor",
+		"regexp of the runtime handlers and containerd runtime types running the pods in a VM (empty to disable)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.CRI.PIDJSONPath,
+		"collector.cri.pid-jsonpath",
+		"",
+		"JSONPath extracting the sandbox PID from the CRI verbose info, overriding the runtime layout (eg: {.pid})",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.CRI.NetnsJSONPath,
+		"collector.cri.netns-jsonpath",
+		"",
+		"JSONPath extracting the sandbox netns path from the CRI verbose info, overriding the runtime layout",
+	)
+
+	// Deployment mode related
+	flag.StringVar(
+		&opts.CollectorOptions.Mode,
+		"mode",
+		collector.ModeDaemonSet,
+		"daemonset (collect every pod of the node, needs hostPID and privileges), sidecar (only collect the network namespace of the pod cosanet runs in, identified by the POD_NAME, POD_NAMESPACE and POD_UID environment variables) or federation (scrape the cosanet pods of every node and serve their merged metrics, see the federation.* flags)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Sidecar.LabelsFile,
+		"sidecar.labels-file",
+		"",
+		"downward API file of the pod labels in sidecar mode (eg: /etc/podinfo/labels), empty for none",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Sidecar.AnnotationsFile,
+		"sidecar.annotations-file",
+		"",
+		"downward API file of the pod annotations in sidecar mode (eg: /etc/podinfo/annotations), empty for none",
+	)
+
+	// Federation related
+	flag.StringVar(
+		&opts.Federation.Service,
+		"federation.service",
+		"cosanet",
+		"service of the cosanet pods in federation mode, as name or namespace/name, the namespace defaulting to POD_NAMESPACE",
+	)
+	flag.StringVar(
+		&opts.Federation.Port,
+		"federation.port",
+		"metrics",
+		"name of the service port serving the metrics of the cosanet pods, empty for the first one",
+	)
+	flag.DurationVar(
+		&opts.Federation.Timeout,
+		"federation.timeout",
+		5*time.Second,
+		"timeout of the scrape of a cosanet pod in federation mode",
+	)
+	flag.StringVar(
+		&opts.Federation.Aggregation,
+		"federation.aggregation",
+		federation.AggregationNone,
+		"none, namespace or controller: sum the pod metrics of every node per namespace or pod controller, dropping the pod and node labels",
+	)
+
+	// Discovery related
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Mode,
+		"discovery.mode",
+		collector.DiscoveryModeCRI,
+		"pod discovery backend: cri (container runtime socket), kubelet (kubelet API, when the CRI socket can't be mounted) or containerd (plain containerd containers)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Kubelet.URL,
+		"discovery.kubelet.url",
+		"https://127.0.0.1:10250",
+		"kubelet API URL used by the kubelet discovery (the read-only port is http://127.0.0.1:10255)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Kubelet.TokenFile,
+		"discovery.kubelet.token-file",
+		"/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"bearer token sent to the kubelet, empty for none",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Kubelet.CAFile,
+		"discovery.kubelet.ca-file",
+		"",
+		"CA bundle verifying the kubelet serving certificate, empty for the system roots",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Discovery.Kubelet.InsecureSkipVerify,
+		"discovery.kubelet.insecure-skip-verify",
+		false,
+		"skip the kubelet serving certificate verification (self-signed kubelet certificates)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Containerd.Socket,
+		"discovery.containerd.socket",
+		"/run/containerd/containerd.sock",
+		"containerd API socket used by the containerd discovery",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Discovery.Containerd.Namespaces,
+		"discovery.containerd.namespaces",
+		"",
+		"comma separated containerd namespaces collected by the containerd discovery (empty for all but k8s.io)",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Discovery.Netns,
+		"discovery.netns",
+		false,
+		"when no container runtime socket is found, collect the named network namespaces of /var/run/netns",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Discovery.ProcScan,
+		"discovery.netns.proc-scan",
+		false,
+		"with -discovery.netns, also collect the network namespaces of the host processes",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Discovery.NamedNetns,
+		"discovery.named-netns",
+		false,
+		"besides the pods, collect the named network namespaces of /var/run/netns no pod lives in (eg: VRFs), labelled with cosanet_netnsname only",
+	)
+
+	flag.StringVar(
+		&opts.CollectorOptions.MetricPrefix,
+		"metric-prefix",
+		collector.DefaultMetricPrefix,
+		"namespace of the metric names, replacing cosanet (eg: mycompany_net gives mycompany_net_proc_net_snmp_Tcp_CurrEstab)",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.LabelPrefix,
+		"metric-prefix.labels",
+		false,
+		"also replace the cosanet prefix of the label names by -metric-prefix (eg: mycompany_net_pod)",
+	)
+
+	flag.StringVar(
+		&opts.CollectorOptions.MetricNames,
+		"collector.metric-names",
+		collector.MetricNamesLegacy,
+		"naming scheme of the metrics and labels: legacy (kernel counter names as is, eg: cosanet_proc_net_snmp_Tcp_CurrEstab) or snake_case (eg: cosanet_proc_net_snmp_tcp_curr_estab)",
+	)
+
+	flag.StringVar(
+		&opts.CollectorOptions.LabelNames,
+		"collector.label-names",
+		"",
+		"comma separated key=name renames of the node, pod, namespace and netnsname labels (eg: node=node,namespace=namespace)",
+	)
+
+	flag.StringVar(
+		&opts.CollectorOptions.HostLabels,
+		"collector.host-labels",
+		collector.HostLabelsSentinel,
+		"namespace and netnsname label values of the host entry: sentinel (HOST) or empty",
+	)
+
+	flag.StringVar(
+		&opts.RelabelConfigFile,
+		"collector.relabel-config",
+		"",
+		"path to a YAML file of rename/drop_label/map_value rules applied to the metrics before emission",
+	)
+
+	flag.StringVar(
+		&opts.CollectorOptions.NamespaceAggregation.Metrics,
+		"collector.namespace-aggregation.metrics",
+		"",
+		"anchored regex of the metric families summed per Kubernetes namespace, without the pod labels (eg: cosanet_proc_net_(tcp|udp))",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.ControllerAggregation.Metrics,
+		"collector.controller-aggregation.metrics",
+		"",
+		"anchored regex of the metric families also summed per pod controller in <family>_by_controller, requires the controller resolver",
+	)
+
+	flag.DurationVar(
+		&opts.CollectorOptions.CollectorTimeout,
+		"collector.timeout",
+		0,
+		"timeout of the conntrack, sockproto, snmp and netstat collectors in each netns, the ones timing out reported by cosanet_collector_success (0 for no timeout)",
+	)
+	flag.DurationVar(
+		&opts.CollectorOptions.Watchdog.Deadline,
+		"collector.watchdog.deadline",
+		0,
+		"abandon the netns of a collection making no progress for this long, and exit once stuck for twice the deadline (0 for no watchdog)",
+	)
+
+	flag.StringVar(
+		&opts.ProcExtraConfigFile,
+		"collector.proc-extra-config",
+		"",
+		"path to a YAML file of additional two-line or key-value procfs files collected in every netns as cosanet_proc_extra_*",
 	)
 
 	// Host related
@@ -85,57 +607,226 @@ func main() {
 		"collect host metrics",
 	)
 
-	// Conntrack related
+	// Collectors
+	collector.RegisterCollectorFlags(flag.CommandLine, &opts.CollectorOptions)
+	flag.StringVar(
+		&opts.CollectorOptions.Collectors,
+		"collectors",
+		"",
+		"comma separated list of the enabled collectors, overriding their collector.<name>.enabled flags (see -list-collectors)",
+	)
 	flag.BoolVar(
-		&opts.CollectorOptions.Conntrack.Enabled,
-		"collector.connstrack.enabled",
-		true,
-		"enable conntack stats (curr and max) collection",
+		&opts.ListCollectors,
+		"list-collectors",
+		false,
+		"list the collectors, whether they are enabled and their description, then exit",
 	)
 
-	// SNMP related
+	// Conntrack related
+	flag.StringVar(
+		&opts.CollectorOptions.Conntrack.Backend,
+		"collector.conntrack.backend",
+		collector.ConntrackBackendNetlink,
+		"conntrack counters source: netlink (falls back to procfs when it fails, e.g. without CAP_NET_ADMIN) or procfs (no table dumps)",
+	)
 	flag.BoolVar(
-		&opts.CollectorOptions.Snmp.Enabled,
-		"collector.snmp.enabled",
-		true,
-		"enable /proc/net/snmp and snmp6 collection",
+		&opts.CollectorOptions.Conntrack.Stats,
+		"collector.conntrack.stats.enabled",
+		false,
+		"enable conntrack statistics (found, insert_failed, drop, early_drop...) collection, summed over CPUs",
 	)
-	flag.StringVar(
-		&opts.CollectorOptions.Snmp.MetricInclude,
-		"collector.snmp.metric-include",
-		"^(Tcp_((Act|Pass)iveOpens|CurrEstab)|Ip6_(In|Out)Octets|Udp6?_(In|Out)Datagrams)$",
-		"filter snmp metrics using regex tested against proto_metric",
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Breakdown,
+		"collector.conntrack.breakdown.enabled",
+		false,
+		"dump the conntrack table to count entries per l4 protocol and TCP state, costly on large tables",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Zones,
+		"collector.conntrack.zones.enabled",
+		false,
+		"dump the host conntrack table to count entries per conntrack zone, costly on large tables",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Accounting.Enabled,
+		"collector.conntrack.accounting.enabled",
+		false,
+		"dump the conntrack table to sum the bytes and packets of the flows, requires nf_conntrack_acct",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Accounting.SplitDirection,
+		"collector.conntrack.accounting.split-direction",
+		false,
+		"split the conntrack bytes and packets between the orig and reply directions",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Events,
+		"collector.conntrack.events.enabled",
+		false,
+		"listen to conntrack events in every netns to count created and destroyed flows",
+	)
+	flag.Float64Var(
+		&opts.CollectorOptions.Conntrack.Saturation.Threshold,
+		"collector.conntrack.saturation.threshold",
+		0,
+		"conntrack fill ratio (eg: 0.8) above which cosanet_conntrack_saturation is set and a warning logged, 0 disables",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Conntrack.Saturation.KubernetesEvents,
+		"collector.conntrack.saturation.events",
+		false,
+		"also create a Warning Event on pods whose conntrack table saturates (requires create on events)",
 	)
 
-	// Netstat related
+	// SNMP related
+	opts.CollectorOptions.Snmp.MetricInclude = []string{
+		"^Tcp_((Act|Pass)iveOpens|CurrEstab)$",
+		"^Ip6_(In|Out)Octets$",
+		"^Udp6?_(In|Out)Datagrams$",
+	}
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Snmp.MetricInclude},
+		"collector.snmp.metric-include",
+		"regex of the snmp metrics to collect, tested against proto_metric, repeatable (any of them matching)",
+	)
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Snmp.MetricExclude},
+		"collector.snmp.metric-exclude",
+		"regex of the snmp metrics not to collect, tested against proto_metric, repeatable",
+	)
 	flag.BoolVar(
-		&opts.CollectorOptions.Netstat.Enabled,
-		"collector.netstat.enabled",
+		&opts.CollectorOptions.Snmp.IcmpMsg,
+		"collector.snmp.icmpmsg",
 		true,
-		"enable /proc/net/netstat collection",
+		"emit the IcmpMsg columns of /proc/net/snmp as cosanet_icmp_messages_total per direction and ICMP type, whatever the metric include",
 	)
-	flag.StringVar(
-		&opts.CollectorOptions.Netstat.MetricInclude,
+
+	// Netstat related
+	opts.CollectorOptions.Netstat.MetricInclude = []string{"^IpExt_(In|Out)Octets$"}
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Netstat.MetricInclude},
 		"collector.netstat.metric-include",
-		"^IpExt_(In|Out)Octets$",
-		"filter netstat metrics using regex tested against proto_metric",
+		"regex of the netstat metrics to collect, tested against proto_metric, repeatable (any of them matching)",
+	)
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Netstat.MetricExclude},
+		"collector.netstat.metric-exclude",
+		"regex of the netstat metrics not to collect, tested against proto_metric, repeatable",
 	)
 
-	// Socket Protocol related
+	// Xfrm related
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Xfrm.MetricInclude},
+		"collector.xfrm.metric-include",
+		"regex of the xfrm_stat metrics to collect, tested against Xfrm_metric (eg: ^Xfrm_(In|Out)Error$), repeatable (all of them when none)",
+	)
+	flag.Var(
+		&repeatableFlag{values: &opts.CollectorOptions.Xfrm.MetricExclude},
+		"collector.xfrm.metric-exclude",
+		"regex of the xfrm_stat metrics not to collect, tested against Xfrm_metric, repeatable",
+	)
+
+	// Netdev related
+	flag.StringVar(
+		&opts.CollectorOptions.Netdev.DeviceInclude,
+		"collector.netdev.device-include",
+		"",
+		"only collect interfaces whose name matches this regex (netdev, link, qdisc, ethtool, bridge and multicast collectors)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Netdev.DeviceExclude,
+		"collector.netdev.device-exclude",
+		"^lo$",
+		"skip interfaces whose name matches this regex (netdev, link, qdisc, ethtool, bridge and multicast collectors)",
+	)
+	flag.BoolVar(
+		&opts.CollectorOptions.Netdev.NetworkAttachment,
+		"collector.netdev.network-attachment",
+		false,
+		"add a cosanet_network label to the per interface metrics, holding the network attachment of the interface from the Multus network-status pod annotation",
+	)
 	flag.BoolVar(
-		&opts.CollectorOptions.SockProto.Enabled,
-		"collector.sockproto.enabled",
+		&opts.CollectorOptions.Ethtool.Pods,
+		"collector.ethtool.pods",
 		false,
-		"enable per socket protocol states stats (/proc/net/{tcp,udp,icmp,udplite,raw}{,6}) (default false)",
+		"also collect ethtool stats of the pods interfaces (pod side veths)",
 	)
+	flag.StringVar(
+		&opts.CollectorOptions.Ethtool.StatInclude,
+		"collector.ethtool.stat-include",
+		"(drop|discard|miss|fifo|err)",
+		"filter ethtool driver stats using regex tested against their name",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.Sysctl.Names,
+		"collector.sysctl.names",
+		"net.core.somaxconn,net.ipv4.tcp_max_syn_backlog,net.ipv4.ip_local_port_range,net.ipv4.tcp_mem,net.netfilter.nf_conntrack_buckets",
+		"comma separated list of net sysctls to expose",
+	)
+
+	// Socket Protocol related
 	flag.StringVar(
 		&opts.CollectorOptions.SockProto.Protos,
 		"collector.sockproto.protos",
 		"tcp,udp",
 		"socket protocol list to collect (comma separated, available: tcp, udp, icmp, udplite and raw)",
 	)
+	flag.StringVar(
+		&opts.CollectorOptions.SockProto.Backend,
+		"collector.sockproto.backend",
+		collector.SockProtoBackendNetlink,
+		"socket states source: netlink (INET_DIAG, falls back to procfs when unsupported) or procfs",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.SockProto.Families,
+		"collector.sockproto.families",
+		"ipv4,ipv6",
+		"IP families to collect socket states for (comma separated, available: ipv4 and ipv6)",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.SockProto.Ports,
+		"collector.sockproto.ports",
+		"",
+		"local ports to count the tcp, udp and udplite sockets per state of (comma separated, eg: 8080,5432)",
+	)
 
-	flag.Parse()
+	// Other socket families related
+	flag.BoolVar(
+		&opts.CollectorOptions.Packet.Details,
+		"collector.packet.details",
+		false,
+		"expose an info metric per AF_PACKET socket with its interface index and inode",
+	)
+
+	// TCP info related
+	flag.StringVar(
+		&opts.CollectorOptions.TCPInfo.RTTBuckets,
+		"collector.tcpinfo.rtt-buckets",
+		"0.0005,0.001,0.0025,0.005,0.01,0.025,0.05,0.1,0.25,0.5,1",
+		"comma separated upper bounds, in seconds, of the TCP RTT histogram",
+	)
+	flag.StringVar(
+		&opts.CollectorOptions.SkMem.Protos,
+		"collector.skmem.protos",
+		"tcp,udp",
+		"socket protocols to dump the memory counters of, comma separated (available: tcp, udp, udplite and raw)",
+	)
+
+	// cosanet selftest [flags] runs the enabled collectors once and reports
+	selftest := len(os.Args) > 1 && os.Args[1] == "selftest"
+	if selftest {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	if opts.ListCollectors {
+		if err := collector.ListCollectors(os.Stdout, opts.CollectorOptions); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	var logLevel slog.Level
 	switch opts.Verbosity {
@@ -149,12 +840,20 @@ func main() {
 		logLevel = slog.LevelInfo
 	}
 
-	if opts.LogFormat == "text" {
-		handler := &PrettyHandler{Out: os.Stdout, Level: logLevel}
-		logger = slog.New(handler)
-	} else {
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+	logHandler, err := newLogHandler(opts.LogFormat, opts.LogFile, &slog.HandlerOptions{
+		Level:     logLevel,
+		AddSource: opts.LogSource,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up the %s logs: %v\n", opts.LogFormat, err)
+		os.Exit(1)
+	}
+	if opts.LogDedupWindow > 0 {
+		dedup := log_dedup.New(logHandler, slog.LevelWarn, opts.LogDedupWindow)
+		opts.CollectorOptions.LogDedup = dedup
+		logHandler = dedup
 	}
+	logger = slog.New(logHandler)
 
 	slog.SetDefault(logger)
 	slog.Info(
@@ -166,6 +865,29 @@ func main() {
 		slog.String("project_url", ProjectURL),
 	)
 
+	if !selftest && opts.ListenAddr == "" && opts.TextfileDirectory == "" {
+		slog.Error("Nothing to export to, set -listen or -textfile.directory")
+		os.Exit(1)
+	}
+
+	if opts.RelabelConfigFile != "" {
+		rules, err := collector.LoadRelabelConfig(opts.RelabelConfigFile)
+		if err != nil {
+			slog.Error("Failed to load relabel config", slog.Any("err", err))
+			os.Exit(1)
+		}
+		opts.CollectorOptions.Relabel = rules
+	}
+
+	if opts.ProcExtraConfigFile != "" {
+		files, err := collector.LoadProcExtraConfig(opts.ProcExtraConfigFile)
+		if err != nil {
+			slog.Error("Failed to load proc extra config", slog.Any("err", err))
+			os.Exit(1)
+		}
+		opts.CollectorOptions.ProcExtra = files
+	}
+
 	nodename := os.Getenv("NODE_NAME")
 	if nodename == "" {
 		var err error
@@ -175,12 +897,41 @@ func main() {
 		}
 	}
 	slog.Info("Nodename", slog.String("hostname", nodename))
+	opts.CollectorOptions.Sidecar.PodName = os.Getenv("POD_NAME")
+	opts.CollectorOptions.Sidecar.PodNamespace = os.Getenv("POD_NAMESPACE")
+	opts.CollectorOptions.Sidecar.PodUID = os.Getenv("POD_UID")
 
-	controller_resolver := controller_resolver.NewResolver(
-		&controller_resolver.ResolverOptions{
-			Nodename: nodename,
-		},
-	)
+	if opts.CollectorOptions.Mode == federation.Mode {
+		runFederation(opts)
+		return
+	}
+
+	var resolver controller_resolver.PodControllerResolver
+	if opts.ControllerResolverEnabled {
+		opts.ControllerResolver.Nodename = nodename
+		var err error
+		resolver, err = controller_resolver.NewResolver(&opts.ControllerResolver)
+		if err != nil {
+			slog.Error("Failed to create the controller resolver", slog.Any("err", err))
+			os.Exit(1)
+		}
+	} else {
+		slog.Info("controller resolver disabled, controller labels will be left empty")
+		if opts.CollectorOptions.ControllerAggregation.Metrics != "" {
+			slog.Warn("controller resolver disabled, -collector.controller-aggregation.metrics won't emit any series")
+		}
+		resolver = controller_resolver.NewNoopResolver()
+	}
+
+	// Stop watching the apiserver on termination
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		slog.Info("cosanet stopping", slog.String("signal", sig.String()))
+		resolver.Shutdown()
+		os.Exit(0)
+	}()
 
 	// Part of the kludge to perform the collection on main thread (see bellow)
 	collectRequestChan := make(chan collector.CollectRequest)
@@ -188,49 +939,106 @@ func main() {
 		nodename,
 		collectRequestChan,
 		opts.CollectorOptions,
-		&controller_resolver,
+		&resolver,
 	)
+	if selftest {
+		if !collector.Selftest(os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-	prometheus.MustRegister(collector)
-
-	http.Handle("/metrics", promhttp.Handler())
+	extraLabels, err := parseExtraLabels(opts.ExtraLabels)
+	if err != nil {
+		slog.Error("Invalid extra labels", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if err := addNodeLabels(extraLabels, nodename, opts.NodeLabels, collector.NodeLabelName); err != nil {
+		slog.Error("Failed to get the node labels", slog.Any("err", err))
+		os.Exit(1)
+	}
+	prometheus.WrapRegistererWith(extraLabels, prometheus.DefaultRegisterer).MustRegister(collector)
 
-	http.HandleFunc("/", indexHandler)
-	go func() {
-		slog.Info("Exporter running", slog.String("address", opts.ListenAddr+"/metrics"))
-		err := http.ListenAndServe(opts.ListenAddr, nil)
+	compressions, err := parseCompressions(opts.MetricsCompression)
+	if err != nil {
+		slog.Error("Invalid metrics compression", slog.Any("err", err))
+		os.Exit(1)
+	}
+	opts.MetricsHandler.OfferedCompressions = compressions
+	opts.MetricsHandler.DisableCompression = len(compressions) == 0
+	// As promhttp.Handler(), with the handler options
+	metricsHandler := promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, opts.MetricsHandler),
+	)
+	metricsHandler = podMetricsHandler(collector, extraLabels, opts.MetricsHandler, metricsHandler)
+	if opts.MetricsGateUntilReady {
+		metricsHandler = collector.GateUntilReady(metricsHandler)
+	}
+	http.Handle("/metrics", metricsHandler)
+	http.Handle("/ready", collector.ReadyHandler())
+	http.Handle("/debug/pods", collector.DebugPodsHandler())
+	if opts.CollectorOptions.API.Enabled {
+		http.Handle("/api/v1/netns", collector.NetnsAPIHandler())
+	}
+	if opts.CollectorOptions.API.GRPCListen != "" {
+		listener, err := listenGRPC(opts.CollectorOptions.API.GRPCListen)
 		if err != nil {
-			slog.Error("Exporter failed", slog.Any("err", err))
+			slog.Error("Failed to listen for the gRPC API", slog.Any("err", err))
 			os.Exit(1)
 		}
-	}()
+		grpcServer := grpc.NewServer()
+		collector.RegisterNetnsStatsServer(grpcServer)
+		go func() {
+			slog.Info("gRPC API running", slog.String("address", opts.CollectorOptions.API.GRPCListen))
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("gRPC API failed", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
+	http.HandleFunc("/", indexHandler)
+	if opts.ListenAddr != "" {
+		go func() {
+			slog.Info("Exporter running", slog.String("address", opts.ListenAddr+"/metrics"))
+			err := http.ListenAndServe(opts.ListenAddr, nil)
+			if err != nil {
+				slog.Error("Exporter failed", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if opts.TextfileDirectory != "" {
+		// cosanet metrics only, the go_* and process_* ones being node_exporter's
+		textfileRegistry := prometheus.NewRegistry()
+		prometheus.WrapRegistererWith(extraLabels, textfileRegistry).MustRegister(collector)
+		slog.Info(
+			"Writing the textfile",
+			slog.String("directory", opts.TextfileDirectory),
+			slog.Duration("interval", opts.TextfileInterval),
+		)
+		go runTextfileWriter(opts.TextfileDirectory, opts.TextfileInterval, textfileRegistry)
+	}
+
+	if opts.CacheSpread {
+		runSpreadCollection(collector, collectRequestChan, opts.CacheDuration)
+		return
+	}
 
 	var cacheTimestamp time.Time
 	var metricsCache []prometheus.Metric
 
 	for collectRequest := range collectRequestChan {
+		if collectRequest.Pod != "" {
+			collector.CollectPodFromMainThread(collectRequest.Pod, collectRequest.Feed)
+			collectRequest.Done <- true
+			continue
+		}
 		if time.Since(cacheTimestamp) > opts.CacheDuration || len(metricsCache) == 0 {
 			metricsChan := make(chan prometheus.Metric)
-			metricTemp := []prometheus.Metric{
-				prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"cosanet_build_info",
-						"A metric with a constant '1' value labeled by version, revision, build_date, builder and project_url from which cosanet was built.",
-						[]string{"version", "revision", "build_date", "builder", "project_url", "goarch", "goos", "goversion"},
-						nil,
-					),
-					prometheus.UntypedValue,
-					1,
-					Version,
-					CommitHash,
-					BuildTimestamp,
-					Builder,
-					ProjectURL,
-					runtime.GOARCH,
-					runtime.GOOS,
-					runtime.Version(),
-				),
-			}
+			metricTemp := []prometheus.Metric{buildInfoMetric(collector.MetricPrefix())}
 			go func() {
 				for m := range metricsChan {
 					metricTemp = append(metricTemp, m)
@@ -249,6 +1057,184 @@ func main() {
 
 }
 
+// runSpreadCollection serves the collect requests off the spread collection,
+// running its steps in between on the main thread
+func runSpreadCollection(c *collector.CosanetCollector, requests <-chan collector.CollectRequest, window time.Duration) {
+	spread := c.NewSpreadCollection(window)
+	buildInfo := buildInfoMetric(c.MetricPrefix())
+	step := time.NewTimer(0)
+	for {
+		select {
+		case collectRequest := <-requests:
+			if collectRequest.Pod != "" {
+				c.CollectPodFromMainThread(collectRequest.Pod, collectRequest.Feed)
+			} else {
+				collectRequest.Feed <- buildInfo
+				spread.CollectFromMainThread(collectRequest.Feed)
+			}
+			collectRequest.Done <- true
+		case <-step.C:
+			step.Reset(spread.StepFromMainThread())
+		}
+	}
+}
+
+func buildInfoMetric(prefix string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			prefix+"_build_info",
+			"A metric with a constant '1' value labeled by version, revision, build_date, builder and project_url from which cosanet was built.",
+			[]string{"version", "revision", "build_date", "builder", "project_url", "goarch", "goos", "goversion"},
+			nil,
+		),
+		prometheus.UntypedValue,
+		1,
+		Version,
+		CommitHash,
+		BuildTimestamp,
+		Builder,
+		ProjectURL,
+		runtime.GOARCH,
+		runtime.GOOS,
+		runtime.Version(),
+	)
+}
+
+// listenGRPC listens on a host:port address, or on the unix socket of a
+// unix:/path one, replacing the socket left by a previous run
+func listenGRPC(address string) (net.Listener, error) {
+	path, found := strings.CutPrefix(address, "unix:")
+	if !found {
+		return net.Listen("tcp", address)
+	}
+	path = strings.TrimPrefix(path, "//")
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// repeatableFlag appends the values of a flag given several times, the first
+// one replacing the default values
+type repeatableFlag struct {
+	values *[]string
+	set    bool
+}
+
+func (f *repeatableFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return strings.Join(*f.values, " ")
+}
+
+func (f *repeatableFlag) Set(value string) error {
+	if !f.set {
+		*f.values = nil
+		f.set = true
+	}
+	*f.values = append(*f.values, value)
+	return nil
+}
+
+// parseExtraLabels parses a comma separated list of name=value labels
+func parseExtraLabels(s string) (prometheus.Labels, error) {
+	labels := prometheus.Labels{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("malformed label %q, expected name=value", pair)
+		}
+		if !model.LabelName(name).IsValidLegacy() {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// addNodeLabels adds the labels of the node listed in keys, comma separated,
+// to labels, named by labelName
+func addNodeLabels(labels prometheus.Labels, nodename, keys string, labelName func(key string) string) error {
+	var names []string
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			names = append(names, key)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	nodeLabels, err := controller_resolver.FetchNodeLabels(nodename, names)
+	if err != nil {
+		return err
+	}
+	for key, value := range nodeLabels {
+		name := labelName(key)
+		if _, found := labels[name]; found {
+			return fmt.Errorf("node label %s already set by -extra-labels", name)
+		}
+		labels[name] = value
+	}
+	slog.Info("Node labels", slog.Any("labels", nodeLabels))
+	return nil
+}
+
+// podMetricsHandler serves /metrics?pod=namespace/name, collecting that pod
+// only, off the cache. The other requests go to next.
+func podMetricsHandler(
+	c *collector.CosanetCollector,
+	extraLabels prometheus.Labels,
+	opts promhttp.HandlerOpts,
+	next http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pod := r.URL.Query().Get("pod")
+		if pod == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if namespace, name, ok := strings.Cut(pod, "/"); !ok || namespace == "" || name == "" {
+			http.Error(w, "pod must be namespace/name", http.StatusBadRequest)
+			return
+		}
+		registry := prometheus.NewRegistry()
+		prometheus.WrapRegistererWith(extraLabels, registry).MustRegister(c.PodCollector(pod))
+		// Gathered first to tell a pod not running on the node
+		families, err := registry.Gather()
+		if err == nil && len(families) == 0 {
+			http.Error(w, "pod "+pod+" not found on the node", http.StatusNotFound)
+			return
+		}
+		gathered := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, err })
+		promhttp.HandlerFor(gathered, opts).ServeHTTP(w, r)
+	})
+}
+
+// parseCompressions parses a comma separated list of response compressions,
+// none for no compression
+func parseCompressions(s string) ([]promhttp.Compression, error) {
+	var compressions []promhttp.Compression
+	for _, name := range strings.Split(s, ",") {
+		switch compression := promhttp.Compression(strings.TrimSpace(name)); compression {
+		case "", "none":
+		case promhttp.Gzip, promhttp.Zstd:
+			compressions = append(compressions, compression)
+		default:
+			return nil, fmt.Errorf("unknown compression %q", name)
+		}
+	}
+	if len(compressions) > 0 {
+		// Still answering the scrapers without compression support
+		compressions = append([]promhttp.Compression{promhttp.Identity}, compressions...)
+	}
+	return compressions, nil
+}
+
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write([]byte(`<html>
@@ -260,6 +1246,8 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	<p>Built on: ` + BuildTimestamp + `</p>
 	<p>Project URL: ` + ProjectURL + `</p>
 	<p><a href="/metrics">Metrics</a></p>
+	<p><a href="/debug/pods">Discovered pods</a></p>
+	<p><a href="/ready">Readiness</a></p>
 </body>
 </html>` + "\n"))
 }
@@ -0,0 +1,5 @@
+// Package netnspb is the gRPC API streaming the metrics of the network
+// namespaces collected by cosanet, per pod, to the other node agents.
+package netnspb
+
+//go:generate protoc --proto_path=../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative pkg/netnspb/netns.proto
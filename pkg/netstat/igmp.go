@@ -0,0 +1,85 @@
+package netstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+const (
+	pathIGMPTab  = "net/igmp"
+	pathIGMP6Tab = "net/igmp6"
+)
+
+// ParseIGMPTab counts the IPv4 groups of /proc/net/igmp, where every device
+// line is followed by one tab indented line per joined group
+func ParseIGMPTab(r io.Reader) (map[string]int, error) {
+	br := bufio.NewScanner(r)
+	groups := make(map[string]int)
+
+	// Discard title
+	br.Scan()
+
+	device := ""
+	for br.Scan() {
+		line := br.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			if device == "" {
+				return nil, fmt.Errorf("netstat: igmp group without device: %v", fields)
+			}
+			groups[device]++
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+		device = fields[1]
+		if _, found := groups[device]; !found {
+			groups[device] = 0
+		}
+	}
+	return groups, br.Err()
+}
+
+// ParseIGMP6Tab counts the IPv6 groups of /proc/net/igmp6, one line per group
+func ParseIGMP6Tab(r io.Reader) (map[string]int, error) {
+	br := bufio.NewScanner(r)
+	groups := make(map[string]int)
+
+	for br.Scan() {
+		fields := strings.Fields(br.Text())
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+		groups[fields[1]]++
+	}
+	return groups, br.Err()
+}
+
+// IGMPGroupsFromProcfs counts the joined IPv4 multicast groups per device
+func IGMPGroupsFromProcfs(procRoot string) (map[string]int, error) {
+	file, err := os.Open(procnet_fields.ProcPath(procRoot, pathIGMPTab))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseIGMPTab(file)
+}
+
+// IGMP6GroupsFromProcfs counts the joined IPv6 multicast groups per device
+func IGMP6GroupsFromProcfs(procRoot string) (map[string]int, error) {
+	file, err := os.Open(procnet_fields.ProcPath(procRoot, pathIGMP6Tab))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseIGMP6Tab(file)
+}
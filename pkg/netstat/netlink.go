@@ -0,0 +1,78 @@
+package netstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+const pathNetlinkTab = "net/netlink"
+
+// Netlink protocols, see include/uapi/linux/netlink.h
+var netlinkProtocols = map[uint64]string{
+	0:  "route",
+	2:  "usersock",
+	3:  "firewall",
+	4:  "sock_diag",
+	5:  "nflog",
+	6:  "xfrm",
+	7:  "selinux",
+	8:  "iscsi",
+	9:  "audit",
+	10: "fib_lookup",
+	11: "connector",
+	12: "netfilter",
+	13: "ip6_fw",
+	14: "dnrtmsg",
+	15: "kobject_uevent",
+	16: "generic",
+	18: "scsitransport",
+	19: "ecryptfs",
+	20: "rdma",
+	21: "crypto",
+	22: "smc",
+}
+
+func netlinkProtocolName(proto uint64) string {
+	if name, found := netlinkProtocols[proto]; found {
+		return name
+	}
+	return strconv.FormatUint(proto, 10)
+}
+
+// ParseNetlinkTab counts the sockets of /proc/net/netlink per protocol
+func ParseNetlinkTab(r io.Reader) (map[string]int, error) {
+	br := bufio.NewScanner(r)
+	stats := make(map[string]int)
+
+	// Discard title
+	br.Scan()
+
+	for br.Scan() {
+		fields := strings.Fields(br.Text())
+		if len(fields) < 10 {
+			return nil, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+		proto, err := strconv.ParseUint(fields[1], 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		stats[netlinkProtocolName(proto)]++
+	}
+	return stats, br.Err()
+}
+
+// NetlinkStatsFromProcfs counts the netlink sockets of /proc/net/netlink per protocol
+func NetlinkStatsFromProcfs(procRoot string) (map[string]int, error) {
+	file, err := os.Open(procnet_fields.ProcPath(procRoot, pathNetlinkTab))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseNetlinkTab(file)
+}
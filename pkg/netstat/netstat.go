@@ -0,0 +1,394 @@
+// Package netstat counts the sockets of a network namespace, from the
+// /proc/net tables or through sock_diag netlink requests.
+//
+// The *FromProcfs and *Stats functions read the tables under procRoot, the
+// procfs mount point ("/proc" when empty), the Parse* functions parsing an
+// already opened table.
+package netstat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+	"github.com/cosanet/cosanet/internal/sockdiag"
+	"golang.org/x/sys/unix"
+)
+
+// Very very very very VERY inspired for the marvelous work of cakturk
+
+// Paths relative to the procfs root
+const (
+	pathTCPTab      = "net/tcp"
+	pathTCP6Tab     = "net/tcp6"
+	pathUDPTab      = "net/udp"
+	pathUDP6Tab     = "net/udp6"
+	pathICMPTab     = "net/icmp"
+	pathICMP6Tab    = "net/icmp6"
+	pathUDPLiteTab  = "net/udplite"
+	pathUDPLite6Tab = "net/udplite6"
+	pathRAWTab      = "net/raw"
+	pathRAW6Tab     = "net/raw6"
+)
+
+// Very very very very VERY inspired for the marvelous work of cakturk
+// Socket states
+const (
+	Established SkState = 0x01
+	SynSent             = 0x02
+	SynRecv             = 0x03
+	FinWait1            = 0x04
+	FinWait2            = 0x05
+	TimeWait            = 0x06
+	Close               = 0x07
+	CloseWait           = 0x08
+	LastAck             = 0x09
+	Listen              = 0x0a
+	Closing             = 0x0b
+)
+
+// Very very very very VERY inspired for the marvelous work of cakturk
+var skStates = [...]string{
+	"UNKNOWN",
+	"ESTABLISHED",
+	"SYN_SENT",
+	"SYN_RECV",
+	"FIN_WAIT1",
+	"FIN_WAIT2",
+	"TIME_WAIT",
+	"CLOSE", // CLOSE
+	"CLOSE_WAIT",
+	"LAST_ACK",
+	"LISTEN",
+	"CLOSING",
+}
+
+// Very very very very VERY inspired for the marvelous work of cakturk
+// Errors returned by gonetstat
+var (
+	ErrNotEnoughFields = errors.New("gonetstat: not enough fields in the line")
+)
+
+// SkState type represents socket connection state
+type SkState uint8
+
+func (s SkState) String() string {
+	return skStates[s]
+}
+
+// QueueStats aggregates a socket queue over a set of sockets
+type QueueStats struct {
+	Total uint64
+	Max   uint64
+}
+
+func (q *QueueStats) add(value uint64) {
+	q.Total += value
+	q.Max = max(q.Max, value)
+}
+
+// SocketStats holds the socket count per state and the aggregated queues.
+// For listening sockets the receive queue is the accept backlog.
+type SocketStats struct {
+	States  map[string]uint64
+	RxQueue QueueStats
+	TxQueue QueueStats
+	// Ports holds the socket count per state of the requested local ports,
+	// nil when none is requested
+	Ports map[uint16]map[string]uint64
+}
+
+func newSocketStats(ports []uint16) SocketStats {
+	stats := SocketStats{States: make(map[string]uint64)}
+	if len(ports) > 0 {
+		stats.Ports = make(map[uint16]map[string]uint64, len(ports))
+		for _, port := range ports {
+			stats.Ports[port] = make(map[string]uint64)
+		}
+	}
+	return stats
+}
+
+// addPort counts the socket in its local port when requested
+func (s *SocketStats) addPort(port uint16, state string) {
+	if states, ok := s.Ports[port]; ok {
+		states[state]++
+	}
+}
+
+// ParseSockTab counts the sockets of a /proc/net/{tcp,udp,icmp,raw}[6] table
+// per state, with their aggregated queues, and per state of the given local
+// ports.
+// Very very very very VERY inspired for the marvelous work of cakturk
+func ParseSockTab(r io.Reader, ports ...uint16) (SocketStats, error) {
+	br := bufio.NewScanner(r)
+	stats := newSocketStats(ports)
+
+	// Discard title
+	br.Scan()
+
+	for br.Scan() {
+		line := br.Text()
+		// Skip comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 12 {
+			return SocketStats{}, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+
+		u, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return SocketStats{}, err
+		}
+		txQueue, rxQueue, found := strings.Cut(fields[4], ":")
+		if !found {
+			return SocketStats{}, fmt.Errorf("netstat: malformed queues: %v", fields[4])
+		}
+		tx, err := strconv.ParseUint(txQueue, 16, 32)
+		if err != nil {
+			return SocketStats{}, err
+		}
+		rx, err := strconv.ParseUint(rxQueue, 16, 32)
+		if err != nil {
+			return SocketStats{}, err
+		}
+
+		state := SkState(u).String()
+		stats.States[state]++
+		stats.TxQueue.add(tx)
+		stats.RxQueue.add(rx)
+		if stats.Ports != nil {
+			_, localPort, found := strings.Cut(fields[1], ":")
+			if !found {
+				return SocketStats{}, fmt.Errorf("netstat: malformed local address: %v", fields[1])
+			}
+			port, err := strconv.ParseUint(localPort, 16, 16)
+			if err != nil {
+				return SocketStats{}, err
+			}
+			stats.addPort(uint16(port), state)
+		}
+	}
+	return stats, br.Err()
+}
+
+func parseSockTabFile(filename string, ports []uint16) (SocketStats, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return SocketStats{}, err
+	}
+	defer file.Close()
+	return ParseSockTab(file, ports...)
+}
+
+// TCPStats counts the TCP sockets of /proc/net/tcp per state
+func TCPStats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathTCPTab), ports)
+}
+
+// TCP6Stats counts the TCP IPv6 sockets of /proc/net/tcp6 per state
+func TCP6Stats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathTCP6Tab), ports)
+}
+
+// UDPStats counts the UDP sockets of /proc/net/udp per state
+func UDPStats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathUDPTab), ports)
+}
+
+// UDP6Stats counts the UDP IPv6 sockets of /proc/net/udp6 per state
+func UDP6Stats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathUDP6Tab), ports)
+}
+
+// ICMPStats counts the ICMP sockets of /proc/net/icmp per state
+func ICMPStats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathICMPTab), ports)
+}
+
+// ICMP6Stats counts the ICMP IPv6 sockets of /proc/net/icmp6 per state
+func ICMP6Stats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathICMP6Tab), ports)
+}
+
+// UDPLiteStats counts the UDPLite sockets of /proc/net/udplite per state
+func UDPLiteStats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathUDPLiteTab), ports)
+}
+
+// UDPLite6Stats counts the UDPLite IPv6 sockets of /proc/net/udplite6 per state
+func UDPLite6Stats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathUDPLite6Tab), ports)
+}
+
+// RAWStats counts the RAW sockets of /proc/net/raw per state
+func RAWStats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathRAWTab), ports)
+}
+
+// RAW6Stats counts the RAW IPv6 sockets of /proc/net/raw6 per state
+func RAW6Stats(procRoot string, ports ...uint16) (SocketStats, error) {
+	return parseSockTabFile(procnet_fields.ProcPath(procRoot, pathRAW6Tab), ports)
+}
+
+// DiagStats counts the sockets per state through INET_DIAG (sock_diag netlink)
+// in the current network namespace, and per state of the given local ports.
+// Only the socket headers are requested, which is way cheaper than formatting
+// and parsing the /proc tables.
+func DiagStats(family, protocol uint8, ports ...uint16) (SocketStats, error) {
+	var counts [len(skStates)]uint64
+	stats := newSocketStats(ports)
+	err := sockdiag.Dump(
+		sockdiag.Request{Family: family, Protocol: protocol, States: sockdiag.AllStates},
+		func(s *sockdiag.Socket) error {
+			if int(s.State) < len(counts) {
+				counts[s.State]++
+				if stats.Ports != nil {
+					stats.addPort(s.SrcPort, SkState(s.State).String())
+				}
+			}
+			stats.RxQueue.add(uint64(s.RQueue))
+			// The write queue of a listening socket is its max backlog,
+			// /proc/net/tcp reports nothing there
+			if SkState(s.State) != Listen {
+				stats.TxQueue.add(uint64(s.WQueue))
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return SocketStats{}, err
+	}
+	for state, count := range counts {
+		if count > 0 {
+			stats.States[SkState(state).String()] = count
+		}
+	}
+	return stats, nil
+}
+
+// TCPInfoStats aggregates the tcp_info of established TCP sockets
+type TCPInfoStats struct {
+	Connections uint64
+	// Sockets currently retransmitting (unacknowledged RTO retransmits)
+	Retransmitting uint64
+	// Sum over the sockets of their retransmitted segments
+	TotalRetrans uint64
+	// Connections per congestion avoidance state, indexed by the kernel
+	// TCP_CA_* values (Open, Disorder, CWR, Recovery, Loss)
+	CAStates [sockdiag.CALoss + 1]uint64
+	// Smoothed RTT histogram in seconds, cumulative counts per upper bound
+	RTTSum     float64
+	RTTBuckets map[float64]uint64
+}
+
+func (s *TCPInfoStats) add(info sockdiag.TCPInfo, rttBounds []float64) {
+	s.Connections++
+	if info.Retransmits > 0 {
+		s.Retransmitting++
+	}
+	s.TotalRetrans += uint64(info.TotalRetrans)
+	if int(info.CAState) < len(s.CAStates) {
+		s.CAStates[info.CAState]++
+	}
+	rtt := float64(info.RTT) / 1e6
+	s.RTTSum += rtt
+	for _, bound := range rttBounds {
+		if rtt <= bound {
+			s.RTTBuckets[bound]++
+		}
+	}
+}
+
+// DiagTCPInfo dumps the tcp_info of the established TCP sockets of the family
+// in the current network namespace. rttBounds are the RTT histogram upper
+// bounds in seconds.
+func DiagTCPInfo(family uint8, rttBounds []float64) (TCPInfoStats, error) {
+	stats := TCPInfoStats{RTTBuckets: make(map[float64]uint64, len(rttBounds))}
+	for _, bound := range rttBounds {
+		stats.RTTBuckets[bound] = 0
+	}
+	err := sockdiag.Dump(
+		sockdiag.Request{
+			Family:   family,
+			Protocol: unix.IPPROTO_TCP,
+			Ext:      sockdiag.ExtInfo,
+			States:   1 << Established,
+		},
+		func(s *sockdiag.Socket) error {
+			payload, found := s.Attrs[sockdiag.AttrInfo]
+			if !found {
+				return nil
+			}
+			info, err := sockdiag.ParseTCPInfo(payload)
+			if err != nil {
+				return err
+			}
+			stats.add(info, rttBounds)
+			return nil
+		},
+	)
+	if err != nil {
+		return TCPInfoStats{}, err
+	}
+	return stats, nil
+}
+
+// SkMemStats aggregates the memory counters of the sockets of a protocol
+type SkMemStats struct {
+	Sockets   uint64
+	RmemAlloc uint64
+	WmemAlloc uint64
+	Backlog   uint64
+	Drops     uint64
+	// Highest receive buffer usage (rmem_alloc / rcvbuf) among the sockets
+	MaxRcvBufUsage float64
+}
+
+func (s *SkMemStats) add(info sockdiag.SkMemInfo) {
+	s.Sockets++
+	s.RmemAlloc += uint64(info.RmemAlloc)
+	s.WmemAlloc += uint64(info.WmemAlloc)
+	s.Backlog += uint64(info.Backlog)
+	s.Drops += uint64(info.Drops)
+	if info.RcvBuf > 0 {
+		s.MaxRcvBufUsage = max(s.MaxRcvBufUsage, float64(info.RmemAlloc)/float64(info.RcvBuf))
+	}
+}
+
+// DiagSkMem dumps the memory counters of the sockets of the family and
+// protocol in the current network namespace
+func DiagSkMem(family, protocol uint8) (SkMemStats, error) {
+	var stats SkMemStats
+	err := sockdiag.Dump(
+		sockdiag.Request{
+			Family:   family,
+			Protocol: protocol,
+			Ext:      sockdiag.ExtSkMemInfo,
+			States:   sockdiag.AllStates,
+		},
+		func(s *sockdiag.Socket) error {
+			payload, found := s.Attrs[sockdiag.AttrSkMemInfo]
+			if !found {
+				return nil
+			}
+			info, err := sockdiag.ParseSkMemInfo(payload)
+			if err != nil {
+				return err
+			}
+			stats.add(info)
+			return nil
+		},
+	)
+	if err != nil {
+		return SkMemStats{}, err
+	}
+	return stats, nil
+}
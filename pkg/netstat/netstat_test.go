@@ -0,0 +1,126 @@
+package netstat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cosanet/cosanet/internal/sockdiag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSocktab_NotEnoughFields(t *testing.T) {
+	_, err := ParseSockTab(strings.NewReader("header\n 0: 00000000:1F90 00000000:0000 0A\n"))
+	assert.Error(t, err)
+}
+
+func TestParseSocktab_MalformedQueues(t *testing.T) {
+	_, err := ParseSockTab(strings.NewReader("header\n 0: 00000000:1F90 00000000:0000 0A 00000000 00:00000000 00000000 0 0 1 1 0 100\n"))
+	assert.Error(t, err)
+}
+
+func TestStats_ProcRoot(t *testing.T) {
+	stats, err := TCPStats("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"LISTEN": 2, "ESTABLISHED": 1, "TIME_WAIT": 1}, stats.States)
+	assert.Equal(t, QueueStats{Total: 0x300, Max: 0x200}, stats.RxQueue)
+	assert.Equal(t, QueueStats{Total: 0x10, Max: 0x10}, stats.TxQueue)
+
+	stats, err = UDP6Stats("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"CLOSE": 1}, stats.States)
+
+	_, err = RAWStats("testdata")
+	assert.Error(t, err)
+}
+
+func TestStats_Ports(t *testing.T) {
+	stats, err := TCPStats("testdata", 8080, 5432)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint16]map[string]uint64{
+		8080: {"LISTEN": 1, "ESTABLISHED": 1, "TIME_WAIT": 1},
+		5432: {},
+	}, stats.Ports)
+
+	stats, err = TCPStats("testdata")
+	require.NoError(t, err)
+	assert.Nil(t, stats.Ports)
+
+	_, err = ParseSockTab(strings.NewReader("header\n 0: 00000000:ZZZZ 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 1 1 0 100\n"), 8080)
+	assert.Error(t, err)
+}
+
+func TestTCPInfoStats_Add(t *testing.T) {
+	bounds := []float64{0.001, 0.01, 0.1}
+	stats := TCPInfoStats{RTTBuckets: map[float64]uint64{0.001: 0, 0.01: 0, 0.1: 0}}
+	stats.add(sockdiag.TCPInfo{RTT: 500, TotalRetrans: 1}, bounds)
+	stats.add(sockdiag.TCPInfo{RTT: 20000, Retransmits: 1, TotalRetrans: 3, CAState: sockdiag.CALoss}, bounds)
+	stats.add(sockdiag.TCPInfo{RTT: 250000, CAState: sockdiag.CARecovery}, bounds)
+
+	assert.Equal(t, uint64(3), stats.Connections)
+	assert.Equal(t, uint64(1), stats.Retransmitting)
+	assert.Equal(t, uint64(4), stats.TotalRetrans)
+	assert.Equal(t, [5]uint64{1, 0, 0, 1, 1}, stats.CAStates)
+	assert.InDelta(t, 0.2705, stats.RTTSum, 1e-9)
+	assert.Equal(t, map[float64]uint64{0.001: 1, 0.01: 1, 0.1: 2}, stats.RTTBuckets)
+}
+
+func TestSkMemStats_Add(t *testing.T) {
+	var stats SkMemStats
+	stats.add(sockdiag.SkMemInfo{RmemAlloc: 1000, RcvBuf: 4000, WmemAlloc: 10, Drops: 2})
+	stats.add(sockdiag.SkMemInfo{RmemAlloc: 3000, RcvBuf: 4000, Backlog: 64})
+	stats.add(sockdiag.SkMemInfo{RmemAlloc: 10})
+
+	assert.Equal(t, SkMemStats{
+		Sockets:        3,
+		RmemAlloc:      4010,
+		WmemAlloc:      10,
+		Backlog:        64,
+		Drops:          2,
+		MaxRcvBufUsage: 0.75,
+	}, stats)
+}
+
+func TestUnixStatsFromProcfs(t *testing.T) {
+	stats, err := UnixStatsFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, UnixStats{
+		"stream":    {"LISTEN": 1, "CONNECTED": 2},
+		"dgram":     {"UNCONNECTED": 1},
+		"seqpacket": {"CONNECTED": 1},
+	}, stats)
+
+	_, err = ParseUnixTab(strings.NewReader("header\n00000000bb1a501c: 00000002 00000000\n"))
+	assert.Error(t, err)
+}
+
+func TestNetlinkStatsFromProcfs(t *testing.T) {
+	stats, err := NetlinkStatsFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"route": 2, "sock_diag": 1, "generic": 1, "17": 1}, stats)
+
+	_, err = ParseNetlinkTab(strings.NewReader("header\n0000000082bbb5b3 0 0\n"))
+	assert.Error(t, err)
+}
+
+func TestPacketSocketsFromProcfs(t *testing.T) {
+	sockets, err := PacketSocketsFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, []PacketSocket{
+		{Type: "raw", Protocol: "all", Ifindex: 0, Inode: 21403},
+		{Type: "dgram", Protocol: "ip", Ifindex: 2, Inode: 21877},
+		{Type: "raw", Protocol: "lldp", Ifindex: 3, Inode: 22011},
+	}, sockets)
+
+	assert.Equal(t, "0x88b5", packetProtocolName(0x88b5))
+}
+
+func TestIGMPGroupsFromProcfs(t *testing.T) {
+	groups, err := IGMPGroupsFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"lo": 1, "eth0": 3}, groups)
+
+	groups, err = IGMP6GroupsFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"lo": 2, "eth0": 3}, groups)
+}
@@ -0,0 +1,104 @@
+package netstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+const pathPacketTab = "net/packet"
+
+var packetTypes = map[uint64]string{
+	2:  "dgram",
+	3:  "raw",
+	10: "packet",
+}
+
+// Common ethernet protocols, see include/uapi/linux/if_ether.h
+var packetProtocols = map[uint64]string{
+	0x0000: "none",
+	0x0003: "all",
+	0x0800: "ip",
+	0x0806: "arp",
+	0x86dd: "ipv6",
+	0x8100: "8021q",
+	0x888e: "pae",
+	0x88cc: "lldp",
+}
+
+// PacketSocket is an AF_PACKET socket of /proc/net/packet
+type PacketSocket struct {
+	Type     string
+	Protocol string
+	// Ifindex is the index of the bound interface, 0 when bound to all of them
+	Ifindex int
+	Inode   uint64
+}
+
+func packetTypeName(t uint64) string {
+	if name, found := packetTypes[t]; found {
+		return name
+	}
+	return strconv.FormatUint(t, 10)
+}
+
+func packetProtocolName(proto uint64) string {
+	if name, found := packetProtocols[proto]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", proto)
+}
+
+// ParsePacketTab lists the AF_PACKET sockets of /proc/net/packet
+func ParsePacketTab(r io.Reader) ([]PacketSocket, error) {
+	br := bufio.NewScanner(r)
+	var sockets []PacketSocket
+
+	// Discard title
+	br.Scan()
+
+	for br.Scan() {
+		fields := strings.Fields(br.Text())
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+		sockType, err := strconv.ParseUint(fields[2], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		proto, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil {
+			return nil, err
+		}
+		ifindex, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, err
+		}
+		inode, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, PacketSocket{
+			Type:     packetTypeName(sockType),
+			Protocol: packetProtocolName(proto),
+			Ifindex:  ifindex,
+			Inode:    inode,
+		})
+	}
+	return sockets, br.Err()
+}
+
+// PacketSocketsFromProcfs lists the AF_PACKET sockets of /proc/net/packet
+func PacketSocketsFromProcfs(procRoot string) ([]PacketSocket, error) {
+	file, err := os.Open(procnet_fields.ProcPath(procRoot, pathPacketTab))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParsePacketTab(file)
+}
@@ -0,0 +1,97 @@
+package netstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+const pathUnixTab = "net/unix"
+
+// __SO_ACCEPTCON, set in the flags of listening sockets
+const unixFlagAcceptCon = 1 << 16
+
+var unixTypes = map[uint64]string{
+	1: "stream",
+	2: "dgram",
+	5: "seqpacket",
+}
+
+// Socket states, see socket_state in the kernel
+var unixStates = [...]string{
+	"FREE",
+	"UNCONNECTED",
+	"CONNECTING",
+	"CONNECTED",
+	"DISCONNECTING",
+}
+
+// UnixStats holds the number of unix sockets per type then state
+type UnixStats map[string]map[string]int
+
+func unixTypeName(t uint64) string {
+	if name, found := unixTypes[t]; found {
+		return name
+	}
+	return strconv.FormatUint(t, 10)
+}
+
+func unixStateName(state, flags uint64) string {
+	if flags&unixFlagAcceptCon != 0 {
+		return "LISTEN"
+	}
+	if state < uint64(len(unixStates)) {
+		return unixStates[state]
+	}
+	return "UNKNOWN"
+}
+
+// ParseUnixTab counts the sockets of /proc/net/unix per type and state
+func ParseUnixTab(r io.Reader) (UnixStats, error) {
+	br := bufio.NewScanner(r)
+	stats := make(UnixStats)
+
+	// Discard title
+	br.Scan()
+
+	for br.Scan() {
+		fields := strings.Fields(br.Text())
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("netstat: not enough fields: %v, %v", len(fields), fields)
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		sockType, err := strconv.ParseUint(fields[4], 16, 16)
+		if err != nil {
+			return nil, err
+		}
+		state, err := strconv.ParseUint(fields[5], 16, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		typeName := unixTypeName(sockType)
+		if stats[typeName] == nil {
+			stats[typeName] = make(map[string]int)
+		}
+		stats[typeName][unixStateName(state, flags)]++
+	}
+	return stats, br.Err()
+}
+
+// UnixStatsFromProcfs counts the unix sockets of /proc/net/unix per type and state
+func UnixStatsFromProcfs(procRoot string) (UnixStats, error) {
+	file, err := os.Open(procnet_fields.ProcPath(procRoot, pathUnixTab))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseUnixTab(file)
+}
@@ -0,0 +1,172 @@
+// Package procnet_2l_parser parses the /proc/net files made of header and
+// value line pairs per section, such as /proc/net/snmp and /proc/net/netstat.
+package procnet_2l_parser
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+// Parser parses the files made of header and value line pairs
+// (/proc/net/snmp, /proc/net/netstat). It is meant to be kept between the
+// pods: the file buffer, the section layouts and the result maps are reused,
+// the returned map being only valid until the next parse. A Parser isn't
+// safe for concurrent use.
+type Parser struct {
+	// Tells if a counter is parsed, nil for all of them
+	include func(section, field string) bool
+	data    bytes.Buffer
+	header  [][]byte
+	values  [][]byte
+	// Sections by name, every netns sharing the same kernel layout
+	sections map[string]*sectionLayout
+	result   map[string]map[string]uint64
+}
+
+// sectionLayout is a parsed header line
+type sectionLayout struct {
+	header []byte
+	// First field of the lines (eg: TcpExt:)
+	prefix []byte
+	name   string
+	// Counter names in the header order, empty when not included
+	fields []string
+	// Tells if a counter of the section is included, the value line of the
+	// others being skipped
+	included bool
+	counters map[string]uint64
+}
+
+// NewParser returns a parser converting the counters matched by include, or
+// all of them when nil. The include function is called once per counter of a
+// section layout, not for every parse.
+func NewParser(include func(section, field string) bool) *Parser {
+	return &Parser{
+		include:  include,
+		sections: make(map[string]*sectionLayout),
+		result:   make(map[string]map[string]uint64),
+	}
+}
+
+// layout returns the layout of the header line, parsing it only when the
+// section was never seen or has changed. It is nil for a malformed header.
+func (p *Parser) layout(headerLine []byte) *sectionLayout {
+	prefix, _ := procnet_fields.Cut(headerLine)
+	name := bytes.TrimSuffix(prefix, []byte(":"))
+	if layout, ok := p.sections[string(name)]; ok && bytes.Equal(layout.header, headerLine) {
+		return layout
+	}
+	p.header = procnet_fields.Split(p.header[:0], headerLine)
+	if len(p.header) == 0 {
+		return nil
+	}
+	layout := &sectionLayout{
+		header:   bytes.Clone(headerLine),
+		prefix:   bytes.Clone(prefix),
+		name:     string(name),
+		fields:   make([]string, len(p.header)-1),
+		counters: make(map[string]uint64, len(p.header)-1),
+	}
+	for i, field := range p.header[1:] {
+		if p.include == nil || p.include(layout.name, string(field)) {
+			layout.fields[i] = string(field)
+			layout.included = true
+		}
+	}
+	p.sections[layout.name] = layout
+	return layout
+}
+
+// parseSection parses a pair of lines: a header line and a value line, false
+// when they don't belong to the same section. Negative values (Tcp MaxConn's
+// -1 for no limit) aren't counters and are skipped, as well as the value
+// lines of the sections without any included counter.
+func (p *Parser) parseSection(headerLine, valueLine []byte) bool {
+	layout := p.layout(headerLine)
+	if layout == nil {
+		return false
+	}
+	if valuePrefix, _ := procnet_fields.Cut(valueLine); !bytes.Equal(layout.prefix, valuePrefix) {
+		return false
+	}
+	if !layout.included {
+		return true
+	}
+
+	p.values = procnet_fields.Split(p.values[:0], valueLine)
+	for i := 1; i <= len(layout.fields) && i < len(p.values); i++ {
+		field := layout.fields[i-1]
+		if field == "" {
+			continue
+		}
+		val, ok := procnet_fields.ParseUint(p.values[i])
+		if !ok {
+			// skip invalid values but continue parsing others
+			continue
+		}
+		layout.counters[field] = val
+	}
+	if len(layout.counters) > 0 {
+		p.result[layout.name] = layout.counters
+	}
+	return true
+}
+
+// Parse parses the contents of a two lines file. It returns a nested map:
+// section → field → uint64, without the sections having no counter.
+func (p *Parser) Parse(r io.Reader) (map[string]map[string]uint64, error) {
+	for _, layout := range p.sections {
+		clear(layout.counters)
+	}
+	clear(p.result)
+
+	p.data.Reset()
+	if _, err := p.data.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	data := p.data.Bytes()
+	for len(data) > 0 {
+		var headerLine, valueLine []byte
+		headerLine, data = procnet_fields.NextLine(data)
+		if len(data) == 0 {
+			break // no matching value line for header
+		}
+		valueLine, data = procnet_fields.NextLine(data)
+		// skip malformed section but keep parsing
+		p.parseSection(headerLine, valueLine)
+	}
+	return p.result, nil
+}
+
+// ParseFile opens the file and passes it to the parser.
+func (p *Parser) ParseFile(filename string) (map[string]map[string]uint64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return p.Parse(file)
+}
+
+// Parse2LFile parses the file with a parser of its own, the returned map
+// being owned by the caller.
+// Callers parsing repeatedly or only needing a few counters should keep a
+// Parser with an include function instead.
+func Parse2LFile(filename string) (map[string]map[string]uint64, error) {
+	return NewParser(nil).ParseFile(filename)
+}
+
+// SnmpFromProcfs parses /proc/net/snmp under procRoot, the procfs mount
+// point ("/proc" when empty).
+func SnmpFromProcfs(procRoot string) (map[string]map[string]uint64, error) {
+	return Parse2LFile(procnet_fields.ProcPath(procRoot, "net/snmp"))
+}
+
+// NetstatFromProcfs parses /proc/net/netstat under procRoot, the procfs mount
+// point ("/proc" when empty).
+func NetstatFromProcfs(procRoot string) (map[string]map[string]uint64, error) {
+	return Parse2LFile(procnet_fields.ProcPath(procRoot, "net/netstat"))
+}
@@ -0,0 +1,200 @@
+package procnet_2l_parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseSectionCouple parses a single header and value line pair
+func parseSectionCouple(headerLine, valueLine string) (bool, map[string]uint64) {
+	p := NewParser(nil)
+	ok := p.parseSection([]byte(headerLine), []byte(valueLine))
+	return ok, p.result[strings.TrimSuffix(strings.Fields(headerLine)[0], ":")]
+}
+
+func TestParseSectionCouple_Valid(t *testing.T) {
+	ok, counters := parseSectionCouple("TcpExt: SyncookiesSent SyncookiesRecv", "TcpExt: 10 20")
+	require.True(t, ok)
+	assert.Equal(t, map[string]uint64{"SyncookiesSent": 10, "SyncookiesRecv": 20}, counters)
+}
+
+func TestParseSectionCouple_Malformed(t *testing.T) {
+	ok, _ := parseSectionCouple("TcpExt: SyncookiesSent SyncookiesRecv", "Other: 10 20")
+	assert.False(t, ok)
+}
+
+func TestParseSectionCouple_InvalidValue(t *testing.T) {
+	ok, counters := parseSectionCouple("TcpExt: SyncookiesSent SyncookiesRecv", "TcpExt: 10 notanint")
+	require.True(t, ok)
+	assert.Equal(t, map[string]uint64{"SyncookiesSent": 10}, counters)
+}
+
+func TestParseSectionCouple_FullWidth(t *testing.T) {
+	ok, counters := parseSectionCouple("IpExt: InOctets OutOctets", "IpExt: 18446744073709551615 4294967296")
+	require.True(t, ok)
+	assert.Equal(t, map[string]uint64{"InOctets": 18446744073709551615, "OutOctets": 4294967296}, counters)
+
+	// Tcp MaxConn is -1, not a counter
+	ok, counters = parseSectionCouple("Tcp: RtoMax MaxConn", "Tcp: 120000 -1")
+	require.True(t, ok)
+	assert.Equal(t, map[string]uint64{"RtoMax": 120000}, counters)
+}
+
+func TestParse_Valid(t *testing.T) {
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets OutOctets\nIpExt: 100 200"
+	result, err := NewParser(nil).Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"TcpExt": {"SyncookiesSent": 10, "SyncookiesRecv": 20},
+		"IpExt":  {"InOctets": 100, "OutOctets": 200},
+	}, result)
+}
+
+func TestParse_MalformedSectionSkipped(t *testing.T) {
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nOther: 10 20\nIpExt: InOctets OutOctets\nIpExt: 100 200\n"
+	result, err := NewParser(nil).Parse(strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"IpExt": {"InOctets": 100, "OutOctets": 200},
+	}, result)
+}
+
+func TestParse_ReaderError(t *testing.T) {
+	_, err := NewParser(nil).Parse(iotest.ErrReader(errors.New("read failed")))
+	assert.Error(t, err)
+}
+
+func TestParse_OddNumberOfLines(t *testing.T) {
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets OutOctets\n"
+	result, err := NewParser(nil).Parse(strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"TcpExt": {"SyncookiesSent": 10, "SyncookiesRecv": 20},
+	}, result)
+}
+
+func TestParse_Empty(t *testing.T) {
+	result, err := NewParser(nil).Parse(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestParse_Include(t *testing.T) {
+	p := NewParser(func(section, field string) bool {
+		return section == "TcpExt" && field == "SyncookiesSent"
+	})
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets OutOctets\nIpExt: 100 200\n"
+	result, err := p.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{"TcpExt": {"SyncookiesSent": 10}}, result)
+}
+
+func TestParse_ExcludedSectionSkipped(t *testing.T) {
+	p := NewParser(func(section, field string) bool { return section == "IpExt" })
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets OutOctets\nIpExt: 100 200\n"
+	_, err := p.Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.False(t, p.sections["TcpExt"].included)
+	assert.True(t, p.sections["IpExt"].included)
+
+	// The excluded values aren't even split, still the sections must match
+	result, err := p.Parse(strings.NewReader("TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: x y z\nIpExt: InOctets OutOctets\nTcpExt: 100 200\n"))
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestParse_Reused(t *testing.T) {
+	p := NewParser(nil)
+	_, err := p.Parse(strings.NewReader("TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets\nIpExt: 100\n"))
+	require.NoError(t, err)
+
+	// The values and the missing sections of the previous file don't leak
+	result, err := p.Parse(strings.NewReader("TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 30 notanint\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{"TcpExt": {"SyncookiesSent": 30}}, result)
+
+	// A new kernel layout of the section is parsed again
+	result, err = p.Parse(strings.NewReader("TcpExt: SyncookiesRecv\nTcpExt: 40\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{"TcpExt": {"SyncookiesRecv": 40}}, result)
+}
+
+func TestParse_NoAllocations(t *testing.T) {
+	data := "TcpExt: SyncookiesSent SyncookiesRecv\nTcpExt: 10 20\nIpExt: InOctets OutOctets\nIpExt: 100 200\n"
+	p := NewParser(nil)
+	reader := strings.NewReader(data)
+	_, err := p.Parse(reader)
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset(data)
+		_, _ = p.Parse(reader)
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkParse(b *testing.B) {
+	header := "TcpExt: SyncookiesSent SyncookiesRecv SyncookiesFailed EmbryonicRsts PruneCalled RcvPruned OfoPruned OutOfWindowIcmps LockDroppedIcmps ArpFilter TW TWRecycled TWKilled PAWSActive PAWSEstab DelayedACKs DelayedACKLocked DelayedACKLost ListenOverflows ListenDrops"
+	values := "TcpExt: 0 0 0 1 0 0 0 0 0 0 1234 0 0 0 0 5678 12 34 0 0"
+	data := strings.Repeat(header+"\n"+values+"\n", 4)
+	p := NewParser(nil)
+	reader := strings.NewReader(data)
+	b.ReportAllocs()
+	for b.Loop() {
+		reader.Reset(data)
+		if _, err := p.Parse(reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse_Filtered(b *testing.B) {
+	var data strings.Builder
+	for _, section := range []string{"Ip", "Icmp", "IcmpMsg", "Tcp", "Udp", "UdpLite", "TcpExt", "IpExt", "MPTcpExt"} {
+		data.WriteString(section + ":")
+		for i := range 40 {
+			data.WriteString(fmt.Sprintf(" Counter%d", i))
+		}
+		data.WriteString("\n" + section + ":")
+		for i := range 40 {
+			data.WriteString(fmt.Sprintf(" %d", i*1000))
+		}
+		data.WriteString("\n")
+	}
+	// As the default snmp filter, a few counters of a single section
+	p := NewParser(func(section, field string) bool {
+		return section == "Tcp" && (field == "Counter5" || field == "Counter6" || field == "Counter9")
+	})
+	reader := strings.NewReader(data.String())
+	b.ReportAllocs()
+	for b.Loop() {
+		reader.Reset(data.String())
+		if _, err := p.Parse(reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFromProcfs(t *testing.T) {
+	snmp, err := SnmpFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"Ip":  {"Forwarding": 1, "DefaultTTL": 64, "InReceives": 1234},
+		"Tcp": {"RtoAlgorithm": 1, "ActiveOpens": 17},
+	}, snmp)
+
+	netstat, err := NetstatFromProcfs("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]uint64{
+		"TcpExt": {"SyncookiesSent": 0, "ListenDrops": 3},
+		"IpExt":  {"InOctets": 4096, "OutOctets": 2048},
+	}, netstat)
+
+	_, err = SnmpFromProcfs("missing")
+	assert.Error(t, err)
+}
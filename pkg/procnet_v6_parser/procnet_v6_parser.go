@@ -0,0 +1,149 @@
+// Package procnet_v6_parser parses /proc/net/snmp6, made of one "key value"
+// line per counter, the keys being split into their section and counter name.
+package procnet_v6_parser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cosanet/cosanet/internal/procnet_fields"
+)
+
+// snmp6Prefixes are the sections of the /proc/net/snmp6 counters, as
+// prefixed by the kernel to the counter names
+var snmp6Prefixes = []string{"Ip6", "Icmp6", "Udp6", "UdpLite6", "Tcp6"}
+
+// splitSnmp6Key splits a counter key (eg: Icmp6InMsgs) into its section and
+// counter name along the longest known prefix. Keys of unknown sections are
+// returned intact, with an empty section.
+func splitSnmp6Key(key string) (string, string) {
+	section := ""
+	for _, prefix := range snmp6Prefixes {
+		if len(prefix) > len(section) && len(key) > len(prefix) && strings.HasPrefix(key, prefix) {
+			section = prefix
+		}
+	}
+	return section, key[len(section):]
+}
+
+// snmp6Key is a split counter key
+type snmp6Key struct {
+	section  string
+	counter  string
+	included bool
+}
+
+// Parser parses the "key value" lines of /proc/net/snmp6. It is meant to be
+// kept between the pods: the file buffer, the split keys and the result maps
+// are reused, the returned map being only valid until the next parse. A
+// Parser isn't safe for concurrent use.
+type Parser struct {
+	// Tells if a counter is parsed, nil for all of them
+	include func(section, counter string) bool
+	data    bytes.Buffer
+	fields  [][]byte
+	keys    map[string]snmp6Key
+	// Counters per section, kept from a parse to the other
+	sections map[string]map[string]uint64
+	result   map[string]map[string]uint64
+}
+
+// NewParser returns a parser converting the counters matched by include, or
+// all of them when nil. The include function is called once per key, not for
+// every parse.
+func NewParser(include func(section, counter string) bool) *Parser {
+	return &Parser{
+		include:  include,
+		keys:     make(map[string]snmp6Key),
+		sections: make(map[string]map[string]uint64),
+		result:   make(map[string]map[string]uint64),
+	}
+}
+
+// key returns the split key, splitting it only the first time it is seen
+func (p *Parser) key(raw []byte) snmp6Key {
+	if key, ok := p.keys[string(raw)]; ok {
+		return key
+	}
+	var key snmp6Key
+	key.section, key.counter = splitSnmp6Key(string(raw))
+	key.included = p.include == nil || p.include(key.section, key.counter)
+	p.keys[string(raw)] = key
+	return key
+}
+
+// parseSnmp6Line parses a single "key value" line from /proc/net/snmp6,
+// false when it is malformed. The value of an excluded key is never looked
+// at.
+func (p *Parser) parseSnmp6Line(line []byte) bool {
+	raw, rest := procnet_fields.Cut(line)
+	if len(raw) == 0 {
+		return false
+	}
+	key := p.key(raw)
+	if !key.included {
+		return true
+	}
+	p.fields = procnet_fields.Split(p.fields[:0], rest)
+	if len(p.fields) != 1 {
+		return false
+	}
+	val, ok := procnet_fields.ParseUint(p.fields[0])
+	if !ok {
+		return false
+	}
+	counters, ok := p.sections[key.section]
+	if !ok {
+		counters = make(map[string]uint64)
+		p.sections[key.section] = counters
+	}
+	counters[key.counter] = val
+	p.result[key.section] = counters
+	return true
+}
+
+// Parse parses /proc/net/snmp6 contents. It returns a nested map: section →
+// field → uint64.
+func (p *Parser) Parse(r io.Reader) (map[string]map[string]uint64, error) {
+	for _, counters := range p.sections {
+		clear(counters)
+	}
+	clear(p.result)
+
+	p.data.Reset()
+	if _, err := p.data.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	for data := p.data.Bytes(); len(data) > 0; {
+		var line []byte
+		line, data = procnet_fields.NextLine(data)
+		p.parseSnmp6Line(line) // skip malformed lines
+	}
+	return p.result, nil
+}
+
+// ParseFile opens the file and passes it to the parser.
+func (p *Parser) ParseFile(filename string) (map[string]map[string]uint64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return p.Parse(file)
+}
+
+// ParseV6File parses the file with a parser of its own, the returned map
+// being owned by the caller.
+// Callers parsing repeatedly or only needing a few counters should keep a
+// Parser with an include function instead.
+func ParseV6File(filename string) (map[string]map[string]uint64, error) {
+	return NewParser(nil).ParseFile(filename)
+}
+
+// Snmp6FromProcfs parses /proc/net/snmp6 under procRoot, the procfs mount
+// point ("/proc" when empty).
+func Snmp6FromProcfs(procRoot string) (map[string]map[string]uint64, error) {
+	return ParseV6File(procnet_fields.ProcPath(procRoot, "net/snmp6"))
+}
@@ -0,0 +1,115 @@
+package procnet_v6_parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSnmp6Line(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantSection string
+		wantCounter string
+		wantValue   uint64
+		wantErr     bool
+	}{
+		{"Icmp6InMsgs     42", "Icmp6", "InMsgs", 42, false},
+		{"Tcp6ActiveOpens 123", "Tcp6", "ActiveOpens", 123, false},
+		{"Udp6InDatagrams 999", "Udp6", "InDatagrams", 999, false},
+		{"Ip6InOctets     9223372036854775808", "Ip6", "InOctets", 9223372036854775808, false},
+		{"UdpLite6InErrors 3", "UdpLite6", "InErrors", 3, false},
+		{"Icmp6OutType136 7", "Icmp6", "OutType136", 7, false},
+		{"Ip6InTruncated6Pkts 5", "Ip6", "InTruncated6Pkts", 5, false},
+		// Unknown sections are passed through intact
+		{"No6Counter      1", "", "No6Counter", 1, false},
+		{"NoSixCounter      1", "", "NoSixCounter", 1, false},
+		{"Ip6 1", "", "Ip6", 1, false},
+		{"MalformedLine", "", "", 0, true},
+		{"MalformedLine6Thing", "", "", 0, true},
+		{"Section6Counter notanint", "", "", 0, true},
+	}
+	p := NewParser(nil)
+	for _, tt := range tests {
+		ok := p.parseSnmp6Line([]byte(tt.line))
+		if ok == tt.wantErr {
+			t.Errorf("parseSnmp6Line(%q) ok = %v, wantErr %v", tt.line, ok, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if val := p.result[tt.wantSection][tt.wantCounter]; val != tt.wantValue {
+			t.Errorf("parseSnmp6Line(%q) %q/%q = %d, want %d", tt.line, tt.wantSection, tt.wantCounter, val, tt.wantValue)
+		}
+	}
+}
+
+func TestParseSnmp6(t *testing.T) {
+	input := `Icmp6InMsgs 42\nTcp6ActiveOpens 123\nUdp6InDatagrams 999\nMalformedLine\nSection6Counter notanint`
+	result, err := NewParser(nil).Parse(strings.NewReader(strings.ReplaceAll(input, "\\n", "\n")))
+	if err != nil {
+		t.Fatalf("ParseSnmp6FromScanner error: %v", err)
+	}
+	if result["Icmp6"]["InMsgs"] != 42 {
+		t.Errorf("Icmp6/InMsgs = %d, want 42", result["Icmp6"]["InMsgs"])
+	}
+	if result["Tcp6"]["ActiveOpens"] != 123 {
+		t.Errorf("Tcp6/ActiveOpens = %d, want 123", result["Tcp6"]["ActiveOpens"])
+	}
+	if result["Udp6"]["InDatagrams"] != 999 {
+		t.Errorf("Udp6/InDatagrams = %d, want 999", result["Udp6"]["InDatagrams"])
+	}
+	if _, ok := result["MalformedLine"]; ok {
+		t.Errorf("MalformedLine should not be parsed")
+	}
+	if _, ok := result["Section6"]; ok {
+		if _, ok2 := result["Section6"]["Counter"]; ok2 {
+			t.Errorf("Section6/Counter should not be parsed due to value error")
+		}
+	}
+}
+
+func TestParseSnmp6Include(t *testing.T) {
+	p := NewParser(func(section, counter string) bool { return section == "Tcp6" })
+	result, err := p.Parse(strings.NewReader("Icmp6InMsgs 42\nTcp6ActiveOpens 123\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(result) != 1 || len(result["Tcp6"]) != 1 || result["Tcp6"]["ActiveOpens"] != 123 {
+		t.Errorf("Parse = %v, want only Tcp6/ActiveOpens", result)
+	}
+
+	// Reused, the previous values don't leak
+	result, err = p.Parse(strings.NewReader("Icmp6InMsgs 42\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Parse = %v, want no counter", result)
+	}
+}
+
+func BenchmarkParseSnmp6(b *testing.B) {
+	data := strings.Repeat("Ip6InReceives 1234\nIcmp6InMsgs 42\nIcmp6OutType136 7\nUdp6InDatagrams 999\nUdpLite6InErrors 0\nTcp6ActiveOpens 123\n", 8)
+	p := NewParser(nil)
+	reader := strings.NewReader(data)
+	b.ReportAllocs()
+	for b.Loop() {
+		reader.Reset(data)
+		if _, err := p.Parse(reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSnmp6FromProcfs(t *testing.T) {
+	result, err := Snmp6FromProcfs("testdata")
+	if err != nil {
+		t.Fatalf("Snmp6FromProcfs error: %v", err)
+	}
+	if result["Ip6"]["InReceives"] != 1234 || result["Icmp6"]["InMsgs"] != 42 {
+		t.Errorf("Snmp6FromProcfs = %v", result)
+	}
+	if _, ok := result["UdpLite6"]["InErrors"]; !ok {
+		t.Errorf("UdpLite6/InErrors missing from %v", result)
+	}
+}
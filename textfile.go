@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Name of the file written for the node_exporter textfile collector, which
+// reads the *.prom files of its directory
+const textfileName = "cosanet.prom"
+
+// runTextfileWriter writes the metrics of the gatherer to the textfile of the
+// directory every interval. The file is written next to it first and renamed,
+// node_exporter never reading a partial file.
+func runTextfileWriter(directory string, interval time.Duration, gatherer prometheus.Gatherer) {
+	path := filepath.Join(directory, textfileName)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		if err := prometheus.WriteToTextfile(path, gatherer); err != nil {
+			slog.Error("failed to write the textfile", slog.String("path", path), slog.Any("err", err))
+		} else {
+			slog.Debug(
+				"textfile written",
+				slog.String("path", path),
+				slog.Float64("ms", float64(time.Since(start).Microseconds())/1e3),
+			)
+		}
+		<-ticker.C
+	}
+}